@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -11,9 +12,14 @@ import (
 
 	"commander/internal/config"
 	"commander/internal/database"
+	"commander/internal/database/bbolt"
+	"commander/internal/database/cache"
 	"commander/internal/database/mongodb"
+	"commander/internal/database/tiered"
+	"commander/internal/edgesync"
 	"commander/internal/handlers"
 	"commander/internal/kv"
+	"commander/internal/models"
 	"commander/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -27,6 +33,11 @@ var (
 )
 
 func main() {
+	// Subcommands run instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:], os.Stderr))
+	}
+
 	// Load configuration
 	cfg := config.LoadConfig()
 	cfg.Version = version
@@ -37,8 +48,35 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// Edge cache replica: opened first, so an instance that already has one can start without its primary
+	var replica *bbolt.BBoltKV
+	if cfg.EdgeCache.Enabled {
+		var err error
+		replica, err = bbolt.NewBBoltKV(cfg.EdgeCache.Path)
+		if err != nil {
+			log.Fatalf("Failed to open edge cache: %v", err)
+		}
+		defer func() {
+			if closeErr := replica.Close(); closeErr != nil {
+				log.Printf("Failed to close edge cache: %v", closeErr)
+			}
+		}()
+	}
+
+	// MongoDB keeps the card records in native collections the KV mirror cannot copy: they are left out of
+	// the KV mirror and the card repository mirrors them instead
+	if cfg.KV.BackendType == config.BackendMongoDB && cfg.KV.Mirror.BackendType != "" {
+		cfg.KV.Mirror.Exclude = services.RepositoryCollections()
+	}
+
 	// Initialize KV store
+	// With a populated replica an unreachable primary is not fatal: verification starts offline
+	var primaryErr error
 	kvStore, err := database.NewKV(cfg)
+	if errors.Is(err, kv.ErrConnectionFailed) && replicaReady(replica) {
+		primaryErr = err
+		kvStore, err = database.NewKVOffline(cfg)
+	}
 	if err != nil {
 		log.Fatalf("Failed to initialize KV store: %v", err)
 	}
@@ -49,25 +87,104 @@ func main() {
 	}()
 
 	// Verify KV connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	if err := kvStore.Ping(ctx); err != nil {
+	if primaryErr == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := kvStore.Ping(ctx)
 		cancel()
-		log.Fatalf("Failed to ping KV store: %v", err) //nolint:gocritic // Intentional exit on startup failure
-	}
-	cancel()
-
-	// Initialize Card Service (only for MongoDB backend)
-	var cardService *services.CardService
-	if cfg.KV.BackendType == config.BackendMongoDB {
-		// Type assertion to get MongoDB client
-		if mongoKV, ok := kvStore.(*mongodb.MongoDBKV); ok {
-			cardService = services.NewCardService(mongoKV.GetClient())
-			log.Println("Card verification service initialized (MongoDB backend)")
-		} else {
-			log.Println("Warning: MongoDB backend expected but type assertion failed")
+		if err != nil && !replicaReady(replica) {
+			log.Fatalf("Failed to ping KV store: %v", err) //nolint:gocritic // Intentional exit on startup failure
+		}
+		primaryErr = err
+	}
+	if primaryErr != nil {
+		log.Printf("Primary backend unreachable, starting from the edge cache replica: %v", primaryErr)
+	}
+
+	if cfg.KV.Mirror.BackendType != "" {
+		log.Printf("KV mirror enabled (backend: %s, queue_size: %d, resync_interval: %s)",
+			cfg.KV.Mirror.BackendType, cfg.KV.Mirror.QueueSize, cfg.KV.Mirror.ResyncInterval)
+	}
+	if _, ok := kvStore.(*cache.Cache); ok {
+		log.Printf("KV cache enabled (max_entries: %d, max_bytes: %d, ttl: %s, negative_ttl: %s, shared_tier: %t)",
+			cfg.KV.Cache.MaxEntries, cfg.KV.Cache.MaxBytes, cfg.KV.Cache.TTL, cfg.KV.Cache.NegativeTTL, cfg.KV.Cache.RedisURI != "")
+	}
+
+	// Background sync and mirror loops stop on shutdown
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	defer stopSync()
+
+	// Initialize Card Service
+	// MongoDB keeps reading the existing devices/cards collections natively, so the KV cache and mirror cannot
+	// sit in front of them and a caching and a mirrored repository take their place; every other backend stores
+	// them as JSON values through the KV interface (and its cache and mirror)
+	var cardRepo services.Repository
+	if mongoKV, ok := kv.Unwrap(kvStore).(*mongodb.MongoDBKV); ok {
+		cardRepo = services.NewMongoRepository(mongoKV.GetClient())
+		if cfg.KV.Cache.Enabled {
+			cachingRepo, err := services.NewCachingRepository(cardRepo, services.CachingRepositoryOptions{
+				TTL:        cfg.KV.Cache.TTL,
+				MaxEntries: cfg.KV.Cache.MaxEntries,
+				RedisURI:   cfg.KV.Cache.RedisURI,
+			})
+			if err != nil {
+				log.Fatalf("Failed to initialize card repository cache: %v", err)
+			}
+			defer func() {
+				if closeErr := cachingRepo.Close(); closeErr != nil {
+					log.Printf("Failed to close card repository cache: %v", closeErr)
+				}
+			}()
+			cardRepo = cachingRepo
+		}
+		if mirrored := mirrorOf(kvStore); mirrored != nil {
+			mirroredRepo := services.NewMirroredRepository(cardRepo, mirrored.Secondary(), mirrored, cfg.KV.Mirror.ResyncInterval)
+			go mirroredRepo.Run(syncCtx)
+			cardRepo = mirroredRepo
 		}
 	} else {
-		log.Printf("Card verification service not available (backend: %s, requires MongoDB)", cfg.KV.BackendType)
+		cardRepo = services.NewKVRepository(kvStore)
+	}
+
+	// Cloud side of edge sync: record every replicated write in the namespace change feed
+	if cfg.Sync.ServerEnabled {
+		cardRepo = services.NewChangeFeed(cardRepo)
+	}
+
+	// Edge cache: verification keeps working from a local replica while the primary is unreachable
+	var edgeCache *services.EdgeCache
+	if cfg.EdgeCache.Enabled {
+		edgeCache = services.NewEdgeCache(cardRepo, replica, cfg.EdgeCache.Namespaces,
+			cfg.EdgeCache.SyncInterval, cfg.EdgeCache.PrimaryTimeout)
+		if primaryErr != nil {
+			edgeCache.MarkOffline(primaryErr)
+		}
+		cardRepo = edgeCache
+		go edgeCache.Run(syncCtx)
+		log.Printf("Edge cache enabled (path: %s, sync_interval: %s, namespaces: %v)",
+			cfg.EdgeCache.Path, cfg.EdgeCache.SyncInterval, cfg.EdgeCache.Namespaces)
+	}
+	cardService := services.NewCardService(cardRepo, services.Policy{
+		RequireActiveDevice:   cfg.Card.RequireActiveDevice,
+		ClockToleranceSeconds: int(cfg.Card.ClockTolerance / time.Second),
+		AntiPassback:          cfg.Card.AntiPassback,
+	})
+	cardService.SetGroupCacheTTL(cfg.Card.GroupCacheTTL)
+	if cfg.AccessLog.Enabled {
+		cardService.EnableAccessLog(cfg.AccessLog.Retention)
+	}
+	log.Printf("Card verification service initialized (backend: %s, access_log: %t)", cfg.KV.BackendType, cfg.AccessLog.Enabled)
+
+	// Edge side of edge sync: pull the upstream change feed and push the local access log
+	if cfg.Sync.UpstreamURL != "" {
+		edgeID := cfg.Sync.EdgeID
+		if edgeID == "" {
+			edgeID, _ = os.Hostname() //nolint:errcheck // An empty edge ID is rejected upstream and logged
+		}
+		agent := edgesync.NewAgent(cardService, edgesync.NewClient(cfg.Sync.UpstreamURL, cfg.Sync.Token),
+			edgeID, cfg.Sync.Namespaces, cfg.Sync.Interval)
+		go agent.Run(syncCtx)
+		log.Printf("Edge sync enabled (upstream: %s, edge_id: %s, interval: %s, namespaces: %v)",
+			cfg.Sync.UpstreamURL, edgeID, cfg.Sync.Interval, cfg.Sync.Namespaces)
 	}
 
 	// Create Gin router
@@ -81,7 +198,7 @@ func main() {
 	handlers.Config = cfg
 
 	// Register routes
-	setupRoutes(router, kvStore, cardService)
+	setupRoutes(router, kvStore, cardService, edgeCache, &cfg.Sync)
 
 	// Create HTTP server
 	port := ":" + cfg.Server.Port
@@ -103,9 +220,10 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
+	stopSync()
 
 	// Graceful shutdown with timeout
-	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
@@ -114,15 +232,45 @@ func main() {
 	log.Println("Server exited")
 }
 
-func setupRoutes(router *gin.Engine, _ kv.KV, cardService *services.CardService) {
-	// Health check
-	router.GET("/health", handlers.HealthHandler)
+// mirrorOf returns the KV mirror in the wrapper chain of store (nil without one)
+func mirrorOf(store kv.KV) *tiered.TieredKV {
+	for {
+		if mirrored, ok := store.(*tiered.TieredKV); ok {
+			return mirrored
+		}
+		wrapper, ok := store.(interface{ Unwrap() kv.KV })
+		if !ok {
+			return nil
+		}
+		store = wrapper.Unwrap()
+	}
+}
+
+// replicaReady reports whether the edge cache replica holds namespaces to serve verification from
+func replicaReady(replica *bbolt.BBoltKV) bool {
+	if replica == nil {
+		return false
+	}
+	namespaces, err := replica.ListNamespaces(context.Background())
+	return err == nil && len(namespaces) > 0
+}
+
+func setupRoutes(router *gin.Engine, kvStore kv.KV, cardService *services.CardService, edgeCache *services.EdgeCache, syncConfig *config.SyncConfig) {
+	// Health check (with the replica state and cache counters when the edge cache or KV cache is enabled)
+	kvCache, _ := kvStore.(*cache.Cache)
+	if edgeCache != nil || kvCache != nil {
+		router.GET("/health", handlers.StatusHealthHandler(edgeCache, kvCache))
+	} else {
+		router.GET("/health", handlers.HealthHandler)
+	}
 
 	// Root
 	router.GET("/", handlers.RootHandler)
 
 	// API v1 routes
 	v1 := router.Group("/api/v1")
+	// Namespaces become BBolt file names, so reject names that could escape the data directory
+	v1.Use(handlers.ValidateNamesMiddleware())
 	// ========== KV CRUD operations (Commented for MVP) ==========
 	// GET /api/v1/kv/{namespace}/{collection}/{key}
 	// v1.GET("/kv/:namespace/:collection/:key", handlers.GetKVHandler(kvStore))
@@ -143,24 +291,30 @@ func setupRoutes(router *gin.Engine, _ kv.KV, cardService *services.CardService)
 	// DELETE /api/v1/kv/batch (batch delete)
 	// v1.DELETE("/kv/batch", handlers.BatchDeleteHandler(kvStore))
 
+	// POST /api/v1/kv/batch/get (batch get, read-only)
+	v1.POST("/kv/batch/get", handlers.BatchGetHandler(kvStore))
+
+	// GET /api/v1/kv/{namespace}/{collection}/watch (Server-Sent Events stream of changes)
+	v1.GET("/kv/:namespace/:collection/watch", handlers.WatchKVHandler(kvStore))
+
 	// ========== List and Management (Commented for MVP) ==========
-	// GET /api/v1/kv/{namespace}/{collection} (list keys)
-	// v1.GET("/kv/:namespace/:collection", handlers.ListKeysHandler(kvStore))
+	// GET /api/v1/kv/{namespace}/{collection} (list keys, read-only)
+	v1.GET("/kv/:namespace/:collection", handlers.ListKeysHandler(kvStore))
 
 	// GET /api/v1/namespaces (list namespaces)
-	// v1.GET("/namespaces", handlers.ListNamespacesHandler(kvStore))
+	v1.GET("/namespaces", handlers.ListNamespacesHandler(kvStore))
 
 	// GET /api/v1/namespace/{namespace}/collections (list collections)
-	// v1.GET("/namespace/:namespace/collections", handlers.ListCollectionsHandler(kvStore))
+	v1.GET("/namespace/:namespace/collections", handlers.ListCollectionsHandler(kvStore))
 
 	// GET /api/v1/namespace/{namespace}/info (get namespace info)
 	// v1.GET("/namespace/:namespace/info", handlers.GetNamespaceInfoHandler(kvStore))
 
-	// DELETE /api/v1/namespace/{namespace} (delete namespace)
-	// v1.DELETE("/namespace/:namespace", handlers.DeleteNamespaceHandler(kvStore))
+	// DELETE /api/v1/namespace/{namespace}?confirm={namespace} (delete namespace)
+	v1.DELETE("/namespace/:namespace", handlers.DeleteNamespaceHandler(kvStore))
 
-	// DELETE /api/v1/namespace/{namespace}/collections/{collection} (delete collection)
-	// v1.DELETE("/namespace/:namespace/collections/:collection", handlers.DeleteCollectionHandler(kvStore))
+	// DELETE /api/v1/namespace/{namespace}/collections/{collection}?confirm={collection} (delete collection)
+	v1.DELETE("/namespace/:namespace/collections/:collection", handlers.DeleteCollectionHandler(kvStore))
 
 	// ========== Card Verification (MVP) ==========
 	if cardService != nil {
@@ -176,5 +330,103 @@ func setupRoutes(router *gin.Engine, _ kv.KV, cardService *services.CardService)
 		// Response: 200 "code=0000" (success) or 404 (error)
 		v1.POST("/namespace/:namespace/device/:device_name/vguang",
 			handlers.CardVerificationVguangHandler(cardService))
+
+		// ========== Card Management ==========
+		// GET /api/v1/namespace/{namespace}/cards (list cards with filters)
+		v1.GET("/namespace/:namespace/cards", handlers.ListCardsHandler(cardService))
+
+		// POST /api/v1/namespace/{namespace}/cards (create card)
+		v1.POST("/namespace/:namespace/cards", handlers.CreateCardHandler(cardService))
+
+		// GET /api/v1/namespace/{namespace}/cards/{id}
+		v1.GET("/namespace/:namespace/cards/:id", handlers.GetCardHandler(cardService))
+
+		// PUT /api/v1/namespace/{namespace}/cards/{id} (replace card fields)
+		v1.PUT("/namespace/:namespace/cards/:id", handlers.UpdateCardHandler(cardService))
+
+		// DELETE /api/v1/namespace/{namespace}/cards/{id}
+		v1.DELETE("/namespace/:namespace/cards/:id", handlers.DeleteCardHandler(cardService))
+
+		// POST /api/v1/namespace/{namespace}/cards/{id}/revoke
+		v1.POST("/namespace/:namespace/cards/:id/revoke", handlers.RevokeCardHandler(cardService))
+
+		// GET /api/v1/namespace/{namespace}/cards/{id}/presence (anti-passback state per zone)
+		v1.GET("/namespace/:namespace/cards/:id/presence", handlers.ListPresenceHandler(cardService))
+
+		// DELETE /api/v1/namespace/{namespace}/cards/{id}/presence (reset anti-passback, optional zone)
+		v1.DELETE("/namespace/:namespace/cards/:id/presence", handlers.ResetPresenceHandler(cardService))
+
+		// ========== Revocation List ==========
+		// GET /api/v1/namespace/{namespace}/revocations (list revoked card numbers and IDs)
+		v1.GET("/namespace/:namespace/revocations", handlers.ListRevocationsHandler(cardService))
+
+		// POST /api/v1/namespace/{namespace}/revocations (revoke a card number or card ID)
+		v1.POST("/namespace/:namespace/revocations", handlers.RevokeHandler(cardService))
+
+		// DELETE /api/v1/namespace/{namespace}/revocations/{id} (unrevoke)
+		v1.DELETE("/namespace/:namespace/revocations/:id", handlers.UnrevokeHandler(cardService))
+
+		// ========== Device Management ==========
+		// GET /api/v1/namespace/{namespace}/devices (list devices, optional status filter)
+		v1.GET("/namespace/:namespace/devices", handlers.ListDevicesHandler(cardService))
+
+		// POST /api/v1/namespace/{namespace}/devices (register device as pending)
+		v1.POST("/namespace/:namespace/devices", handlers.RegisterDeviceHandler(cardService))
+
+		// GET /api/v1/namespace/{namespace}/devices/{id}
+		v1.GET("/namespace/:namespace/devices/:id", handlers.GetDeviceHandler(cardService))
+
+		// PUT /api/v1/namespace/{namespace}/devices/{id} (replace device fields)
+		v1.PUT("/namespace/:namespace/devices/:id", handlers.UpdateDeviceHandler(cardService))
+
+		// POST /api/v1/namespace/{namespace}/devices/{id}/activate|deactivate|decommission
+		v1.POST("/namespace/:namespace/devices/:id/activate",
+			handlers.DeviceStatusHandler(cardService, models.DeviceStatusActive))
+		v1.POST("/namespace/:namespace/devices/:id/deactivate",
+			handlers.DeviceStatusHandler(cardService, models.DeviceStatusInactive))
+		v1.POST("/namespace/:namespace/devices/:id/decommission",
+			handlers.DeviceStatusHandler(cardService, models.DeviceStatusDecommissioned))
+
+		// ========== Device Groups ==========
+		// GET /api/v1/namespace/{namespace}/groups (list groups, optional device filter)
+		v1.GET("/namespace/:namespace/groups", handlers.ListDeviceGroupsHandler(cardService))
+
+		// POST /api/v1/namespace/{namespace}/groups (create group)
+		v1.POST("/namespace/:namespace/groups", handlers.CreateDeviceGroupHandler(cardService))
+
+		// GET /api/v1/namespace/{namespace}/groups/{id}
+		v1.GET("/namespace/:namespace/groups/:id", handlers.GetDeviceGroupHandler(cardService))
+
+		// PUT /api/v1/namespace/{namespace}/groups/{id} (replace members)
+		v1.PUT("/namespace/:namespace/groups/:id", handlers.UpdateDeviceGroupHandler(cardService))
+
+		// DELETE /api/v1/namespace/{namespace}/groups/{id}
+		v1.DELETE("/namespace/:namespace/groups/:id", handlers.DeleteDeviceGroupHandler(cardService))
+
+		// ========== Namespace Policy ==========
+		// GET /api/v1/namespace/{namespace}/policy (effective policy and overrides)
+		v1.GET("/namespace/:namespace/policy", handlers.GetPolicyHandler(cardService))
+
+		// PUT /api/v1/namespace/{namespace}/policy (replace overrides)
+		v1.PUT("/namespace/:namespace/policy", handlers.SetPolicyHandler(cardService))
+
+		// ========== Time Diagnostics ==========
+		// GET /api/v1/namespace/{namespace}/time (server time, clock tolerance and optional device drift)
+		v1.GET("/namespace/:namespace/time", handlers.TimeHandler(cardService))
+
+		// ========== Access Logs ==========
+		// GET /api/v1/namespace/{namespace}/access-logs (verification attempts, oldest first)
+		v1.GET("/namespace/:namespace/access-logs", handlers.ListAccessLogsHandler(cardService))
+
+		// ========== Edge Sync ==========
+		if syncConfig != nil && syncConfig.ServerEnabled {
+			syncRoutes := v1.Group("/namespace/:namespace/sync", handlers.SyncAuthMiddleware(syncConfig.Token))
+
+			// GET /api/v1/namespace/{namespace}/sync/changes (change feed pulled by edge instances)
+			syncRoutes.GET("/changes", handlers.SyncChangesHandler(cardService))
+
+			// POST /api/v1/namespace/{namespace}/sync/access-logs (access log pushed by edge instances)
+			syncRoutes.POST("/access-logs", handlers.SyncAccessLogsHandler(cardService))
+		}
 	}
 }
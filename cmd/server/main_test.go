@@ -1,9 +1,24 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"commander/internal/database/bbolt"
+	"commander/internal/database/cache"
+	"commander/internal/database/memory"
+	"commander/internal/database/tiered"
+	"commander/internal/handlers"
+	"commander/internal/kv"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestVersionVariables(t *testing.T) {
@@ -172,3 +187,209 @@ func TestServerMiddleware(t *testing.T) {
 //   }
 //
 // This would allow testing initialization without OS-level signal handling.
+
+func TestSetupRoutes_CardManagementRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create memory KV: %v", err)
+	}
+	defer store.Close()
+
+	router := gin.New()
+	setupRoutes(router, store, services.NewCardService(services.NewKVRepository(store), services.Policy{}), nil, nil)
+
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+
+	for _, route := range []string{
+		"POST /api/v1/namespace/:namespace",
+		"POST /api/v1/namespace/:namespace/device/:device_name/vguang",
+		"GET /api/v1/namespace/:namespace/cards",
+		"POST /api/v1/namespace/:namespace/cards",
+		"GET /api/v1/namespace/:namespace/cards/:id",
+		"PUT /api/v1/namespace/:namespace/cards/:id",
+		"DELETE /api/v1/namespace/:namespace/cards/:id",
+		"POST /api/v1/namespace/:namespace/cards/:id/revoke",
+		"GET /api/v1/namespace/:namespace/devices",
+		"POST /api/v1/namespace/:namespace/devices",
+		"GET /api/v1/namespace/:namespace/devices/:id",
+		"PUT /api/v1/namespace/:namespace/devices/:id",
+		"POST /api/v1/namespace/:namespace/devices/:id/activate",
+		"POST /api/v1/namespace/:namespace/devices/:id/deactivate",
+		"POST /api/v1/namespace/:namespace/devices/:id/decommission",
+		"GET /api/v1/namespace/:namespace/cards/:id/presence",
+		"DELETE /api/v1/namespace/:namespace/cards/:id/presence",
+		"GET /api/v1/namespace/:namespace/revocations",
+		"POST /api/v1/namespace/:namespace/revocations",
+		"DELETE /api/v1/namespace/:namespace/revocations/:id",
+		"GET /api/v1/namespace/:namespace/groups",
+		"POST /api/v1/namespace/:namespace/groups",
+		"GET /api/v1/namespace/:namespace/groups/:id",
+		"PUT /api/v1/namespace/:namespace/groups/:id",
+		"DELETE /api/v1/namespace/:namespace/groups/:id",
+		"GET /api/v1/namespace/:namespace/policy",
+		"PUT /api/v1/namespace/:namespace/policy",
+		"GET /api/v1/namespace/:namespace/access-logs",
+		"GET /api/v1/namespace/:namespace/time",
+	} {
+		assert.True(t, registered[route], "route %s not registered", route)
+	}
+}
+
+func TestSetupRoutes_BatchGet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Set(context.Background(), "hotel_a", "cards", "c1", []byte(`{"room":"302"}`)))
+
+	router := gin.New()
+	setupRoutes(router, store, nil, nil, nil)
+
+	body := `{"operations":[{"namespace":"hotel_a","collection":"cards","key":"c1"},` +
+		`{"namespace":"hotel_a","collection":"cards","key":"missing"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/kv/batch/get", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp handlers.BatchGetResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 2)
+	assert.True(t, resp.Results[0].Success)
+	assert.Equal(t, map[string]interface{}{"room": "302"}, resp.Results[0].Value)
+	assert.False(t, resp.Results[1].Success)
+	assert.Equal(t, 1, resp.SuccessCount)
+}
+
+func TestSetupRoutes_Watch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	defer store.Close()
+
+	router := gin.New()
+	setupRoutes(router, store, nil, nil, nil)
+
+	// A cancelled request ends the stream right after the headers are sent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/kv/hotel_a/cards/watch", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+}
+
+func TestSetupRoutes_ListKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	defer store.Close()
+	for _, key := range []string{"c1", "c2", "d1"} {
+		require.NoError(t, store.Set(context.Background(), "hotel_a", "cards", key, []byte(`{}`)))
+	}
+
+	router := gin.New()
+	setupRoutes(router, store, nil, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/kv/hotel_a/cards?prefix=c&limit=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp handlers.ListKeysResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"c1"}, resp.Keys)
+	assert.Equal(t, "c1", resp.NextCursor)
+}
+
+func TestSetupRoutes_Namespaces(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Set(context.Background(), "hotel_a", "cards", "c1", []byte(`{}`)))
+	require.NoError(t, store.Set(context.Background(), "hotel_a", "devices", "d1", []byte(`{}`)))
+
+	router := gin.New()
+	setupRoutes(router, store, nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/namespaces", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var namespaces handlers.ListNamespacesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &namespaces))
+	assert.Equal(t, []string{"hotel_a"}, namespaces.Namespaces)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/namespace/hotel_a/collections", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var collections handlers.ListCollectionsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &collections))
+	assert.Equal(t, []string{"cards", "devices"}, collections.Collections)
+}
+
+func TestSetupRoutes_DeleteNamespace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.Set(ctx, "hotel_a", "cards", "c1", []byte(`{}`)))
+	require.NoError(t, store.Set(ctx, "hotel_a", "devices", "d1", []byte(`{}`)))
+	require.NoError(t, store.Set(ctx, "hotel_b", "cards", "c1", []byte(`{}`)))
+
+	router := gin.New()
+	setupRoutes(router, store, nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/namespace/hotel_a/collections/cards?confirm=cards", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	_, err = store.Get(ctx, "hotel_a", "cards", "c1")
+	assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+	_, err = store.Get(ctx, "hotel_a", "devices", "d1")
+	assert.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/namespace/hotel_b", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/namespace/hotel_b?confirm=hotel_b", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	_, err = store.Get(ctx, "hotel_b", "cards", "c1")
+	assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+}
+
+func TestReplicaReady(t *testing.T) {
+	assert.False(t, replicaReady(nil))
+
+	replica, err := bbolt.NewBBoltKV(t.TempDir())
+	require.NoError(t, err)
+	defer replica.Close()
+	assert.False(t, replicaReady(replica), "an empty replica cannot serve verification")
+
+	require.NoError(t, replica.Set(context.Background(), "hotel_a", "cards", "c1", []byte(`{}`)))
+	assert.True(t, replicaReady(replica))
+}
+
+func TestMirrorOf(t *testing.T) {
+	primary, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	secondary, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	assert.Nil(t, mirrorOf(primary))
+
+	mirrored, err := tiered.NewTieredKV(primary, secondary, tiered.Options{ResyncInterval: -1})
+	require.NoError(t, err)
+	cached, err := cache.New(mirrored, cache.Options{})
+	require.NoError(t, err)
+	defer cached.Close()
+	assert.Same(t, mirrored, mirrorOf(cached), "the mirror should be found behind the cache")
+}
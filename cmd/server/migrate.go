@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"commander/internal/database"
+	"commander/internal/database/mongodb"
+	"commander/internal/kv"
+	"commander/internal/migrate"
+	"commander/internal/services"
+)
+
+// migrateProgressInterval is how often a running migration logs its progress
+const migrateProgressInterval = 5 * time.Second
+
+const migrateUsage = `Usage: server migrate --from <uri> --to <uri> [options]
+
+Copies every namespace, collection and key from one KV backend to another.
+Backend URIs: mongodb://..., redis://..., bbolt://<data dir>, memory://[snapshot file]
+With MongoDB on either side, devices, cards and the other card service records are copied
+record by record, so they are stored as native documents on MongoDB.
+
+Options:
+`
+
+// runMigrate runs the migrate subcommand and returns the process exit code
+func runMigrate(args []string, output io.Writer) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(output)
+	from := flags.String("from", "", "source backend URI")
+	to := flags.String("to", "", "destination backend URI")
+	dryRun := flags.Bool("dry-run", false, "read the source and report what would be copied, without writing")
+	resume := flags.Bool("resume", false, "continue an interrupted migration from the state file")
+	statePath := flags.String("state", "commander-migrate.json", "file recording progress for --resume")
+	batchSize := flags.Int("batch-size", migrate.DefaultBatchSize, "keys read and written at once")
+	namespaces := flags.String("namespaces", "", "comma-separated namespaces to copy (default all)")
+	exclude := flags.String("exclude", "", "comma-separated collections to skip in every namespace")
+	skipVerify := flags.Bool("skip-verify", false, "skip comparing key counts and checksums at the end")
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(output, migrateUsage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *from == "" || *to == "" {
+		_, _ = fmt.Fprintln(output, "Both --from and --to are required")
+		flags.Usage()
+		return 2
+	}
+	if *from == *to {
+		_, _ = fmt.Fprintln(output, "--from and --to must be different backends")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := migrateBackends(ctx, *from, *to, migrateOptions{
+		dryRun:     *dryRun,
+		resume:     *resume,
+		statePath:  *statePath,
+		batchSize:  *batchSize,
+		namespaces: splitList(*namespaces),
+		exclude:    splitList(*exclude),
+		verify:     !*skipVerify,
+		records:    isMongoURI(*from) || isMongoURI(*to),
+	}); err != nil {
+		log.Printf("[Migrate] Migration failed: error=%v", err)
+		return 1
+	}
+	return 0
+}
+
+// migrateOptions are the parsed flags of the migrate subcommand
+type migrateOptions struct {
+	dryRun     bool
+	resume     bool
+	statePath  string
+	batchSize  int
+	namespaces []string
+	exclude    []string
+	verify     bool
+
+	// records copies the card service collections through services.Repository instead of key by key,
+	// for MongoDB, which stores them as native documents
+	records bool
+}
+
+// migrateBackends opens both backends, copies the data and verifies it
+func migrateBackends(ctx context.Context, from, to string, opts migrateOptions) error {
+	state, err := loadMigrateState(from, to, opts)
+	if err != nil {
+		return err
+	}
+
+	source, err := openMigrateBackend(ctx, from)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	defer func() {
+		if closeErr := source.Close(); closeErr != nil {
+			log.Printf("[Migrate] Failed to close source: error=%v", closeErr)
+		}
+	}()
+	destination, err := openMigrateBackend(ctx, to)
+	if err != nil {
+		return fmt.Errorf("destination: %w", err)
+	}
+	defer func() {
+		if closeErr := destination.Close(); closeErr != nil {
+			log.Printf("[Migrate] Failed to close destination: error=%v", closeErr)
+		}
+	}()
+
+	return migrateStores(ctx, source, destination, state, opts)
+}
+
+// migrateStores copies the data of source to destination and verifies it
+func migrateStores(ctx context.Context, source, destination kv.KV, state *migrate.State, opts migrateOptions) error {
+	exclude := opts.exclude
+	if opts.records {
+		exclude = append(slices.Clone(exclude), services.RepositoryCollections()...)
+	}
+
+	lastLog := time.Now()
+	migrator, err := migrate.New(source, destination, migrate.Options{
+		BatchSize:  opts.batchSize,
+		DryRun:     opts.dryRun,
+		Namespaces: opts.namespaces,
+		Exclude:    exclude,
+		State:      state,
+		StatePath:  opts.statePath,
+		Progress: func(p migrate.Progress) {
+			if time.Since(lastLog) >= migrateProgressInterval {
+				lastLog = time.Now()
+				log.Printf("[Migrate] Copying: namespace=%s, collection=%s, keys=%d, total=%d",
+					p.Namespace, p.Collection, p.Keys, p.Total)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	report, err := migrator.Run(ctx)
+	if report != nil {
+		for _, c := range report.Collections {
+			switch {
+			case c.Resumed:
+				log.Printf("[Migrate] Already copied: namespace=%s, collection=%s", c.Namespace, c.Collection)
+			case opts.dryRun:
+				log.Printf("[Migrate] Would copy: namespace=%s, collection=%s, keys=%d, bytes=%d",
+					c.Namespace, c.Collection, c.Keys, c.Bytes)
+			default:
+				log.Printf("[Migrate] Copied: namespace=%s, collection=%s, keys=%d, bytes=%d",
+					c.Namespace, c.Collection, c.Keys, c.Bytes)
+			}
+		}
+	}
+	if err != nil {
+		if state != nil {
+			log.Printf("[Migrate] Progress saved, rerun with --resume to continue: state=%s", opts.statePath)
+		}
+		return err
+	}
+
+	var namespaces []string
+	if opts.records {
+		if namespaces, err = recordNamespaces(ctx, source, opts.namespaces); err != nil {
+			return err
+		}
+		if err := copyRecords(ctx, source, destination, namespaces, opts); err != nil {
+			return err
+		}
+	}
+	log.Printf("[Migrate] Finished: keys=%d, bytes=%d, dry_run=%t, duration=%s",
+		report.Keys, report.Bytes, opts.dryRun, time.Since(start).Round(time.Millisecond))
+
+	if opts.dryRun || !opts.verify {
+		return nil
+	}
+
+	verifications, err := migrator.Verify(ctx)
+	if err != nil {
+		return fmt.Errorf("verification: %w", err)
+	}
+	mismatches := 0
+	for _, v := range verifications {
+		if v.OK() {
+			continue
+		}
+		mismatches++
+		log.Printf("[Migrate] Mismatch: namespace=%s, collection=%s, source_keys=%d, destination_keys=%d, "+
+			"source_checksum=%s, destination_checksum=%s",
+			v.Namespace, v.Collection, v.SourceKeys, v.DestinationKeys, v.SourceChecksum, v.DestinationChecksum)
+	}
+	checked := len(verifications)
+	if opts.records {
+		recordMismatches, recordChecked, err := verifyRecords(ctx, source, destination, namespaces, opts.exclude)
+		if err != nil {
+			return fmt.Errorf("verification: %w", err)
+		}
+		mismatches += recordMismatches
+		checked += recordChecked
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("%d of %d collections differ between source and destination", mismatches, checked)
+	}
+	log.Printf("[Migrate] Verified: collections=%d", checked)
+
+	// The migration is complete, so the next run starts over
+	if err := os.Remove(opts.statePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("[Migrate] Failed to remove state file: state=%s, error=%v", opts.statePath, err)
+	}
+	return nil
+}
+
+// loadMigrateState returns the state to resume, a new state, or nil for a dry run
+func loadMigrateState(from, to string, opts migrateOptions) (*migrate.State, error) {
+	if opts.dryRun {
+		return nil, nil
+	}
+
+	fingerprint := migrate.Fingerprint(from, to)
+	state, err := migrate.LoadState(opts.statePath)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case state == nil:
+		return &migrate.State{Fingerprint: fingerprint}, nil
+	case !opts.resume:
+		return nil, fmt.Errorf("state file %s exists; pass --resume to continue that migration or remove it", opts.statePath)
+	case state.Fingerprint != fingerprint:
+		return nil, fmt.Errorf("state file %s belongs to a migration between other backends", opts.statePath)
+	}
+	log.Printf("[Migrate] Resuming: state=%s", opts.statePath)
+	return state, nil
+}
+
+// openMigrateBackend opens a backend from its URI and checks the connection
+func openMigrateBackend(ctx context.Context, uri string) (kv.KV, error) {
+	store, err := database.NewKVFromURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := store.Ping(pingCtx); err != nil {
+		_ = store.Close()
+		return nil, fmt.Errorf("failed to ping backend: %w", err)
+	}
+	return store, nil
+}
+
+// migrateRepository returns the card repository of a migration backend, as the server would use it
+func migrateRepository(store kv.KV) services.Repository {
+	if mongoKV, ok := kv.Unwrap(store).(*mongodb.MongoDBKV); ok {
+		return services.NewMongoRepository(mongoKV.GetClient())
+	}
+	return services.NewKVRepository(store)
+}
+
+// recordNamespaces returns the source namespaces whose records are copied
+func recordNamespaces(ctx context.Context, source kv.KV, selected []string) ([]string, error) {
+	lister, ok := source.(kv.Lister)
+	if !ok {
+		return nil, fmt.Errorf("source %T cannot list namespaces", source)
+	}
+	namespaces, err := lister.ListNamespaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	slices.Sort(namespaces)
+	if len(selected) == 0 {
+		return namespaces, nil
+	}
+	return slices.DeleteFunc(namespaces, func(namespace string) bool { return !slices.Contains(selected, namespace) }), nil
+}
+
+// copyRecords copies the card service records of every namespace through the repositories of both backends
+// Records are saved by ID, so a resumed migration copies them again without duplicating them
+func copyRecords(ctx context.Context, source, destination kv.KV, namespaces []string, opts migrateOptions) error {
+	sourceRepo, destinationRepo := migrateRepository(source), migrateRepository(destination)
+	for _, namespace := range namespaces {
+		counts, err := services.CopyRecords(ctx, sourceRepo, destinationRepo, namespace, services.CopyOptions{
+			DryRun:  opts.dryRun,
+			Exclude: opts.exclude,
+		})
+		for _, c := range counts {
+			if opts.dryRun {
+				log.Printf("[Migrate] Would copy records: namespace=%s, collection=%s, records=%d", namespace, c.Collection, c.Records)
+			} else {
+				log.Printf("[Migrate] Copied records: namespace=%s, collection=%s, records=%d", namespace, c.Collection, c.Records)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+	}
+	return nil
+}
+
+// verifyRecords compares the record count and checksum of every card service collection on both sides
+// and returns the number of collections that differ and of collections compared
+func verifyRecords(ctx context.Context, source, destination kv.KV, namespaces, exclude []string) (int, int, error) {
+	sourceRepo, destinationRepo := migrateRepository(source), migrateRepository(destination)
+	mismatches, checked := 0, 0
+	for _, namespace := range namespaces {
+		sourceSums, err := services.ChecksumRecords(ctx, sourceRepo, namespace, exclude)
+		if err != nil {
+			return mismatches, checked, fmt.Errorf("source namespace %s: %w", namespace, err)
+		}
+		destinationSums, err := services.ChecksumRecords(ctx, destinationRepo, namespace, exclude)
+		if err != nil {
+			return mismatches, checked, fmt.Errorf("destination namespace %s: %w", namespace, err)
+		}
+		for i, sum := range sourceSums {
+			checked++
+			if sum == destinationSums[i] {
+				continue
+			}
+			mismatches++
+			log.Printf("[Migrate] Mismatch: namespace=%s, collection=%s, source_records=%d, destination_records=%d, "+
+				"source_checksum=%s, destination_checksum=%s",
+				namespace, sum.Collection, sum.Records, destinationSums[i].Records, sum.Checksum, destinationSums[i].Checksum)
+		}
+	}
+	return mismatches, checked, nil
+}
+
+// isMongoURI reports whether uri names a MongoDB backend
+func isMongoURI(uri string) bool {
+	return strings.HasPrefix(uri, "mongodb://") || strings.HasPrefix(uri, "mongodb+srv://")
+}
+
+// splitList parses a comma-separated flag value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
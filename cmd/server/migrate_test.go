@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"commander/internal/database/bbolt"
+	"commander/internal/database/memory"
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunMigrate_Flags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		exitCode int
+		output   string
+	}{
+		{"help", []string{"--help"}, 0, "Usage: server migrate"},
+		{"missing destination", []string{"--from", "memory://"}, 2, "Both --from and --to are required"},
+		{"same backend", []string{"--from", "memory://", "--to", "memory://"}, 2, "must be different"},
+		{"unknown flag", []string{"--unknown"}, 2, "flag provided but not defined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			assert.Equal(t, tt.exitCode, runMigrate(tt.args, &output))
+			assert.Contains(t, output.String(), tt.output)
+		})
+	}
+}
+
+func TestRunMigrate_BBoltToMemorySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "bbolt")
+	snapshot := filepath.Join(dir, "snapshot.json")
+	statePath := filepath.Join(dir, "state.json")
+	ctx := context.Background()
+
+	source, err := bbolt.NewBBoltKV(dataDir)
+	require.NoError(t, err)
+	require.NoError(t, source.Set(ctx, "hotel_a", "cards", "c1", []byte(`{"id":"c1"}`)))
+	require.NoError(t, source.Set(ctx, "hotel_a", "access_logs", "l1", []byte(`{"id":"l1"}`)))
+	require.NoError(t, source.Close())
+
+	args := []string{"--from", "bbolt://" + dataDir, "--to", "memory://" + snapshot, "--state", statePath}
+
+	t.Run("dry run writes nothing", func(t *testing.T) {
+		assert.Equal(t, 0, runMigrate(append(args, "--dry-run"), os.Stderr))
+		_, err := os.Stat(statePath)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("existing state requires resume", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(statePath, []byte(`{"fingerprint":"other"}`), 0o600))
+		defer func() { _ = os.Remove(statePath) }()
+		assert.Equal(t, 1, runMigrate(args, os.Stderr))
+		assert.Equal(t, 1, runMigrate(append(args, "--resume"), os.Stderr))
+	})
+
+	t.Run("copy and verify", func(t *testing.T) {
+		assert.Equal(t, 0, runMigrate(append(args, "--exclude", "access_logs"), os.Stderr))
+		_, err := os.Stat(statePath)
+		assert.True(t, os.IsNotExist(err), "state file is removed after a verified migration")
+
+		destination, err := memory.NewMemoryKV(snapshot)
+		require.NoError(t, err)
+		defer func() { _ = destination.Close() }()
+		value, err := destination.Get(ctx, "hotel_a", "cards", "c1")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id":"c1"}`, string(value))
+		exists, err := destination.Exists(ctx, "hotel_a", "access_logs", "l1")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestMigrateStores_Records(t *testing.T) {
+	ctx := context.Background()
+	source, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	defer func() { _ = source.Close() }()
+	destination, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	defer func() { _ = destination.Close() }()
+
+	// The KV repository stands in for the MongoDB repository, which stores the same records natively
+	sourceRepo := services.NewKVRepository(source)
+	require.NoError(t, sourceRepo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-1", DeviceID: "lobby", SN: "SN-001"}))
+	require.NoError(t, sourceRepo.SaveCard(ctx, "hotel_a", &models.Card{ID: "card-1", Number: "GUEST-1", Devices: []string{"SN-001"}}))
+	require.NoError(t, source.Set(ctx, "hotel_a", "notes", "n1", []byte(`{"id":"n1"}`)))
+
+	opts := migrateOptions{batchSize: 10, verify: true, records: true}
+	require.NoError(t, migrateStores(ctx, source, destination, nil, opts))
+
+	destinationRepo := services.NewKVRepository(destination)
+	card, err := destinationRepo.GetCardByNumber(ctx, "hotel_a", "GUEST-1")
+	require.NoError(t, err)
+	assert.Equal(t, "card-1", card.ID)
+	device, err := destinationRepo.GetDeviceBySN(ctx, "hotel_a", "SN-001")
+	require.NoError(t, err)
+	assert.Equal(t, "device-1", device.ID)
+	value, err := destination.Get(ctx, "hotel_a", "notes", "n1")
+	require.NoError(t, err, "collections outside the card service are copied key by key")
+	assert.JSONEq(t, `{"id":"n1"}`, string(value))
+
+	// A record changed on the destination is reported by the verification
+	card.DisplayName = "Changed"
+	require.NoError(t, destinationRepo.SaveCard(ctx, "hotel_a", card))
+	opts.dryRun = true
+	require.NoError(t, migrateStores(ctx, source, destination, nil, opts))
+	mismatches, checked, err := verifyRecords(ctx, source, destination, []string{"hotel_a"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, mismatches)
+	assert.Equal(t, len(services.RepositoryCollections())-2, checked, "index collections are not compared on their own")
+}
@@ -2,14 +2,20 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Version string
-	Server  ServerConfig
-	KV      KVConfig
+	Version   string
+	Server    ServerConfig
+	KV        KVConfig
+	Card      CardConfig
+	AccessLog AccessLogConfig
+	EdgeCache EdgeCacheConfig
+	Sync      SyncConfig
 }
 
 // ServerConfig holds server-related configuration
@@ -30,6 +36,121 @@ type KVConfig struct {
 
 	// BBolt path
 	BBoltPath string
+
+	// Memory snapshot file, loaded on start and written on shutdown (empty = no snapshot)
+	MemorySnapshotPath string
+
+	// Read-through cache in front of the backend
+	Cache KVCacheConfig
+
+	// Local mirror of the backend
+	Mirror KVMirrorConfig
+}
+
+// KVMirrorConfig holds the local mirror settings
+// The configured backend stays the source of truth; reads are served by the mirror
+type KVMirrorConfig struct {
+	// Mirror backend (empty = no mirror)
+	BackendType BackendType
+
+	// Connection settings of the mirror backend, as in KVConfig
+	MongoURI  string
+	RedisURI  string
+	BBoltPath string
+
+	// Number of writes waiting to be mirrored before writers wait for the mirror
+	QueueSize int
+
+	// How often the whole backend is compared with the mirror (negative = only at startup)
+	ResyncInterval time.Duration
+
+	// Collections left out of the mirror (not read from the environment; set by the server for a MongoDB backend)
+	Exclude []string
+}
+
+// KVCacheConfig holds the read-through cache settings
+type KVCacheConfig struct {
+	// Serve reads from an in-process LRU and read through to the backend on a miss
+	Enabled bool
+
+	// Maximum number of cached keys
+	MaxEntries int
+
+	// Maximum size of cached keys and values in bytes
+	MaxBytes int64
+
+	// How long a value is served from the cache
+	TTL time.Duration
+
+	// How long a "not found" is served from the cache (0 = not cached)
+	NegativeTTL time.Duration
+
+	// Redis URI of a second tier shared between instances (empty = in-process only)
+	RedisURI string
+}
+
+// CardConfig holds the default card verification policy
+// Namespaces can override these values through the policy API
+type CardConfig struct {
+	// Reject devices whose status is not "active"
+	RequireActiveDevice bool
+
+	// Tolerance applied to card validity windows for device clock drift
+	ClockTolerance time.Duration
+
+	// How long device groups are cached during verification (0 = no cache)
+	GroupCacheTTL time.Duration
+
+	// Anti-passback mode: off, soft (log only) or hard (deny)
+	AntiPassback string
+}
+
+// AccessLogConfig holds the card verification audit log settings
+type AccessLogConfig struct {
+	// Record every verification attempt
+	Enabled bool
+
+	// How long entries are kept (0 = forever)
+	Retention time.Duration
+}
+
+// EdgeCacheConfig holds the offline replica of the records used by card verification
+type EdgeCacheConfig struct {
+	// Keep a local bbolt replica and fall back to it when the primary backend is unreachable
+	Enabled bool
+
+	// Directory of the replica's bbolt files
+	Path string
+
+	// Namespaces synced from startup; other namespaces are added when first verified against
+	Namespaces []string
+
+	// How often the replica is refreshed from the primary
+	SyncInterval time.Duration
+
+	// How long a verification read waits for the primary before using the replica
+	PrimaryTimeout time.Duration
+}
+
+// SyncConfig holds the edge-to-cloud sync settings
+type SyncConfig struct {
+	// Record a change feed and serve the sync endpoints (cloud side)
+	ServerEnabled bool
+
+	// Bearer token required by the sync endpoints and sent by the edge (empty = no check)
+	Token string
+
+	// Base URL of the upstream Commander this edge syncs with (empty = not an edge)
+	UpstreamURL string
+
+	// Namespaces pulled from the upstream
+	Namespaces []string
+
+	// How often the edge syncs
+	Interval time.Duration
+
+	// Identifies this edge in the upstream access log (empty = host name)
+	EdgeID string
 }
 
 // BackendType represents the type of KV backend
@@ -40,6 +161,7 @@ const (
 	BackendMongoDB BackendType = "mongodb"
 	BackendRedis   BackendType = "redis"
 	BackendBBolt   BackendType = "bbolt"
+	BackendMemory  BackendType = "memory"
 )
 
 // LoadConfig loads configuration from environment variables
@@ -55,6 +177,8 @@ func LoadConfig() *Config {
 		backendType = BackendRedis
 	case "bbolt":
 		backendType = BackendBBolt
+	case "memory":
+		backendType = BackendMemory
 	default:
 		// Default to bbolt if unknown type
 		backendType = BackendBBolt
@@ -77,6 +201,56 @@ func LoadConfig() *Config {
 
 			// BBolt path (default: /var/lib/stayforge/commander)
 			BBoltPath: getEnv("DATA_PATH", "/var/lib/stayforge/commander"),
+
+			// Memory snapshot file (optional)
+			MemorySnapshotPath: getEnv("MEMORY_SNAPSHOT_PATH", ""),
+
+			// Read-through cache (disabled by default)
+			Cache: KVCacheConfig{
+				Enabled:     getEnvBool("KV_CACHE_ENABLED", false),
+				MaxEntries:  getEnvInt("KV_CACHE_MAX_ENTRIES", 10000),
+				MaxBytes:    int64(getEnvInt("KV_CACHE_MAX_BYTES", 64<<20)),
+				TTL:         getEnvDuration("KV_CACHE_TTL", 30*time.Second),
+				NegativeTTL: getEnvDuration("KV_CACHE_NEGATIVE_TTL", 5*time.Second),
+				RedisURI:    getEnv("KV_CACHE_REDIS_URI", ""),
+			},
+
+			// Local mirror (disabled unless a mirror backend is set)
+			Mirror: KVMirrorConfig{
+				BackendType:    BackendType(strings.ToLower(getEnv("KV_MIRROR_BACKEND", ""))),
+				MongoURI:       getEnv("KV_MIRROR_MONGODB_URI", ""),
+				RedisURI:       getEnv("KV_MIRROR_REDIS_URI", ""),
+				BBoltPath:      getEnv("KV_MIRROR_DATA_PATH", "/var/lib/stayforge/commander/mirror"),
+				QueueSize:      getEnvInt("KV_MIRROR_QUEUE_SIZE", 10000),
+				ResyncInterval: getEnvDuration("KV_MIRROR_RESYNC_INTERVAL", 10*time.Minute),
+			},
+		},
+		Card: CardConfig{
+			RequireActiveDevice: getEnvBool("CARD_REQUIRE_ACTIVE_DEVICE", false),
+			ClockTolerance:      getEnvDuration("CARD_CLOCK_TOLERANCE", 60*time.Second),
+			GroupCacheTTL:       getEnvDuration("CARD_GROUP_CACHE_TTL", 30*time.Second),
+			AntiPassback:        getEnv("CARD_ANTI_PASSBACK", "off"),
+		},
+		AccessLog: AccessLogConfig{
+			Enabled: getEnvBool("ACCESS_LOG_ENABLED", true),
+
+			// Default: 90 days
+			Retention: getEnvDuration("ACCESS_LOG_RETENTION", 90*24*time.Hour),
+		},
+		EdgeCache: EdgeCacheConfig{
+			Enabled:        getEnvBool("EDGE_CACHE_ENABLED", false),
+			Path:           getEnv("EDGE_CACHE_PATH", "/var/lib/stayforge/commander/edge-cache"),
+			Namespaces:     getEnvList("EDGE_CACHE_NAMESPACES"),
+			SyncInterval:   getEnvDuration("EDGE_CACHE_SYNC_INTERVAL", time.Minute),
+			PrimaryTimeout: getEnvDuration("EDGE_CACHE_PRIMARY_TIMEOUT", 2*time.Second),
+		},
+		Sync: SyncConfig{
+			ServerEnabled: getEnvBool("SYNC_SERVER_ENABLED", false),
+			Token:         getEnv("SYNC_TOKEN", ""),
+			UpstreamURL:   getEnv("SYNC_UPSTREAM_URL", ""),
+			Namespaces:    getEnvList("SYNC_NAMESPACES"),
+			Interval:      getEnvDuration("SYNC_INTERVAL", 30*time.Second),
+			EdgeID:        getEnv("SYNC_EDGE_ID", ""),
 		},
 	}
 }
@@ -87,3 +261,41 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvBool parses a boolean environment variable, falling back to defaultValue if unset or invalid
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvInt parses an integer environment variable, falling back to defaultValue if unset or invalid
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvList parses a comma-separated environment variable, skipping empty items
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvDuration parses a duration environment variable (e.g. "720h"), falling back to defaultValue if unset or invalid
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
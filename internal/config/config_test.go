@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig_DefaultValues(t *testing.T) {
@@ -74,6 +75,22 @@ func TestLoadConfig_Redis(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_Memory(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("DATABASE", "memory")
+	os.Setenv("MEMORY_SNAPSHOT_PATH", "/tmp/commander.snapshot")
+
+	cfg := LoadConfig()
+
+	if cfg.KV.BackendType != BackendMemory {
+		t.Errorf("Expected backend type 'memory', got '%s'", cfg.KV.BackendType)
+	}
+
+	if cfg.KV.MemorySnapshotPath != "/tmp/commander.snapshot" {
+		t.Errorf("Expected snapshot path '/tmp/commander.snapshot', got '%s'", cfg.KV.MemorySnapshotPath)
+	}
+}
+
 func TestLoadConfig_CaseInsensitive(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -127,3 +144,198 @@ func TestGetEnv(t *testing.T) {
 		t.Errorf("Expected 'default', got '%s'", got)
 	}
 }
+
+func TestGetEnvBool(t *testing.T) {
+	os.Clearenv()
+
+	os.Setenv("TEST_BOOL", "true")
+	if got := getEnvBool("TEST_BOOL", false); !got {
+		t.Error("Expected true")
+	}
+
+	// Invalid values fall back to the default
+	os.Setenv("TEST_BOOL", "maybe")
+	if got := getEnvBool("TEST_BOOL", true); !got {
+		t.Error("Expected default true for invalid value")
+	}
+
+	if got := getEnvBool("UNSET_KEY", false); got {
+		t.Error("Expected default false")
+	}
+}
+
+func TestLoadConfig_Card(t *testing.T) {
+	os.Clearenv()
+
+	if cfg := LoadConfig(); cfg.Card.RequireActiveDevice {
+		t.Error("Expected active device check to be disabled by default")
+	}
+
+	if cfg := LoadConfig(); cfg.Card.ClockTolerance != 60*time.Second {
+		t.Errorf("Expected default clock tolerance of 60s, got %v", cfg.Card.ClockTolerance)
+	}
+	if cfg := LoadConfig(); cfg.Card.GroupCacheTTL != 30*time.Second {
+		t.Errorf("Expected default group cache TTL of 30s, got %v", cfg.Card.GroupCacheTTL)
+	}
+	if cfg := LoadConfig(); cfg.Card.AntiPassback != "off" {
+		t.Errorf("Expected anti-passback to be off by default, got %q", cfg.Card.AntiPassback)
+	}
+
+	os.Setenv("CARD_REQUIRE_ACTIVE_DEVICE", "true")
+	os.Setenv("CARD_CLOCK_TOLERANCE", "5s")
+	cfg := LoadConfig()
+	if !cfg.Card.RequireActiveDevice {
+		t.Error("Expected active device check to be enabled")
+	}
+	if cfg.Card.ClockTolerance != 5*time.Second {
+		t.Errorf("Expected clock tolerance of 5s, got %v", cfg.Card.ClockTolerance)
+	}
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	os.Clearenv()
+
+	os.Setenv("TEST_DURATION", "36h")
+	if got := getEnvDuration("TEST_DURATION", time.Hour); got != 36*time.Hour {
+		t.Errorf("Expected 36h, got %v", got)
+	}
+
+	// Invalid values fall back to the default
+	os.Setenv("TEST_DURATION", "90 days")
+	if got := getEnvDuration("TEST_DURATION", time.Hour); got != time.Hour {
+		t.Errorf("Expected default 1h for invalid value, got %v", got)
+	}
+}
+
+func TestLoadConfig_AccessLog(t *testing.T) {
+	os.Clearenv()
+
+	cfg := LoadConfig()
+	if !cfg.AccessLog.Enabled {
+		t.Error("Expected access log to be enabled by default")
+	}
+	if cfg.AccessLog.Retention != 90*24*time.Hour {
+		t.Errorf("Expected default retention of 90 days, got %v", cfg.AccessLog.Retention)
+	}
+
+	os.Setenv("ACCESS_LOG_ENABLED", "false")
+	os.Setenv("ACCESS_LOG_RETENTION", "0")
+	cfg = LoadConfig()
+	if cfg.AccessLog.Enabled {
+		t.Error("Expected access log to be disabled")
+	}
+	if cfg.AccessLog.Retention != 0 {
+		t.Errorf("Expected retention 0 (keep forever), got %v", cfg.AccessLog.Retention)
+	}
+}
+
+func TestLoadConfig_EdgeCache(t *testing.T) {
+	os.Clearenv()
+
+	cfg := LoadConfig()
+	if cfg.EdgeCache.Enabled {
+		t.Error("Expected edge cache to be disabled by default")
+	}
+	if cfg.EdgeCache.SyncInterval != time.Minute {
+		t.Errorf("Expected default sync interval of 1m, got %v", cfg.EdgeCache.SyncInterval)
+	}
+	if cfg.EdgeCache.PrimaryTimeout != 2*time.Second {
+		t.Errorf("Expected default primary timeout of 2s, got %v", cfg.EdgeCache.PrimaryTimeout)
+	}
+	if len(cfg.EdgeCache.Namespaces) != 0 {
+		t.Errorf("Expected no namespaces by default, got %v", cfg.EdgeCache.Namespaces)
+	}
+
+	os.Setenv("EDGE_CACHE_ENABLED", "true")
+	os.Setenv("EDGE_CACHE_NAMESPACES", "hotel_a, ,hotel_b")
+	cfg = LoadConfig()
+	if !cfg.EdgeCache.Enabled {
+		t.Error("Expected edge cache to be enabled")
+	}
+	if len(cfg.EdgeCache.Namespaces) != 2 || cfg.EdgeCache.Namespaces[0] != "hotel_a" || cfg.EdgeCache.Namespaces[1] != "hotel_b" {
+		t.Errorf("Expected namespaces [hotel_a hotel_b], got %v", cfg.EdgeCache.Namespaces)
+	}
+}
+
+func TestLoadConfig_Sync(t *testing.T) {
+	os.Clearenv()
+
+	cfg := LoadConfig()
+	if cfg.Sync.ServerEnabled || cfg.Sync.UpstreamURL != "" {
+		t.Error("Expected sync to be disabled by default")
+	}
+	if cfg.Sync.Interval != 30*time.Second {
+		t.Errorf("Expected default sync interval of 30s, got %v", cfg.Sync.Interval)
+	}
+
+	os.Setenv("SYNC_UPSTREAM_URL", "https://cloud.example.com")
+	os.Setenv("SYNC_NAMESPACES", "hotel_a")
+	os.Setenv("SYNC_INTERVAL", "10s")
+	cfg = LoadConfig()
+	if cfg.Sync.UpstreamURL != "https://cloud.example.com" {
+		t.Errorf("Expected upstream URL to be set, got %q", cfg.Sync.UpstreamURL)
+	}
+	if len(cfg.Sync.Namespaces) != 1 || cfg.Sync.Namespaces[0] != "hotel_a" {
+		t.Errorf("Expected namespaces [hotel_a], got %v", cfg.Sync.Namespaces)
+	}
+	if cfg.Sync.Interval != 10*time.Second {
+		t.Errorf("Expected sync interval of 10s, got %v", cfg.Sync.Interval)
+	}
+}
+
+func TestLoadConfig_KVCache(t *testing.T) {
+	os.Clearenv()
+
+	cfg := LoadConfig()
+	if cfg.KV.Cache.Enabled {
+		t.Error("Expected KV cache to be disabled by default")
+	}
+	if cfg.KV.Cache.MaxEntries != 10000 || cfg.KV.Cache.MaxBytes != 64<<20 {
+		t.Errorf("Expected default limits of 10000 entries and 64 MiB, got %d and %d",
+			cfg.KV.Cache.MaxEntries, cfg.KV.Cache.MaxBytes)
+	}
+	if cfg.KV.Cache.TTL != 30*time.Second || cfg.KV.Cache.NegativeTTL != 5*time.Second {
+		t.Errorf("Expected default TTLs of 30s and 5s, got %v and %v", cfg.KV.Cache.TTL, cfg.KV.Cache.NegativeTTL)
+	}
+
+	os.Setenv("KV_CACHE_ENABLED", "true")
+	os.Setenv("KV_CACHE_MAX_ENTRIES", "500")
+	os.Setenv("KV_CACHE_MAX_BYTES", "not-a-number")
+	os.Setenv("KV_CACHE_NEGATIVE_TTL", "0s")
+	os.Setenv("KV_CACHE_REDIS_URI", "redis://cache:6379/1")
+	cfg = LoadConfig()
+	if !cfg.KV.Cache.Enabled || cfg.KV.Cache.MaxEntries != 500 || cfg.KV.Cache.RedisURI != "redis://cache:6379/1" {
+		t.Errorf("Expected cache settings from the environment, got %+v", cfg.KV.Cache)
+	}
+	if cfg.KV.Cache.MaxBytes != 64<<20 {
+		t.Errorf("Expected invalid max bytes to fall back to the default, got %d", cfg.KV.Cache.MaxBytes)
+	}
+	if cfg.KV.Cache.NegativeTTL != 0 {
+		t.Errorf("Expected negative caching to be disabled, got %v", cfg.KV.Cache.NegativeTTL)
+	}
+}
+
+func TestLoadConfig_KVMirror(t *testing.T) {
+	os.Clearenv()
+
+	cfg := LoadConfig()
+	if cfg.KV.Mirror.BackendType != "" {
+		t.Errorf("Expected no mirror by default, got %q", cfg.KV.Mirror.BackendType)
+	}
+	if cfg.KV.Mirror.QueueSize != 10000 || cfg.KV.Mirror.ResyncInterval != 10*time.Minute {
+		t.Errorf("Expected default queue size 10000 and resync interval 10m, got %d and %v",
+			cfg.KV.Mirror.QueueSize, cfg.KV.Mirror.ResyncInterval)
+	}
+
+	os.Setenv("DATABASE", "mongodb")
+	os.Setenv("KV_MIRROR_BACKEND", "BBolt")
+	os.Setenv("KV_MIRROR_DATA_PATH", "/tmp/mirror")
+	os.Setenv("KV_MIRROR_RESYNC_INTERVAL", "-1s")
+	cfg = LoadConfig()
+	if cfg.KV.BackendType != BackendMongoDB || cfg.KV.Mirror.BackendType != BackendBBolt {
+		t.Errorf("Expected mongodb mirrored to bbolt, got %q and %q", cfg.KV.BackendType, cfg.KV.Mirror.BackendType)
+	}
+	if cfg.KV.Mirror.BBoltPath != "/tmp/mirror" || cfg.KV.Mirror.ResyncInterval != -time.Second {
+		t.Errorf("Expected mirror settings from the environment, got %+v", cfg.KV.Mirror)
+	}
+}
@@ -1,12 +1,17 @@
 package bbolt
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"commander/internal/kv"
 
@@ -22,19 +27,102 @@ type BBoltKV struct {
 	baseDir string
 	dbs     map[string]*bbolt.DB
 	mu      sync.RWMutex
+
+	// Background sweeper for expired keys
+	stopSweep chan struct{}
+	stopOnce  sync.Once
+	sweepDone sync.WaitGroup
+
+	// events fans out committed writes to Watch callers
+	events *kv.Broadcaster
 }
 
+// sweepInterval is how often expired keys are removed from open namespaces
+const sweepInterval = time.Minute
+
 // NewBBoltKV creates a new bbolt KV store
+// A background goroutine removes expired keys until Close is called
 func NewBBoltKV(baseDir string) (*BBoltKV, error) {
 	// Create base directory if it doesn't exist
 	if err := os.MkdirAll(baseDir, 0o755); err != nil {
 		return nil, fmt.Errorf("failed to create base directory: %w", err)
 	}
 
-	return &BBoltKV{
-		baseDir: baseDir,
-		dbs:     make(map[string]*bbolt.DB),
-	}, nil
+	b := &BBoltKV{
+		baseDir:   baseDir,
+		dbs:       make(map[string]*bbolt.DB),
+		stopSweep: make(chan struct{}),
+		events:    kv.NewBroadcaster(),
+	}
+
+	b.sweepDone.Add(1)
+	go b.runSweeper(sweepInterval)
+
+	return b, nil
+}
+
+// runSweeper periodically removes expired keys until stopSweep is closed
+func (b *BBoltKV) runSweeper(interval time.Duration) {
+	defer b.sweepDone.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stopSweep:
+			return
+		case <-ticker.C:
+			b.sweepExpired(time.Now())
+		}
+	}
+}
+
+// sweepExpired deletes expired keys from every open namespace
+// Namespaces that are not open are cleaned lazily: expired keys are never returned by reads
+func (b *BBoltKV) sweepExpired(now time.Time) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for namespace, db := range b.dbs {
+		var removed []kv.Event
+		err := db.Update(func(tx *bbolt.Tx) error {
+			return tx.ForEach(func(name []byte, bucket *bbolt.Bucket) error {
+				if isReserved(name) {
+					return nil
+				}
+				var expired [][]byte
+				err := bucket.ForEach(func(k, v []byte) error {
+					if isExpired(v, now) {
+						expired = append(expired, append([]byte(nil), k...))
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				for _, k := range expired {
+					if err := bucket.Delete(k); err != nil {
+						return err
+					}
+					removed = append(removed, kv.Event{
+						Type:       kv.EventDelete,
+						Namespace:  namespace,
+						Collection: string(name),
+						Key:        string(k),
+					})
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			// Best effort cleanup, retried on next tick
+			continue
+		}
+		for _, event := range removed {
+			b.events.Publish(event)
+		}
+	}
 }
 
 // getDB returns the database for the given namespace (file)
@@ -57,95 +145,381 @@ func (b *BBoltKV) getDB(namespace string) (*bbolt.DB, error) {
 		return existingDB, nil
 	}
 
-	// Create database file path: <baseDir>/<namespace>.db
-	dbPath := filepath.Join(b.baseDir, fmt.Sprintf("%s.db", namespace))
+	dbPath, err := b.dbPath(namespace)
+	if err != nil {
+		return nil, err
+	}
+	_, statErr := os.Stat(dbPath)
 
-	db, err := bbolt.Open(dbPath, 0o600, nil)
+	db, err = bbolt.Open(dbPath, 0o600, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database %s: %w", dbPath, err)
 	}
 
+	if errors.Is(statErr, os.ErrNotExist) {
+		if err := b.seedRevision(namespace, db); err != nil {
+			_ = db.Close()
+			_ = os.Remove(dbPath)
+			return nil, fmt.Errorf("failed to seed revisions of %s: %w", namespace, err)
+		}
+	}
+
 	// Store the database connection
 	b.dbs[namespace] = db
 
 	return db, nil
 }
 
+// dbPath returns the database file path for a namespace: <baseDir>/<namespace>.db
+// Namespaces that could point outside baseDir are rejected (see kv.ValidateName)
+func (b *BBoltKV) dbPath(namespace string) (string, error) {
+	if err := kv.ValidateName(namespace); err != nil {
+		return "", err
+	}
+	return filepath.Join(b.baseDir, fmt.Sprintf("%s.db", namespace)), nil
+}
+
 // Get retrieves a JSON value by key from namespace and collection
 func (b *BBoltKV) Get(ctx context.Context, namespace, collection, key string) ([]byte, error) {
+	value, _, err := b.GetWithVersion(ctx, namespace, collection, key)
+	return value, err
+}
+
+// GetWithVersion retrieves a JSON value and its revision from namespace and collection
+func (b *BBoltKV) GetWithVersion(ctx context.Context, namespace, collection, key string) ([]byte, uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
 	namespace = kv.NormalizeNamespace(namespace)
 	db, err := b.getDB(namespace)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	var value []byte
+	var rec record
 	err = db.View(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(collection))
 		if bucket == nil {
 			return kv.ErrKeyNotFound
 		}
 
-		value = bucket.Get([]byte(key))
-		if value == nil {
+		data := bucket.Get([]byte(key))
+		if data == nil || isExpired(data, time.Now()) {
 			return kv.ErrKeyNotFound
 		}
 
-		// Copy the value since it's only valid within the transaction
-		value = append([]byte(nil), value...)
+		// decodeRecord copies the value since it's only valid within the transaction
+		rec = decodeRecord(data)
 		return nil
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	return rec.value, rec.revision, nil
+}
+
+// GetMany retrieves keys using one read transaction per namespace
+func (b *BBoltKV) GetMany(ctx context.Context, keys []kv.Key) ([]kv.GetResult, error) {
+	// Group key positions by namespace, keeping first-seen order
+	byNamespace := make(map[string][]int)
+	namespaces := make([]string, 0)
+	for i, k := range keys {
+		namespace := kv.NormalizeNamespace(k.Namespace)
+		if _, seen := byNamespace[namespace]; !seen {
+			namespaces = append(namespaces, namespace)
+		}
+		byNamespace[namespace] = append(byNamespace[namespace], i)
+	}
+
+	results := make([]kv.GetResult, len(keys))
+	now := time.Now()
+	for _, namespace := range namespaces {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		db, err := b.getDB(namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		err = db.View(func(tx *bbolt.Tx) error {
+			for _, i := range byNamespace[namespace] {
+				bucket := tx.Bucket([]byte(keys[i].Collection))
+				if bucket == nil {
+					continue
+				}
+				data := bucket.Get([]byte(keys[i].Key))
+				if data == nil || isExpired(data, now) {
+					continue
+				}
+				rec := decodeRecord(data)
+				results[i] = kv.GetResult{Value: rec.value, Found: true, TTL: rec.ttl(now)}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return value, nil
+	return results, nil
 }
 
 // Set stores a JSON value by key in namespace and collection
 func (b *BBoltKV) Set(ctx context.Context, namespace, collection, key string, value []byte) error {
+	return b.SetWithTTL(ctx, namespace, collection, key, value, 0)
+}
+
+// SetWithTTL stores a JSON value that expires after ttl (ttl <= 0 means never)
+// The expiry is stored in the value header and enforced on read and by the background sweeper
+func (b *BBoltKV) SetWithTTL(ctx context.Context, namespace, collection, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	namespace = kv.NormalizeNamespace(namespace)
 	db, err := b.getDB(namespace)
 	if err != nil {
 		return err
 	}
 
-	return db.Update(func(tx *bbolt.Tx) error {
-		bucket, err := tx.CreateBucketIfNotExists([]byte(collection))
-		if err != nil {
-			return fmt.Errorf("failed to create bucket %s: %w", collection, err)
-		}
+	var revision uint64
+	err = db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		revision, err = put(tx, collection, key, value, ttl, anyVersion)
+		return err
+	})
+	if err != nil {
+		return err
+	}
 
-		return bucket.Put([]byte(key), value)
+	b.publishSet(namespace, collection, key, value, revision)
+	return nil
+}
+
+// CompareAndSet stores a JSON value only if the current revision equals expectedVersion
+func (b *BBoltKV) CompareAndSet(ctx context.Context, namespace, collection, key string, expectedVersion uint64, value []byte) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+	db, err := b.getDB(namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	var revision uint64
+	err = db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		revision, err = put(tx, collection, key, value, 0, expectedVersion)
+		return err
 	})
+	if err != nil {
+		return 0, err
+	}
+
+	b.publishSet(namespace, collection, key, value, revision)
+	return revision, nil
 }
 
 // Delete removes a key-value pair from namespace and collection
 func (b *BBoltKV) Delete(ctx context.Context, namespace, collection, key string) error {
+	return b.CompareAndDelete(ctx, namespace, collection, key, anyVersion)
+}
+
+// CompareAndDelete removes a key only if the current revision equals expectedVersion
+func (b *BBoltKV) CompareAndDelete(ctx context.Context, namespace, collection, key string, expectedVersion uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	namespace = kv.NormalizeNamespace(namespace)
 	db, err := b.getDB(namespace)
 	if err != nil {
 		return err
 	}
 
-	return db.Update(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket([]byte(collection))
-		if bucket == nil {
-			return kv.ErrKeyNotFound
+	err = db.Update(func(tx *bbolt.Tx) error {
+		return remove(tx, collection, key, expectedVersion)
+	})
+	if err != nil {
+		return err
+	}
+
+	b.events.Publish(kv.Event{Type: kv.EventDelete, Namespace: namespace, Collection: collection, Key: key})
+	return nil
+}
+
+// publishSet notifies watchers of a committed write
+// value is copied because callers may reuse the slice
+func (b *BBoltKV) publishSet(namespace, collection, key string, value []byte, revision uint64) {
+	b.events.Publish(kv.Event{
+		Type:       kv.EventSet,
+		Namespace:  namespace,
+		Collection: collection,
+		Key:        key,
+		Value:      append([]byte(nil), value...),
+		Revision:   revision,
+	})
+}
+
+// Watch reports committed writes to keys in namespace and collection starting with prefix
+// Events come from this process only; expired keys are reported when the sweeper removes them
+func (b *BBoltKV) Watch(ctx context.Context, namespace, collection, prefix string) (<-chan kv.Event, error) {
+	return b.events.Subscribe(ctx, namespace, collection, prefix), nil
+}
+
+// anyVersion disables the revision check in put and remove
+const anyVersion = ^uint64(0)
+
+// put writes a value into the collection bucket under the next revision of the namespace
+// Unless expectedVersion is anyVersion, the current revision must equal it
+func put(tx *bbolt.Tx, collection, key string, value []byte, ttl time.Duration, expectedVersion uint64) (uint64, error) {
+	if isReserved([]byte(collection)) {
+		return 0, errReservedCollection
+	}
+	bucket, err := tx.CreateBucketIfNotExists([]byte(collection))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create bucket %s: %w", collection, err)
+	}
+
+	now := time.Now()
+	current := currentRevision(bucket.Get([]byte(key)), now)
+	if expectedVersion != anyVersion && current != expectedVersion {
+		return 0, kv.ErrVersionMismatch
+	}
+
+	revision, err := nextRevision(tx, current)
+	if err != nil {
+		return 0, err
+	}
+	if err := bucket.Put([]byte(key), newRecord(value, ttl, revision, now).encode()); err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+// remove deletes a key from the collection bucket
+// Unless expectedVersion is anyVersion, the current revision must equal it
+func remove(tx *bbolt.Tx, collection, key string, expectedVersion uint64) error {
+	bucket := tx.Bucket([]byte(collection))
+	if bucket == nil || isReserved([]byte(collection)) {
+		return kv.ErrKeyNotFound
+	}
+
+	data := bucket.Get([]byte(key))
+	if data == nil {
+		return kv.ErrKeyNotFound
+	}
+
+	current := currentRevision(data, time.Now())
+	if current == 0 {
+		// Remove the expired value, but report it as missing
+		if err := bucket.Delete([]byte(key)); err != nil {
+			return err
 		}
+		return kv.ErrKeyNotFound
+	}
 
-		value := bucket.Get([]byte(key))
-		if value == nil {
-			return kv.ErrKeyNotFound
+	if expectedVersion != anyVersion && current != expectedVersion {
+		return kv.ErrVersionMismatch
+	}
+
+	return bucket.Delete([]byte(key))
+}
+
+// Apply executes ops atomically using one write transaction per namespace
+// Transactions are opened in namespace order and committed only after every operation succeeded;
+// a failed operation rolls all of them back. Namespaces are separate files, so if a commit itself
+// fails, namespaces committed before it keep their writes
+func (b *BBoltKV) Apply(ctx context.Context, ops []kv.Op) error {
+	namespaces := make([]string, 0)
+	for _, op := range ops {
+		namespace := kv.NormalizeNamespace(op.Namespace)
+		if !slices.Contains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	// A fixed order keeps concurrent multi-namespace transactions from deadlocking
+	sort.Strings(namespaces)
+
+	// Open every database before starting any transaction, so getDB never waits on b.mu
+	// (held by DropNamespace while it closes a database) with a write transaction open
+	dbs := make([]*bbolt.DB, len(namespaces))
+	for i, namespace := range namespaces {
+		db, err := b.getDB(namespace)
+		if err != nil {
+			return err
 		}
+		dbs[i] = db
+	}
 
-		return bucket.Delete([]byte(key))
-	})
+	txs := make(map[string]*bbolt.Tx, len(namespaces))
+	rollback := func() {
+		for _, tx := range txs {
+			_ = tx.Rollback() //nolint:errcheck // Nothing was committed
+		}
+	}
+
+	for i, namespace := range namespaces {
+		tx, err := dbs[i].Begin(true)
+		if err != nil {
+			rollback()
+			return err
+		}
+		txs[namespace] = tx
+	}
+
+	events := make([]kv.Event, 0, len(ops))
+	for i, op := range ops {
+		if err := ctx.Err(); err != nil {
+			rollback()
+			return err
+		}
+
+		namespace := kv.NormalizeNamespace(op.Namespace)
+		tx := txs[namespace]
+		event := kv.Event{Namespace: namespace, Collection: op.Collection, Key: op.Key}
+		var err error
+		switch op.Type {
+		case kv.OpSet:
+			event.Type = kv.EventSet
+			event.Value = append([]byte(nil), op.Value...)
+			event.Revision, err = put(tx, op.Collection, op.Key, op.Value, op.TTL, anyVersion)
+		case kv.OpDelete:
+			event.Type = kv.EventDelete
+			err = remove(tx, op.Collection, op.Key, anyVersion)
+		default:
+			err = fmt.Errorf("unknown operation type %d", op.Type)
+		}
+		if err != nil {
+			rollback()
+			return &kv.TxError{Index: i, Err: err}
+		}
+		events = append(events, event)
+	}
+
+	for _, namespace := range namespaces {
+		if err := txs[namespace].Commit(); err != nil {
+			delete(txs, namespace)
+			rollback()
+			return err
+		}
+		delete(txs, namespace)
+	}
+
+	for _, event := range events {
+		b.events.Publish(event)
+	}
+	return nil
 }
 
 // Exists checks if a key exists in namespace and collection
 func (b *BBoltKV) Exists(ctx context.Context, namespace, collection, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	namespace = kv.NormalizeNamespace(namespace)
 	db, err := b.getDB(namespace)
 	if err != nil {
@@ -160,15 +534,248 @@ func (b *BBoltKV) Exists(ctx context.Context, namespace, collection, key string)
 		}
 
 		value := bucket.Get([]byte(key))
-		exists = value != nil
+		exists = value != nil && !isExpired(value, time.Now())
 		return nil
 	})
 
 	return exists, err
 }
 
-// Close closes all database connections
+// List returns keys in namespace and collection using a bucket cursor
+// Keys are returned in bbolt's native byte order
+func (b *BBoltKV) List(ctx context.Context, namespace, collection string, opts kv.ListOptions) (*kv.ListResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+	db, err := b.getDB(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = kv.DefaultListLimit
+	}
+
+	result := &kv.ListResult{Keys: make([]string, 0)}
+	err = db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(collection))
+		if bucket == nil {
+			return nil
+		}
+
+		prefix := []byte(opts.Prefix)
+		cursor := bucket.Cursor()
+
+		// Start from whichever comes later: the prefix or the cursor
+		var k, v []byte
+		if opts.Cursor != "" && opts.Cursor >= opts.Prefix {
+			k, v = cursor.Seek([]byte(opts.Cursor))
+			if k != nil && string(k) == opts.Cursor {
+				k, v = cursor.Next()
+			}
+		} else {
+			k, v = cursor.Seek(prefix)
+		}
+
+		now := time.Now()
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			if isExpired(v, now) {
+				continue
+			}
+			if len(result.Keys) == limit {
+				result.NextCursor = result.Keys[limit-1]
+				break
+			}
+			result.Keys = append(result.Keys, string(k))
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ListNamespaces returns namespaces by listing *.db files in the base directory
+func (b *BBoltKV) ListNamespaces(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(b.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base directory: %w", err)
+	}
+
+	namespaces := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		// Skip directories and hidden files such as .ping.db
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".db") {
+			continue
+		}
+		namespaces = append(namespaces, strings.TrimSuffix(name, ".db"))
+	}
+
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// ListCollections returns the bucket names of a namespace
+// A namespace without a .db file has no collections (the file is not created)
+func (b *BBoltKV) ListCollections(ctx context.Context, namespace string) ([]string, error) {
+	namespace = kv.NormalizeNamespace(namespace)
+	dbPath, err := b.dbPath(namespace)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+
+	db, err := b.getDB(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]string, 0)
+	err = db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+			if !isReserved(name) {
+				collections = append(collections, string(name))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// DropNamespace removes the namespace .db file
+// The cached connection is closed and evicted first so the file is not in use, and the last
+// revision of the namespace is saved so a recreated namespace continues above it
+// Every key of the namespace is reported to watchers as deleted
+func (b *BBoltKV) DropNamespace(ctx context.Context, namespace string) error {
+	namespace = kv.NormalizeNamespace(namespace)
+	dbPath, err := b.dbPath(namespace)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	db, exists := b.dbs[namespace]
+	if !exists {
+		if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if db, err = bbolt.Open(dbPath, 0o600, nil); err != nil {
+			return fmt.Errorf("failed to open database %s: %w", dbPath, err)
+		}
+	}
+
+	var dropped []kv.Event
+	err = db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bbolt.Bucket) error {
+			if !isReserved(name) {
+				dropped = append(dropped, deleteEvents(namespace, name, bucket)...)
+			}
+			return nil
+		})
+	})
+	revision, revErr := namespaceRevision(db)
+	err = errors.Join(err, revErr)
+	if closeErr := db.Close(); closeErr != nil {
+		return fmt.Errorf("failed to close database %s: %w", namespace, closeErr)
+	}
+	delete(b.dbs, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to read revisions of %s: %w", namespace, err)
+	}
+	if revision > 0 {
+		if err := b.saveDroppedRevision(namespace, revision); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(dbPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove database %s: %w", namespace, err)
+	}
+
+	for _, event := range dropped {
+		b.events.Publish(event)
+	}
+	return nil
+}
+
+// DropCollection removes the collection bucket from the namespace and reports its keys to watchers as deleted
+func (b *BBoltKV) DropCollection(ctx context.Context, namespace, collection string) error {
+	namespace = kv.NormalizeNamespace(namespace)
+	dbPath, err := b.dbPath(namespace)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	db, err := b.getDB(namespace)
+	if err != nil {
+		return err
+	}
+
+	if isReserved([]byte(collection)) {
+		return nil
+	}
+
+	var dropped []kv.Event
+	err = db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(collection))
+		if bucket == nil {
+			return nil
+		}
+		dropped = deleteEvents(namespace, []byte(collection), bucket)
+		return tx.DeleteBucket([]byte(collection))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, event := range dropped {
+		b.events.Publish(event)
+	}
+	return nil
+}
+
+// deleteEvents returns a delete event for every key in a bucket that is about to be dropped
+func deleteEvents(namespace string, collection []byte, bucket *bbolt.Bucket) []kv.Event {
+	var events []kv.Event
+	_ = bucket.ForEach(func(k, v []byte) error {
+		if v != nil {
+			events = append(events, kv.Event{
+				Type:       kv.EventDelete,
+				Namespace:  namespace,
+				Collection: string(collection),
+				Key:        string(k),
+			})
+		}
+		return nil
+	})
+	return events
+}
+
+// Close stops the sweeper and closes all database connections
 func (b *BBoltKV) Close() error {
+	b.stopOnce.Do(func() {
+		close(b.stopSweep)
+	})
+	b.sweepDone.Wait()
+	b.events.Close()
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
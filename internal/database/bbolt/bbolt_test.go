@@ -3,8 +3,17 @@ package bbolt
 import (
 	"bytes"
 	"commander/internal/kv"
+	"commander/internal/kv/kvtest"
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
 )
 
 func TestNewBBoltKV(t *testing.T) {
@@ -358,3 +367,607 @@ func TestBBoltKV_UpdateValue(t *testing.T) {
 		t.Errorf("Expected updated value %s, got %s", value2, retrieved)
 	}
 }
+
+func TestBBoltKV_List(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	for _, key := range []string{"card_003", "card_001", "device_001", "card_002"} {
+		if err := store.Set(ctx, "testdb", "items", key, []byte(`{}`)); err != nil {
+			t.Fatalf("Failed to set value: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		opts           kv.ListOptions
+		expectedKeys   []string
+		expectedCursor string
+	}{
+		{"all keys", kv.ListOptions{}, []string{"card_001", "card_002", "card_003", "device_001"}, ""},
+		{"prefix", kv.ListOptions{Prefix: "card_"}, []string{"card_001", "card_002", "card_003"}, ""},
+		{"limit", kv.ListOptions{Prefix: "card_", Limit: 2}, []string{"card_001", "card_002"}, "card_002"},
+		{"cursor", kv.ListOptions{Prefix: "card_", Cursor: "card_002"}, []string{"card_003"}, ""},
+		{"cursor before prefix", kv.ListOptions{Prefix: "device_", Cursor: "card_001"}, []string{"device_001"}, ""},
+		{"no match", kv.ListOptions{Prefix: "zzz"}, []string{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := store.List(ctx, "testdb", "items", tt.opts)
+			if err != nil {
+				t.Fatalf("Failed to list keys: %v", err)
+			}
+			if !reflect.DeepEqual(result.Keys, tt.expectedKeys) {
+				t.Errorf("Expected keys %v, got %v", tt.expectedKeys, result.Keys)
+			}
+			if result.NextCursor != tt.expectedCursor {
+				t.Errorf("Expected cursor %q, got %q", tt.expectedCursor, result.NextCursor)
+			}
+		})
+	}
+
+	// Missing bucket returns an empty page
+	result, err := store.List(ctx, "testdb", "missing", kv.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(result.Keys) != 0 {
+		t.Errorf("Expected no keys, got %v", result.Keys)
+	}
+}
+
+func TestBBoltKV_ListNamespacesAndCollections(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.Ping(ctx); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	_ = store.Set(ctx, "hotel-b", "cards", "c1", []byte(`{}`))
+	_ = store.Set(ctx, "hotel-a", "devices", "d1", []byte(`{}`))
+	_ = store.Set(ctx, "hotel-a", "cards", "c1", []byte(`{}`))
+
+	namespaces, err := store.ListNamespaces(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list namespaces: %v", err)
+	}
+	// .ping.db must not be reported as a namespace
+	if !reflect.DeepEqual(namespaces, []string{"hotel-a", "hotel-b"}) {
+		t.Errorf("Expected namespaces [hotel-a hotel-b], got %v", namespaces)
+	}
+
+	collections, err := store.ListCollections(ctx, "hotel-a")
+	if err != nil {
+		t.Fatalf("Failed to list collections: %v", err)
+	}
+	if !reflect.DeepEqual(collections, []string{"cards", "devices"}) {
+		t.Errorf("Expected collections [cards devices], got %v", collections)
+	}
+
+	// Unknown namespace has no collections and no file is created
+	collections, err = store.ListCollections(ctx, "missing")
+	if err != nil {
+		t.Fatalf("Failed to list collections: %v", err)
+	}
+	if len(collections) != 0 {
+		t.Errorf("Expected no collections, got %v", collections)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "missing.db")); !os.IsNotExist(err) {
+		t.Errorf("Expected missing.db not to be created, got %v", err)
+	}
+}
+
+func TestBBoltKV_DropNamespace(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.Set(ctx, "hotel-a", "cards", "c1", []byte(`{}`))
+	_ = store.Set(ctx, "hotel-b", "cards", "c1", []byte(`{}`))
+
+	if err := store.DropNamespace(ctx, "hotel-a"); err != nil {
+		t.Fatalf("Failed to drop namespace: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "hotel-a.db")); !os.IsNotExist(err) {
+		t.Errorf("Expected hotel-a.db to be removed, got %v", err)
+	}
+	if _, exists := store.dbs["hotel-a"]; exists {
+		t.Error("Expected cached connection to be evicted")
+	}
+	if _, err := store.Get(ctx, "hotel-b", "cards", "c1"); err != nil {
+		t.Errorf("Expected other namespace to be intact, got %v", err)
+	}
+
+	// The namespace can be recreated after being dropped
+	if _, err := store.Get(ctx, "hotel-a", "cards", "c1"); err != kv.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	// Dropping a missing namespace is not an error
+	if err := store.DropNamespace(ctx, "missing"); err != nil {
+		t.Errorf("Expected no error dropping missing namespace, got %v", err)
+	}
+}
+
+func TestBBoltKV_DropCollection(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.Set(ctx, "hotel-a", "cards", "c1", []byte(`{}`))
+	_ = store.Set(ctx, "hotel-a", "devices", "d1", []byte(`{}`))
+
+	if err := store.DropCollection(ctx, "hotel-a", "cards"); err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+
+	if _, err := store.Get(ctx, "hotel-a", "cards", "c1"); err != kv.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+	if _, err := store.Get(ctx, "hotel-a", "devices", "d1"); err != nil {
+		t.Errorf("Expected other collection to be intact, got %v", err)
+	}
+
+	// Dropping missing collections and namespaces is not an error
+	if err := store.DropCollection(ctx, "hotel-a", "missing"); err != nil {
+		t.Errorf("Expected no error dropping missing collection, got %v", err)
+	}
+	if err := store.DropCollection(ctx, "missing", "cards"); err != nil {
+		t.Errorf("Expected no error dropping collection of missing namespace, got %v", err)
+	}
+}
+
+func TestBBoltKV_SetWithTTL(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.SetWithTTL(ctx, "testdb", "sessions", "expired", []byte(`"a"`), time.Millisecond); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if err := store.SetWithTTL(ctx, "testdb", "sessions", "live", []byte(`"b"`), time.Hour); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := store.Get(ctx, "testdb", "sessions", "expired"); err != kv.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for expired key, got %v", err)
+	}
+	if exists, _ := store.Exists(ctx, "testdb", "sessions", "expired"); exists {
+		t.Error("Expected expired key to not exist")
+	}
+	value, err := store.Get(ctx, "testdb", "sessions", "live")
+	if err != nil || !bytes.Equal(value, []byte(`"b"`)) {
+		t.Errorf("Expected live value, got %s (%v)", value, err)
+	}
+
+	result, err := store.List(ctx, "testdb", "sessions", kv.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if !reflect.DeepEqual(result.Keys, []string{"live"}) {
+		t.Errorf("Expected only live key to be listed, got %v", result.Keys)
+	}
+
+	// Overwriting without TTL clears the expiry
+	if err := store.SetWithTTL(ctx, "testdb", "sessions", "live", []byte(`"c"`), 0); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if _, err := store.Get(ctx, "testdb", "sessions", "live"); err != nil {
+		t.Errorf("Expected value without TTL, got %v", err)
+	}
+}
+
+func TestBBoltKV_SweepExpired(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.SetWithTTL(ctx, "testdb", "sessions", "expired", []byte(`"a"`), time.Minute)
+	_ = store.Set(ctx, "testdb", "sessions", "permanent", []byte(`"b"`))
+
+	store.sweepExpired(time.Now().Add(2 * time.Minute))
+
+	db, err := store.getDB("testdb")
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+	_ = db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte("sessions"))
+		if bucket.Get([]byte("expired")) != nil {
+			t.Error("Expected sweeper to remove expired key")
+		}
+		if bucket.Get([]byte("permanent")) == nil {
+			t.Error("Expected sweeper to keep permanent key")
+		}
+		return nil
+	})
+}
+
+func TestBBoltKV_LegacyValue(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	// Write a value the way it was stored before values had a header
+	db, err := store.getDB("testdb")
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+	legacy := []byte(`{"name":"Fire Dragon"}`)
+	_ = db.Update(func(tx *bbolt.Tx) error {
+		bucket, _ := tx.CreateBucketIfNotExists([]byte("cards"))
+		return bucket.Put([]byte("card_001"), legacy)
+	})
+
+	value, err := store.Get(context.Background(), "testdb", "cards", "card_001")
+	if err != nil {
+		t.Fatalf("Failed to get legacy value: %v", err)
+	}
+	if !bytes.Equal(value, legacy) {
+		t.Errorf("Expected legacy value %s, got %s", legacy, value)
+	}
+}
+
+func TestBBoltKV_CompareAndSet(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	// Expected version 0 creates the key only if it does not exist
+	rev, err := store.CompareAndSet(ctx, "testdb", "cards", "c1", 0, []byte(`"v1"`))
+	if err != nil || rev != 1 {
+		t.Fatalf("Expected revision 1, got %d (err %v)", rev, err)
+	}
+	if _, err := store.CompareAndSet(ctx, "testdb", "cards", "c1", 0, []byte(`"v1"`)); err != kv.ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+
+	// Stale version is rejected, current version is accepted
+	if _, err := store.CompareAndSet(ctx, "testdb", "cards", "c1", 5, []byte(`"v2"`)); err != kv.ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+	rev, err = store.CompareAndSet(ctx, "testdb", "cards", "c1", 1, []byte(`"v2"`))
+	if err != nil || rev != 2 {
+		t.Fatalf("Expected revision 2, got %d (err %v)", rev, err)
+	}
+
+	// Plain Set also bumps the revision
+	if err := store.Set(ctx, "testdb", "cards", "c1", []byte(`"v3"`)); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	value, rev, err := store.GetWithVersion(ctx, "testdb", "cards", "c1")
+	if err != nil || rev != 3 || string(value) != `"v3"` {
+		t.Errorf("Expected v3 at revision 3, got %s at %d (err %v)", value, rev, err)
+	}
+
+	// CompareAndDelete
+	if err := store.CompareAndDelete(ctx, "testdb", "cards", "c1", 2); err != kv.ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+	if err := store.CompareAndDelete(ctx, "testdb", "cards", "c1", 3); err != nil {
+		t.Errorf("Failed to delete: %v", err)
+	}
+	if err := store.CompareAndDelete(ctx, "testdb", "cards", "c1", 3); err != kv.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	// An expired key counts as missing, and its revisions are not handed out again
+	_ = store.SetWithTTL(ctx, "testdb", "cards", "c2", []byte(`"old"`), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if rev, err := store.CompareAndSet(ctx, "testdb", "cards", "c2", 0, []byte(`"new"`)); err != nil || rev != 5 {
+		t.Errorf("Expected revision 5 after expiry, got %d (err %v)", rev, err)
+	}
+}
+
+func TestBBoltKV_RevisionsSurviveDrop(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.Set(ctx, "testdb", "cards", "c1", []byte(`"v1"`))
+	_ = store.Set(ctx, "testdb", "cards", "c1", []byte(`"v2"`))
+
+	collections, _ := store.ListCollections(ctx, "testdb")
+	if !reflect.DeepEqual(collections, []string{"cards"}) {
+		t.Errorf("Expected the revision counter to be hidden, got %v", collections)
+	}
+
+	if err := store.DropNamespace(ctx, "testdb"); err != nil {
+		t.Fatalf("Failed to drop namespace: %v", err)
+	}
+	namespaces, _ := store.ListNamespaces(ctx)
+	if len(namespaces) != 0 {
+		t.Errorf("Expected no namespaces after drop, got %v", namespaces)
+	}
+
+	// The dropped namespace is recreated by a fresh store on the same directory
+	store.Close()
+	store, err = NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	if rev, err := store.CompareAndSet(ctx, "testdb", "cards", "c1", 0, []byte(`"v1"`)); err != nil || rev != 3 {
+		t.Errorf("Expected revision 3 after drop, got %d (err %v)", rev, err)
+	}
+	if err := store.Set(ctx, "testdb", string(revisionBucket), "revision", []byte(`1`)); !errors.Is(err, errReservedCollection) {
+		t.Errorf("Expected errReservedCollection, got %v", err)
+	}
+}
+
+func TestBBoltKV_Apply(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.Set(ctx, "hotel_a", "cards", "old", []byte(`"old"`))
+
+	// Successful transaction spanning two namespaces
+	err = store.Apply(ctx, []kv.Op{
+		{Type: kv.OpSet, Namespace: "hotel_a", Collection: "cards", Key: "c1", Value: []byte(`"a1"`)},
+		{Type: kv.OpSet, Namespace: "hotel_b", Collection: "cards", Key: "c1", Value: []byte(`"b1"`)},
+		{Type: kv.OpDelete, Namespace: "hotel_a", Collection: "cards", Key: "old"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to apply transaction: %v", err)
+	}
+	if value, _ := store.Get(ctx, "hotel_b", "cards", "c1"); string(value) != `"b1"` {
+		t.Errorf("Expected b1, got %s", value)
+	}
+	if exists, _ := store.Exists(ctx, "hotel_a", "cards", "old"); exists {
+		t.Error("Expected old key to be deleted")
+	}
+
+	// A failing operation rolls back every namespace
+	err = store.Apply(ctx, []kv.Op{
+		{Type: kv.OpSet, Namespace: "hotel_a", Collection: "cards", Key: "c2", Value: []byte(`"a2"`)},
+		{Type: kv.OpSet, Namespace: "hotel_b", Collection: "cards", Key: "c1", Value: []byte(`"b2"`)},
+		{Type: kv.OpDelete, Namespace: "hotel_a", Collection: "cards", Key: "missing"},
+	})
+	var txErr *kv.TxError
+	if !errors.As(err, &txErr) || txErr.Index != 2 || !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Fatalf("Expected TxError at index 2 wrapping ErrKeyNotFound, got %v", err)
+	}
+	if exists, _ := store.Exists(ctx, "hotel_a", "cards", "c2"); exists {
+		t.Error("Expected c2 to be rolled back")
+	}
+	if value, _ := store.Get(ctx, "hotel_b", "cards", "c1"); string(value) != `"b1"` {
+		t.Errorf("Expected b1 after rollback, got %s", value)
+	}
+
+	// Later operations see earlier ones on the same key
+	err = store.Apply(ctx, []kv.Op{
+		{Type: kv.OpSet, Namespace: "hotel_a", Collection: "cards", Key: "tmp", Value: []byte(`"t"`)},
+		{Type: kv.OpDelete, Namespace: "hotel_a", Collection: "cards", Key: "tmp"},
+	})
+	if err != nil {
+		t.Errorf("Expected set then delete to succeed, got %v", err)
+	}
+}
+
+func TestBBoltKV_GetMany(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.Set(ctx, "hotel_a", "cards", "c1", []byte(`"a1"`))
+	_ = store.Set(ctx, "hotel_b", "cards", "c1", []byte(`"b1"`))
+	_ = store.Set(ctx, "", "devices", "d1", []byte(`"d1"`))
+
+	results, err := store.GetMany(ctx, []kv.Key{
+		{Namespace: "hotel_a", Collection: "cards", Key: "c1"},
+		{Namespace: "hotel_a", Collection: "cards", Key: "missing"},
+		{Namespace: "hotel_b", Collection: "cards", Key: "c1"},
+		{Namespace: "hotel_b", Collection: "unknown", Key: "c1"},
+		{Namespace: "default", Collection: "devices", Key: "d1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get keys: %v", err)
+	}
+
+	expected := []kv.GetResult{
+		{Value: []byte(`"a1"`), Found: true},
+		{},
+		{Value: []byte(`"b1"`), Found: true},
+		{},
+		{Value: []byte(`"d1"`), Found: true},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, got %v", expected, results)
+	}
+}
+
+func TestBBoltKV_Watch(t *testing.T) {
+	tempDir := t.TempDir()
+	store, err := NewBBoltKV(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := store.Watch(ctx, "", "cards", "guest_")
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	_ = store.Set(ctx, "default", "cards", "staff_1", []byte(`"ignored"`))
+	_ = store.Set(ctx, "default", "devices", "guest_1", []byte(`"ignored"`))
+	_ = store.Set(ctx, "default", "cards", "guest_1", []byte(`"v1"`))
+	_ = store.Delete(ctx, "default", "cards", "guest_1")
+	_ = store.Apply(ctx, []kv.Op{{Type: kv.OpSet, Namespace: "default", Collection: "cards", Key: "guest_2", Value: []byte(`"v2"`)}})
+
+	expected := []kv.Event{
+		{Type: kv.EventSet, Namespace: "default", Collection: "cards", Key: "guest_1", Value: []byte(`"v1"`), Revision: 3},
+		{Type: kv.EventDelete, Namespace: "default", Collection: "cards", Key: "guest_1"},
+		{Type: kv.EventSet, Namespace: "default", Collection: "cards", Key: "guest_2", Value: []byte(`"v2"`), Revision: 4},
+	}
+	for _, want := range expected {
+		select {
+		case got := <-events:
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected event %+v, got %+v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %+v", want)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("Expected channel to be closed after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for channel to close")
+	}
+}
+
+func TestBBoltKV_WatchDrop(t *testing.T) {
+	store, err := NewBBoltKV(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_ = store.Set(ctx, "default", "cards", "guest_1", []byte(`"v1"`))
+	_ = store.Set(ctx, "default", "cards", "guest_2", []byte(`"v2"`))
+	_ = store.Set(ctx, "default", "cards", "staff_1", []byte(`"ignored"`))
+
+	events, err := store.Watch(ctx, "default", "cards", "guest_")
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	receive := func(count int) []string {
+		var keys []string
+		for range count {
+			select {
+			case event := <-events:
+				if event.Type != kv.EventDelete || event.Namespace != "default" || event.Collection != "cards" {
+					t.Errorf("Expected delete event, got %+v", event)
+				}
+				keys = append(keys, event.Key)
+			case <-time.After(time.Second):
+				t.Fatalf("Timed out waiting for delete event %d of %d", len(keys)+1, count)
+			}
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	if err := store.DropCollection(ctx, "default", "cards"); err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+	if keys := receive(2); !reflect.DeepEqual(keys, []string{"guest_1", "guest_2"}) {
+		t.Errorf("Expected deletes for guest_1 and guest_2, got %v", keys)
+	}
+
+	_ = store.Set(ctx, "default", "cards", "guest_3", []byte(`"v3"`))
+	if event := <-events; event.Type != kv.EventSet || event.Key != "guest_3" {
+		t.Errorf("Expected set event for guest_3, got %+v", event)
+	}
+	if err := store.DropNamespace(ctx, "default"); err != nil {
+		t.Fatalf("Failed to drop namespace: %v", err)
+	}
+	if keys := receive(1); !reflect.DeepEqual(keys, []string{"guest_3"}) {
+		t.Errorf("Expected delete for guest_3, got %v", keys)
+	}
+}
+
+func TestBBoltKV_RejectsPathTraversal(t *testing.T) {
+	parent := t.TempDir()
+	baseDir := filepath.Join(parent, "data")
+	victim := filepath.Join(parent, "victim.db")
+	if err := os.WriteFile(victim, []byte("keep"), 0o600); err != nil {
+		t.Fatalf("Failed to create victim file: %v", err)
+	}
+
+	store, err := NewBBoltKV(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create BBolt KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.Set(ctx, "../victim", "cards", "c1", []byte("{}")); !errors.Is(err, kv.ErrInvalidName) {
+		t.Errorf("Expected ErrInvalidName from Set, got %v", err)
+	}
+	if err := store.DropNamespace(ctx, "../victim"); !errors.Is(err, kv.ErrInvalidName) {
+		t.Errorf("Expected ErrInvalidName from DropNamespace, got %v", err)
+	}
+	if _, err := store.ListCollections(ctx, "../victim"); !errors.Is(err, kv.ErrInvalidName) {
+		t.Errorf("Expected ErrInvalidName from ListCollections, got %v", err)
+	}
+
+	data, err := os.ReadFile(victim)
+	if err != nil || string(data) != "keep" {
+		t.Errorf("Expected victim file to survive, got %q, %v", data, err)
+	}
+}
+
+func TestBBoltKV_Conformance(t *testing.T) {
+	kvtest.RunConformance(t, func(t *testing.T) kv.KV {
+		store, err := NewBBoltKV(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create BBolt KV: %v", err)
+		}
+		return store
+	})
+}
@@ -0,0 +1,88 @@
+package bbolt
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Stored values are prefixed with a small header so metadata such as expiry can live next to the value
+// Header: [magic 0x00][format 1][expires_at int64 unix nanos][revision uint64][value...]
+// Integers are big endian. Values written before the header existed are plain JSON
+// (never starting with 0x00), never expire and report revision 1
+const (
+	recordMagic      byte = 0x00
+	recordFormat     byte = 1
+	recordHeaderSize      = 18
+)
+
+// record is a decoded stored value
+type record struct {
+	// expiresAt is the expiry time in unix nanoseconds (0 = never expires)
+	expiresAt int64
+	// revision is taken from the namespace counter on every write (see nextRevision)
+	revision uint64
+	value    []byte
+}
+
+// newRecord creates a record for value, expiring after ttl (ttl <= 0 means never)
+func newRecord(value []byte, ttl time.Duration, revision uint64, now time.Time) record {
+	rec := record{value: value, revision: revision}
+	if ttl > 0 {
+		rec.expiresAt = now.Add(ttl).UnixNano()
+	}
+	return rec
+}
+
+// encode serializes the record with its header
+func (r record) encode() []byte {
+	buf := make([]byte, recordHeaderSize+len(r.value))
+	buf[0] = recordMagic
+	buf[1] = recordFormat
+	binary.BigEndian.PutUint64(buf[2:10], uint64(r.expiresAt))
+	binary.BigEndian.PutUint64(buf[10:18], r.revision)
+	copy(buf[recordHeaderSize:], r.value)
+	return buf
+}
+
+// decodeHeader parses the header of a stored value and returns the metadata and header size
+func decodeHeader(data []byte) (expiresAt int64, revision uint64, headerSize int) {
+	if len(data) >= recordHeaderSize && data[0] == recordMagic && data[1] == recordFormat {
+		return int64(binary.BigEndian.Uint64(data[2:10])), binary.BigEndian.Uint64(data[10:18]), recordHeaderSize
+	}
+	// Legacy value without header
+	return 0, 1, 0
+}
+
+// decodeRecord parses a stored value
+// The returned value is a copy and stays valid after the transaction ends
+func decodeRecord(data []byte) record {
+	expiresAt, revision, headerSize := decodeHeader(data)
+	return record{
+		expiresAt: expiresAt,
+		revision:  revision,
+		value:     append([]byte(nil), data[headerSize:]...),
+	}
+}
+
+// ttl returns the remaining lifetime of the record at now (0 = never expires)
+func (r record) ttl(now time.Time) time.Duration {
+	if r.expiresAt == 0 {
+		return 0
+	}
+	return time.Duration(r.expiresAt - now.UnixNano())
+}
+
+// isExpired reports whether a stored value has expired at now without copying it
+func isExpired(data []byte, now time.Time) bool {
+	expiresAt, _, _ := decodeHeader(data)
+	return expiresAt != 0 && now.UnixNano() >= expiresAt
+}
+
+// currentRevision returns the revision of a stored value, or 0 if it is missing or expired
+func currentRevision(data []byte, now time.Time) uint64 {
+	if data == nil || isExpired(data, now) {
+		return 0
+	}
+	_, revision, _ := decodeHeader(data)
+	return revision
+}
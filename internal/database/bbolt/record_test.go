@@ -0,0 +1,74 @@
+package bbolt
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRecord_EncodeDecode(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		value         []byte
+		ttl           time.Duration
+		expectExpired bool
+	}{
+		{"no ttl", []byte(`{"a":1}`), 0, false},
+		{"future expiry", []byte(`{"a":1}`), time.Hour, false},
+		{"empty value", []byte{}, time.Hour, false},
+		{"negative ttl never expires", []byte(`"x"`), -time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := newRecord(tt.value, tt.ttl, 7, now).encode()
+			decoded := decodeRecord(data)
+
+			if !bytes.Equal(decoded.value, tt.value) {
+				t.Errorf("Expected value %q, got %q", tt.value, decoded.value)
+			}
+			if decoded.revision != 7 {
+				t.Errorf("Expected revision 7, got %d", decoded.revision)
+			}
+			if isExpired(data, now) != tt.expectExpired {
+				t.Errorf("Expected expired=%v", tt.expectExpired)
+			}
+		})
+	}
+}
+
+func TestRecord_Expiry(t *testing.T) {
+	now := time.Now()
+	data := newRecord([]byte(`"x"`), time.Minute, 1, now).encode()
+
+	if isExpired(data, now.Add(59*time.Second)) {
+		t.Error("Expected record to be live before expiry")
+	}
+	if !isExpired(data, now.Add(time.Minute)) {
+		t.Error("Expected record to be expired at expiry time")
+	}
+}
+
+func TestRecord_LegacyValue(t *testing.T) {
+	// Values written before the header existed are plain JSON
+	legacy := []byte(`{"name":"Fire Dragon"}`)
+
+	decoded := decodeRecord(legacy)
+	if !bytes.Equal(decoded.value, legacy) {
+		t.Errorf("Expected legacy value %q, got %q", legacy, decoded.value)
+	}
+	if decoded.expiresAt != 0 {
+		t.Errorf("Expected legacy value to never expire, got %d", decoded.expiresAt)
+	}
+	if isExpired(legacy, time.Now().Add(100*365*24*time.Hour)) {
+		t.Error("Expected legacy value to never expire")
+	}
+	if decoded.revision != 1 {
+		t.Errorf("Expected legacy value to report revision 1, got %d", decoded.revision)
+	}
+	if currentRevision(nil, time.Now()) != 0 {
+		t.Error("Expected missing value to report revision 0")
+	}
+}
@@ -0,0 +1,132 @@
+package bbolt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.etcd.io/bbolt"
+)
+
+// Revisions come from a counter shared by every key of a namespace, so a key that is deleted or
+// expires and is written again continues above its old revisions instead of restarting at 1
+// The counter lives in a reserved bucket of the namespace file. Dropping a namespace removes
+// that file, so its last revision is kept in <baseDir>/.revisions.db and seeds the counter
+// when the namespace is created again
+var (
+	revisionBucket = []byte("\x00revision")
+	revisionKey    = []byte("revision")
+	droppedBucket  = []byte("dropped")
+)
+
+// droppedRevisionsFile holds the last revision of dropped namespaces; hidden files are not namespaces
+const droppedRevisionsFile = ".revisions.db"
+
+// errReservedCollection is returned when a write targets the bucket holding the revision counter
+var errReservedCollection = errors.New("collection name is reserved")
+
+// isReserved reports whether a bucket holds internal data rather than a collection
+func isReserved(collection []byte) bool {
+	return string(collection) == string(revisionBucket)
+}
+
+// nextRevision advances the namespace counter past current (the revision of the key being written)
+// and returns the new revision
+// Namespace files written before the counter existed start from their keys' revisions
+func nextRevision(tx *bbolt.Tx, current uint64) (uint64, error) {
+	bucket, err := tx.CreateBucketIfNotExists(revisionBucket)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create revision bucket: %w", err)
+	}
+
+	revision := max(readCounter(bucket), current) + 1
+	if err := writeCounter(bucket, revision); err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+// readCounter returns the revision stored in bucket (0 if none)
+func readCounter(bucket *bbolt.Bucket) uint64 {
+	if data := bucket.Get(revisionKey); len(data) == 8 {
+		return binary.BigEndian.Uint64(data)
+	}
+	return 0
+}
+
+// writeCounter stores revision in bucket
+func writeCounter(bucket *bbolt.Bucket, revision uint64) error {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], revision)
+	return bucket.Put(revisionKey, buf[:])
+}
+
+// namespaceRevision returns the last revision handed out in a namespace file
+func namespaceRevision(db *bbolt.DB) (uint64, error) {
+	var revision uint64
+	err := db.View(func(tx *bbolt.Tx) error {
+		if bucket := tx.Bucket(revisionBucket); bucket != nil {
+			revision = readCounter(bucket)
+		}
+		return nil
+	})
+	return revision, err
+}
+
+// seedRevision starts the counter of a newly created namespace file above the revisions the
+// namespace used before it was dropped (caller holds b.mu)
+func (b *BBoltKV) seedRevision(namespace string, db *bbolt.DB) error {
+	path := filepath.Join(b.baseDir, droppedRevisionsFile)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	meta, err := bbolt.Open(path, 0o600, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer meta.Close() //nolint:errcheck // Read-only use
+
+	var revision uint64
+	err = meta.View(func(tx *bbolt.Tx) error {
+		if bucket := tx.Bucket(droppedBucket); bucket != nil {
+			if data := bucket.Get([]byte(namespace)); len(data) == 8 {
+				revision = binary.BigEndian.Uint64(data)
+			}
+		}
+		return nil
+	})
+	if err != nil || revision == 0 {
+		return err
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(revisionBucket)
+		if err != nil {
+			return err
+		}
+		return writeCounter(bucket, max(readCounter(bucket), revision))
+	})
+}
+
+// saveDroppedRevision records the last revision of a namespace that is about to be dropped (caller holds b.mu)
+func (b *BBoltKV) saveDroppedRevision(namespace string, revision uint64) error {
+	path := filepath.Join(b.baseDir, droppedRevisionsFile)
+	meta, err := bbolt.Open(path, 0o600, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	err = meta.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(droppedBucket)
+		if err != nil {
+			return err
+		}
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], revision)
+		return bucket.Put([]byte(namespace), buf[:])
+	})
+	return errors.Join(err, meta.Close())
+}
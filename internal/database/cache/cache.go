@@ -0,0 +1,473 @@
+// Package cache provides a read-through cache in front of a kv.KV backend
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"commander/internal/kv"
+)
+
+// Default limits used when Options leaves them unset
+const (
+	DefaultMaxEntries  = 10000
+	DefaultMaxBytes    = 64 << 20
+	DefaultTTL         = 30 * time.Second
+	DefaultNegativeTTL = 5 * time.Second
+)
+
+// Options configures a Cache
+type Options struct {
+	// MaxEntries bounds the number of cached keys (DefaultMaxEntries if <= 0)
+	MaxEntries int
+
+	// MaxBytes bounds the size of cached keys and values (DefaultMaxBytes if <= 0)
+	MaxBytes int64
+
+	// TTL is how long a value is served from the cache (DefaultTTL if <= 0)
+	TTL time.Duration
+
+	// NegativeTTL is how long a "not found" is served from the cache (0 = not cached)
+	NegativeTTL time.Duration
+
+	// RedisURI enables a second tier shared by every instance using the same Redis (empty = in-process only)
+	// The instances also announce their writes on it, so the others drop those keys from their LRU
+	RedisURI string
+}
+
+// Stats reports how well the cache is doing
+type Stats struct {
+	// Hits counts lookups answered by either tier, NegativeHits and RedisHits are subsets of it
+	Hits         uint64 `json:"hits"`
+	NegativeHits uint64 `json:"negative_hits"`
+	RedisHits    uint64 `json:"redis_hits"`
+	// Misses counts lookups read from the backend
+	Misses uint64 `json:"misses"`
+	// HitRatio is Hits / (Hits + Misses), 0 before the first lookup
+	HitRatio float64 `json:"hit_ratio"`
+
+	// Evictions counts entries dropped to stay within the limits
+	Evictions  uint64 `json:"evictions"`
+	Entries    int    `json:"entries"`
+	Bytes      int64  `json:"bytes"`
+	MaxEntries int    `json:"max_entries"`
+	MaxBytes   int64  `json:"max_bytes"`
+}
+
+// Cache is a kv.KV that serves Get, GetMany and Exists from an in-process LRU, optionally backed by a
+// shared Redis tier, and reads through to the backend on a miss. Writes go to the backend and then
+// drop the affected keys from both tiers. Versioned reads, List and Watch always use the backend
+//
+// Values written with a TTL are not cached and always read from the backend. Writes made by another
+// instance become visible after at most TTL (NegativeTTL for keys that did not exist), or right away
+// when the instances share a Redis (see Options.RedisURI)
+type Cache struct {
+	store kv.Store
+	redis *redisTier
+	bus   *InvalidationBus
+
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu    sync.Mutex
+	local *lru
+	// generation increases on every invalidation; a read only fills the cache if it did not change
+	// meanwhile, so a value read before a concurrent write is never cached after it
+	generation uint64
+
+	hits         atomic.Uint64
+	negativeHits atomic.Uint64
+	redisHits    atomic.Uint64
+	misses       atomic.Uint64
+}
+
+// New wraps store with a read-through cache
+// store must implement kv.Store so the cache keeps every capability of the backend
+func New(store kv.KV, opts Options) (*Cache, error) {
+	backend, ok := store.(kv.Store)
+	if !ok {
+		return nil, fmt.Errorf("cache: backend %T does not implement every KV capability", store)
+	}
+
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultMaxEntries
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	if opts.NegativeTTL < 0 {
+		opts.NegativeTTL = 0
+	}
+
+	c := &Cache{
+		store:       backend,
+		ttl:         opts.TTL,
+		negativeTTL: opts.NegativeTTL,
+		now:         time.Now,
+		local:       newLRU(opts.MaxEntries, opts.MaxBytes),
+	}
+	if opts.RedisURI != "" {
+		tier, err := newRedisTier(opts.RedisURI)
+		if err != nil {
+			return nil, err
+		}
+		c.redis = tier
+
+		bus, err := NewInvalidationBus(opts.RedisURI, invalidationChannel, c.dropLocal)
+		if err != nil {
+			_ = tier.close()
+			return nil, err
+		}
+		c.bus = bus
+	}
+	return c, nil
+}
+
+// Unwrap returns the cached backend
+func (c *Cache) Unwrap() kv.KV {
+	return c.store
+}
+
+// Stats returns the current counters
+func (c *Cache) Stats() Stats {
+	stats := Stats{
+		Hits:         c.hits.Load(),
+		NegativeHits: c.negativeHits.Load(),
+		RedisHits:    c.redisHits.Load(),
+		Misses:       c.misses.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats.Evictions = c.local.evictions
+	stats.Entries = c.local.order.Len()
+	stats.Bytes = c.local.bytes
+	stats.MaxEntries = c.local.maxEntries
+	stats.MaxBytes = c.local.maxBytes
+	return stats
+}
+
+// Get retrieves a value from the cache, or from the backend on a miss
+func (c *Cache) Get(ctx context.Context, namespace, collection, key string) ([]byte, error) {
+	entryKey := cacheKey(namespace, collection, key)
+	if value, found, ok := c.lookup(ctx, entryKey); ok {
+		if !found {
+			return nil, kv.ErrKeyNotFound
+		}
+		return value, nil
+	}
+
+	// GetMany reports the TTL of the key, which decides whether it may be cached
+	state := c.beforeRead(ctx)
+	results, err := c.store.GetMany(ctx, []kv.Key{{Namespace: namespace, Collection: collection, Key: key}})
+	if err != nil {
+		return nil, err
+	}
+	c.fill(ctx, state, entryKey, results[0])
+	if !results[0].Found {
+		return nil, kv.ErrKeyNotFound
+	}
+	return results[0].Value, nil
+}
+
+// GetMany retrieves keys from the cache and reads the misses from the backend in one call
+func (c *Cache) GetMany(ctx context.Context, keys []kv.Key) ([]kv.GetResult, error) {
+	results := make([]kv.GetResult, len(keys))
+	var missing []kv.Key
+	var missingIndex []int
+	for i, k := range keys {
+		if value, found, ok := c.lookup(ctx, cacheKey(k.Namespace, k.Collection, k.Key)); ok {
+			results[i] = kv.GetResult{Value: value, Found: found}
+			continue
+		}
+		missing = append(missing, k)
+		missingIndex = append(missingIndex, i)
+	}
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	state := c.beforeRead(ctx)
+	fetched, err := c.store.GetMany(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range fetched {
+		k := missing[i]
+		c.fill(ctx, state, cacheKey(k.Namespace, k.Collection, k.Key), result)
+		results[missingIndex[i]] = result
+	}
+	return results, nil
+}
+
+// Exists reports a cached lookup, or asks the backend on a miss
+func (c *Cache) Exists(ctx context.Context, namespace, collection, key string) (bool, error) {
+	if _, found, ok := c.lookup(ctx, cacheKey(namespace, collection, key)); ok {
+		return found, nil
+	}
+	return c.store.Exists(ctx, namespace, collection, key)
+}
+
+// Set stores a value in the backend and drops it from the cache
+func (c *Cache) Set(ctx context.Context, namespace, collection, key string, value []byte) error {
+	defer c.invalidate(ctx, cacheKey(namespace, collection, key))
+	return c.store.Set(ctx, namespace, collection, key, value)
+}
+
+// SetWithTTL stores an expiring value in the backend and drops it from the cache
+func (c *Cache) SetWithTTL(ctx context.Context, namespace, collection, key string, value []byte, ttl time.Duration) error {
+	defer c.invalidate(ctx, cacheKey(namespace, collection, key))
+	return c.store.SetWithTTL(ctx, namespace, collection, key, value, ttl)
+}
+
+// Delete removes a key from the backend and the cache
+func (c *Cache) Delete(ctx context.Context, namespace, collection, key string) error {
+	defer c.invalidate(ctx, cacheKey(namespace, collection, key))
+	return c.store.Delete(ctx, namespace, collection, key)
+}
+
+// GetWithVersion reads from the backend, since the version is used for a following conditional write
+func (c *Cache) GetWithVersion(ctx context.Context, namespace, collection, key string) ([]byte, uint64, error) {
+	return c.store.GetWithVersion(ctx, namespace, collection, key)
+}
+
+// CompareAndSet writes through to the backend and drops the key from the cache
+func (c *Cache) CompareAndSet(ctx context.Context, namespace, collection, key string, expectedVersion uint64, value []byte) (uint64, error) {
+	defer c.invalidate(ctx, cacheKey(namespace, collection, key))
+	return c.store.CompareAndSet(ctx, namespace, collection, key, expectedVersion, value)
+}
+
+// CompareAndDelete deletes through the backend and drops the key from the cache
+func (c *Cache) CompareAndDelete(ctx context.Context, namespace, collection, key string, expectedVersion uint64) error {
+	defer c.invalidate(ctx, cacheKey(namespace, collection, key))
+	return c.store.CompareAndDelete(ctx, namespace, collection, key, expectedVersion)
+}
+
+// Apply runs the transaction on the backend and drops every written key from the cache
+func (c *Cache) Apply(ctx context.Context, ops []kv.Op) error {
+	keys := make([]string, len(ops))
+	for i, op := range ops {
+		keys[i] = cacheKey(op.Namespace, op.Collection, op.Key)
+	}
+	defer c.invalidate(ctx, keys...)
+	return c.store.Apply(ctx, ops)
+}
+
+// List returns keys from the backend
+func (c *Cache) List(ctx context.Context, namespace, collection string, opts kv.ListOptions) (*kv.ListResult, error) {
+	return c.store.List(ctx, namespace, collection, opts)
+}
+
+// ListNamespaces returns the namespaces of the backend
+func (c *Cache) ListNamespaces(ctx context.Context) ([]string, error) {
+	return c.store.ListNamespaces(ctx)
+}
+
+// ListCollections returns the collections of a namespace in the backend
+func (c *Cache) ListCollections(ctx context.Context, namespace string) ([]string, error) {
+	return c.store.ListCollections(ctx, namespace)
+}
+
+// DropNamespace removes a namespace from the backend and the cache
+func (c *Cache) DropNamespace(ctx context.Context, namespace string) error {
+	defer c.invalidatePrefix(ctx, kv.NormalizeNamespace(namespace)+"\x00")
+	return c.store.DropNamespace(ctx, namespace)
+}
+
+// DropCollection removes a collection from the backend and the cache
+func (c *Cache) DropCollection(ctx context.Context, namespace, collection string) error {
+	defer c.invalidatePrefix(ctx, cacheKey(namespace, collection, ""))
+	return c.store.DropCollection(ctx, namespace, collection)
+}
+
+// Watch streams changes from the backend
+func (c *Cache) Watch(ctx context.Context, namespace, collection, prefix string) (<-chan kv.Event, error) {
+	return c.store.Watch(ctx, namespace, collection, prefix)
+}
+
+// Ping checks the backend
+func (c *Cache) Ping(ctx context.Context) error {
+	return c.store.Ping(ctx)
+}
+
+// Close closes the shared tier and the backend
+func (c *Cache) Close() error {
+	var redisErr error
+	if c.redis != nil {
+		redisErr = errors.Join(c.bus.Close(), c.redis.close())
+	}
+	return errors.Join(c.store.Close(), redisErr)
+}
+
+// cacheKey joins the parts with NUL bytes, which keeps keys of different collections apart
+// even when the parts themselves contain separators
+func cacheKey(namespace, collection, key string) string {
+	return kv.NormalizeNamespace(namespace) + "\x00" + collection + "\x00" + key
+}
+
+// lookup returns a copy of the cached value and whether the key existed; ok is false on a miss
+func (c *Cache) lookup(ctx context.Context, key string) (value []byte, found, ok bool) {
+	c.mu.Lock()
+	entry, ok := c.local.get(key, c.now())
+	if ok {
+		value, found = cloneBytes(entry.value), entry.found
+	}
+	generation := c.generation
+	c.mu.Unlock()
+	if ok {
+		c.recordHit(found)
+		return value, found, true
+	}
+
+	if c.redis != nil {
+		value, found, ttl, err := c.redis.get(ctx, key)
+		if err != nil {
+			log.Printf("[KVCache] Shared tier read failed: error=%v", err)
+		}
+		if ttl > 0 {
+			c.redisHits.Add(1)
+			c.recordHit(found)
+			c.addLocal(generation, key, value, found, ttl)
+			return cloneBytes(value), found, true
+		}
+	}
+
+	c.misses.Add(1)
+	return nil, false, false
+}
+
+// recordHit counts a lookup answered by the cache
+func (c *Cache) recordHit(found bool) {
+	c.hits.Add(1)
+	if !found {
+		c.negativeHits.Add(1)
+	}
+}
+
+// fillState records the invalidation counters of both tiers before a backend read
+type fillState struct {
+	generation uint64
+	epoch      int64
+	// shared is false if the shared tier epoch could not be read, which leaves the read out of that tier
+	shared bool
+}
+
+// beforeRead returns the invalidation counters to pass to fill after the backend read
+func (c *Cache) beforeRead(ctx context.Context) fillState {
+	c.mu.Lock()
+	state := fillState{generation: c.generation}
+	c.mu.Unlock()
+
+	if c.redis != nil {
+		epoch, err := c.redis.epoch(ctx)
+		if err != nil {
+			log.Printf("[KVCache] Shared tier read failed: error=%v", err)
+		} else {
+			state.epoch, state.shared = epoch, true
+		}
+	}
+	return state
+}
+
+// fill caches a backend read in both tiers unless a key was invalidated since state was taken
+// Keys written with a TTL are not cached, so they are never served after they expire
+func (c *Cache) fill(ctx context.Context, state fillState, key string, result kv.GetResult) {
+	if result.TTL > 0 {
+		return
+	}
+	ttl := c.ttl
+	if !result.Found {
+		ttl = c.negativeTTL
+	}
+	if !c.addLocal(state.generation, key, result.Value, result.Found, ttl) {
+		return
+	}
+
+	if c.redis != nil && state.shared {
+		if err := c.redis.set(ctx, key, result.Value, result.Found, ttl, state.epoch); err != nil {
+			log.Printf("[KVCache] Shared tier write failed: error=%v", err)
+		}
+	}
+}
+
+// addLocal stores a lookup in the LRU for ttl unless a key was invalidated since generation
+func (c *Cache) addLocal(generation uint64, key string, value []byte, found bool, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return false
+	}
+	c.local.add(&lruEntry{key: key, value: cloneBytes(value), found: found, expiresAt: c.now().Add(ttl)})
+	return true
+}
+
+// invalidate drops keys from both tiers and tells the other instances to drop them too
+// The shared tier is cleared even if the caller's context is already done, so it never keeps stale values
+func (c *Cache) invalidate(ctx context.Context, keys ...string) {
+	c.dropLocal(Invalidation{Keys: keys})
+
+	if c.redis != nil && len(keys) > 0 {
+		ctx = context.WithoutCancel(ctx)
+		if err := c.redis.delete(ctx, keys...); err != nil {
+			log.Printf("[KVCache] Shared tier invalidation failed: keys=%d, error=%v", len(keys), err)
+		}
+		c.announce(ctx, Invalidation{Keys: keys})
+	}
+}
+
+// invalidatePrefix drops every key starting with prefix from both tiers and the other instances
+func (c *Cache) invalidatePrefix(ctx context.Context, prefix string) {
+	c.dropLocal(Invalidation{Prefix: prefix})
+
+	if c.redis != nil {
+		ctx = context.WithoutCancel(ctx)
+		if err := c.redis.deletePrefix(ctx, prefix); err != nil {
+			log.Printf("[KVCache] Shared tier invalidation failed: prefix=%q, error=%v", prefix, err)
+		}
+		c.announce(ctx, Invalidation{Prefix: prefix})
+	}
+}
+
+// announce publishes an invalidation to the other instances sharing the Redis tier
+func (c *Cache) announce(ctx context.Context, inv Invalidation) {
+	if err := c.bus.Publish(ctx, inv); err != nil {
+		log.Printf("[KVCache] Invalidation broadcast failed: keys=%d, prefix=%q, error=%v", len(inv.Keys), inv.Prefix, err)
+	}
+}
+
+// dropLocal removes invalidated keys from the LRU, whether written here or announced by another instance
+func (c *Cache) dropLocal(inv Invalidation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for _, key := range inv.Keys {
+		c.local.remove(key)
+	}
+	if inv.Prefix != "" {
+		c.local.removePrefix(inv.Prefix)
+	}
+}
+
+// cloneBytes returns a copy of value, so callers cannot modify cached data
+func cloneBytes(value []byte) []byte {
+	if value == nil {
+		return nil
+	}
+	return append([]byte{}, value...)
+}
@@ -0,0 +1,437 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"commander/internal/database/memory"
+	"commander/internal/kv"
+	"commander/internal/kv/kvtest"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// countingKV counts the reads that reach the backend and runs onGet before answering one
+type countingKV struct {
+	*memory.MemoryKV
+	gets  int
+	onGet func()
+}
+
+func (c *countingKV) Get(ctx context.Context, namespace, collection, key string) ([]byte, error) {
+	c.gets++
+	if c.onGet != nil {
+		c.onGet()
+	}
+	return c.MemoryKV.Get(ctx, namespace, collection, key)
+}
+
+func (c *countingKV) GetMany(ctx context.Context, keys []kv.Key) ([]kv.GetResult, error) {
+	c.gets += len(keys)
+	if c.onGet != nil {
+		c.onGet()
+	}
+	return c.MemoryKV.GetMany(ctx, keys)
+}
+
+// newTestCache returns a cache over a counting memory backend
+func newTestCache(t *testing.T, opts Options) (*Cache, *countingKV) {
+	t.Helper()
+	store, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create memory KV: %v", err)
+	}
+	backend := &countingKV{MemoryKV: store}
+	c, err := New(backend, opts)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c, backend
+}
+
+func TestCache_InterfaceImplementation(t *testing.T) {
+	var _ kv.Store = (*Cache)(nil)
+}
+
+func TestCache_Conformance(t *testing.T) {
+	kvtest.RunConformance(t, func(t *testing.T) kv.KV {
+		store, err := memory.NewMemoryKV("")
+		if err != nil {
+			t.Fatalf("Failed to create memory KV: %v", err)
+		}
+		c, err := New(store, Options{NegativeTTL: time.Minute})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		return c
+	})
+}
+
+func TestNew_RejectsIncompleteBackend(t *testing.T) {
+	store, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create memory KV: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	if _, err := New(struct{ kv.KV }{store}, Options{}); err == nil {
+		t.Error("Expected an error for a backend without the optional capabilities")
+	}
+}
+
+func TestCache_ReadThrough(t *testing.T) {
+	c, backend := newTestCache(t, Options{NegativeTTL: time.Minute})
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "", "cards", "c1", []byte(`{"n":1}`)); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		value, err := c.Get(ctx, "default", "cards", "c1")
+		if err != nil || string(value) != `{"n":1}` {
+			t.Fatalf("Expected cached value, got %s (err %v)", value, err)
+		}
+		value[0] = 'X' // Returned values are copies
+	}
+	if backend.gets != 1 {
+		t.Errorf("Expected 1 backend read, got %d", backend.gets)
+	}
+
+	// Not found is cached as well
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(ctx, "", "cards", "missing"); !errors.Is(err, kv.ErrKeyNotFound) {
+			t.Errorf("Expected ErrKeyNotFound, got %v", err)
+		}
+	}
+	if exists, err := c.Exists(ctx, "", "cards", "missing"); err != nil || exists {
+		t.Errorf("Expected missing key to be reported as absent, got %v (err %v)", exists, err)
+	}
+	if backend.gets != 2 {
+		t.Errorf("Expected 2 backend reads, got %d", backend.gets)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 4 || stats.NegativeHits != 2 || stats.Misses != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if stats.HitRatio < 0.66 || stats.HitRatio > 0.67 {
+		t.Errorf("Expected hit ratio of 4/6, got %f", stats.HitRatio)
+	}
+	if stats.Entries != 2 {
+		t.Errorf("Expected 2 cached entries, got %d", stats.Entries)
+	}
+}
+
+func TestCache_NegativeCachingDisabled(t *testing.T) {
+	c, backend := newTestCache(t, Options{})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, _ = c.Get(ctx, "", "cards", "missing")
+	}
+	if backend.gets != 2 {
+		t.Errorf("Expected every lookup of a missing key to reach the backend, got %d reads", backend.gets)
+	}
+}
+
+func TestCache_Invalidation(t *testing.T) {
+	c, _ := newTestCache(t, Options{NegativeTTL: time.Minute})
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		write    func() error
+		expected string // "" = not found
+	}{
+		{"Set", func() error { return c.Set(ctx, "", "cards", "c1", []byte("set")) }, "set"},
+		{"SetWithTTL", func() error { return c.SetWithTTL(ctx, "", "cards", "c1", []byte("ttl"), time.Hour) }, "ttl"},
+		{"Delete", func() error { return c.Delete(ctx, "", "cards", "c1") }, ""},
+		{"CompareAndSet", func() error {
+			_, err := c.CompareAndSet(ctx, "", "cards", "c1", 0, []byte("cas"))
+			return err
+		}, "cas"},
+		{"Apply", func() error {
+			return c.Apply(ctx, []kv.Op{{Type: kv.OpSet, Collection: "cards", Key: "c1", Value: []byte("tx")}})
+		}, "tx"},
+		{"DropCollection", func() error { return c.DropCollection(ctx, "", "cards") }, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _ = c.Get(ctx, "", "cards", "c1") // Cache the previous state
+			if err := tt.write(); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			value, err := c.Get(ctx, "", "cards", "c1")
+			if tt.expected == "" {
+				if !errors.Is(err, kv.ErrKeyNotFound) {
+					t.Errorf("Expected ErrKeyNotFound, got %s (err %v)", value, err)
+				}
+				return
+			}
+			if err != nil || string(value) != tt.expected {
+				t.Errorf("Expected %q, got %q (err %v)", tt.expected, value, err)
+			}
+		})
+	}
+}
+
+func TestCache_ConcurrentWriteNotCached(t *testing.T) {
+	c, backend := newTestCache(t, Options{})
+	ctx := context.Background()
+	if err := c.Set(ctx, "", "cards", "c1", []byte("old")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	// A write lands while the read is in flight; the old value must not be cached
+	backend.onGet = func() {
+		backend.onGet = nil
+		if err := c.Set(ctx, "", "cards", "c1", []byte("new")); err != nil {
+			t.Errorf("Failed to set value: %v", err)
+		}
+	}
+	_, _ = c.Get(ctx, "", "cards", "c1")
+
+	value, err := c.Get(ctx, "", "cards", "c1")
+	if err != nil || string(value) != "new" {
+		t.Errorf("Expected new value, got %q (err %v)", value, err)
+	}
+}
+
+func TestCache_Expiry(t *testing.T) {
+	c, backend := newTestCache(t, Options{TTL: time.Minute})
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	if err := c.Set(ctx, "", "cards", "c1", []byte("v")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	_, _ = c.Get(ctx, "", "cards", "c1")
+	now = now.Add(59 * time.Second)
+	_, _ = c.Get(ctx, "", "cards", "c1")
+	if backend.gets != 1 {
+		t.Errorf("Expected value to be cached within TTL, got %d backend reads", backend.gets)
+	}
+
+	now = now.Add(time.Second)
+	_, _ = c.Get(ctx, "", "cards", "c1")
+	if backend.gets != 2 {
+		t.Errorf("Expected expired entry to be read again, got %d backend reads", backend.gets)
+	}
+}
+
+func TestCache_ExpiringKeysNotCached(t *testing.T) {
+	c, backend := newTestCache(t, Options{TTL: time.Minute})
+	ctx := context.Background()
+	if err := c.SetWithTTL(ctx, "", "sessions", "s1", []byte("v"), 50*time.Millisecond); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	_, _ = c.Get(ctx, "", "sessions", "s1")
+	_, _ = c.GetMany(ctx, []kv.Key{{Collection: "sessions", Key: "s1"}})
+	if backend.gets != 2 || c.Stats().Entries != 0 {
+		t.Errorf("Expected every read of an expiring key to reach the backend, got %d reads and %+v", backend.gets, c.Stats())
+	}
+
+	// The key is gone from the backend once it expires, so it is never served stale
+	time.Sleep(100 * time.Millisecond)
+	if _, err := c.Get(ctx, "", "sessions", "s1"); !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Errorf("Expected expired key to be reported missing, got %v", err)
+	}
+}
+
+func TestCache_Eviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		entries int
+	}{
+		{"max entries", Options{MaxEntries: 2}, 2},
+		{"max bytes", Options{MaxBytes: 25}, 1}, // Each entry is 14 bytes ("default\x00c\x00kN" + value)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCache(t, tt.opts)
+			ctx := context.Background()
+			for _, key := range []string{"k1", "k2", "k3"} {
+				if err := c.Set(ctx, "", "c", key, []byte("v")); err != nil {
+					t.Fatalf("Failed to set value: %v", err)
+				}
+				_, _ = c.Get(ctx, "", "c", key)
+			}
+
+			stats := c.Stats()
+			if stats.Entries != tt.entries || stats.Evictions != uint64(3-tt.entries) {
+				t.Errorf("Expected %d entries after evictions, got %+v", tt.entries, stats)
+			}
+
+			// The most recently used key is kept
+			_, _ = c.Get(ctx, "", "c", "k3")
+			if c.Stats().Hits != 1 {
+				t.Error("Expected k3 to still be cached")
+			}
+		})
+	}
+}
+
+func TestCache_RedisTier(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	store, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create memory KV: %v", err)
+	}
+	backend := &countingKV{MemoryKV: store}
+	opts := Options{RedisURI: "redis://" + mr.Addr(), NegativeTTL: time.Minute}
+	first, err := New(backend, opts)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer func() { _ = first.Close() }()
+	second, err := New(backend, opts)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := first.Set(ctx, "", "cards", "c1", []byte("v1")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	_, _ = first.Get(ctx, "", "cards", "c1")
+	_, _ = first.Get(ctx, "", "cards", "missing")
+
+	// The second instance is served by the shared tier
+	value, err := second.Get(ctx, "", "cards", "c1")
+	if err != nil || string(value) != "v1" {
+		t.Errorf("Expected shared value, got %q (err %v)", value, err)
+	}
+	if _, err := second.Get(ctx, "", "cards", "missing"); !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Errorf("Expected shared not found, got %v", err)
+	}
+	if backend.gets != 2 || second.Stats().RedisHits != 2 {
+		t.Errorf("Expected 2 backend reads and 2 shared hits, got %d and %+v", backend.gets, second.Stats())
+	}
+
+	// A write drops the key from the shared tier
+	if err := first.Set(ctx, "", "cards", "c1", []byte("v2")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if mr.Exists(redisKeyPrefix + cacheKey("", "cards", "c1")) {
+		t.Error("Expected key to be removed from the shared tier")
+	}
+	if err := first.DropNamespace(ctx, ""); err != nil {
+		t.Fatalf("Failed to drop namespace: %v", err)
+	}
+	if keys := mr.Keys(); len(keys) != 1 || keys[0] != redisEpochKey {
+		t.Errorf("Expected only the epoch in the shared tier after dropping the namespace, got %v", keys)
+	}
+	_ = second.Close()
+}
+
+func TestCache_SharedFillAfterInvalidation(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	store, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create memory KV: %v", err)
+	}
+	opts := Options{RedisURI: "redis://" + mr.Addr(), TTL: time.Hour}
+	first, err := New(store, opts)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer func() { _ = first.Close() }()
+	second, err := New(store, opts)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer func() { _ = second.Close() }()
+	ctx := context.Background()
+
+	if err := store.Set(ctx, "", "cards", "c1", []byte("v1")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	// first reads v1, then second writes v2 before first fills the cache with v1
+	state := first.beforeRead(ctx)
+	results, err := store.GetMany(ctx, []kv.Key{{Collection: "cards", Key: "c1"}})
+	if err != nil {
+		t.Fatalf("Failed to read value: %v", err)
+	}
+	if err := second.Set(ctx, "", "cards", "c1", []byte("v2")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	// The invalidation announced by second may not have reached first yet
+	first.mu.Lock()
+	state.generation = first.generation
+	first.mu.Unlock()
+	first.fill(ctx, state, cacheKey("", "cards", "c1"), results[0])
+
+	if mr.Exists(redisKeyPrefix + cacheKey("", "cards", "c1")) {
+		t.Error("Expected the stale read to stay out of the shared tier")
+	}
+	if value, _ := second.Get(ctx, "", "cards", "c1"); string(value) != "v2" {
+		t.Errorf("Expected v2, got %q", value)
+	}
+}
+
+func TestCache_CrossInstanceInvalidation(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	store, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create memory KV: %v", err)
+	}
+	opts := Options{RedisURI: "redis://" + mr.Addr(), TTL: time.Hour}
+	first, err := New(store, opts)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer func() { _ = first.Close() }()
+	second, err := New(store, opts)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer func() { _ = second.Close() }()
+	ctx := context.Background()
+
+	if err := first.Set(ctx, "", "cards", "c1", []byte("v1")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if value, _ := second.Get(ctx, "", "cards", "c1"); string(value) != "v1" {
+		t.Fatalf("Expected v1, got %q", value)
+	}
+
+	// second holds c1 in its LRU; a write on first must reach it well before the TTL
+	if err := first.Set(ctx, "", "cards", "c1", []byte("v2")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for second.Stats().Entries != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the invalidation to reach the other instance")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if value, _ := second.Get(ctx, "", "cards", "c1"); string(value) != "v2" {
+		t.Errorf("Expected v2 after the invalidation, got %q", value)
+	}
+}
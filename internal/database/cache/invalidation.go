@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// invalidationChannel is the pub/sub channel the Cache announces its invalidations on
+const invalidationChannel = "commander:kvcache:invalidate"
+
+// Invalidation names cached entries that were written by another instance
+type Invalidation struct {
+	// Keys lists the invalidated entries
+	Keys []string `json:"keys,omitempty"`
+	// Prefix invalidates every entry starting with it ("" = none)
+	Prefix string `json:"prefix,omitempty"`
+	// Source identifies the publishing bus, so an instance ignores its own messages
+	Source string `json:"source"`
+}
+
+// InvalidationBus tells every instance subscribed to the same Redis channel which cached entries
+// another instance wrote, so in-process caches drop them right away instead of after their TTL
+// Delivery is best effort: messages published while an instance is disconnected from Redis are
+// lost, and that instance serves its entries until they expire
+type InvalidationBus struct {
+	client  *redis.Client
+	pubsub  *redis.PubSub
+	channel string
+	source  string
+	done    chan struct{}
+}
+
+// NewInvalidationBus connects to uri (redis://[:password@]host[:port][/db]), subscribes to channel
+// and calls handle with every invalidation published by another instance
+func NewInvalidationBus(uri, channel string, handle func(Invalidation)) (*InvalidationBus, error) {
+	opts, err := redis.ParseURL(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache Redis URI: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to generate invalidation source: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pubsub := client.Subscribe(ctx, channel)
+	// Wait for the subscription confirmation so no invalidation published after this returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to subscribe to cache invalidations: %w", err)
+	}
+
+	b := &InvalidationBus{
+		client:  client,
+		pubsub:  pubsub,
+		channel: channel,
+		source:  hex.EncodeToString(id[:]),
+		done:    make(chan struct{}),
+	}
+	go b.listen(handle)
+	return b, nil
+}
+
+// listen delivers invalidations from other instances until the bus is closed
+func (b *InvalidationBus) listen(handle func(Invalidation)) {
+	defer close(b.done)
+	for msg := range b.pubsub.Channel() {
+		var inv Invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+			log.Printf("[KVCache] Invalid invalidation message: channel=%s, error=%v", b.channel, err)
+			continue
+		}
+		if inv.Source == b.source {
+			continue
+		}
+		handle(inv)
+	}
+}
+
+// Publish announces an invalidation to the other instances
+func (b *InvalidationBus) Publish(ctx context.Context, inv Invalidation) error {
+	inv.Source = b.source
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, payload).Err()
+}
+
+// Close unsubscribes and closes the connection
+func (b *InvalidationBus) Close() error {
+	err := b.pubsub.Close()
+	<-b.done
+	if closeErr := b.client.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"time"
+)
+
+// lruEntry is a cached lookup result
+type lruEntry struct {
+	key string
+	// value is nil for a cached "not found"
+	value     []byte
+	found     bool
+	expiresAt time.Time
+}
+
+// size is the number of bytes charged against the byte limit
+func (e *lruEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// lru is a size-bounded least recently used map; callers hold the cache lock
+type lru struct {
+	maxEntries int
+	maxBytes   int64
+
+	order   *list.List // front = most recently used
+	entries map[string]*list.Element
+	bytes   int64
+
+	evictions uint64
+}
+
+// newLRU creates an empty lru; a limit <= 0 is not enforced
+func newLRU(maxEntries int, maxBytes int64) *lru {
+	return &lru{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the entry for key unless it is missing or expired at now
+func (l *lru) get(key string, now time.Time) (*lruEntry, bool) {
+	element, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !now.Before(entry.expiresAt) {
+		l.removeElement(element)
+		return nil, false
+	}
+	l.order.MoveToFront(element)
+	return entry, true
+}
+
+// add stores entry, evicting the least recently used entries to stay within the limits
+// An entry larger than the byte limit on its own is not stored
+func (l *lru) add(entry *lruEntry) {
+	if l.maxBytes > 0 && entry.size() > l.maxBytes {
+		l.remove(entry.key)
+		return
+	}
+
+	if element, ok := l.entries[entry.key]; ok {
+		l.bytes += entry.size() - element.Value.(*lruEntry).size()
+		element.Value = entry
+		l.order.MoveToFront(element)
+	} else {
+		l.entries[entry.key] = l.order.PushFront(entry)
+		l.bytes += entry.size()
+	}
+
+	for (l.maxEntries > 0 && l.order.Len() > l.maxEntries) || (l.maxBytes > 0 && l.bytes > l.maxBytes) {
+		l.removeElement(l.order.Back())
+		l.evictions++
+	}
+}
+
+// remove drops key if it is cached
+func (l *lru) remove(key string) {
+	if element, ok := l.entries[key]; ok {
+		l.removeElement(element)
+	}
+}
+
+// removePrefix drops every key starting with prefix
+func (l *lru) removePrefix(prefix string) {
+	for key, element := range l.entries {
+		if strings.HasPrefix(key, prefix) {
+			l.removeElement(element)
+		}
+	}
+}
+
+// removeElement unlinks element and updates the byte count
+func (l *lru) removeElement(element *list.Element) {
+	entry := l.order.Remove(element).(*lruEntry)
+	delete(l.entries, entry.key)
+	l.bytes -= entry.size()
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix prefixes every key of the shared tier, so it can live in a Redis used for other data
+const redisKeyPrefix = "commander:kvcache:"
+
+// redisEpochKey counts the invalidations of the shared tier; cache keys always contain a NUL separator,
+// so they never collide with it
+const redisEpochKey = redisKeyPrefix + "epoch"
+
+// setIfEpochScript caches a lookup only if no invalidation happened since the epoch the reader saw
+// KEYS[1] = entry, KEYS[2] = epoch; ARGV[1] = value, ARGV[2] = TTL in ms, ARGV[3] = epoch seen
+var setIfEpochScript = redis.NewScript(`
+if (redis.call("GET", KEYS[2]) or "0") ~= ARGV[3] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return 1
+`)
+
+// Markers stored as the first byte of a shared tier value
+const (
+	markerNotFound byte = 0
+	markerFound    byte = 1
+)
+
+// redisTier is the optional second tier shared by every instance using the same Redis
+type redisTier struct {
+	client *redis.Client
+}
+
+// newRedisTier connects to uri (redis://[:password@]host[:port][/db])
+func newRedisTier(uri string) (*redisTier, error) {
+	opts, err := redis.ParseURL(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache Redis URI: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to cache Redis: %w", err)
+	}
+	return &redisTier{client: client}, nil
+}
+
+// get returns the cached lookup for key and its remaining lifetime; ttl is 0 on a miss
+// The local tier keeps the entry no longer than that, so a shared entry never outlives its TTL
+func (r *redisTier) get(ctx context.Context, key string) (value []byte, found bool, ttl time.Duration, err error) {
+	pipe := r.client.Pipeline()
+	get := pipe.Get(ctx, redisKeyPrefix+key)
+	pttl := pipe.PTTL(ctx, redisKeyPrefix+key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, false, 0, err
+	}
+
+	raw, err := get.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, 0, nil
+	}
+	if err != nil || len(raw) == 0 || pttl.Val() <= 0 {
+		return nil, false, 0, err
+	}
+	if raw[0] == markerNotFound {
+		return nil, false, pttl.Val(), nil
+	}
+	return raw[1:], true, pttl.Val(), nil
+}
+
+// epoch returns the invalidation counter, read before a backend read whose result may be cached
+func (r *redisTier) epoch(ctx context.Context) (int64, error) {
+	epoch, err := r.client.Get(ctx, redisEpochKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return epoch, err
+}
+
+// set caches a lookup for ttl unless the tier was invalidated after epoch was read
+// Without the check, an instance could store a value it read just before another instance's write
+// and invalidation, and every instance would serve it until ttl
+func (r *redisTier) set(ctx context.Context, key string, value []byte, found bool, ttl time.Duration, epoch int64) error {
+	raw := []byte{markerNotFound}
+	if found {
+		raw = append([]byte{markerFound}, value...)
+	}
+	return setIfEpochScript.Run(ctx, r.client, []string{redisKeyPrefix + key, redisEpochKey},
+		raw, ttl.Milliseconds(), strconv.FormatInt(epoch, 10)).Err()
+}
+
+// delete drops keys from the tier
+// The epoch is raised first, so a read that started before the write can no longer fill the keys
+func (r *redisTier) delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = redisKeyPrefix + key
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, redisEpochKey)
+		pipe.Del(ctx, prefixed...)
+		return nil
+	})
+	return err
+}
+
+// deletePrefix drops every key starting with prefix, raising the epoch first like delete
+func (r *redisTier) deletePrefix(ctx context.Context, prefix string) error {
+	if err := r.client.Incr(ctx, redisEpochKey).Err(); err != nil {
+		return err
+	}
+
+	iter := r.client.Scan(ctx, 0, escapePattern(redisKeyPrefix+prefix)+"*", 1000).Iterator()
+	var batch []string
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == 1000 {
+			if err := r.client.Del(ctx, batch...).Err(); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return r.client.Del(ctx, batch...).Err()
+	}
+	return nil
+}
+
+// close closes the connection
+func (r *redisTier) close() error {
+	return r.client.Close()
+}
+
+// escapePattern escapes the glob characters of a SCAN MATCH pattern
+func escapePattern(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
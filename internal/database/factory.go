@@ -3,28 +3,134 @@ package database
 import (
 	"commander/internal/config"
 	"commander/internal/database/bbolt"
+	"commander/internal/database/cache"
+	"commander/internal/database/memory"
 	"commander/internal/database/mongodb"
 	"commander/internal/database/redis"
+	"commander/internal/database/tiered"
 	"commander/internal/kv"
 	"fmt"
+	"strings"
 )
 
 // NewKV creates a new KV store based on configuration
+// With KV_MIRROR_BACKEND the backend is mirrored to a second backend serving the reads (see tiered.TieredKV),
+// and with KV_CACHE_ENABLED the result is wrapped in a read-through cache (see cache.Cache)
+// The collections in Mirror.Exclude are left out of the mirror; with a MongoDB backend the caller
+// excludes the native card collections and mirrors them at the repository layer (see services.MirroredRepository)
 func NewKV(cfg *config.Config) (kv.KV, error) {
-	switch cfg.KV.BackendType {
+	return newKV(cfg, true)
+}
+
+// NewKVOffline is NewKV for an instance that starts while its backend may be unreachable
+// MongoDB and Redis backends are created without checking the server; their drivers connect in the background
+func NewKVOffline(cfg *config.Config) (kv.KV, error) {
+	return newKV(cfg, false)
+}
+
+// newKV builds the store of NewKV; verify checks that network backends are reachable
+func newKV(cfg *config.Config, verify bool) (kv.KV, error) {
+	store, err := newBackend(cfg.KV, verify)
+	if err != nil {
+		return nil, err
+	}
+
+	if mirror := cfg.KV.Mirror; mirror.BackendType != "" {
+		secondary, err := newBackend(config.KVConfig{
+			BackendType: mirror.BackendType,
+			MongoURI:    mirror.MongoURI,
+			RedisURI:    mirror.RedisURI,
+			BBoltPath:   mirror.BBoltPath,
+		}, verify)
+		if err != nil {
+			_ = store.Close()
+			return nil, fmt.Errorf("mirror backend (KV_MIRROR_*): %w", err)
+		}
+		mirrored, err := tiered.NewTieredKV(store, secondary, tiered.Options{
+			QueueSize:      mirror.QueueSize,
+			ResyncInterval: mirror.ResyncInterval,
+			Exclude:        mirror.Exclude,
+		})
+		if err != nil {
+			_ = store.Close()
+			_ = secondary.Close()
+			return nil, err
+		}
+		store = mirrored
+	}
+
+	if !cfg.KV.Cache.Enabled {
+		return store, nil
+	}
+
+	cached, err := cache.New(store, cache.Options{
+		MaxEntries:  cfg.KV.Cache.MaxEntries,
+		MaxBytes:    cfg.KV.Cache.MaxBytes,
+		TTL:         cfg.KV.Cache.TTL,
+		NegativeTTL: cfg.KV.Cache.NegativeTTL,
+		RedisURI:    cfg.KV.Cache.RedisURI,
+	})
+	if err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	return cached, nil
+}
+
+// NewKVFromURI creates a single backend from a URI, for tools that work with two backends at once
+// Supported forms: mongodb://..., mongodb+srv://..., redis://..., bbolt://<data dir>
+// and memory://[snapshot file]; the backend is not cached or mirrored
+func NewKVFromURI(uri string) (kv.KV, error) {
+	cfg := config.KVConfig{MongoURI: uri, RedisURI: uri}
+	switch {
+	case strings.HasPrefix(uri, "mongodb://"), strings.HasPrefix(uri, "mongodb+srv://"):
+		cfg.BackendType = config.BackendMongoDB
+	case strings.HasPrefix(uri, "redis://"):
+		cfg.BackendType = config.BackendRedis
+	case strings.HasPrefix(uri, "bbolt://"):
+		cfg.BackendType = config.BackendBBolt
+		cfg.BBoltPath = strings.TrimPrefix(uri, "bbolt://")
+		if cfg.BBoltPath == "" {
+			return nil, fmt.Errorf("bbolt URI needs a data directory (bbolt:///path/to/data)")
+		}
+	case strings.HasPrefix(uri, "memory://"):
+		cfg.BackendType = config.BackendMemory
+		cfg.MemorySnapshotPath = strings.TrimPrefix(uri, "memory://")
+	default:
+		return nil, fmt.Errorf("unsupported backend URI (expected mongodb://, redis://, bbolt:// or memory://)")
+	}
+
+	store, err := newBackend(cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// newBackend creates a single backend; verify checks that a MongoDB or Redis server is reachable
+func newBackend(cfg config.KVConfig, verify bool) (kv.KV, error) {
+	switch cfg.BackendType {
 	case config.BackendMongoDB:
-		if cfg.KV.MongoURI == "" {
+		if cfg.MongoURI == "" {
 			return nil, fmt.Errorf("MongoDB URI is required (set MONGODB_URI)")
 		}
-		return mongodb.NewMongoDBKV(cfg.KV.MongoURI)
+		if !verify {
+			return mongodb.OpenMongoDBKV(cfg.MongoURI)
+		}
+		return mongodb.NewMongoDBKV(cfg.MongoURI)
 	case config.BackendRedis:
-		if cfg.KV.RedisURI == "" {
+		if cfg.RedisURI == "" {
 			return nil, fmt.Errorf("Redis URI is required (set REDIS_URI)")
 		}
-		return redis.NewRedisKV(cfg.KV.RedisURI)
+		if !verify {
+			return redis.OpenRedisKV(cfg.RedisURI)
+		}
+		return redis.NewRedisKV(cfg.RedisURI)
 	case config.BackendBBolt:
-		return bbolt.NewBBoltKV(cfg.KV.BBoltPath)
+		return bbolt.NewBBoltKV(cfg.BBoltPath)
+	case config.BackendMemory:
+		return memory.NewMemoryKV(cfg.MemorySnapshotPath)
 	default:
-		return nil, fmt.Errorf("unsupported backend type: %s", cfg.KV.BackendType)
+		return nil, fmt.Errorf("unsupported backend type: %s", cfg.BackendType)
 	}
 }
@@ -0,0 +1,457 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"commander/internal/kv"
+)
+
+// entry is a stored value with its metadata
+type entry struct {
+	value []byte
+	// revision is taken from the namespace counter on every write
+	revision uint64
+	// expiresAt is zero if the value never expires
+	expiresAt time.Time
+}
+
+// expired reports whether the entry has expired at now
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// sweepInterval is how often expired keys are removed
+const sweepInterval = time.Minute
+
+// MemoryKV implements KV interface in process memory
+// namespace -> collection -> key, guarded by a single RWMutex
+// Data is lost on exit unless a snapshot path is configured
+//
+//nolint:revive // MemoryKV name is intentional to match package name
+type MemoryKV struct {
+	data map[string]map[string]map[string]*entry
+	mu   sync.RWMutex
+
+	// revisions is the last revision handed out per namespace; it survives deletes and drops
+	// so a recreated key never repeats a revision
+	revisions map[string]uint64
+
+	// snapshotPath is loaded on start and written on Close (empty = no snapshot)
+	snapshotPath string
+
+	// events fans out writes to Watch callers
+	events *kv.Broadcaster
+
+	// Background sweeper for expired keys
+	stopSweep chan struct{}
+	stopOnce  sync.Once
+	sweepDone sync.WaitGroup
+}
+
+// NewMemoryKV creates a new in-memory KV store
+// If snapshotPath is set and the file exists, its contents are loaded; Close writes the data back to it
+func NewMemoryKV(snapshotPath string) (*MemoryKV, error) {
+	m := &MemoryKV{
+		data:         make(map[string]map[string]map[string]*entry),
+		revisions:    make(map[string]uint64),
+		snapshotPath: snapshotPath,
+		events:       kv.NewBroadcaster(),
+		stopSweep:    make(chan struct{}),
+	}
+
+	if snapshotPath != "" {
+		if err := m.loadSnapshot(snapshotPath); err != nil {
+			return nil, fmt.Errorf("failed to load snapshot %s: %w", snapshotPath, err)
+		}
+	}
+
+	m.sweepDone.Add(1)
+	go m.runSweeper(sweepInterval)
+
+	return m, nil
+}
+
+// runSweeper periodically removes expired keys until stopSweep is closed
+func (m *MemoryKV) runSweeper(interval time.Duration) {
+	defer m.sweepDone.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopSweep:
+			return
+		case <-ticker.C:
+			m.sweepExpired(time.Now())
+		}
+	}
+}
+
+// sweepExpired deletes expired keys and reports them to watchers
+func (m *MemoryKV) sweepExpired(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for namespace, collections := range m.data {
+		for collection, keys := range collections {
+			for key, e := range keys {
+				if e.expired(now) {
+					m.remove(namespace, collection, key)
+				}
+			}
+		}
+	}
+}
+
+// lookup returns the live entry for a key, or nil (caller holds mu)
+func (m *MemoryKV) lookup(namespace, collection, key string, now time.Time) *entry {
+	e := m.data[namespace][collection][key]
+	if e == nil || e.expired(now) {
+		return nil
+	}
+	return e
+}
+
+// put stores a value under the next revision of the namespace (caller holds the write lock)
+func (m *MemoryKV) put(namespace, collection, key string, value []byte, ttl time.Duration, now time.Time) uint64 {
+	m.revisions[namespace]++
+	e := &entry{value: append([]byte(nil), value...), revision: m.revisions[namespace]}
+	if ttl > 0 {
+		e.expiresAt = now.Add(ttl)
+	}
+
+	if m.data[namespace] == nil {
+		m.data[namespace] = make(map[string]map[string]*entry)
+	}
+	if m.data[namespace][collection] == nil {
+		m.data[namespace][collection] = make(map[string]*entry)
+	}
+	m.data[namespace][collection][key] = e
+
+	m.events.Publish(kv.Event{
+		Type:       kv.EventSet,
+		Namespace:  namespace,
+		Collection: collection,
+		Key:        key,
+		Value:      append([]byte(nil), value...),
+		Revision:   e.revision,
+	})
+	return e.revision
+}
+
+// remove deletes a key and prunes empty maps (caller holds the write lock)
+func (m *MemoryKV) remove(namespace, collection, key string) {
+	delete(m.data[namespace][collection], key)
+	if len(m.data[namespace][collection]) == 0 {
+		delete(m.data[namespace], collection)
+	}
+	if len(m.data[namespace]) == 0 {
+		delete(m.data, namespace)
+	}
+
+	m.events.Publish(kv.Event{Type: kv.EventDelete, Namespace: namespace, Collection: collection, Key: key})
+}
+
+// Get retrieves a JSON value by key from namespace and collection
+func (m *MemoryKV) Get(ctx context.Context, namespace, collection, key string) ([]byte, error) {
+	value, _, err := m.GetWithVersion(ctx, namespace, collection, key)
+	return value, err
+}
+
+// GetWithVersion retrieves a JSON value and its revision from namespace and collection
+func (m *MemoryKV) GetWithVersion(ctx context.Context, namespace, collection, key string) ([]byte, uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e := m.lookup(namespace, collection, key, time.Now())
+	if e == nil {
+		return nil, 0, kv.ErrKeyNotFound
+	}
+	return append([]byte(nil), e.value...), e.revision, nil
+}
+
+// GetMany retrieves keys under a single read lock
+func (m *MemoryKV) GetMany(ctx context.Context, keys []kv.Key) ([]kv.GetResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	results := make([]kv.GetResult, len(keys))
+	for i, k := range keys {
+		if e := m.lookup(kv.NormalizeNamespace(k.Namespace), k.Collection, k.Key, now); e != nil {
+			results[i] = kv.GetResult{Value: append([]byte(nil), e.value...), Found: true}
+			if !e.expiresAt.IsZero() {
+				results[i].TTL = e.expiresAt.Sub(now)
+			}
+		}
+	}
+	return results, nil
+}
+
+// Set stores a JSON value by key in namespace and collection
+func (m *MemoryKV) Set(ctx context.Context, namespace, collection, key string, value []byte) error {
+	return m.SetWithTTL(ctx, namespace, collection, key, value, 0)
+}
+
+// SetWithTTL stores a JSON value that expires after ttl (ttl <= 0 means never)
+func (m *MemoryKV) SetWithTTL(ctx context.Context, namespace, collection, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.put(namespace, collection, key, value, ttl, time.Now())
+	return nil
+}
+
+// CompareAndSet stores a JSON value only if the current revision equals expectedVersion
+func (m *MemoryKV) CompareAndSet(ctx context.Context, namespace, collection, key string, expectedVersion uint64, value []byte) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var current uint64
+	if e := m.lookup(namespace, collection, key, now); e != nil {
+		current = e.revision
+	}
+	if current != expectedVersion {
+		return 0, kv.ErrVersionMismatch
+	}
+	return m.put(namespace, collection, key, value, 0, now), nil
+}
+
+// Delete removes a key-value pair from namespace and collection
+func (m *MemoryKV) Delete(ctx context.Context, namespace, collection, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lookup(namespace, collection, key, time.Now()) == nil {
+		return kv.ErrKeyNotFound
+	}
+	m.remove(namespace, collection, key)
+	return nil
+}
+
+// CompareAndDelete removes a key only if the current revision equals expectedVersion
+func (m *MemoryKV) CompareAndDelete(ctx context.Context, namespace, collection, key string, expectedVersion uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.lookup(namespace, collection, key, time.Now())
+	if e == nil {
+		return kv.ErrKeyNotFound
+	}
+	if e.revision != expectedVersion {
+		return kv.ErrVersionMismatch
+	}
+	m.remove(namespace, collection, key)
+	return nil
+}
+
+// Apply executes ops atomically under the write lock
+// Operations are checked against a view of the pending writes before anything is applied
+func (m *MemoryKV) Apply(ctx context.Context, ops []kv.Op) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	pending := make(map[kv.Key]bool)
+	for i, op := range ops {
+		k := kv.Key{Namespace: kv.NormalizeNamespace(op.Namespace), Collection: op.Collection, Key: op.Key}
+		switch op.Type {
+		case kv.OpSet:
+			pending[k] = true
+		case kv.OpDelete:
+			exists, seen := pending[k]
+			if !seen {
+				exists = m.lookup(k.Namespace, k.Collection, k.Key, now) != nil
+			}
+			if !exists {
+				return &kv.TxError{Index: i, Err: kv.ErrKeyNotFound}
+			}
+			pending[k] = false
+		default:
+			return &kv.TxError{Index: i, Err: fmt.Errorf("unknown operation type %d", op.Type)}
+		}
+	}
+
+	for _, op := range ops {
+		namespace := kv.NormalizeNamespace(op.Namespace)
+		if op.Type == kv.OpSet {
+			m.put(namespace, op.Collection, op.Key, op.Value, op.TTL, now)
+		} else {
+			m.remove(namespace, op.Collection, op.Key)
+		}
+	}
+	return nil
+}
+
+// Exists checks if a key exists in namespace and collection
+func (m *MemoryKV) Exists(ctx context.Context, namespace, collection, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.lookup(namespace, collection, key, time.Now()) != nil, nil
+}
+
+// List returns keys in namespace and collection, filtered by prefix and paged by cursor
+func (m *MemoryKV) List(ctx context.Context, namespace, collection string, opts kv.ListOptions) (*kv.ListResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+
+	m.mu.RLock()
+	now := time.Now()
+	keys := make([]string, 0, len(m.data[namespace][collection]))
+	for key, e := range m.data[namespace][collection] {
+		if !e.expired(now) && strings.HasPrefix(key, opts.Prefix) {
+			keys = append(keys, key)
+		}
+	}
+	m.mu.RUnlock()
+
+	return kv.Paginate(keys, opts), nil
+}
+
+// ListNamespaces returns namespaces that hold at least one key
+func (m *MemoryKV) ListNamespaces(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	namespaces := make([]string, 0, len(m.data))
+	for namespace := range m.data {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// ListCollections returns collections in a namespace that hold at least one key
+func (m *MemoryKV) ListCollections(ctx context.Context, namespace string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collections := make([]string, 0, len(m.data[namespace]))
+	for collection := range m.data[namespace] {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+	return collections, nil
+}
+
+// DropNamespace removes a namespace and every collection in it
+// The revision counter of the namespace is kept
+// Watchers receive a delete event for each key
+func (m *MemoryKV) DropNamespace(ctx context.Context, namespace string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for collection := range m.data[namespace] {
+		m.dropCollection(namespace, collection)
+	}
+	return nil
+}
+
+// DropCollection removes a collection and every key in it
+// Watchers receive a delete event for each key
+func (m *MemoryKV) DropCollection(ctx context.Context, namespace, collection string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	namespace = kv.NormalizeNamespace(namespace)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.dropCollection(namespace, collection)
+	return nil
+}
+
+// dropCollection removes every key of a collection, reporting each to watchers (caller holds m.mu)
+func (m *MemoryKV) dropCollection(namespace, collection string) {
+	for key := range m.data[namespace][collection] {
+		m.remove(namespace, collection, key)
+	}
+}
+
+// Watch reports writes to keys in namespace and collection starting with prefix
+// Expired keys are reported when the sweeper removes them
+func (m *MemoryKV) Watch(ctx context.Context, namespace, collection, prefix string) (<-chan kv.Event, error) {
+	return m.events.Subscribe(ctx, namespace, collection, prefix), nil
+}
+
+// Close stops the sweeper, closes watchers and writes the snapshot if a path is configured
+func (m *MemoryKV) Close() error {
+	m.stopOnce.Do(func() {
+		close(m.stopSweep)
+	})
+	m.sweepDone.Wait()
+	m.events.Close()
+
+	if m.snapshotPath == "" {
+		return nil
+	}
+	return m.Snapshot(m.snapshotPath)
+}
+
+// Ping always succeeds for the in-memory store
+func (m *MemoryKV) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
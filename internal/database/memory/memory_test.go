@@ -0,0 +1,333 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"commander/internal/kv"
+	"commander/internal/kv/kvtest"
+)
+
+func newTestStore(t *testing.T) *MemoryKV {
+	t.Helper()
+	store, err := NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create memory KV: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestMemoryKV_InterfaceImplementation(t *testing.T) {
+	var _ kv.KV = (*MemoryKV)(nil)
+	var _ kv.Lister = (*MemoryKV)(nil)
+	var _ kv.Dropper = (*MemoryKV)(nil)
+	var _ kv.Versioned = (*MemoryKV)(nil)
+	var _ kv.Transactional = (*MemoryKV)(nil)
+	var _ kv.BatchGetter = (*MemoryKV)(nil)
+	var _ kv.Watcher = (*MemoryKV)(nil)
+}
+
+func TestMemoryKV_CRUD(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	if err := store.Set(ctx, "", "cards", "c1", []byte(`{"name":"Fire Dragon"}`)); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	value, err := store.Get(ctx, "default", "cards", "c1")
+	if err != nil || string(value) != `{"name":"Fire Dragon"}` {
+		t.Errorf("Expected stored value, got %s (err %v)", value, err)
+	}
+
+	// Returned values are copies
+	value[0] = 'X'
+	if again, _ := store.Get(ctx, "default", "cards", "c1"); again[0] != '{' {
+		t.Error("Expected Get to return a copy")
+	}
+
+	if exists, _ := store.Exists(ctx, "default", "cards", "c1"); !exists {
+		t.Error("Expected key to exist")
+	}
+	if err := store.Delete(ctx, "default", "cards", "c1"); err != nil {
+		t.Errorf("Failed to delete: %v", err)
+	}
+	if err := store.Delete(ctx, "default", "cards", "c1"); err != kv.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+	if _, err := store.Get(ctx, "default", "cards", "c1"); err != kv.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestMemoryKV_SetWithTTL(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	_ = store.SetWithTTL(ctx, "default", "sessions", "expired", []byte(`"a"`), time.Millisecond)
+	_ = store.SetWithTTL(ctx, "default", "sessions", "live", []byte(`"b"`), time.Hour)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := store.Get(ctx, "default", "sessions", "expired"); err != kv.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for expired key, got %v", err)
+	}
+	result, _ := store.List(ctx, "default", "sessions", kv.ListOptions{})
+	if !reflect.DeepEqual(result.Keys, []string{"live"}) {
+		t.Errorf("Expected only live key, got %v", result.Keys)
+	}
+
+	store.sweepExpired(time.Now())
+	if _, ok := store.data["default"]["sessions"]["expired"]; ok {
+		t.Error("Expected sweeper to remove expired key")
+	}
+}
+
+func TestMemoryKV_ListAndDrop(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"b", "a", "c"} {
+		_ = store.Set(ctx, "hotel_a", "cards", key, []byte(`1`))
+	}
+	_ = store.Set(ctx, "hotel_a", "devices", "d1", []byte(`1`))
+	_ = store.Set(ctx, "hotel_b", "cards", "x", []byte(`1`))
+
+	page, _ := store.List(ctx, "hotel_a", "cards", kv.ListOptions{Limit: 2})
+	if !reflect.DeepEqual(page.Keys, []string{"a", "b"}) || page.NextCursor != "b" {
+		t.Errorf("Unexpected first page: %+v", page)
+	}
+
+	namespaces, _ := store.ListNamespaces(ctx)
+	if !reflect.DeepEqual(namespaces, []string{"hotel_a", "hotel_b"}) {
+		t.Errorf("Unexpected namespaces: %v", namespaces)
+	}
+	collections, _ := store.ListCollections(ctx, "hotel_a")
+	if !reflect.DeepEqual(collections, []string{"cards", "devices"}) {
+		t.Errorf("Unexpected collections: %v", collections)
+	}
+
+	_ = store.DropCollection(ctx, "hotel_a", "cards")
+	collections, _ = store.ListCollections(ctx, "hotel_a")
+	if !reflect.DeepEqual(collections, []string{"devices"}) {
+		t.Errorf("Unexpected collections after drop: %v", collections)
+	}
+	_ = store.DropNamespace(ctx, "hotel_a")
+	namespaces, _ = store.ListNamespaces(ctx)
+	if !reflect.DeepEqual(namespaces, []string{"hotel_b"}) {
+		t.Errorf("Unexpected namespaces after drop: %v", namespaces)
+	}
+}
+
+func TestMemoryKV_CompareAndSet(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	if rev, err := store.CompareAndSet(ctx, "default", "cards", "c1", 0, []byte(`"v1"`)); err != nil || rev != 1 {
+		t.Fatalf("Expected revision 1, got %d (err %v)", rev, err)
+	}
+	if _, err := store.CompareAndSet(ctx, "default", "cards", "c1", 0, []byte(`"v1"`)); err != kv.ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+	_ = store.Set(ctx, "default", "cards", "c1", []byte(`"v2"`))
+	if _, rev, _ := store.GetWithVersion(ctx, "default", "cards", "c1"); rev != 2 {
+		t.Errorf("Expected revision 2, got %d", rev)
+	}
+	if err := store.CompareAndDelete(ctx, "default", "cards", "c1", 1); err != kv.ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+	if err := store.CompareAndDelete(ctx, "default", "cards", "c1", 2); err != nil {
+		t.Errorf("Failed to delete: %v", err)
+	}
+}
+
+func TestMemoryKV_Apply(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	_ = store.Set(ctx, "default", "cards", "old", []byte(`"old"`))
+
+	err := store.Apply(ctx, []kv.Op{
+		{Type: kv.OpSet, Namespace: "default", Collection: "cards", Key: "c1", Value: []byte(`"v1"`)},
+		{Type: kv.OpDelete, Namespace: "default", Collection: "cards", Key: "missing"},
+	})
+	var txErr *kv.TxError
+	if !errors.As(err, &txErr) || txErr.Index != 1 {
+		t.Fatalf("Expected TxError at index 1, got %v", err)
+	}
+	if exists, _ := store.Exists(ctx, "default", "cards", "c1"); exists {
+		t.Error("Expected c1 to be rolled back")
+	}
+
+	err = store.Apply(ctx, []kv.Op{
+		{Type: kv.OpSet, Namespace: "default", Collection: "cards", Key: "c1", Value: []byte(`"v1"`)},
+		{Type: kv.OpDelete, Namespace: "default", Collection: "cards", Key: "old"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to apply transaction: %v", err)
+	}
+
+	results, _ := store.GetMany(ctx, []kv.Key{
+		{Namespace: "default", Collection: "cards", Key: "c1"},
+		{Namespace: "default", Collection: "cards", Key: "old"},
+	})
+	if !results[0].Found || results[1].Found {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+}
+
+func TestMemoryKV_Watch(t *testing.T) {
+	store := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, _ := store.Watch(ctx, "default", "cards", "")
+	_ = store.Set(ctx, "default", "cards", "c1", []byte(`"v1"`))
+
+	select {
+	case event := <-events:
+		if event.Type != kv.EventSet || event.Key != "c1" || event.Revision != 1 {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+}
+
+func TestMemoryKV_WatchDrop(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_ = store.Set(ctx, "default", "cards", "guest_1", []byte(`"v1"`))
+	_ = store.Set(ctx, "default", "cards", "guest_2", []byte(`"v2"`))
+	_ = store.Set(ctx, "default", "cards", "staff_1", []byte(`"ignored"`))
+
+	events, err := store.Watch(ctx, "default", "cards", "guest_")
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	receive := func(count int) []string {
+		var keys []string
+		for range count {
+			select {
+			case event := <-events:
+				if event.Type != kv.EventDelete || event.Namespace != "default" || event.Collection != "cards" {
+					t.Errorf("Expected delete event, got %+v", event)
+				}
+				keys = append(keys, event.Key)
+			case <-time.After(time.Second):
+				t.Fatalf("Timed out waiting for delete event %d of %d", len(keys)+1, count)
+			}
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	if err := store.DropCollection(ctx, "default", "cards"); err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+	if keys := receive(2); !reflect.DeepEqual(keys, []string{"guest_1", "guest_2"}) {
+		t.Errorf("Expected deletes for guest_1 and guest_2, got %v", keys)
+	}
+
+	_ = store.Set(ctx, "default", "cards", "guest_3", []byte(`"v3"`))
+	if event := <-events; event.Type != kv.EventSet || event.Key != "guest_3" {
+		t.Errorf("Expected set event for guest_3, got %+v", event)
+	}
+	if err := store.DropNamespace(ctx, "default"); err != nil {
+		t.Fatalf("Failed to drop namespace: %v", err)
+	}
+	if keys := receive(1); !reflect.DeepEqual(keys, []string{"guest_3"}) {
+		t.Errorf("Expected delete for guest_3, got %v", keys)
+	}
+}
+
+func TestMemoryKV_ConcurrentWriters(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_ = store.Set(ctx, "default", "counters", "shared", []byte(`1`))
+				_, _ = store.Get(ctx, "default", "counters", "shared")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, rev, _ := store.GetWithVersion(ctx, "default", "counters", "shared"); rev != 1000 {
+		t.Errorf("Expected revision 1000 after 1000 writes, got %d", rev)
+	}
+}
+
+func TestMemoryKV_Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots", "memory.json")
+	ctx := context.Background()
+
+	store, err := NewMemoryKV(path)
+	if err != nil {
+		t.Fatalf("Failed to create memory KV: %v", err)
+	}
+	_ = store.Set(ctx, "hotel_a", "cards", "c1", []byte(`{"room":"302"}`))
+	_ = store.Set(ctx, "hotel_a", "cards", "c1", []byte(`{"room":"303"}`))
+	_ = store.SetWithTTL(ctx, "hotel_a", "sessions", "s1", []byte(`"token"`), time.Hour)
+	_ = store.SetWithTTL(ctx, "hotel_a", "sessions", "gone", []byte(`"token"`), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close and snapshot: %v", err)
+	}
+
+	restored, err := NewMemoryKV(path)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	defer restored.Close()
+
+	value, rev, err := restored.GetWithVersion(ctx, "hotel_a", "cards", "c1")
+	if err != nil || rev != 2 || string(value) != `{"room":"303"}` {
+		t.Errorf("Expected restored value at revision 2, got %s at %d (err %v)", value, rev, err)
+	}
+	if exists, _ := restored.Exists(ctx, "hotel_a", "sessions", "s1"); !exists {
+		t.Error("Expected key with TTL to be restored")
+	}
+	if exists, _ := restored.Exists(ctx, "hotel_a", "sessions", "gone"); exists {
+		t.Error("Expected expired key to be skipped")
+	}
+
+	// The namespace counter is restored too, so revisions of the expired key are not handed out again
+	if rev, err := restored.CompareAndSet(ctx, "hotel_a", "sessions", "gone", 0, []byte(`"token"`)); err != nil || rev != 5 {
+		t.Errorf("Expected revision 5 after restore, got %d (err %v)", rev, err)
+	}
+}
+
+func TestMemoryKV_ContextCanceled(t *testing.T) {
+	store := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := store.Set(ctx, "default", "cards", "c1", []byte(`1`)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestMemoryKV_Conformance(t *testing.T) {
+	kvtest.RunConformance(t, func(t *testing.T) kv.KV {
+		store, err := NewMemoryKV("")
+		if err != nil {
+			t.Fatalf("Failed to create memory KV: %v", err)
+		}
+		return store
+	})
+}
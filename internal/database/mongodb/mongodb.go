@@ -3,6 +3,10 @@ package mongodb
 import (
 	"context"
 	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"commander/internal/kv"
@@ -23,17 +27,31 @@ type MongoDBKV struct {
 
 // NewMongoDBKV creates a new MongoDB KV store
 func NewMongoDBKV(uri string) (*MongoDBKV, error) {
+	store, err := OpenMongoDBKV(uri)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	clientOptions := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(ctx, clientOptions)
-	if err != nil {
+	// Ping to verify connection
+	if err := store.client.Ping(ctx, nil); err != nil {
+		_ = store.client.Disconnect(context.Background())
 		return nil, errors.Join(kv.ErrConnectionFailed, err)
 	}
+	return store, nil
+}
 
-	// Ping to verify connection
-	if err := client.Ping(ctx, nil); err != nil {
+// OpenMongoDBKV creates a MongoDB KV store without checking that the server is reachable
+// The driver connects in the background; operations fail until the server answers
+func OpenMongoDBKV(uri string) (*MongoDBKV, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	clientOptions := options.Client().ApplyURI(uri)
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
 		return nil, errors.Join(kv.ErrConnectionFailed, err)
 	}
 
@@ -50,67 +68,276 @@ func (m *MongoDBKV) getCollection(namespace, collection string) *mongo.Collectio
 	return db.Collection(collection)
 }
 
-// ensureIndex ensures unique index on key for the collection
+// ensureIndex ensures unique index on key and TTL index on expires_at for the collection
+// MongoDB removes expired documents in the background (roughly every 60 seconds),
+// so reads also filter on expires_at via notExpired
 func (m *MongoDBKV) ensureIndex(ctx context.Context, coll *mongo.Collection) error {
-	indexModel := mongo.IndexModel{
-		Keys:    bson.D{{Key: "key", Value: 1}},
-		Options: options.Index().SetUnique(true),
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
 	}
-	_, err := coll.Indexes().CreateOne(ctx, indexModel)
+	_, err := coll.Indexes().CreateMany(ctx, indexModels)
 	// Ignore errors if index already exists
 	return err
 }
 
+// notExpired returns a filter matching documents that have no expiry or expire after now
+func notExpired() bson.M {
+	return bson.M{
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": time.Now()}},
+		},
+	}
+}
+
+// liveKey returns a filter matching the unexpired document for key
+func liveKey(key string) bson.M {
+	filter := notExpired()
+	filter["key"] = key
+	return filter
+}
+
+// revisionIs returns a filter matching documents at the given revision
+// Documents written before revisions were tracked have no revision field and count as revision 1
+func revisionIs(revision uint64) bson.M {
+	if revision == 1 {
+		return bson.M{"$or": bson.A{
+			bson.M{"revision": int64(1)},
+			bson.M{"revision": bson.M{"$exists": false}},
+		}}
+	}
+	return bson.M{"revision": int64(revision)}
+}
+
+// metaDatabase holds the revision counter of every namespace; it is never reported as a namespace
+const metaDatabase = "commander_meta"
+
+// nextRevisions reserves count revisions from the counter of a namespace and returns the last one
+// The counter lives outside the namespace database, so deleted, expired and dropped keys never
+// hand their revisions out again
+func (m *MongoDBKV) nextRevisions(ctx context.Context, namespace string, count int) (uint64, error) {
+	var counter struct {
+		Revision int64 `bson:"revision"`
+	}
+	err := m.client.Database(metaDatabase).Collection("revisions").FindOneAndUpdate(ctx,
+		bson.M{"_id": namespace},
+		bson.M{"$inc": bson.M{"revision": int64(count)}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve revision: %w", err)
+	}
+	return uint64(counter.Revision), nil
+}
+
+// writeStages returns an update pipeline that stores value under revision and sets or clears expires_at
+// A document already above revision (written before the namespace counter existed) moves one past
+// its own revision instead; a legacy document without a revision counts as revision 1
+func writeStages(value []byte, ttl time.Duration, revision uint64) mongo.Pipeline {
+	fields := bson.D{{Key: "value", Value: string(value)}}
+	if ttl > 0 {
+		fields = append(fields, bson.E{Key: "expires_at", Value: time.Now().Add(ttl)})
+	}
+
+	stages := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"revision": bson.M{"$max": bson.A{
+			int64(revision),
+			bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$revision", bson.M{"$cond": bson.A{
+					bson.M{"$eq": bson.A{bson.M{"$type": "$value"}, "missing"}}, 0, 1,
+				}}}},
+				1,
+			}},
+		}}}}},
+		{{Key: "$set", Value: fields}},
+	}
+	if ttl <= 0 {
+		stages = append(stages, bson.D{{Key: "$unset", Value: "expires_at"}})
+	}
+	return stages
+}
+
 // Get retrieves a JSON value by key from namespace and collection
 func (m *MongoDBKV) Get(ctx context.Context, namespace, collection, key string) ([]byte, error) {
+	value, _, err := m.GetWithVersion(ctx, namespace, collection, key)
+	return value, err
+}
+
+// GetWithVersion retrieves a JSON value and its revision from namespace and collection
+func (m *MongoDBKV) GetWithVersion(ctx context.Context, namespace, collection, key string) ([]byte, uint64, error) {
 	namespace = kv.NormalizeNamespace(namespace)
 	coll := m.getCollection(namespace, collection)
 	_ = m.ensureIndex(ctx, coll) //nolint:errcheck // Best effort index creation
 
 	var doc struct {
-		Key   string `bson:"key"`
-		Value string `bson:"value"`
+		Key      string `bson:"key"`
+		Value    string `bson:"value"`
+		Revision *int64 `bson:"revision"`
 	}
 
-	err := coll.FindOne(ctx, bson.M{"key": key}).Decode(&doc)
+	err := coll.FindOne(ctx, liveKey(key)).Decode(&doc)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, kv.ErrKeyNotFound
+			return nil, 0, kv.ErrKeyNotFound
 		}
-		return nil, err
+		return nil, 0, err
 	}
 
-	return []byte(doc.Value), nil
+	revision := uint64(1)
+	if doc.Revision != nil {
+		revision = uint64(*doc.Revision)
+	}
+	return []byte(doc.Value), revision, nil
+}
+
+// GetMany retrieves keys with one $in query per namespace and collection
+func (m *MongoDBKV) GetMany(ctx context.Context, keys []kv.Key) ([]kv.GetResult, error) {
+	type target struct{ namespace, collection string }
+
+	// Group key positions by collection, keeping first-seen order
+	groups := make(map[target][]int)
+	order := make([]target, 0)
+	for i, k := range keys {
+		t := target{kv.NormalizeNamespace(k.Namespace), k.Collection}
+		if _, seen := groups[t]; !seen {
+			order = append(order, t)
+		}
+		groups[t] = append(groups[t], i)
+	}
+
+	results := make([]kv.GetResult, len(keys))
+	for _, t := range order {
+		positions := groups[t]
+		names := make([]string, len(positions))
+		for j, i := range positions {
+			names[j] = keys[i].Key
+		}
+
+		filter := notExpired()
+		filter["key"] = bson.M{"$in": names}
+		cursor, err := m.getCollection(t.namespace, t.collection).Find(ctx, filter,
+			options.Find().SetProjection(bson.M{"key": 1, "value": 1, "expires_at": 1, "_id": 0}))
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		found := make(map[string]kv.GetResult, len(names))
+		for cursor.Next(ctx) {
+			var doc struct {
+				Key       string     `bson:"key"`
+				Value     string     `bson:"value"`
+				ExpiresAt *time.Time `bson:"expires_at"`
+			}
+			if err := cursor.Decode(&doc); err != nil {
+				_ = cursor.Close(ctx) //nolint:errcheck // Best effort cursor cleanup
+				return nil, err
+			}
+			result := kv.GetResult{Value: []byte(doc.Value), Found: true}
+			if doc.ExpiresAt != nil {
+				result.TTL = doc.ExpiresAt.Sub(now)
+			}
+			found[doc.Key] = result
+		}
+		err = cursor.Err()
+		_ = cursor.Close(ctx) //nolint:errcheck // Best effort cursor cleanup
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range positions {
+			results[i] = found[keys[i].Key]
+		}
+	}
+
+	return results, nil
 }
 
 // Set stores a JSON value by key in namespace and collection
 func (m *MongoDBKV) Set(ctx context.Context, namespace, collection, key string, value []byte) error {
+	return m.SetWithTTL(ctx, namespace, collection, key, value, 0)
+}
+
+// SetWithTTL stores a JSON value that expires after ttl (ttl <= 0 means never)
+// The expiry is stored in expires_at, which is covered by a TTL index
+func (m *MongoDBKV) SetWithTTL(ctx context.Context, namespace, collection, key string, value []byte, ttl time.Duration) error {
 	namespace = kv.NormalizeNamespace(namespace)
 	coll := m.getCollection(namespace, collection)
 	_ = m.ensureIndex(ctx, coll) //nolint:errcheck // Best effort index creation
 
-	doc := bson.M{
-		"key":   key,
-		"value": string(value),
+	revision, err := m.nextRevisions(ctx, namespace, 1)
+	if err != nil {
+		return err
 	}
 
 	opts := options.Update().SetUpsert(true)
-	_, err := coll.UpdateOne(
+	_, err = coll.UpdateOne(
 		ctx,
 		bson.M{"key": key},
-		bson.M{"$set": doc},
+		writeStages(value, ttl, revision),
 		opts,
 	)
 
 	return err
 }
 
+// CompareAndSet stores a JSON value only if the current revision equals expectedVersion
+// Expected version 0 inserts the document and relies on the unique key index to reject duplicates
+func (m *MongoDBKV) CompareAndSet(ctx context.Context, namespace, collection, key string, expectedVersion uint64, value []byte) (uint64, error) {
+	namespace = kv.NormalizeNamespace(namespace)
+	coll := m.getCollection(namespace, collection)
+	_ = m.ensureIndex(ctx, coll) //nolint:errcheck // Best effort index creation
+
+	revision, err := m.nextRevisions(ctx, namespace, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	if expectedVersion == 0 {
+		// An expired document may still be present until the TTL monitor removes it
+		_, err := coll.DeleteOne(ctx, bson.M{"key": key, "expires_at": bson.M{"$lte": time.Now()}})
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = coll.InsertOne(ctx, bson.M{"key": key, "value": string(value), "revision": int64(revision)})
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return 0, kv.ErrVersionMismatch
+			}
+			return 0, err
+		}
+		return revision, nil
+	}
+
+	filter := bson.M{
+		"key":  key,
+		"$and": bson.A{notExpired(), revisionIs(expectedVersion)},
+	}
+	result, err := coll.UpdateOne(ctx, filter, writeStages(value, 0, revision))
+	if err != nil {
+		return 0, err
+	}
+	if result.MatchedCount == 0 {
+		return 0, kv.ErrVersionMismatch
+	}
+	// The filter pinned the current revision, so writeStages stored the larger of the two
+	return max(revision, expectedVersion+1), nil
+}
+
 // Delete removes a key-value pair from namespace and collection
 func (m *MongoDBKV) Delete(ctx context.Context, namespace, collection, key string) error {
 	namespace = kv.NormalizeNamespace(namespace)
 	coll := m.getCollection(namespace, collection)
 
-	result, err := coll.DeleteOne(ctx, bson.M{"key": key})
+	result, err := coll.DeleteOne(ctx, liveKey(key))
 	if err != nil {
 		return err
 	}
@@ -120,18 +347,237 @@ func (m *MongoDBKV) Delete(ctx context.Context, namespace, collection, key strin
 	return nil
 }
 
+// CompareAndDelete removes a key only if the current revision equals expectedVersion
+func (m *MongoDBKV) CompareAndDelete(ctx context.Context, namespace, collection, key string, expectedVersion uint64) error {
+	namespace = kv.NormalizeNamespace(namespace)
+	coll := m.getCollection(namespace, collection)
+
+	filter := bson.M{
+		"key":  key,
+		"$and": bson.A{notExpired(), revisionIs(expectedVersion)},
+	}
+	result, err := coll.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount > 0 {
+		return nil
+	}
+
+	// Nothing matched: tell a missing key apart from a revision conflict
+	exists, err := m.Exists(ctx, namespace, collection, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return kv.ErrVersionMismatch
+	}
+	return kv.ErrKeyNotFound
+}
+
+// Apply executes ops atomically in a multi-document transaction
+// Transactions require a replica set or sharded cluster; a standalone server returns kv.ErrTransactionsUnsupported
+func (m *MongoDBKV) Apply(ctx context.Context, ops []kv.Op) error {
+	// Indexes cannot be created inside a transaction, so prepare every collection first
+	prepared := make(map[string]bool)
+	sets := make(map[string]int)
+	for _, op := range ops {
+		namespace := kv.NormalizeNamespace(op.Namespace)
+		if name := namespace + "." + op.Collection; !prepared[name] {
+			_ = m.ensureIndex(ctx, m.getCollection(namespace, op.Collection)) //nolint:errcheck // Best effort index creation
+			prepared[name] = true
+		}
+		if op.Type == kv.OpSet {
+			sets[namespace]++
+		}
+	}
+
+	// Revisions are reserved outside the transaction; a rolled back transaction only leaves a gap
+	revisions := make([]uint64, len(ops))
+	for namespace, count := range sets {
+		last, err := m.nextRevisions(ctx, namespace, count)
+		if err != nil {
+			return err
+		}
+		next := last - uint64(count) + 1
+		for i, op := range ops {
+			if op.Type == kv.OpSet && kv.NormalizeNamespace(op.Namespace) == namespace {
+				revisions[i] = next
+				next++
+			}
+		}
+	}
+
+	session, err := m.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		for i, op := range ops {
+			coll := m.getCollection(kv.NormalizeNamespace(op.Namespace), op.Collection)
+			switch op.Type {
+			case kv.OpSet:
+				_, err := coll.UpdateOne(sessCtx, bson.M{"key": op.Key}, writeStages(op.Value, op.TTL, revisions[i]),
+					options.Update().SetUpsert(true))
+				if err != nil {
+					return nil, &kv.TxError{Index: i, Err: err}
+				}
+			case kv.OpDelete:
+				result, err := coll.DeleteOne(sessCtx, liveKey(op.Key))
+				if err != nil {
+					return nil, &kv.TxError{Index: i, Err: err}
+				}
+				if result.DeletedCount == 0 {
+					return nil, &kv.TxError{Index: i, Err: kv.ErrKeyNotFound}
+				}
+			default:
+				return nil, &kv.TxError{Index: i, Err: fmt.Errorf("unknown operation type %d", op.Type)}
+			}
+		}
+		return nil, nil
+	})
+	return transactionError(err)
+}
+
+// illegalOperationCode is the server error code for a transaction started on a standalone server
+const illegalOperationCode = 20
+
+// transactionError maps the error a standalone server returns for the first write of a transaction,
+// which Apply reports as a *kv.TxError of that operation, to kv.ErrTransactionsUnsupported
+func transactionError(err error) error {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == illegalOperationCode && strings.Contains(cmdErr.Message, "Transaction numbers") {
+		return fmt.Errorf("%w: %s", kv.ErrTransactionsUnsupported, cmdErr.Message)
+	}
+	return err
+}
+
 // Exists checks if a key exists in namespace and collection
 func (m *MongoDBKV) Exists(ctx context.Context, namespace, collection, key string) (bool, error) {
 	namespace = kv.NormalizeNamespace(namespace)
 	coll := m.getCollection(namespace, collection)
 
-	count, err := coll.CountDocuments(ctx, bson.M{"key": key})
+	count, err := coll.CountDocuments(ctx, liveKey(key))
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
+// List returns keys in namespace and collection using a find sorted on key
+// The prefix is matched with an anchored regex so the unique key index is used
+func (m *MongoDBKV) List(ctx context.Context, namespace, collection string, opts kv.ListOptions) (*kv.ListResult, error) {
+	namespace = kv.NormalizeNamespace(namespace)
+	coll := m.getCollection(namespace, collection)
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = kv.DefaultListLimit
+	}
+
+	keyFilter := bson.M{}
+	if opts.Prefix != "" {
+		keyFilter["$regex"] = "^" + regexp.QuoteMeta(opts.Prefix)
+	}
+	if opts.Cursor != "" {
+		keyFilter["$gt"] = opts.Cursor
+	}
+	filter := notExpired()
+	if len(keyFilter) > 0 {
+		filter["key"] = keyFilter
+	}
+
+	// Fetch one extra document to know whether another page exists
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "key", Value: 1}}).
+		SetLimit(int64(limit) + 1).
+		SetProjection(bson.M{"key": 1, "_id": 0})
+
+	cursor, err := coll.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx) //nolint:errcheck // Best effort cursor cleanup
+
+	result := &kv.ListResult{Keys: make([]string, 0, limit)}
+	for cursor.Next(ctx) {
+		var doc struct {
+			Key string `bson:"key"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		if len(result.Keys) == limit {
+			result.NextCursor = result.Keys[limit-1]
+			break
+		}
+		result.Keys = append(result.Keys, doc.Key)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// systemDatabases are MongoDB internal databases and metaDatabase, which are never reported as namespaces
+var systemDatabases = map[string]bool{
+	"admin":      true,
+	"config":     true,
+	"local":      true,
+	metaDatabase: true,
+}
+
+// ListNamespaces returns database names, excluding MongoDB system databases and metaDatabase
+func (m *MongoDBKV) ListNamespaces(ctx context.Context) ([]string, error) {
+	names, err := m.client.ListDatabaseNames(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := make([]string, 0, len(names))
+	for _, name := range names {
+		if !systemDatabases[name] {
+			namespaces = append(namespaces, name)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// ListCollections returns collection names in a namespace database, excluding system.* collections
+func (m *MongoDBKV) ListCollections(ctx context.Context, namespace string) ([]string, error) {
+	namespace = kv.NormalizeNamespace(namespace)
+	names, err := m.client.Database(namespace).ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]string, 0, len(names))
+	for _, name := range names {
+		if !strings.HasPrefix(name, "system.") {
+			collections = append(collections, name)
+		}
+	}
+	sort.Strings(collections)
+	return collections, nil
+}
+
+// DropNamespace drops the namespace database
+// Its revision counter in metaDatabase is kept, so a recreated namespace continues above its old revisions
+func (m *MongoDBKV) DropNamespace(ctx context.Context, namespace string) error {
+	namespace = kv.NormalizeNamespace(namespace)
+	return m.client.Database(namespace).Drop(ctx)
+}
+
+// DropCollection drops the collection from the namespace database
+func (m *MongoDBKV) DropCollection(ctx context.Context, namespace, collection string) error {
+	namespace = kv.NormalizeNamespace(namespace)
+	return m.getCollection(namespace, collection).Drop(ctx)
+}
+
 // Close closes the MongoDB connection
 func (m *MongoDBKV) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -2,9 +2,15 @@ package mongodb
 
 import (
 	"commander/internal/kv"
+	"commander/internal/kv/kvtest"
+	"context"
+	"errors"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Test NewMongoDBKV with invalid connection
@@ -36,6 +42,30 @@ func TestMongoDBKV_InterfaceImplementation(t *testing.T) {
 		// Verify the interface contract
 		var _ kv.KV = (*MongoDBKV)(nil)
 	})
+
+	t.Run("MongoDBKV implements Lister", func(t *testing.T) {
+		var _ kv.Lister = (*MongoDBKV)(nil)
+	})
+
+	t.Run("MongoDBKV implements Dropper", func(t *testing.T) {
+		var _ kv.Dropper = (*MongoDBKV)(nil)
+	})
+
+	t.Run("MongoDBKV implements Versioned", func(t *testing.T) {
+		var _ kv.Versioned = (*MongoDBKV)(nil)
+	})
+
+	t.Run("MongoDBKV implements Transactional", func(t *testing.T) {
+		var _ kv.Transactional = (*MongoDBKV)(nil)
+	})
+
+	t.Run("MongoDBKV implements BatchGetter", func(t *testing.T) {
+		var _ kv.BatchGetter = (*MongoDBKV)(nil)
+	})
+
+	t.Run("MongoDBKV implements Watcher", func(t *testing.T) {
+		var _ kv.Watcher = (*MongoDBKV)(nil)
+	})
 }
 
 // === MongoDBKV Method Validation Tests ===
@@ -98,6 +128,52 @@ func TestMongoDBKV_IndexManagement(t *testing.T) {
 	})
 }
 
+// === Revision Tests ===
+
+func TestRevisionIs(t *testing.T) {
+	t.Run("revision 1 also matches documents without a revision", func(t *testing.T) {
+		filter := revisionIs(1)
+		if _, ok := filter["$or"]; !ok {
+			t.Errorf("Expected $or filter for revision 1, got %v", filter)
+		}
+	})
+
+	t.Run("other revisions match exactly", func(t *testing.T) {
+		filter := revisionIs(3)
+		if filter["revision"] != int64(3) {
+			t.Errorf("Expected revision 3 filter, got %v", filter)
+		}
+	})
+}
+
+func TestWriteStages(t *testing.T) {
+	if stages := writeStages([]byte(`"v"`), 0, 1); len(stages) != 3 {
+		t.Errorf("Expected revision, value and unset stages, got %d", len(stages))
+	}
+	if stages := writeStages([]byte(`"v"`), time.Minute, 1); len(stages) != 2 {
+		t.Errorf("Expected revision and value stages, got %d", len(stages))
+	}
+}
+
+func TestTransactionError(t *testing.T) {
+	standalone := mongo.CommandError{Code: 20, Name: "IllegalOperation",
+		Message: "Transaction numbers are only allowed on a replica set member or mongos"}
+
+	err := transactionError(&kv.TxError{Index: 0, Err: standalone})
+	var txErr *kv.TxError
+	if !errors.Is(err, kv.ErrTransactionsUnsupported) || errors.As(err, &txErr) {
+		t.Errorf("Expected ErrTransactionsUnsupported not tied to an operation, got %v", err)
+	}
+
+	other := &kv.TxError{Index: 1, Err: kv.ErrKeyNotFound}
+	if err := transactionError(other); err != other {
+		t.Errorf("Expected other errors to be returned unchanged, got %v", err)
+	}
+	if err := transactionError(nil); err != nil {
+		t.Errorf("Expected nil, got %v", err)
+	}
+}
+
 // === CRUD Operations Tests ===
 
 func TestMongoDBKV_CRUDOperations(t *testing.T) {
@@ -265,3 +341,26 @@ func TestMongoDBKV_ErrorRecovery(t *testing.T) {
 //   }
 //   container, _ := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{...})
 //   // Get port and create MongoDBKV with container URI
+
+// Test the shared kv.KV contract against a local MongoDB (replica set not required)
+// Set MONGODB_TEST_URI to a disposable instance; the conformance namespaces are dropped between tests
+// CI sets it to a single-node replica set started in the test job
+func TestMongoDBKV_Conformance(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("Set MONGODB_TEST_URI to run against a local MongoDB instance")
+	}
+
+	kvtest.RunConformance(t, func(t *testing.T) kv.KV {
+		store, err := NewMongoDBKV(uri)
+		if err != nil {
+			t.Fatalf("Failed to create MongoDB KV: %v", err)
+		}
+		for _, namespace := range kvtest.Namespaces {
+			if err := store.DropNamespace(context.Background(), namespace); err != nil {
+				t.Fatalf("Failed to drop namespace %s: %v", namespace, err)
+			}
+		}
+		return store
+	})
+}
@@ -0,0 +1,131 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"commander/internal/kv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// watchedDocument is the subset of a stored document carried in change events
+type watchedDocument struct {
+	Key      string `bson:"key"`
+	Value    string `bson:"value"`
+	Revision *int64 `bson:"revision"`
+}
+
+// changeEvent is the subset of a change stream document used by Watch
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	// FullDocument is the document after an insert, update or replace
+	FullDocument *watchedDocument `bson:"fullDocument"`
+	// FullDocumentBeforeChange is the document before a delete (see enablePreImages)
+	FullDocumentBeforeChange *watchedDocument `bson:"fullDocumentBeforeChange"`
+}
+
+// Server error codes returned while enabling pre-images
+const (
+	namespaceNotFoundCode = 26
+	namespaceExistsCode   = 48
+)
+
+// Watch reports writes to keys in namespace and collection starting with prefix using a change stream
+// Change streams require a replica set or sharded cluster. Delete events only carry the document _id,
+// so Watch enables pre-images on the collection (MongoDB 6.0 or later) and reads the key of a deleted
+// document, including documents removed by the TTL monitor, from its pre-image. A delete whose pre-image
+// has already expired (see the server's changeStreamOptions.preAndPostImages.expireAfterSeconds) is
+// not reported. Dropping the collection or database ends the stream
+func (m *MongoDBKV) Watch(ctx context.Context, namespace, collection, prefix string) (<-chan kv.Event, error) {
+	namespace = kv.NormalizeNamespace(namespace)
+	coll := m.getCollection(namespace, collection)
+
+	if err := m.enablePreImages(ctx, namespace, collection); err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}}}}},
+	}
+	stream, err := coll.Watch(ctx, pipeline, options.ChangeStream().
+		SetFullDocument(options.WhenAvailable).
+		SetFullDocumentBeforeChange(options.WhenAvailable))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan kv.Event, kv.WatchBufferSize)
+	go func() {
+		defer close(events)
+		defer stream.Close(context.Background()) //nolint:errcheck // Best effort cleanup
+
+		for stream.Next(ctx) {
+			var change changeEvent
+			if err := stream.Decode(&change); err != nil {
+				continue
+			}
+
+			event := kv.Event{Namespace: namespace, Collection: collection}
+			switch change.OperationType {
+			case "delete":
+				doc := change.FullDocumentBeforeChange
+				if doc == nil || !strings.HasPrefix(doc.Key, prefix) {
+					continue
+				}
+				event.Type = kv.EventDelete
+				event.Key = doc.Key
+			default:
+				doc := change.FullDocument
+				if doc == nil || !strings.HasPrefix(doc.Key, prefix) {
+					continue
+				}
+				event.Type = kv.EventSet
+				event.Key = doc.Key
+				event.Value = []byte(doc.Value)
+				event.Revision = 1
+				if doc.Revision != nil {
+					event.Revision = uint64(*doc.Revision)
+				}
+			}
+
+			select {
+			case events <- event:
+			default:
+				// Watcher fell too far behind
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// enablePreImages turns on changeStreamPreAndPostImages for a collection, creating it if needed,
+// so delete events carry the document that was removed
+func (m *MongoDBKV) enablePreImages(ctx context.Context, namespace, collection string) error {
+	db := m.client.Database(namespace)
+	preImages := bson.M{"enabled": true}
+	collMod := func() error {
+		return db.RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: collection},
+			{Key: "changeStreamPreAndPostImages", Value: preImages},
+		}).Err()
+	}
+
+	err := collMod()
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != namespaceNotFoundCode {
+		return err
+	}
+
+	err = db.CreateCollection(ctx, collection, options.CreateCollection().SetChangeStreamPreAndPostImages(preImages))
+	if errors.As(err, &cmdErr) && cmdErr.Code == namespaceExistsCode {
+		// Created concurrently by a write or another watcher
+		return collMod()
+	}
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,8 +15,12 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// scanCount is the COUNT hint passed to SCAN
+const scanCount = 1000
+
 // RedisKV implements KV interface using Redis
 // Key format: <namespace>:<collection>:<key>
+// Each key holds the value as a plain string; revisions are kept in sidecar keys (see scripts.go)
 //
 //nolint:revive // RedisKV name is intentional to match package name
 type RedisKV struct {
@@ -30,6 +35,25 @@ type RedisKV struct {
 //   - redis://localhost:6379/0
 //   - redis://:password@localhost:6379/1
 func NewRedisKV(uri string) (*RedisKV, error) {
+	store, err := OpenRedisKV(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Test connection
+	if err := store.client.Ping(ctx).Err(); err != nil {
+		_ = store.client.Close()
+		return nil, errors.Join(kv.ErrConnectionFailed, err)
+	}
+	return store, nil
+}
+
+// OpenRedisKV creates a Redis KV store from URI without checking that the server is reachable
+// The client connects on first use; operations fail until the server answers
+func OpenRedisKV(uri string) (*RedisKV, error) {
 	if uri == "" {
 		return nil, fmt.Errorf("Redis URI is required")
 	}
@@ -69,14 +93,6 @@ func NewRedisKV(uri string) (*RedisKV, error) {
 		DB:       db,
 	})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Test connection
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, errors.Join(kv.ErrConnectionFailed, err)
-	}
-
 	return &RedisKV{
 		client: client,
 	}, nil
@@ -89,36 +105,239 @@ func (r *RedisKV) buildKey(namespace, collection, key string) string {
 	return fmt.Sprintf("%s:%s:%s", namespace, collection, key)
 }
 
+// metaPrefix starts the internal keys holding revisions, which are never listed as namespaces
+const metaPrefix = "\x00"
+
+// revisionKey returns the sidecar key holding the revision of a value key
+// Format: \x00rev:<namespace>:<collection>:<key>
+func revisionKey(redisKey string) string {
+	return metaPrefix + "rev:" + redisKey
+}
+
+// counterKey returns the key holding the last revision handed out in a namespace
+// Format: \x00revision:<namespace>
+func counterKey(namespace string) string {
+	return metaPrefix + "revision:" + kv.NormalizeNamespace(namespace)
+}
+
 // Get retrieves a JSON value by key from namespace and collection
 func (r *RedisKV) Get(ctx context.Context, namespace, collection, key string) ([]byte, error) {
+	value, _, err := r.GetWithVersion(ctx, namespace, collection, key)
+	return value, err
+}
+
+// GetWithVersion retrieves a JSON value and its revision from namespace and collection
+func (r *RedisKV) GetWithVersion(ctx context.Context, namespace, collection, key string) ([]byte, uint64, error) {
 	redisKey := r.buildKey(namespace, collection, key)
-	val, err := r.client.Get(ctx, redisKey).Result()
+	fields, err := r.client.MGet(ctx, redisKey, revisionKey(redisKey)).Result()
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return nil, kv.ErrKeyNotFound
+		return nil, 0, err
+	}
+
+	value, ok := fields[0].(string)
+	if !ok {
+		return nil, 0, kv.ErrKeyNotFound
+	}
+	return []byte(value), parseRevision(fields[1]), nil
+}
+
+// parseRevision reads a sidecar revision; a missing one means the value predates revisions (revision 1)
+func parseRevision(field interface{}) uint64 {
+	text, _ := field.(string)
+	revision, err := strconv.ParseUint(text, 10, 64)
+	if err != nil || revision == 0 {
+		return 1
+	}
+	return revision
+}
+
+// GetMany retrieves keys and their remaining TTLs in a single MULTI/EXEC round trip
+func (r *RedisKV) GetMany(ctx context.Context, keys []kv.Key) ([]kv.GetResult, error) {
+	if len(keys) == 0 {
+		return []kv.GetResult{}, nil
+	}
+
+	redisKeys := make([]string, len(keys))
+	for i, k := range keys {
+		redisKeys[i] = r.buildKey(k.Namespace, k.Collection, k.Key)
+	}
+	var values *redis.SliceCmd
+	ttls := make([]*redis.DurationCmd, len(keys))
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		values = pipe.MGet(ctx, redisKeys...)
+		for i, redisKey := range redisKeys {
+			ttls[i] = pipe.PTTL(ctx, redisKey)
 		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
-	return []byte(val), nil
+
+	results := make([]kv.GetResult, len(keys))
+	for i, value := range values.Val() {
+		if value, ok := value.(string); ok {
+			results[i] = kv.GetResult{Value: []byte(value), Found: true}
+			// PTTL is negative for keys without expiry
+			if ttl := ttls[i].Val(); ttl > 0 {
+				results[i].TTL = ttl
+			}
+		}
+	}
+	return results, nil
 }
 
 // Set stores a JSON value by key in namespace and collection
 func (r *RedisKV) Set(ctx context.Context, namespace, collection, key string, value []byte) error {
+	return r.SetWithTTL(ctx, namespace, collection, key, value, 0)
+}
+
+// SetWithTTL stores a JSON value that expires after ttl (ttl <= 0 means never)
+// Expiry is handled natively by Redis
+func (r *RedisKV) SetWithTTL(ctx context.Context, namespace, collection, key string, value []byte, ttl time.Duration) error {
+	_, err := r.set(ctx, namespace, collection, key, value, ttl, "")
+	return err
+}
+
+// CompareAndSet stores a JSON value only if the current revision equals expectedVersion
+func (r *RedisKV) CompareAndSet(ctx context.Context, namespace, collection, key string, expectedVersion uint64, value []byte) (uint64, error) {
+	return r.set(ctx, namespace, collection, key, value, 0, strconv.FormatUint(expectedVersion, 10))
+}
+
+// set runs setScript, maps its result and publishes the change to watchers
+func (r *RedisKV) set(ctx context.Context, namespace, collection, key string, value []byte, ttl time.Duration, expected string) (uint64, error) {
+	revision, err := setScript.Run(ctx, r.client, r.scriptKeys(namespace, collection, key),
+		value, max(ttl.Milliseconds(), 0), expected).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if revision < 0 {
+		return 0, kv.ErrVersionMismatch
+	}
+
+	r.publish(ctx, kv.Event{
+		Type:       kv.EventSet,
+		Namespace:  namespace,
+		Collection: collection,
+		Key:        key,
+		Value:      value,
+		Revision:   uint64(revision),
+	})
+	return uint64(revision), nil
+}
+
+// scriptKeys returns the keys setScript works on: the value, its revision and the namespace counter
+func (r *RedisKV) scriptKeys(namespace, collection, key string) []string {
 	redisKey := r.buildKey(namespace, collection, key)
-	return r.client.Set(ctx, redisKey, value, 0).Err()
+	return []string{redisKey, revisionKey(redisKey), counterKey(namespace)}
 }
 
-// Delete removes a key-value pair from namespace and collection
-func (r *RedisKV) Delete(ctx context.Context, namespace, collection, key string) error {
+// CompareAndDelete removes a key only if the current revision equals expectedVersion
+func (r *RedisKV) CompareAndDelete(ctx context.Context, namespace, collection, key string, expectedVersion uint64) error {
+	return r.delete(ctx, namespace, collection, key, strconv.FormatUint(expectedVersion, 10))
+}
+
+// delete runs deleteScript, maps its result and publishes the change to watchers
+func (r *RedisKV) delete(ctx context.Context, namespace, collection, key, expected string) error {
 	redisKey := r.buildKey(namespace, collection, key)
-	result := r.client.Del(ctx, redisKey)
-	if result.Err() != nil {
-		return result.Err()
+	result, err := deleteScript.Run(ctx, r.client, []string{redisKey, revisionKey(redisKey)}, expected).Int64()
+	if err != nil {
+		return err
 	}
-	if result.Val() == 0 {
+
+	switch {
+	case result == 0:
 		return kv.ErrKeyNotFound
+	case result < 0:
+		return kv.ErrVersionMismatch
+	default:
+		r.publish(ctx, kv.Event{Type: kv.EventDelete, Namespace: namespace, Collection: collection, Key: key})
+		return nil
 	}
-	return nil
+}
+
+// Delete removes a key-value pair and its revision from namespace and collection
+func (r *RedisKV) Delete(ctx context.Context, namespace, collection, key string) error {
+	return r.delete(ctx, namespace, collection, key, "")
+}
+
+// txMaxRetries is how often Apply retries when a watched key changes before EXEC
+const txMaxRetries = 5
+
+// Apply executes ops atomically in a MULTI/EXEC transaction
+// Every key is WATCHed first so deletes of missing keys can be rejected before anything is queued;
+// if another client modifies a watched key the transaction is retried
+func (r *RedisKV) Apply(ctx context.Context, ops []kv.Op) error {
+	keys := make([]string, len(ops))
+	for i, op := range ops {
+		if op.Type != kv.OpSet && op.Type != kv.OpDelete {
+			return &kv.TxError{Index: i, Err: fmt.Errorf("unknown operation type %d", op.Type)}
+		}
+		keys[i] = r.buildKey(op.Namespace, op.Collection, op.Key)
+	}
+
+	var revisions []*redis.Cmd
+	apply := func(tx *redis.Tx) error {
+		// Track keys written earlier in the batch so a later delete sees them
+		pending := make(map[string]bool)
+		for i, op := range ops {
+			if op.Type != kv.OpDelete {
+				pending[keys[i]] = true
+				continue
+			}
+
+			exists, seen := pending[keys[i]]
+			if !seen {
+				count, err := tx.Exists(ctx, keys[i]).Result()
+				if err != nil {
+					return err
+				}
+				exists = count > 0
+			}
+			if !exists {
+				return &kv.TxError{Index: i, Err: kv.ErrKeyNotFound}
+			}
+			pending[keys[i]] = false
+		}
+
+		revisions = make([]*redis.Cmd, len(ops))
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, op := range ops {
+				if op.Type == kv.OpDelete {
+					pipe.Del(ctx, keys[i], revisionKey(keys[i]))
+					continue
+				}
+				// EVAL rather than EVALSHA: a NOSCRIPT error would only surface at EXEC
+				revisions[i] = setScript.Eval(ctx, pipe, r.scriptKeys(op.Namespace, op.Collection, op.Key),
+					op.Value, max(op.TTL.Milliseconds(), 0), "")
+			}
+			return nil
+		})
+		return err
+	}
+
+	for range txMaxRetries {
+		err := r.client.Watch(ctx, apply, keys...)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for i, op := range ops {
+			event := kv.Event{Type: kv.EventDelete, Namespace: op.Namespace, Collection: op.Collection, Key: op.Key}
+			if op.Type == kv.OpSet {
+				revision, _ := revisions[i].Int64() //nolint:errcheck // EXEC succeeded, so the script returned a revision
+				event.Type = kv.EventSet
+				event.Value = op.Value
+				event.Revision = uint64(revision)
+			}
+			r.publish(ctx, event)
+		}
+		return nil
+	}
+	return fmt.Errorf("transaction aborted after %d retries: %w", txMaxRetries, redis.TxFailedErr)
 }
 
 // Exists checks if a key exists in namespace and collection
@@ -131,6 +350,130 @@ func (r *RedisKV) Exists(ctx context.Context, namespace, collection, key string)
 	return count > 0, nil
 }
 
+// List returns keys in namespace and collection using SCAN on <namespace>:<collection>:*
+// SCAN is unordered, so matching keys are collected and sorted before paging
+func (r *RedisKV) List(ctx context.Context, namespace, collection string, opts kv.ListOptions) (*kv.ListResult, error) {
+	keyPrefix := r.buildKey(namespace, collection, "")
+	pattern := escapePattern(keyPrefix) + escapePattern(opts.Prefix) + "*"
+
+	keys := make([]string, 0)
+	iter := r.client.Scan(ctx, 0, pattern, scanCount).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), keyPrefix))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return kv.Paginate(keys, opts), nil
+}
+
+// ListNamespaces returns the distinct namespace prefixes of all keys
+func (r *RedisKV) ListNamespaces(ctx context.Context) ([]string, error) {
+	return r.scanSegments(ctx, "*", 0)
+}
+
+// ListCollections returns the distinct collection prefixes of keys in a namespace
+func (r *RedisKV) ListCollections(ctx context.Context, namespace string) ([]string, error) {
+	namespace = kv.NormalizeNamespace(namespace)
+	return r.scanSegments(ctx, escapePattern(namespace)+":*", 1)
+}
+
+// scanSegments scans keys matching pattern and returns the distinct values
+// of segment index (0 = namespace, 1 = collection) in sorted order
+// Keys that do not have the <namespace>:<collection>:<key> shape and revision keys are ignored
+func (r *RedisKV) scanSegments(ctx context.Context, pattern string, index int) ([]string, error) {
+	seen := make(map[string]struct{})
+	iter := r.client.Scan(ctx, 0, pattern, scanCount).Iterator()
+	for iter.Next(ctx) {
+		if strings.HasPrefix(iter.Val(), metaPrefix) {
+			continue
+		}
+		parts := strings.SplitN(iter.Val(), ":", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		seen[parts[index]] = struct{}{}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	segments := make([]string, 0, len(seen))
+	for segment := range seen {
+		segments = append(segments, segment)
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// DropNamespace deletes every key with the <namespace>: prefix and their revisions
+// The namespace counter is kept, so a recreated namespace continues above its old revisions
+// Watchers receive a delete event for each key
+func (r *RedisKV) DropNamespace(ctx context.Context, namespace string) error {
+	namespace = kv.NormalizeNamespace(namespace)
+	return r.dropPrefix(ctx, namespace+":")
+}
+
+// DropCollection deletes every key with the <namespace>:<collection>: prefix and their revisions
+// Watchers of the collection receive a delete event for each key
+func (r *RedisKV) DropCollection(ctx context.Context, namespace, collection string) error {
+	return r.dropPrefix(ctx, r.buildKey(namespace, collection, ""))
+}
+
+// dropPrefix deletes the values starting with prefix, reporting each to watchers, then their revision keys
+func (r *RedisKV) dropPrefix(ctx context.Context, prefix string) error {
+	if err := r.deleteMatching(ctx, escapePattern(prefix)+"*", r.publishDropped); err != nil {
+		return err
+	}
+	return r.deleteMatching(ctx, escapePattern(revisionKey(prefix))+"*", nil)
+}
+
+// publishDropped reports dropped <namespace>:<collection>:<key> values to watchers as deleted
+func (r *RedisKV) publishDropped(ctx context.Context, redisKeys []string) {
+	for _, redisKey := range redisKeys {
+		parts := strings.SplitN(redisKey, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		r.publish(ctx, kv.Event{Type: kv.EventDelete, Namespace: parts[0], Collection: parts[1], Key: parts[2]})
+	}
+}
+
+// deleteMatching scans keys matching pattern and deletes them in batches of scanCount
+// deleted, if set, is called with each batch once it is deleted
+func (r *RedisKV) deleteMatching(ctx context.Context, pattern string, deleted func(context.Context, []string)) error {
+	batch := make([]string, 0, scanCount)
+	flush := func() error {
+		if err := r.client.Del(ctx, batch...).Err(); err != nil {
+			return err
+		}
+		if deleted != nil {
+			deleted(ctx, batch)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	iter := r.client.Scan(ctx, 0, pattern, scanCount).Iterator()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == scanCount {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if len(batch) > 0 {
+		return flush()
+	}
+	return nil
+}
+
 // Close closes the Redis connection
 func (r *RedisKV) Close() error {
 	return r.client.Close()
@@ -140,3 +483,16 @@ func (r *RedisKV) Close() error {
 func (r *RedisKV) Ping(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
 }
+
+// escapePattern escapes glob metacharacters so s matches literally in SCAN MATCH
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -3,8 +3,13 @@ package redis
 import (
 	"bytes"
 	"commander/internal/kv"
+	"commander/internal/kv/kvtest"
 	"context"
+	"errors"
+	"reflect"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
 )
@@ -462,3 +467,503 @@ func TestRedisKV_UpdateValue(t *testing.T) {
 		t.Errorf("Expected updated value %s, got %s", value2, retrieved)
 	}
 }
+
+func TestRedisKV_List(t *testing.T) {
+	mr, uri := setupMiniredis(t)
+	defer mr.Close()
+
+	store, err := NewRedisKV(uri)
+	if err != nil {
+		t.Fatalf("Failed to create Redis KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	for _, key := range []string{"card_003", "card_001", "device_001", "card_002", "card*1"} {
+		if err := store.Set(ctx, "testdb", "items", key, []byte(`{}`)); err != nil {
+			t.Fatalf("Failed to set value: %v", err)
+		}
+	}
+	// Keys in other collections must not leak into the listing
+	if err := store.Set(ctx, "testdb", "other", "card_999", []byte(`{}`)); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		opts           kv.ListOptions
+		expectedKeys   []string
+		expectedCursor string
+	}{
+		{"all keys", kv.ListOptions{}, []string{"card*1", "card_001", "card_002", "card_003", "device_001"}, ""},
+		{"prefix", kv.ListOptions{Prefix: "card_"}, []string{"card_001", "card_002", "card_003"}, ""},
+		{"glob characters are literal", kv.ListOptions{Prefix: "card*"}, []string{"card*1"}, ""},
+		{"limit", kv.ListOptions{Prefix: "card_", Limit: 2}, []string{"card_001", "card_002"}, "card_002"},
+		{"cursor", kv.ListOptions{Prefix: "card_", Cursor: "card_002"}, []string{"card_003"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := store.List(ctx, "testdb", "items", tt.opts)
+			if err != nil {
+				t.Fatalf("Failed to list keys: %v", err)
+			}
+			if !reflect.DeepEqual(result.Keys, tt.expectedKeys) {
+				t.Errorf("Expected keys %v, got %v", tt.expectedKeys, result.Keys)
+			}
+			if result.NextCursor != tt.expectedCursor {
+				t.Errorf("Expected cursor %q, got %q", tt.expectedCursor, result.NextCursor)
+			}
+		})
+	}
+}
+
+func TestRedisKV_ListNamespacesAndCollections(t *testing.T) {
+	mr, uri := setupMiniredis(t)
+	defer mr.Close()
+
+	store, err := NewRedisKV(uri)
+	if err != nil {
+		t.Fatalf("Failed to create Redis KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.Set(ctx, "hotel-b", "cards", "c1", []byte(`{}`))
+	_ = store.Set(ctx, "hotel-a", "devices", "d1", []byte(`{}`))
+	_ = store.Set(ctx, "hotel-a", "cards", "c1", []byte(`{}`))
+	_ = store.Set(ctx, "hotel-a", "cards", "c2", []byte(`{}`))
+	// Keys not written by Commander are ignored
+	mr.Set("unrelated", "value")
+
+	namespaces, err := store.ListNamespaces(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list namespaces: %v", err)
+	}
+	if !reflect.DeepEqual(namespaces, []string{"hotel-a", "hotel-b"}) {
+		t.Errorf("Expected namespaces [hotel-a hotel-b], got %v", namespaces)
+	}
+
+	collections, err := store.ListCollections(ctx, "hotel-a")
+	if err != nil {
+		t.Fatalf("Failed to list collections: %v", err)
+	}
+	if !reflect.DeepEqual(collections, []string{"cards", "devices"}) {
+		t.Errorf("Expected collections [cards devices], got %v", collections)
+	}
+
+	collections, err = store.ListCollections(ctx, "missing")
+	if err != nil {
+		t.Fatalf("Failed to list collections: %v", err)
+	}
+	if len(collections) != 0 {
+		t.Errorf("Expected no collections, got %v", collections)
+	}
+}
+
+func TestRedisKV_DropNamespaceAndCollection(t *testing.T) {
+	mr, uri := setupMiniredis(t)
+	defer mr.Close()
+
+	store, err := NewRedisKV(uri)
+	if err != nil {
+		t.Fatalf("Failed to create Redis KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.Set(ctx, "hotel-a", "cards", "c1", []byte(`{}`))
+	_ = store.Set(ctx, "hotel-a", "devices", "d1", []byte(`{}`))
+	_ = store.Set(ctx, "hotel-ab", "cards", "c1", []byte(`{}`))
+
+	if err := store.DropCollection(ctx, "hotel-a", "cards"); err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+	if exists, _ := store.Exists(ctx, "hotel-a", "cards", "c1"); exists {
+		t.Error("Expected collection keys to be deleted")
+	}
+	if exists, _ := store.Exists(ctx, "hotel-a", "devices", "d1"); !exists {
+		t.Error("Expected other collection to be intact")
+	}
+
+	if err := store.DropNamespace(ctx, "hotel-a"); err != nil {
+		t.Fatalf("Failed to drop namespace: %v", err)
+	}
+	if exists, _ := store.Exists(ctx, "hotel-a", "devices", "d1"); exists {
+		t.Error("Expected namespace keys to be deleted")
+	}
+	// A namespace sharing the same leading characters must not be affected
+	if exists, _ := store.Exists(ctx, "hotel-ab", "cards", "c1"); !exists {
+		t.Error("Expected other namespace to be intact")
+	}
+
+	if err := store.DropNamespace(ctx, "missing"); err != nil {
+		t.Errorf("Expected no error dropping missing namespace, got %v", err)
+	}
+}
+
+func TestRedisKV_SetWithTTL(t *testing.T) {
+	mr, uri := setupMiniredis(t)
+	defer mr.Close()
+
+	store, err := NewRedisKV(uri)
+	if err != nil {
+		t.Fatalf("Failed to create Redis KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.SetWithTTL(ctx, "testdb", "sessions", "token", []byte(`"a"`), time.Minute); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if ttl := mr.TTL("testdb:sessions:token"); ttl != time.Minute {
+		t.Errorf("Expected TTL of 1m, got %v", ttl)
+	}
+
+	mr.FastForward(2 * time.Minute)
+	if _, err := store.Get(ctx, "testdb", "sessions", "token"); err != kv.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for expired key, got %v", err)
+	}
+
+	// Set without TTL clears a previous expiry
+	_ = store.SetWithTTL(ctx, "testdb", "sessions", "token", []byte(`"a"`), time.Minute)
+	_ = store.SetWithTTL(ctx, "testdb", "sessions", "token", []byte(`"b"`), -time.Second)
+	if ttl := mr.TTL("testdb:sessions:token"); ttl != 0 {
+		t.Errorf("Expected no TTL, got %v", ttl)
+	}
+}
+
+func TestRedisKV_CompareAndSet(t *testing.T) {
+	mr, uri := setupMiniredis(t)
+	defer mr.Close()
+
+	store, err := NewRedisKV(uri)
+	if err != nil {
+		t.Fatalf("Failed to create Redis KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	// Expected version 0 creates the key only if it does not exist
+	rev, err := store.CompareAndSet(ctx, "testdb", "cards", "c1", 0, []byte(`"v1"`))
+	if err != nil || rev != 1 {
+		t.Fatalf("Expected revision 1, got %d (err %v)", rev, err)
+	}
+	if _, err := store.CompareAndSet(ctx, "testdb", "cards", "c1", 0, []byte(`"v1"`)); err != kv.ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+
+	// Stale version is rejected, current version is accepted
+	if _, err := store.CompareAndSet(ctx, "testdb", "cards", "c1", 5, []byte(`"v2"`)); err != kv.ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+	rev, err = store.CompareAndSet(ctx, "testdb", "cards", "c1", 1, []byte(`"v2"`))
+	if err != nil || rev != 2 {
+		t.Fatalf("Expected revision 2, got %d (err %v)", rev, err)
+	}
+
+	// Plain Set also bumps the revision
+	if err := store.Set(ctx, "testdb", "cards", "c1", []byte(`"v3"`)); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	value, rev, err := store.GetWithVersion(ctx, "testdb", "cards", "c1")
+	if err != nil || rev != 3 || string(value) != `"v3"` {
+		t.Errorf("Expected v3 at revision 3, got %s at %d (err %v)", value, rev, err)
+	}
+
+	// CompareAndDelete
+	if err := store.CompareAndDelete(ctx, "testdb", "cards", "c1", 2); err != kv.ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+	if err := store.CompareAndDelete(ctx, "testdb", "cards", "c1", 3); err != nil {
+		t.Errorf("Failed to delete: %v", err)
+	}
+	if err := store.CompareAndDelete(ctx, "testdb", "cards", "c1", 3); err != kv.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestRedisKV_LegacyStringValue(t *testing.T) {
+	mr, uri := setupMiniredis(t)
+	defer mr.Close()
+
+	store, err := NewRedisKV(uri)
+	if err != nil {
+		t.Fatalf("Failed to create Redis KV: %v", err)
+	}
+	defer store.Close()
+
+	// Values written before revisions were tracked are plain strings
+	if err := mr.Set("testdb:cards:old", `{"legacy":true}`); err != nil {
+		t.Fatalf("Failed to seed legacy value: %v", err)
+	}
+
+	ctx := context.Background()
+	value, rev, err := store.GetWithVersion(ctx, "testdb", "cards", "old")
+	if err != nil || rev != 1 || string(value) != `{"legacy":true}` {
+		t.Fatalf("Expected legacy value at revision 1, got %s at %d (err %v)", value, rev, err)
+	}
+
+	rev, err = store.CompareAndSet(ctx, "testdb", "cards", "old", 1, []byte(`{"legacy":false}`))
+	if err != nil || rev != 2 {
+		t.Fatalf("Expected revision 2, got %d (err %v)", rev, err)
+	}
+	if got, _ := store.Get(ctx, "testdb", "cards", "old"); string(got) != `{"legacy":false}` {
+		t.Errorf("Expected updated value, got %s", got)
+	}
+}
+
+func TestRedisKV_PlainStringValues(t *testing.T) {
+	mr, uri := setupMiniredis(t)
+	defer mr.Close()
+
+	store, err := NewRedisKV(uri)
+	if err != nil {
+		t.Fatalf("Failed to create Redis KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.SetWithTTL(ctx, "testdb", "sessions", "token", []byte(`"a"`), time.Minute)
+
+	// Other clients keep reading values with a plain GET
+	if value, err := mr.Get("testdb:sessions:token"); err != nil || value != `"a"` {
+		t.Errorf("Expected a plain string value, got %q (err %v)", value, err)
+	}
+	if ttl := mr.TTL(revisionKey("testdb:sessions:token")); ttl != time.Minute {
+		t.Errorf("Expected the revision to expire with the value, got %v", ttl)
+	}
+
+	// Revisions continue after expiry, deletes and drops instead of restarting at 1
+	mr.FastForward(2 * time.Minute)
+	if rev, err := store.CompareAndSet(ctx, "testdb", "sessions", "token", 0, []byte(`"b"`)); err != nil || rev != 2 {
+		t.Errorf("Expected revision 2 after expiry, got %d (err %v)", rev, err)
+	}
+	if err := store.DropNamespace(ctx, "testdb"); err != nil {
+		t.Fatalf("Failed to drop namespace: %v", err)
+	}
+	if mr.Exists(revisionKey("testdb:sessions:token")) {
+		t.Error("Expected the revision key to be dropped with the value")
+	}
+	if namespaces, _ := store.ListNamespaces(ctx); len(namespaces) != 0 {
+		t.Errorf("Expected the namespace counter to be hidden, got %v", namespaces)
+	}
+	if rev, err := store.CompareAndSet(ctx, "testdb", "sessions", "token", 0, []byte(`"c"`)); err != nil || rev != 3 {
+		t.Errorf("Expected revision 3 after drop, got %d (err %v)", rev, err)
+	}
+}
+
+func TestRedisKV_Apply(t *testing.T) {
+	mr, uri := setupMiniredis(t)
+	defer mr.Close()
+
+	store, err := NewRedisKV(uri)
+	if err != nil {
+		t.Fatalf("Failed to create Redis KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.Set(ctx, "testdb", "cards", "old", []byte(`"old"`))
+
+	err = store.Apply(ctx, []kv.Op{
+		{Type: kv.OpSet, Namespace: "testdb", Collection: "cards", Key: "c1", Value: []byte(`"v1"`)},
+		{Type: kv.OpSet, Namespace: "testdb", Collection: "sessions", Key: "s1", Value: []byte(`"s"`), TTL: time.Minute},
+		{Type: kv.OpDelete, Namespace: "testdb", Collection: "cards", Key: "old"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to apply transaction: %v", err)
+	}
+	if value, _ := store.Get(ctx, "testdb", "cards", "c1"); string(value) != `"v1"` {
+		t.Errorf("Expected v1, got %s", value)
+	}
+	if ttl := mr.TTL("testdb:sessions:s1"); ttl != time.Minute {
+		t.Errorf("Expected TTL of 1m, got %v", ttl)
+	}
+	if exists, _ := store.Exists(ctx, "testdb", "cards", "old"); exists {
+		t.Error("Expected old key to be deleted")
+	}
+
+	// Deleting a missing key aborts before anything is written
+	err = store.Apply(ctx, []kv.Op{
+		{Type: kv.OpSet, Namespace: "testdb", Collection: "cards", Key: "c2", Value: []byte(`"v2"`)},
+		{Type: kv.OpDelete, Namespace: "testdb", Collection: "cards", Key: "missing"},
+	})
+	var txErr *kv.TxError
+	if !errors.As(err, &txErr) || txErr.Index != 1 || !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Fatalf("Expected TxError at index 1 wrapping ErrKeyNotFound, got %v", err)
+	}
+	if exists, _ := store.Exists(ctx, "testdb", "cards", "c2"); exists {
+		t.Error("Expected c2 to not be written")
+	}
+
+	// Later operations see earlier ones on the same key
+	err = store.Apply(ctx, []kv.Op{
+		{Type: kv.OpSet, Namespace: "testdb", Collection: "cards", Key: "tmp", Value: []byte(`"t"`)},
+		{Type: kv.OpDelete, Namespace: "testdb", Collection: "cards", Key: "tmp"},
+	})
+	if err != nil {
+		t.Errorf("Expected set then delete to succeed, got %v", err)
+	}
+}
+
+func TestRedisKV_GetMany(t *testing.T) {
+	mr, uri := setupMiniredis(t)
+	defer mr.Close()
+
+	store, err := NewRedisKV(uri)
+	if err != nil {
+		t.Fatalf("Failed to create Redis KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	_ = store.Set(ctx, "hotel_a", "cards", "c1", []byte(`"a1"`))
+	_ = store.Set(ctx, "hotel_b", "cards", "c1", []byte(`"b1"`))
+	_ = store.Set(ctx, "", "devices", "d1", []byte(`"d1"`))
+
+	results, err := store.GetMany(ctx, []kv.Key{
+		{Namespace: "hotel_a", Collection: "cards", Key: "c1"},
+		{Namespace: "hotel_a", Collection: "cards", Key: "missing"},
+		{Namespace: "hotel_b", Collection: "cards", Key: "c1"},
+		{Namespace: "hotel_b", Collection: "unknown", Key: "c1"},
+		{Namespace: "default", Collection: "devices", Key: "d1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get keys: %v", err)
+	}
+
+	expected := []kv.GetResult{
+		{Value: []byte(`"a1"`), Found: true},
+		{},
+		{Value: []byte(`"b1"`), Found: true},
+		{},
+		{Value: []byte(`"d1"`), Found: true},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, got %v", expected, results)
+	}
+}
+
+func TestRedisKV_Watch(t *testing.T) {
+	mr, uri := setupMiniredis(t)
+	defer mr.Close()
+
+	store, err := NewRedisKV(uri)
+	if err != nil {
+		t.Fatalf("Failed to create Redis KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := store.Watch(ctx, "", "cards", "guest_")
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	_ = store.Set(ctx, "default", "cards", "staff_1", []byte(`"ignored"`))
+	_ = store.Set(ctx, "default", "devices", "guest_1", []byte(`"ignored"`))
+	_ = store.Set(ctx, "default", "cards", "guest_1", []byte(`"v1"`))
+	_ = store.Delete(ctx, "default", "cards", "guest_1")
+	_ = store.Apply(ctx, []kv.Op{{Type: kv.OpSet, Namespace: "default", Collection: "cards", Key: "guest_2", Value: []byte(`"v2"`)}})
+
+	expected := []kv.Event{
+		{Type: kv.EventSet, Namespace: "default", Collection: "cards", Key: "guest_1", Value: []byte(`"v1"`), Revision: 3},
+		{Type: kv.EventDelete, Namespace: "default", Collection: "cards", Key: "guest_1"},
+		{Type: kv.EventSet, Namespace: "default", Collection: "cards", Key: "guest_2", Value: []byte(`"v2"`), Revision: 4},
+	}
+	for _, want := range expected {
+		select {
+		case got := <-events:
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected event %+v, got %+v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %+v", want)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("Expected channel to be closed after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for channel to close")
+	}
+}
+
+func TestRedisKV_WatchDrop(t *testing.T) {
+	mr, uri := setupMiniredis(t)
+	defer mr.Close()
+
+	store, err := NewRedisKV(uri)
+	if err != nil {
+		t.Fatalf("Failed to create Redis KV: %v", err)
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_ = store.Set(ctx, "default", "cards", "guest_1", []byte(`"v1"`))
+	_ = store.Set(ctx, "default", "cards", "guest_2", []byte(`"v2"`))
+	_ = store.Set(ctx, "default", "cards", "staff_1", []byte(`"ignored"`))
+
+	events, err := store.Watch(ctx, "default", "cards", "guest_")
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	receive := func(count int) []string {
+		var keys []string
+		for range count {
+			select {
+			case event := <-events:
+				if event.Type != kv.EventDelete || event.Namespace != "default" || event.Collection != "cards" {
+					t.Errorf("Expected delete event, got %+v", event)
+				}
+				keys = append(keys, event.Key)
+			case <-time.After(time.Second):
+				t.Fatalf("Timed out waiting for delete event %d of %d", len(keys)+1, count)
+			}
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	if err := store.DropCollection(ctx, "default", "cards"); err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+	if keys := receive(2); !reflect.DeepEqual(keys, []string{"guest_1", "guest_2"}) {
+		t.Errorf("Expected deletes for guest_1 and guest_2, got %v", keys)
+	}
+
+	_ = store.Set(ctx, "default", "cards", "guest_3", []byte(`"v3"`))
+	if event := <-events; event.Type != kv.EventSet || event.Key != "guest_3" {
+		t.Errorf("Expected set event for guest_3, got %+v", event)
+	}
+	if err := store.DropNamespace(ctx, "default"); err != nil {
+		t.Fatalf("Failed to drop namespace: %v", err)
+	}
+	if keys := receive(1); !reflect.DeepEqual(keys, []string{"guest_3"}) {
+		t.Errorf("Expected delete for guest_3, got %v", keys)
+	}
+}
+
+func TestRedisKV_Conformance(t *testing.T) {
+	kvtest.RunConformance(t, func(t *testing.T) kv.KV {
+		mr, uri := setupMiniredis(t)
+		t.Cleanup(mr.Close)
+
+		store, err := NewRedisKV(uri)
+		if err != nil {
+			t.Fatalf("Failed to create Redis KV: %v", err)
+		}
+		return store
+	})
+}
@@ -0,0 +1,53 @@
+package redis
+
+import "github.com/redis/go-redis/v9"
+
+// Values are plain strings, so other Redis clients keep reading them with GET
+// The revision of a value lives in a sidecar key (see revisionKey) with the same expiry, and is drawn
+// from a counter per namespace (see counterKey) that deletes, expiry and drops never reset, so a
+// recreated key continues above its old revisions
+// Values without a sidecar, written before revisions were tracked or by another client, read as revision 1
+
+// setScript writes a value under the next revision of the namespace and sets or clears the expiry
+// Returns the new revision, or -1 if the expected revision does not match
+// KEYS[1] = key, KEYS[2] = revision key, KEYS[3] = namespace counter,
+// ARGV[1] = value, ARGV[2] = ttl in milliseconds (0 = never),
+// ARGV[3] = expected revision ("" = any, "0" = key must not exist)
+var setScript = redis.NewScript(`
+local current = 0
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	current = tonumber(redis.call('GET', KEYS[2])) or 1
+end
+if ARGV[3] ~= '' and tonumber(ARGV[3]) ~= current then
+	return -1
+end
+local revision = redis.call('INCR', KEYS[3])
+if revision <= current then
+	revision = current + 1
+	redis.call('SET', KEYS[3], revision)
+end
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+	redis.call('SET', KEYS[2], revision, 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+	redis.call('SET', KEYS[2], revision)
+end
+return revision
+`)
+
+// deleteScript deletes a key and its revision if the revision matches
+// Returns 1 if deleted, 0 if the key does not exist, -1 if the expected revision does not match
+// KEYS[1] = key, KEYS[2] = revision key, ARGV[1] = expected revision ("" = any)
+var deleteScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local current = tonumber(redis.call('GET', KEYS[2])) or 1
+if ARGV[1] ~= '' and tonumber(ARGV[1]) ~= current then
+	return -1
+end
+redis.call('DEL', KEYS[1], KEYS[2])
+return 1
+`)
@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"commander/internal/kv"
+)
+
+// eventChannelPrefix is the pub/sub channel prefix for change events
+// Channel format: commander:events:<namespace>:<collection>
+const eventChannelPrefix = "commander:events:"
+
+// eventChannel returns the pub/sub channel for a collection
+func eventChannel(namespace, collection string) string {
+	return eventChannelPrefix + kv.NormalizeNamespace(namespace) + ":" + collection
+}
+
+// publish sends a change event to watchers of the collection
+// Publishing is best effort: the write already succeeded, so a failure only means watchers miss the event
+func (r *RedisKV) publish(ctx context.Context, event kv.Event) {
+	event.Namespace = kv.NormalizeNamespace(event.Namespace)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	_ = r.client.Publish(ctx, eventChannel(event.Namespace, event.Collection), payload).Err() //nolint:errcheck // Best effort notification
+}
+
+// Watch reports writes to keys in namespace and collection starting with prefix
+// Events are published by every RedisKV writing to the same server; keys expired by Redis itself
+// are not reported, and events published while a watcher is disconnected are lost
+func (r *RedisKV) Watch(ctx context.Context, namespace, collection, prefix string) (<-chan kv.Event, error) {
+	pubsub := r.client.Subscribe(ctx, eventChannel(namespace, collection))
+	// Wait for the subscription confirmation so no event published after Watch returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close() //nolint:errcheck // Subscription failed anyway
+		return nil, err
+	}
+
+	events := make(chan kv.Event, kv.WatchBufferSize)
+	go func() {
+		defer close(events)
+		defer pubsub.Close() //nolint:errcheck // Best effort cleanup
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event kv.Event
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					continue
+				}
+				if !strings.HasPrefix(event.Key, prefix) {
+					continue
+				}
+				select {
+				case events <- event:
+				default:
+					// Watcher fell too far behind
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
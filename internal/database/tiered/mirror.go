@@ -0,0 +1,415 @@
+package tiered
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"commander/internal/kv"
+)
+
+const (
+	// resyncPageSize is the number of keys compared per primary read during a resync
+	resyncPageSize = 500
+
+	// resyncAttempts is how often a page is read again when writes keep interleaving with it
+	resyncAttempts = 3
+)
+
+// mirrorOp is a write waiting to be applied to the secondary
+type mirrorOp struct {
+	// ops are applied in order; TTLs count from queuedAt
+	ops      []kv.Op
+	queuedAt time.Time
+
+	// drop removes collection, or the whole namespace if collection is empty
+	drop       bool
+	namespace  string
+	collection string
+
+	// done is closed once every earlier write has been applied (see flush)
+	done chan struct{}
+}
+
+// ResyncResult reports what a resync changed on the secondary
+type ResyncResult struct {
+	Copied  int
+	Removed int
+}
+
+// pendingKeys returns the keys of the pending map that op covers
+func (op *mirrorOp) pendingKeys() []string {
+	if op.drop {
+		return []string{pendingKey(op.namespace, op.collection, "", op.collection == "")}
+	}
+	keys := make([]string, len(op.ops))
+	for i, write := range op.ops {
+		keys[i] = pendingKey(write.Namespace, write.Collection, write.Key, false)
+	}
+	return keys
+}
+
+// pendingKey identifies a key, or a whole collection or namespace, in the pending map
+func pendingKey(namespace, collection, key string, wholeNamespace bool) string {
+	if wholeNamespace {
+		return kv.NormalizeNamespace(namespace) + "\x00"
+	}
+	return kv.NormalizeNamespace(namespace) + "\x00" + collection + "\x00" + key
+}
+
+// isPending reports whether a write to key, its collection or its namespace is still queued
+// Such keys are read from the primary, so the instance always reads its own writes
+func (t *TieredKV) isPending(namespace, collection, key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) == 0 {
+		return false
+	}
+	return t.pending[pendingKey(namespace, collection, key, false)] > 0 ||
+		t.pending[pendingKey(namespace, collection, "", false)] > 0 ||
+		t.pending[pendingKey(namespace, "", "", true)] > 0
+}
+
+// enqueue queues op after a successful primary write, waiting for room if the mirror is behind
+// Writes to excluded collections are left out
+func (t *TieredKV) enqueue(op mirrorOp) {
+	if op.drop && op.collection != "" && !t.mirrored(op.collection) {
+		return
+	}
+	if !op.drop {
+		writes := op.ops[:0:0]
+		for _, write := range op.ops {
+			if t.mirrored(write.Collection) {
+				writes = append(writes, write)
+			}
+		}
+		if len(writes) == 0 {
+			return
+		}
+		op.ops = writes
+	}
+
+	t.mu.Lock()
+	t.generation++
+	for _, key := range op.pendingKeys() {
+		t.pending[key]++
+	}
+	t.mu.Unlock()
+
+	// The caller may reuse its buffers once the primary write returned
+	for i := range op.ops {
+		op.ops[i].Value = append([]byte{}, op.ops[i].Value...)
+	}
+	op.queuedAt = time.Now()
+	select {
+	case t.queue <- op:
+	case <-t.stop:
+		log.Printf("[KVMirror] Mirror closed, write not mirrored: namespace=%s, writes=%d", op.namespace, len(op.ops))
+	}
+}
+
+// flush waits until every write queued before it has been applied
+func (t *TieredKV) flush() {
+	done := make(chan struct{})
+	select {
+	case t.queue <- mirrorOp{done: done}:
+		<-done
+	case <-t.stop:
+	}
+}
+
+// currentGeneration returns the write counter before a primary read that will be copied
+func (t *TieredKV) currentGeneration() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.generation
+}
+
+// repair copies a value read from the primary, with its remaining TTL, to the secondary unless a write
+// happened since generation
+func (t *TieredKV) repair(ctx context.Context, generation uint64, key kv.Key, result kv.GetResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.generation != generation {
+		return
+	}
+	if err := t.secondary.SetWithTTL(context.WithoutCancel(ctx), key.Namespace, key.Collection, key.Key, result.Value, result.TTL); err != nil {
+		log.Printf("[KVMirror] Read repair failed: namespace=%s, collection=%s, error=%v", key.Namespace, key.Collection, err)
+	}
+}
+
+// runMirror applies queued writes in order; on Close it applies what is left in the queue and returns
+func (t *TieredKV) runMirror() {
+	defer t.done.Done()
+	for {
+		select {
+		case op := <-t.queue:
+			t.apply(op)
+		case <-t.stop:
+			for {
+				select {
+				case op := <-t.queue:
+					t.apply(op)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// apply writes op to the secondary
+// Failures are logged; the next resync repairs the secondary
+func (t *TieredKV) apply(op mirrorOp) {
+	if op.done != nil {
+		close(op.done)
+		return
+	}
+	defer func() {
+		t.mu.Lock()
+		for _, key := range op.pendingKeys() {
+			if t.pending[key]--; t.pending[key] <= 0 {
+				delete(t.pending, key)
+			}
+		}
+		t.mu.Unlock()
+	}()
+
+	ctx := context.Background()
+	if op.drop {
+		var err error
+		if op.collection == "" {
+			err = t.secondary.DropNamespace(ctx, op.namespace)
+		} else {
+			err = t.secondary.DropCollection(ctx, op.namespace, op.collection)
+		}
+		if err != nil {
+			log.Printf("[KVMirror] Mirror drop failed: namespace=%s, collection=%s, error=%v", op.namespace, op.collection, err)
+		}
+		return
+	}
+
+	for _, write := range op.ops {
+		var err error
+		switch {
+		case write.Type == kv.OpDelete:
+			err = t.secondary.Delete(ctx, write.Namespace, write.Collection, write.Key)
+		case write.TTL > 0:
+			if remaining := write.TTL - time.Since(op.queuedAt); remaining > 0 {
+				err = t.secondary.SetWithTTL(ctx, write.Namespace, write.Collection, write.Key, write.Value, remaining)
+			} else {
+				err = t.secondary.Delete(ctx, write.Namespace, write.Collection, write.Key)
+			}
+		default:
+			err = t.secondary.Set(ctx, write.Namespace, write.Collection, write.Key, write.Value)
+		}
+		if err != nil && !errors.Is(err, kv.ErrKeyNotFound) {
+			log.Printf("[KVMirror] Mirror write failed: namespace=%s, collection=%s, error=%v",
+				write.Namespace, write.Collection, err)
+		}
+	}
+}
+
+// runResync resyncs at startup and then every resync interval until Close
+func (t *TieredKV) runResync() {
+	defer t.done.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-t.stop
+		cancel()
+	}()
+
+	var ticker <-chan time.Time
+	if t.resyncInterval > 0 {
+		timer := time.NewTicker(t.resyncInterval)
+		defer timer.Stop()
+		ticker = timer.C
+	}
+
+	for {
+		start := time.Now()
+		result, err := t.Resync(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[KVMirror] Resync failed: copied=%d, removed=%d, error=%v", result.Copied, result.Removed, err)
+		} else if err == nil {
+			log.Printf("[KVMirror] Resync finished: copied=%d, removed=%d, duration=%s",
+				result.Copied, result.Removed, time.Since(start).Round(time.Millisecond))
+		}
+
+		select {
+		case <-ticker:
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// Resync copies every key of the primary that the secondary lacks or holds with another value or
+// expiry, and removes the keys and collections the primary no longer has
+// Copies keep the remaining TTL of the primary key, so they expire on the secondary at the same time
+func (t *TieredKV) Resync(ctx context.Context) (ResyncResult, error) {
+	var result ResyncResult
+	namespaces, err := t.primary.ListNamespaces(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	collections := make(map[string]map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		names, err := t.primary.ListCollections(ctx, namespace)
+		if err != nil {
+			return result, err
+		}
+		collections[namespace] = make(map[string]bool, len(names))
+		for _, collection := range names {
+			if !t.mirrored(collection) {
+				continue
+			}
+			collections[namespace][collection] = true
+			if err := t.resyncCollection(ctx, namespace, collection, &result); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// Drop the collections the primary no longer has
+	localNamespaces, err := t.secondary.ListNamespaces(ctx)
+	if err != nil {
+		return result, err
+	}
+	for _, namespace := range localNamespaces {
+		localCollections, err := t.secondary.ListCollections(ctx, namespace)
+		if err != nil {
+			return result, err
+		}
+		for _, collection := range localCollections {
+			if collections[namespace][collection] || !t.mirrored(collection) {
+				continue
+			}
+			if err := t.dropLocal(ctx, namespace, collection); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// resyncCollection compares one collection page by page
+func (t *TieredKV) resyncCollection(ctx context.Context, namespace, collection string, result *ResyncResult) error {
+	seen := make(map[string]bool)
+	cursor := ""
+	for {
+		page, err := t.primary.List(ctx, namespace, collection, kv.ListOptions{Cursor: cursor, Limit: resyncPageSize})
+		if err != nil {
+			return err
+		}
+		keys := make([]kv.Key, len(page.Keys))
+		for i, key := range page.Keys {
+			keys[i] = kv.Key{Namespace: namespace, Collection: collection, Key: key}
+			seen[key] = true
+		}
+		if err := t.copyKeys(ctx, keys, result); err != nil {
+			return err
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	// Keys the secondary has beyond the primary listing are checked against the primary before removal
+	cursor = ""
+	for {
+		page, err := t.secondary.List(ctx, namespace, collection, kv.ListOptions{Cursor: cursor, Limit: resyncPageSize})
+		if err != nil {
+			return err
+		}
+		var extra []kv.Key
+		for _, key := range page.Keys {
+			if !seen[key] {
+				extra = append(extra, kv.Key{Namespace: namespace, Collection: collection, Key: key})
+			}
+		}
+		if len(extra) > 0 {
+			if err := t.copyKeys(ctx, extra, result); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// copyKeys makes the secondary match the primary for keys
+// The values are read again if a write happens in between; after resyncAttempts the keys are left to the
+// next resync
+func (t *TieredKV) copyKeys(ctx context.Context, keys []kv.Key, result *ResyncResult) error {
+	for attempt := 0; attempt < resyncAttempts; attempt++ {
+		generation := t.currentGeneration()
+		upstream, err := t.primary.GetMany(ctx, keys)
+		if err != nil {
+			return err
+		}
+		local, err := t.secondary.GetMany(ctx, keys)
+		if err != nil {
+			return err
+		}
+
+		applied, err := t.applyCopy(ctx, generation, keys, upstream, local, result)
+		if applied || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyCopy writes the differences between upstream and local unless a write happened since generation
+func (t *TieredKV) applyCopy(ctx context.Context, generation uint64, keys []kv.Key, upstream, local []kv.GetResult, result *ResyncResult) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.generation != generation {
+		return false, nil
+	}
+	for i, k := range keys {
+		switch {
+		case upstream[i].Found && (!local[i].Found || !bytes.Equal(upstream[i].Value, local[i].Value) ||
+			(upstream[i].TTL > 0) != (local[i].TTL > 0)):
+			if err := t.secondary.SetWithTTL(ctx, k.Namespace, k.Collection, k.Key, upstream[i].Value, upstream[i].TTL); err != nil {
+				return true, err
+			}
+			result.Copied++
+		case !upstream[i].Found && local[i].Found:
+			if err := t.secondary.Delete(ctx, k.Namespace, k.Collection, k.Key); err != nil && !errors.Is(err, kv.ErrKeyNotFound) {
+				return true, err
+			}
+			result.Removed++
+		}
+	}
+	return true, nil
+}
+
+// dropLocal drops a collection from the secondary if the primary still does not have it
+func (t *TieredKV) dropLocal(ctx context.Context, namespace, collection string) error {
+	generation := t.currentGeneration()
+	collections, err := t.primary.ListCollections(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	for _, name := range collections {
+		if name == collection {
+			return nil
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.generation != generation {
+		return nil
+	}
+	return t.secondary.DropCollection(ctx, namespace, collection)
+}
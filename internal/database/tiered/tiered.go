@@ -0,0 +1,313 @@
+// Package tiered provides a kv.KV that keeps a local mirror of a primary backend
+package tiered
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"commander/internal/kv"
+)
+
+// Defaults used when Options leaves them unset
+const (
+	DefaultQueueSize      = 10000
+	DefaultResyncInterval = 10 * time.Minute
+)
+
+// Options configures a TieredKV
+type Options struct {
+	// QueueSize is the number of writes waiting to be mirrored (DefaultQueueSize if <= 0)
+	// Writes wait for room when the mirror falls this far behind
+	QueueSize int
+
+	// ResyncInterval is how often the whole primary is compared with the mirror (DefaultResyncInterval if 0,
+	// negative = only at startup)
+	ResyncInterval time.Duration
+
+	// Exclude lists collections that are never mirrored: they are read from and written to the primary
+	// only, and resyncs leave them alone on both sides
+	Exclude []string
+}
+
+// TieredKV writes to a primary backend, the source of truth, and mirrors every write asynchronously to a
+// local secondary backend. Get, GetMany and Exists are served by the secondary and fall back to the
+// primary when it does not have the key or fails; keys found that way are copied to the secondary
+// Keys with writes still waiting to be mirrored are read from the primary, so an instance always reads
+// its own writes. Versioned reads, listing and watch always use the primary
+//
+// Writes made directly to the primary by other instances reach the secondary with the next resync, which
+// copies every key of the primary and removes keys the primary no longer has. Until then the secondary
+// can serve the previous value
+//
+// Collections in Options.Exclude bypass the secondary entirely, so their names stay free for data the
+// caller keeps in the secondary itself
+//
+//nolint:revive // TieredKV name is intentional to match the naming of the other backends
+type TieredKV struct {
+	primary   kv.Store
+	secondary kv.Store
+
+	queue          chan mirrorOp
+	resyncInterval time.Duration
+	exclude        map[string]bool
+
+	// mu orders writes against copies made outside the queue (read repair and resync):
+	// generation increases before a write is queued, and a copy is only applied if it did not change
+	// since the copied value was read from the primary
+	mu         sync.Mutex
+	generation uint64
+	// pending counts the queued writes per key, collection and namespace (see isPending)
+	pending map[string]int
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      sync.WaitGroup
+}
+
+// NewTieredKV mirrors primary to secondary
+// Both must implement kv.Store; a first resync starts in the background
+func NewTieredKV(primary, secondary kv.KV, opts Options) (*TieredKV, error) {
+	primaryStore, ok := primary.(kv.Store)
+	if !ok {
+		return nil, fmt.Errorf("tiered: primary %T does not implement every KV capability", primary)
+	}
+	secondaryStore, ok := secondary.(kv.Store)
+	if !ok {
+		return nil, fmt.Errorf("tiered: secondary %T does not implement every KV capability", secondary)
+	}
+
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.ResyncInterval == 0 {
+		opts.ResyncInterval = DefaultResyncInterval
+	}
+
+	t := &TieredKV{
+		primary:        primaryStore,
+		secondary:      secondaryStore,
+		queue:          make(chan mirrorOp, opts.QueueSize),
+		resyncInterval: opts.ResyncInterval,
+		exclude:        make(map[string]bool, len(opts.Exclude)),
+		pending:        make(map[string]int),
+		stop:           make(chan struct{}),
+	}
+	for _, collection := range opts.Exclude {
+		t.exclude[collection] = true
+	}
+	t.done.Add(2)
+	go t.runMirror()
+	go t.runResync()
+	return t, nil
+}
+
+// Unwrap returns the primary backend
+func (t *TieredKV) Unwrap() kv.KV {
+	return t.primary
+}
+
+// Secondary returns the local backend
+// Writes made to it directly are only safe in excluded collections; resyncs overwrite the others
+func (t *TieredKV) Secondary() kv.Store {
+	return t.secondary
+}
+
+// mirrored reports whether collection is kept in the secondary
+func (t *TieredKV) mirrored(collection string) bool {
+	return !t.exclude[collection]
+}
+
+// Get reads the secondary, falling back to the primary
+func (t *TieredKV) Get(ctx context.Context, namespace, collection, key string) ([]byte, error) {
+	if !t.mirrored(collection) || t.isPending(namespace, collection, key) {
+		return t.primary.Get(ctx, namespace, collection, key)
+	}
+
+	value, err := t.secondary.Get(ctx, namespace, collection, key)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, kv.ErrKeyNotFound) && ctx.Err() == nil {
+		log.Printf("[KVMirror] Secondary read failed, using primary: namespace=%s, collection=%s, error=%v",
+			namespace, collection, err)
+	}
+
+	// GetMany reports the TTL of the key, which the copy keeps
+	generation := t.currentGeneration()
+	k := kv.Key{Namespace: namespace, Collection: collection, Key: key}
+	results, err := t.primary.GetMany(ctx, []kv.Key{k})
+	if err != nil {
+		return nil, err
+	}
+	if !results[0].Found {
+		return nil, kv.ErrKeyNotFound
+	}
+	t.repair(ctx, generation, k, results[0])
+	return results[0].Value, nil
+}
+
+// GetMany reads the secondary and reads the keys it does not have from the primary in one call
+func (t *TieredKV) GetMany(ctx context.Context, keys []kv.Key) ([]kv.GetResult, error) {
+	results, err := t.secondary.GetMany(ctx, keys)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[KVMirror] Secondary batch read failed, using primary: keys=%d, error=%v", len(keys), err)
+		}
+		results = make([]kv.GetResult, len(keys))
+	}
+
+	var missing []kv.Key
+	var missingIndex []int
+	for i, result := range results {
+		if !result.Found || !t.mirrored(keys[i].Collection) || t.isPending(keys[i].Namespace, keys[i].Collection, keys[i].Key) {
+			missing = append(missing, keys[i])
+			missingIndex = append(missingIndex, i)
+		}
+	}
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	generation := t.currentGeneration()
+	fetched, err := t.primary.GetMany(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range fetched {
+		if result.Found && t.mirrored(missing[i].Collection) {
+			t.repair(ctx, generation, missing[i], result)
+		}
+		results[missingIndex[i]] = result
+	}
+	return results, nil
+}
+
+// Exists checks the secondary, falling back to the primary
+func (t *TieredKV) Exists(ctx context.Context, namespace, collection, key string) (bool, error) {
+	if !t.mirrored(collection) || t.isPending(namespace, collection, key) {
+		return t.primary.Exists(ctx, namespace, collection, key)
+	}
+	if exists, err := t.secondary.Exists(ctx, namespace, collection, key); err == nil && exists {
+		return true, nil
+	}
+	return t.primary.Exists(ctx, namespace, collection, key)
+}
+
+// Set stores a value in the primary and mirrors it
+func (t *TieredKV) Set(ctx context.Context, namespace, collection, key string, value []byte) error {
+	if err := t.primary.Set(ctx, namespace, collection, key, value); err != nil {
+		return err
+	}
+	t.enqueue(mirrorOp{ops: []kv.Op{{Type: kv.OpSet, Namespace: namespace, Collection: collection, Key: key, Value: value}}})
+	return nil
+}
+
+// SetWithTTL stores an expiring value in the primary and mirrors it with the same expiry
+func (t *TieredKV) SetWithTTL(ctx context.Context, namespace, collection, key string, value []byte, ttl time.Duration) error {
+	if err := t.primary.SetWithTTL(ctx, namespace, collection, key, value, ttl); err != nil {
+		return err
+	}
+	t.enqueue(mirrorOp{ops: []kv.Op{{Type: kv.OpSet, Namespace: namespace, Collection: collection, Key: key, Value: value, TTL: ttl}}})
+	return nil
+}
+
+// Delete removes a key from the primary and mirrors the deletion
+func (t *TieredKV) Delete(ctx context.Context, namespace, collection, key string) error {
+	if err := t.primary.Delete(ctx, namespace, collection, key); err != nil {
+		return err
+	}
+	t.enqueue(mirrorOp{ops: []kv.Op{{Type: kv.OpDelete, Namespace: namespace, Collection: collection, Key: key}}})
+	return nil
+}
+
+// GetWithVersion reads from the primary, since versions are those of the primary
+func (t *TieredKV) GetWithVersion(ctx context.Context, namespace, collection, key string) ([]byte, uint64, error) {
+	return t.primary.GetWithVersion(ctx, namespace, collection, key)
+}
+
+// CompareAndSet writes to the primary and mirrors the stored value
+func (t *TieredKV) CompareAndSet(ctx context.Context, namespace, collection, key string, expectedVersion uint64, value []byte) (uint64, error) {
+	version, err := t.primary.CompareAndSet(ctx, namespace, collection, key, expectedVersion, value)
+	if err != nil {
+		return 0, err
+	}
+	t.enqueue(mirrorOp{ops: []kv.Op{{Type: kv.OpSet, Namespace: namespace, Collection: collection, Key: key, Value: value}}})
+	return version, nil
+}
+
+// CompareAndDelete deletes from the primary and mirrors the deletion
+func (t *TieredKV) CompareAndDelete(ctx context.Context, namespace, collection, key string, expectedVersion uint64) error {
+	if err := t.primary.CompareAndDelete(ctx, namespace, collection, key, expectedVersion); err != nil {
+		return err
+	}
+	t.enqueue(mirrorOp{ops: []kv.Op{{Type: kv.OpDelete, Namespace: namespace, Collection: collection, Key: key}}})
+	return nil
+}
+
+// Apply runs the transaction on the primary and mirrors its writes
+func (t *TieredKV) Apply(ctx context.Context, ops []kv.Op) error {
+	if err := t.primary.Apply(ctx, ops); err != nil {
+		return err
+	}
+	t.enqueue(mirrorOp{ops: append([]kv.Op{}, ops...)})
+	return nil
+}
+
+// List returns keys from the primary
+func (t *TieredKV) List(ctx context.Context, namespace, collection string, opts kv.ListOptions) (*kv.ListResult, error) {
+	return t.primary.List(ctx, namespace, collection, opts)
+}
+
+// ListNamespaces returns the namespaces of the primary
+func (t *TieredKV) ListNamespaces(ctx context.Context) ([]string, error) {
+	return t.primary.ListNamespaces(ctx)
+}
+
+// ListCollections returns the collections of a namespace in the primary
+func (t *TieredKV) ListCollections(ctx context.Context, namespace string) ([]string, error) {
+	return t.primary.ListCollections(ctx, namespace)
+}
+
+// DropNamespace removes a namespace from the primary and mirrors the drop
+func (t *TieredKV) DropNamespace(ctx context.Context, namespace string) error {
+	if err := t.primary.DropNamespace(ctx, namespace); err != nil {
+		return err
+	}
+	t.enqueue(mirrorOp{drop: true, namespace: namespace})
+	return nil
+}
+
+// DropCollection removes a collection from the primary and mirrors the drop
+func (t *TieredKV) DropCollection(ctx context.Context, namespace, collection string) error {
+	if err := t.primary.DropCollection(ctx, namespace, collection); err != nil {
+		return err
+	}
+	t.enqueue(mirrorOp{drop: true, namespace: namespace, collection: collection})
+	return nil
+}
+
+// Watch streams changes from the primary
+func (t *TieredKV) Watch(ctx context.Context, namespace, collection, prefix string) (<-chan kv.Event, error) {
+	return t.primary.Watch(ctx, namespace, collection, prefix)
+}
+
+// Ping checks the primary; a failing secondary only slows reads down, so it is logged
+func (t *TieredKV) Ping(ctx context.Context) error {
+	if err := t.secondary.Ping(ctx); err != nil {
+		log.Printf("[KVMirror] Secondary ping failed: error=%v", err)
+	}
+	return t.primary.Ping(ctx)
+}
+
+// Close mirrors the queued writes, then closes both backends
+func (t *TieredKV) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.stop)
+		t.done.Wait()
+	})
+	return errors.Join(t.primary.Close(), t.secondary.Close())
+}
@@ -0,0 +1,318 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"commander/internal/database/memory"
+	"commander/internal/kv"
+	"commander/internal/kv/kvtest"
+)
+
+// newTestStores returns a tiered store over two memory stores and the two stores
+// Periodic resyncs are disabled; tests call Resync themselves
+func newTestStores(t *testing.T) (*TieredKV, *memory.MemoryKV, *memory.MemoryKV) {
+	t.Helper()
+	primary, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create primary: %v", err)
+	}
+	secondary, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create secondary: %v", err)
+	}
+	store, err := NewTieredKV(primary, secondary, Options{ResyncInterval: -1})
+	if err != nil {
+		t.Fatalf("Failed to create tiered KV: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store, primary, secondary
+}
+
+func TestTieredKV_InterfaceImplementation(t *testing.T) {
+	var _ kv.Store = (*TieredKV)(nil)
+}
+
+func TestTieredKV_Conformance(t *testing.T) {
+	kvtest.RunConformance(t, func(t *testing.T) kv.KV {
+		primary, err := memory.NewMemoryKV("")
+		if err != nil {
+			t.Fatalf("Failed to create primary: %v", err)
+		}
+		secondary, err := memory.NewMemoryKV("")
+		if err != nil {
+			t.Fatalf("Failed to create secondary: %v", err)
+		}
+		store, err := NewTieredKV(primary, secondary, Options{ResyncInterval: -1})
+		if err != nil {
+			t.Fatalf("Failed to create tiered KV: %v", err)
+		}
+		return store
+	})
+}
+
+func TestTieredKV_MirrorsWrites(t *testing.T) {
+	store, _, secondary := newTestStores(t)
+	ctx := context.Background()
+
+	if err := store.Set(ctx, "", "cards", "c1", []byte("v1")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if err := store.SetWithTTL(ctx, "", "cards", "c2", []byte("v2"), time.Hour); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if _, err := store.CompareAndSet(ctx, "", "cards", "c3", 0, []byte("v3")); err != nil {
+		t.Fatalf("Failed to create value: %v", err)
+	}
+	err := store.Apply(ctx, []kv.Op{
+		{Type: kv.OpSet, Collection: "cards", Key: "c4", Value: []byte("v4")},
+		{Type: kv.OpDelete, Collection: "cards", Key: "c1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to apply transaction: %v", err)
+	}
+	if err := store.Set(ctx, "", "devices", "d1", []byte("d")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if err := store.DropCollection(ctx, "", "devices"); err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+	store.flush()
+
+	expected := map[string]string{"c2": "v2", "c3": "v3", "c4": "v4"}
+	for key, value := range expected {
+		got, err := secondary.Get(ctx, "", "cards", key)
+		if err != nil || string(got) != value {
+			t.Errorf("Expected %s to be mirrored as %q, got %q (err %v)", key, value, got, err)
+		}
+	}
+	if _, err := secondary.Get(ctx, "", "cards", "c1"); !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Errorf("Expected deleted key to be removed from the secondary, got %v", err)
+	}
+	if exists, _ := secondary.Exists(ctx, "", "devices", "d1"); exists {
+		t.Error("Expected dropped collection to be removed from the secondary")
+	}
+
+	// A failed primary write is not mirrored
+	if _, err := store.CompareAndSet(ctx, "", "cards", "c3", 0, []byte("other")); !errors.Is(err, kv.ErrVersionMismatch) {
+		t.Fatalf("Expected ErrVersionMismatch, got %v", err)
+	}
+	store.flush()
+	if got, _ := secondary.Get(ctx, "", "cards", "c3"); string(got) != "v3" {
+		t.Errorf("Expected secondary to keep v3, got %q", got)
+	}
+}
+
+func TestTieredKV_ReadsLocalWithFallback(t *testing.T) {
+	store, primary, secondary := newTestStores(t)
+	ctx := context.Background()
+
+	// Served by the secondary
+	if err := secondary.Set(ctx, "", "cards", "local", []byte("local")); err != nil {
+		t.Fatalf("Failed to seed secondary: %v", err)
+	}
+	if got, err := store.Get(ctx, "", "cards", "local"); err != nil || string(got) != "local" {
+		t.Errorf("Expected value from the secondary, got %q (err %v)", got, err)
+	}
+
+	// Missing locally: read from the primary and copied
+	if err := primary.Set(ctx, "", "cards", "remote", []byte("remote")); err != nil {
+		t.Fatalf("Failed to seed primary: %v", err)
+	}
+	if got, err := store.Get(ctx, "", "cards", "remote"); err != nil || string(got) != "remote" {
+		t.Errorf("Expected value from the primary, got %q (err %v)", got, err)
+	}
+	if got, err := secondary.Get(ctx, "", "cards", "remote"); err != nil || string(got) != "remote" {
+		t.Errorf("Expected value to be copied to the secondary, got %q (err %v)", got, err)
+	}
+	if _, err := store.Get(ctx, "", "cards", "missing"); !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	// Batch reads combine both
+	if err := primary.Set(ctx, "", "cards", "remote-2", []byte("remote-2")); err != nil {
+		t.Fatalf("Failed to seed primary: %v", err)
+	}
+	results, err := store.GetMany(ctx, []kv.Key{
+		{Collection: "cards", Key: "local"},
+		{Collection: "cards", Key: "remote-2"},
+		{Collection: "cards", Key: "missing"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get many: %v", err)
+	}
+	if string(results[0].Value) != "local" || string(results[1].Value) != "remote-2" || results[2].Found {
+		t.Errorf("Unexpected batch results: %+v", results)
+	}
+	if exists, err := store.Exists(ctx, "", "cards", "remote-2"); err != nil || !exists {
+		t.Errorf("Expected key to exist, got %v (err %v)", exists, err)
+	}
+}
+
+func TestTieredKV_Resync(t *testing.T) {
+	store, primary, secondary := newTestStores(t)
+	ctx := context.Background()
+
+	// Written to the primary by another instance
+	if err := primary.Set(ctx, "hotel_a", "cards", "c1", []byte("new")); err != nil {
+		t.Fatalf("Failed to seed primary: %v", err)
+	}
+	if err := primary.Set(ctx, "hotel_a", "cards", "c2", []byte("v2")); err != nil {
+		t.Fatalf("Failed to seed primary: %v", err)
+	}
+	// Stale local state
+	if err := secondary.Set(ctx, "hotel_a", "cards", "c1", []byte("old")); err != nil {
+		t.Fatalf("Failed to seed secondary: %v", err)
+	}
+	if err := secondary.Set(ctx, "hotel_a", "cards", "deleted", []byte("x")); err != nil {
+		t.Fatalf("Failed to seed secondary: %v", err)
+	}
+	if err := secondary.Set(ctx, "hotel_a", "gone", "k", []byte("x")); err != nil {
+		t.Fatalf("Failed to seed secondary: %v", err)
+	}
+
+	result, err := store.Resync(ctx)
+	if err != nil {
+		t.Fatalf("Resync failed: %v", err)
+	}
+	if result.Copied != 2 || result.Removed != 1 {
+		t.Errorf("Expected 2 copied and 1 removed, got %+v", result)
+	}
+
+	for key, value := range map[string]string{"c1": "new", "c2": "v2"} {
+		if got, err := secondary.Get(ctx, "hotel_a", "cards", key); err != nil || string(got) != value {
+			t.Errorf("Expected %s = %q on the secondary, got %q (err %v)", key, value, got, err)
+		}
+	}
+	if exists, _ := secondary.Exists(ctx, "hotel_a", "cards", "deleted"); exists {
+		t.Error("Expected key missing from the primary to be removed")
+	}
+	collections, err := secondary.ListCollections(ctx, "hotel_a")
+	if err != nil || len(collections) != 1 || collections[0] != "cards" {
+		t.Errorf("Expected only the cards collection to remain, got %v (err %v)", collections, err)
+	}
+
+	// A second resync has nothing to do
+	if result, err := store.Resync(ctx); err != nil || result != (ResyncResult{}) {
+		t.Errorf("Expected an empty resync, got %+v (err %v)", result, err)
+	}
+}
+
+func TestTieredKV_Exclude(t *testing.T) {
+	primary, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create primary: %v", err)
+	}
+	secondary, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create secondary: %v", err)
+	}
+	store, err := NewTieredKV(primary, secondary, Options{ResyncInterval: -1, Exclude: []string{"cards"}})
+	if err != nil {
+		t.Fatalf("Failed to create tiered KV: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	ctx := context.Background()
+
+	// The secondary keeps its own data under the excluded name
+	if err := secondary.Set(ctx, "hotel_a", "cards", "local", []byte("local")); err != nil {
+		t.Fatalf("Failed to seed secondary: %v", err)
+	}
+	if err := store.Set(ctx, "hotel_a", "cards", "c1", []byte("v1")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if err := store.Set(ctx, "hotel_a", "devices", "d1", []byte("d")); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	store.flush()
+
+	if exists, _ := secondary.Exists(ctx, "hotel_a", "cards", "c1"); exists {
+		t.Error("Expected write to an excluded collection not to be mirrored")
+	}
+	if got, err := store.Get(ctx, "hotel_a", "cards", "local"); !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Errorf("Expected excluded reads to skip the secondary, got %q (err %v)", got, err)
+	}
+	if got, err := store.Get(ctx, "hotel_a", "cards", "c1"); err != nil || string(got) != "v1" {
+		t.Errorf("Expected c1 from the primary, got %q (err %v)", got, err)
+	}
+	if exists, _ := secondary.Exists(ctx, "hotel_a", "cards", "c1"); exists {
+		t.Error("Expected reads of an excluded collection not to be repaired")
+	}
+
+	if _, err := store.Resync(ctx); err != nil {
+		t.Fatalf("Resync failed: %v", err)
+	}
+	if got, err := secondary.Get(ctx, "hotel_a", "cards", "local"); err != nil || string(got) != "local" {
+		t.Errorf("Expected resync to leave the excluded collection alone, got %q (err %v)", got, err)
+	}
+	if got, err := secondary.Get(ctx, "hotel_a", "devices", "d1"); err != nil || string(got) != "d" {
+		t.Errorf("Expected other collections to be mirrored, got %q (err %v)", got, err)
+	}
+}
+
+func TestTieredKV_CopiesKeepTTL(t *testing.T) {
+	store, primary, secondary := newTestStores(t)
+	ctx := context.Background()
+
+	// Access logs expire on the primary; a copy without the expiry is replaced too
+	if err := primary.SetWithTTL(ctx, "hotel_a", "access_logs", "l1", []byte("v1"), time.Hour); err != nil {
+		t.Fatalf("Failed to seed primary: %v", err)
+	}
+	if err := primary.SetWithTTL(ctx, "hotel_a", "access_logs", "l2", []byte("v2"), time.Hour); err != nil {
+		t.Fatalf("Failed to seed primary: %v", err)
+	}
+	if err := secondary.Set(ctx, "hotel_a", "access_logs", "l1", []byte("v1")); err != nil {
+		t.Fatalf("Failed to seed secondary: %v", err)
+	}
+	if result, err := store.Resync(ctx); err != nil || result.Copied != 2 {
+		t.Errorf("Expected 2 copied, got %+v (err %v)", result, err)
+	}
+
+	// Read repair keeps the expiry as well
+	if err := primary.SetWithTTL(ctx, "hotel_a", "access_logs", "l3", []byte("v3"), time.Hour); err != nil {
+		t.Fatalf("Failed to seed primary: %v", err)
+	}
+	if _, err := store.Get(ctx, "hotel_a", "access_logs", "l3"); err != nil {
+		t.Fatalf("Failed to read through: %v", err)
+	}
+
+	results, err := secondary.GetMany(ctx, []kv.Key{
+		{Namespace: "hotel_a", Collection: "access_logs", Key: "l1"},
+		{Namespace: "hotel_a", Collection: "access_logs", Key: "l2"},
+		{Namespace: "hotel_a", Collection: "access_logs", Key: "l3"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to read secondary: %v", err)
+	}
+	for i, result := range results {
+		if !result.Found || result.TTL <= time.Hour-time.Minute || result.TTL > time.Hour {
+			t.Errorf("Expected l%d to be copied with about an hour left, got %+v", i+1, result)
+		}
+	}
+}
+
+func TestTieredKV_RepairSkippedAfterWrite(t *testing.T) {
+	store, primary, secondary := newTestStores(t)
+	ctx := context.Background()
+	if err := primary.Set(ctx, "", "cards", "c1", []byte("old")); err != nil {
+		t.Fatalf("Failed to seed primary: %v", err)
+	}
+
+	// A write between the primary read and the copy wins
+	generation := store.currentGeneration()
+	value, err := primary.Get(ctx, "", "cards", "c1")
+	if err != nil {
+		t.Fatalf("Failed to read primary: %v", err)
+	}
+	if err := store.Delete(ctx, "", "cards", "c1"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	store.repair(ctx, generation, kv.Key{Collection: "cards", Key: "c1"}, kv.GetResult{Value: value, Found: true})
+	store.flush()
+
+	if exists, _ := secondary.Exists(ctx, "", "cards", "c1"); exists {
+		t.Error("Expected stale value not to be copied to the secondary")
+	}
+}
@@ -0,0 +1,175 @@
+package edgesync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+)
+
+// Agent defaults
+const (
+	DefaultSyncInterval = 30 * time.Second
+
+	// pullPageSize is the number of changes requested per pull
+	pullPageSize = 500
+
+	// pushBatchSize is the number of access log entries sent per push
+	pushBatchSize = 500
+
+	// accessLogSettle keeps the newest entries back for a push, so an attempt whose ID was taken just before
+	// a push but written after it is not skipped by the checkpoint
+	accessLogSettle = 5 * time.Second
+)
+
+// Agent syncs namespaces of an edge instance with the upstream
+// Each round pulls the change feed into the local records (see services.CardService.ApplyChanges for the
+// conflict rules), then pushes the access log entries recorded since the last push. Progress is stored
+// after every page, so an interrupted round resumes where it stopped
+// Use counts and anti-passback presence are not pushed: use limits and zone state apply per edge
+type Agent struct {
+	service    *services.CardService
+	client     *Client
+	edgeID     string
+	namespaces []string
+	interval   time.Duration
+}
+
+// NewAgent creates an agent syncing namespaces of service with the upstream behind client
+// edgeID identifies this instance in the access log upstream
+func NewAgent(service *services.CardService, client *Client, edgeID string, namespaces []string, interval time.Duration) *Agent {
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	return &Agent{
+		service:    service,
+		client:     client,
+		edgeID:     edgeID,
+		namespaces: namespaces,
+		interval:   interval,
+	}
+}
+
+// Run syncs every namespace each interval until ctx is cancelled
+func (a *Agent) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.Sync(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync runs one round for every namespace; failures are logged and retried on the next round
+func (a *Agent) Sync(ctx context.Context) {
+	for _, namespace := range a.namespaces {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := a.SyncNamespace(ctx, namespace); err != nil {
+			log.Printf("[EdgeSync] Sync failed: namespace=%s, error=%v", namespace, err)
+		}
+	}
+}
+
+// SyncNamespace pulls the upstream changes of namespace, then pushes its access log
+func (a *Agent) SyncNamespace(ctx context.Context, namespace string) error {
+	state, err := a.service.SyncState(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	state.LastAttempt = a.service.Now().UTC()
+
+	err = a.pull(ctx, namespace, state)
+	if err == nil {
+		err = a.push(ctx, namespace, state)
+	}
+	if err != nil {
+		state.LastError = err.Error()
+	} else {
+		state.LastSync = state.LastAttempt
+		state.LastError = ""
+	}
+
+	if saveErr := a.service.SaveSyncState(context.WithoutCancel(ctx), namespace, state); saveErr != nil {
+		log.Printf("[EdgeSync] Failed to save sync state: namespace=%s, error=%v", namespace, saveErr)
+	}
+	return err
+}
+
+// pull applies upstream changes page by page, storing the checkpoint after each page
+func (a *Agent) pull(ctx context.Context, namespace string, state *models.SyncState) error {
+	for {
+		page, err := a.client.PullChanges(ctx, namespace, state.PulledRevision, pullPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to pull changes: %w", err)
+		}
+
+		if page.Snapshot {
+			log.Printf("[EdgeSync] Applying snapshot: namespace=%s, records=%d, revision=%d, previous_revision=%d",
+				namespace, len(page.Changes), page.Checkpoint, state.PulledRevision)
+			err = a.service.ApplySnapshot(ctx, namespace, page.Changes)
+		} else {
+			err = a.service.ApplyChanges(ctx, namespace, page.Changes)
+		}
+		if err != nil {
+			return err
+		}
+
+		state.PulledRevision = page.Checkpoint
+		if err := a.service.SaveSyncState(ctx, namespace, state); err != nil {
+			return err
+		}
+		if !page.More {
+			return nil
+		}
+	}
+}
+
+// push sends the access log entries recorded after the last pushed entry, storing progress after each batch
+// Entries pushed to this instance by other edges are not forwarded
+func (a *Agent) push(ctx context.Context, namespace string, state *models.SyncState) error {
+	until := a.service.Now().Add(-accessLogSettle)
+	for {
+		entries, next, err := a.service.ListAccessLogs(ctx, namespace, services.AccessLogQuery{
+			To:     until,
+			Cursor: state.PushedAccessLog,
+			Limit:  pushBatchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read access logs: %w", err)
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		local := make([]*models.AccessLog, 0, len(entries))
+		for _, entry := range entries {
+			if entry.Edge == "" {
+				local = append(local, entry)
+			}
+		}
+		if len(local) > 0 {
+			if _, err := a.client.PushAccessLogs(ctx, namespace, a.edgeID, local); err != nil {
+				return fmt.Errorf("failed to push access logs: %w", err)
+			}
+		}
+
+		state.PushedAccessLog = entries[len(entries)-1].ID
+		if err := a.service.SaveSyncState(ctx, namespace, state); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package edgesync
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"commander/internal/database/memory"
+	"commander/internal/handlers"
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// laterClock is a Clock running ahead of the system clock, so the newest access log entries are pushed
+type laterClock struct {
+	offset time.Duration
+}
+
+// Now returns the system time plus offset
+func (c laterClock) Now() time.Time {
+	return time.Now().Add(c.offset)
+}
+
+// newCloud starts an in-process upstream recording its changes, with the sync endpoints behind token
+func newCloud(t *testing.T, token string) (*services.CardService, *httptest.Server) {
+	t.Helper()
+	store, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	service := services.NewCardService(services.NewChangeFeed(services.NewKVRepository(store)), services.Policy{})
+	service.EnableAccessLog(0)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	sync := router.Group("/api/v1/namespace/:namespace/sync", handlers.SyncAuthMiddleware(token))
+	sync.GET("/changes", handlers.SyncChangesHandler(service))
+	sync.POST("/access-logs", handlers.SyncAccessLogsHandler(service))
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return service, server
+}
+
+// newEdge creates an edge instance on its own store
+func newEdge(t *testing.T) *services.CardService {
+	t.Helper()
+	store, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	service := services.NewCardService(services.NewKVRepository(store), services.Policy{})
+	service.EnableAccessLog(0)
+	return service
+}
+
+func guestCard(number string) *models.Card {
+	now := time.Now().UTC()
+	return &models.Card{
+		Number:      number,
+		Devices:     []string{"SN-001"},
+		EffectiveAt: now.Add(-time.Hour),
+		InvalidAt:   now.Add(24 * time.Hour),
+	}
+}
+
+func TestAgent_SyncsTwoInstances(t *testing.T) {
+	cloud, server := newCloud(t, "secret")
+	edge := newEdge(t)
+	agent := NewAgent(edge, NewClient(server.URL, "secret"), "edge-1", []string{"hotel_a"}, time.Minute)
+	ctx := context.Background()
+
+	_, err := cloud.RegisterDevice(ctx, "hotel_a", &models.Device{ID: "device-1", DeviceID: "lobby", SN: "SN-001"})
+	require.NoError(t, err)
+	_, err = cloud.CreateCard(ctx, "hotel_a", guestCard("GUEST-1"))
+	require.NoError(t, err)
+
+	// The first round starts from a snapshot
+	require.NoError(t, agent.SyncNamespace(ctx, "hotel_a"))
+	require.NoError(t, edge.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard))
+
+	// Later rounds apply the delta since the stored checkpoint
+	_, err = cloud.CreateCard(ctx, "hotel_a", guestCard("GUEST-2"))
+	require.NoError(t, err)
+	_, err = cloud.Revoke(ctx, "hotel_a", &models.Revocation{CardNumber: "GUEST-1", Reason: "lost"})
+	require.NoError(t, err)
+	require.NoError(t, agent.SyncNamespace(ctx, "hotel_a"))
+
+	assert.ErrorIs(t, edge.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard), services.ErrCardRevoked)
+	assert.NoError(t, edge.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-2", models.ProtocolStandard))
+
+	state, err := edge.SyncState(ctx, "hotel_a")
+	require.NoError(t, err)
+	latest, err := cloud.ListChanges(ctx, "hotel_a", state.PulledRevision, 0)
+	require.NoError(t, err)
+	assert.Empty(t, latest.Changes)
+	assert.Empty(t, state.LastError)
+
+	// The edge access log reaches the cloud once, however often the round runs
+	edge.SetClock(laterClock{time.Minute})
+	require.NoError(t, agent.SyncNamespace(ctx, "hotel_a"))
+	require.NoError(t, agent.SyncNamespace(ctx, "hotel_a"))
+
+	edgeLogs, _, err := edge.ListAccessLogs(ctx, "hotel_a", services.AccessLogQuery{})
+	require.NoError(t, err)
+	cloudLogs, _, err := cloud.ListAccessLogs(ctx, "hotel_a", services.AccessLogQuery{})
+	require.NoError(t, err)
+	require.Len(t, cloudLogs, len(edgeLogs))
+	for i, entry := range cloudLogs {
+		assert.Equal(t, edgeLogs[i].ID, entry.ID)
+		assert.Equal(t, "edge-1", entry.Edge)
+	}
+}
+
+func TestAgent_ConflictRules(t *testing.T) {
+	cloud, server := newCloud(t, "")
+	edge := newEdge(t)
+	agent := NewAgent(edge, NewClient(server.URL, ""), "edge-1", []string{"hotel_a"}, time.Minute)
+	ctx := context.Background()
+
+	_, err := cloud.RegisterDevice(ctx, "hotel_a", &models.Device{ID: "device-1", DeviceID: "lobby", SN: "SN-001"})
+	require.NoError(t, err)
+	limited := guestCard("ONCE")
+	limited.MaxUses = 2
+	card, err := cloud.CreateCard(ctx, "hotel_a", limited)
+	require.NoError(t, err)
+
+	// A card created only on the edge
+	_, err = edge.RegisterDevice(ctx, "hotel_a", &models.Device{ID: "device-1", DeviceID: "lobby", SN: "SN-001"})
+	require.NoError(t, err)
+	local, err := edge.CreateCard(ctx, "hotel_a", guestCard("GUEST-9"))
+	require.NoError(t, err)
+	require.NoError(t, agent.SyncNamespace(ctx, "hotel_a"))
+
+	// The snapshot removed the edge-only card
+	_, err = edge.GetCard(ctx, "hotel_a", local.ID)
+	assert.ErrorIs(t, err, services.ErrCardNotFound)
+
+	// Uses counted on the edge survive an upstream update of the card
+	require.NoError(t, edge.VerifyCard(ctx, "hotel_a", "SN-001", "ONCE", models.ProtocolStandard))
+	update := guestCard("ONCE")
+	update.MaxUses = 2
+	update.DisplayName = "Renamed"
+	_, err = cloud.UpdateCard(ctx, "hotel_a", card.ID, update)
+	require.NoError(t, err)
+	require.NoError(t, agent.SyncNamespace(ctx, "hotel_a"))
+
+	synced, err := edge.GetCard(ctx, "hotel_a", card.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", synced.DisplayName)
+	assert.Equal(t, 1, synced.UseCount)
+
+	// Upstream wins a number conflict with a card created on the edge in between
+	_, err = edge.CreateCard(ctx, "hotel_a", guestCard("GUEST-3"))
+	require.NoError(t, err)
+	upstream, err := cloud.CreateCard(ctx, "hotel_a", guestCard("GUEST-3"))
+	require.NoError(t, err)
+	require.NoError(t, agent.SyncNamespace(ctx, "hotel_a"))
+
+	cards, _, err := edge.ListCards(ctx, "hotel_a", services.CardQuery{Number: "GUEST-3"})
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+	assert.Equal(t, upstream.ID, cards[0].ID)
+}
+
+func TestAgent_Unauthorized(t *testing.T) {
+	_, server := newCloud(t, "secret")
+	edge := newEdge(t)
+	agent := NewAgent(edge, NewClient(server.URL, "wrong"), "edge-1", []string{"hotel_a"}, time.Minute)
+	ctx := context.Background()
+
+	err := agent.SyncNamespace(ctx, "hotel_a")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+
+	state, err := edge.SyncState(ctx, "hotel_a")
+	require.NoError(t, err)
+	assert.NotEmpty(t, state.LastError)
+	assert.True(t, state.LastSync.IsZero())
+}
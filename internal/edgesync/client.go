@@ -0,0 +1,121 @@
+// Package edgesync keeps an edge instance in sync with an upstream (cloud) Commander: it pulls the
+// namespace change feed and pushes the access log recorded on the edge
+package edgesync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"commander/internal/models"
+)
+
+// DefaultRequestTimeout bounds every request to the upstream
+const DefaultRequestTimeout = 30 * time.Second
+
+// ChangesPage is a page of the upstream change feed
+type ChangesPage struct {
+	Changes    []*models.Change `json:"changes"`
+	Checkpoint int64            `json:"checkpoint"`
+	More       bool             `json:"more"`
+	Snapshot   bool             `json:"snapshot"`
+}
+
+// Client calls the sync endpoints of an upstream Commander
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient creates a client for the upstream at baseURL (e.g. "https://commander.example.com")
+// token is sent as a bearer token when set
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: DefaultRequestTimeout},
+	}
+}
+
+// PullChanges reads the changes of namespace after revision since
+func (c *Client) PullChanges(ctx context.Context, namespace string, since int64, limit int) (*ChangesPage, error) {
+	query := url.Values{}
+	query.Set("since", strconv.FormatInt(since, 10))
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var page ChangesPage
+	if err := c.do(ctx, http.MethodGet, c.namespaceURL(namespace, "changes")+"?"+query.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// PushAccessLogs sends access log entries recorded on the edge edgeID and returns the number accepted
+func (c *Client) PushAccessLogs(ctx context.Context, namespace, edgeID string, entries []*models.AccessLog) (int, error) {
+	body, err := json.Marshal(struct {
+		EdgeID  string              `json:"edge_id"`
+		Entries []*models.AccessLog `json:"entries"`
+	}{edgeID, entries})
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Accepted int `json:"accepted"`
+	}
+	if err := c.do(ctx, http.MethodPost, c.namespaceURL(namespace, "access-logs"), body, &response); err != nil {
+		return 0, err
+	}
+	return response.Accepted, nil
+}
+
+// namespaceURL returns the URL of a sync endpoint of namespace
+func (c *Client) namespaceURL(namespace, endpoint string) string {
+	return c.baseURL + "/api/v1/namespace/" + url.PathEscape(namespace) + "/sync/" + endpoint
+}
+
+// do sends a request and decodes a 200 response into v
+func (c *Client) do(ctx context.Context, method, target string, body []byte, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck // Best effort cleanup
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&failure) //nolint:errcheck // The status code is reported either way
+		return fmt.Errorf("upstream %s %s returned %d: %s", method, req.URL.Path, resp.StatusCode, failure.Message)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode upstream response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAccessLogsResponse represents the response for listing access logs
+type ListAccessLogsResponse struct {
+	Message    string              `json:"message"`
+	Namespace  string              `json:"namespace"`
+	AccessLogs []*models.AccessLog `json:"access_logs"`
+	Count      int                 `json:"count"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+	Timestamp  string              `json:"timestamp"`
+}
+
+// ListAccessLogsHandler handles GET /api/v1/namespace/{namespace}/access-logs
+// Filters: device (SN), card (number or ID), outcome (granted/denied), from and to (RFC3339, to is exclusive)
+// Entries are returned oldest first; pagination: limit (default 100, max 1000) and cursor/next_cursor
+func ListAccessLogsHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		query := services.AccessLogQuery{
+			DeviceSN: c.Query("device"),
+			Card:     c.Query("card"),
+			Outcome:  c.Query("outcome"),
+			Cursor:   c.Query("cursor"),
+		}
+		if query.Outcome != "" && query.Outcome != models.AccessGranted && query.Outcome != models.AccessDenied {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "outcome must be granted or denied",
+				Code:    "INVALID_PARAMS",
+			})
+			return
+		}
+
+		var ok bool
+		if query.Limit, ok = parseListLimit(c); !ok {
+			return
+		}
+		if query.From, ok = parseTimeParam(c, "from"); !ok {
+			return
+		}
+		if query.To, ok = parseTimeParam(c, "to"); !ok {
+			return
+		}
+
+		entries, nextCursor, err := cardService.ListAccessLogs(c.Request.Context(), namespace, query)
+		if err != nil {
+			log.Printf("[AccessLog] Failed to list access logs: namespace=%s, error=%v", namespace, err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to list access logs",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, ListAccessLogsResponse{
+			Message:    "Successfully",
+			Namespace:  namespace,
+			AccessLogs: entries,
+			Count:      len(entries),
+			NextCursor: nextCursor,
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
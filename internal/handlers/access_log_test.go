@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAccessLogsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	repo := services.NewKVRepository(NewMockKV())
+	require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-1", SN: "SN001"}))
+	require.NoError(t, repo.SaveCard(ctx, "hotel_a", &models.Card{
+		ID:          "card-1",
+		Number:      "GUEST-1",
+		Devices:     []string{"SN001"},
+		EffectiveAt: now.Add(-time.Hour),
+		InvalidAt:   now.Add(time.Hour),
+	}))
+	service := services.NewCardService(repo, services.Policy{})
+	service.EnableAccessLog(0)
+
+	router := gin.New()
+	router.POST("/api/v1/namespace/:namespace", CardVerificationHandler(service))
+	router.GET("/api/v1/namespace/:namespace/access-logs", ListAccessLogsHandler(service))
+
+	for _, number := range []string{"GUEST-1", "GUEST-2"} {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/namespace/hotel_a", strings.NewReader(number))
+		req.Header.Set("X-Device-SN", "SN001")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedCount int
+	}{
+		{"all", "", http.StatusOK, 2},
+		{"granted", "outcome=granted", http.StatusOK, 1},
+		{"card", "card=card-1", http.StatusOK, 1},
+		{"device", "device=SN002", http.StatusOK, 0},
+		{"time range", "from=" + now.Add(-time.Minute).Format(time.RFC3339) + "&to=" + now.Add(time.Minute).Format(time.RFC3339), http.StatusOK, 2},
+		{"invalid outcome", "outcome=maybe", http.StatusBadRequest, 0},
+		{"invalid from", "from=yesterday", http.StatusBadRequest, 0},
+		{"invalid limit", "limit=0", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/access-logs?"+tt.query, "")
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var response ListAccessLogsResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedCount, response.Count)
+			for _, entry := range response.AccessLogs {
+				assert.Equal(t, models.ProtocolStandard, entry.Protocol)
+			}
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
@@ -13,6 +17,7 @@ import (
 // BatchSetRequest represents a batch set operation request
 type BatchSetRequest struct {
 	Operations []BatchSetOperation `json:"operations" binding:"required,min=1,max=1000"`
+	Atomic     bool                `json:"atomic,omitempty"` // Apply all operations or none (requires kv.Transactional)
 }
 
 // BatchSetOperation represents a single set operation in a batch
@@ -21,11 +26,13 @@ type BatchSetOperation struct {
 	Collection string      `json:"collection" binding:"required"`
 	Key        string      `json:"key" binding:"required"`
 	Value      interface{} `json:"value" binding:"required"`
+	TTL        int64       `json:"ttl,omitempty"` // Optional expiry in seconds (0 = never expires)
 }
 
 // BatchDeleteRequest represents a batch delete operation request
 type BatchDeleteRequest struct {
 	Operations []BatchDeleteOperation `json:"operations" binding:"required,min=1,max=1000"`
+	Atomic     bool                   `json:"atomic,omitempty"` // Apply all operations or none (requires kv.Transactional)
 }
 
 // BatchDeleteOperation represents a single delete operation in a batch
@@ -35,6 +42,18 @@ type BatchDeleteOperation struct {
 	Key        string `json:"key" binding:"required"`
 }
 
+// BatchGetRequest represents a batch get operation request
+type BatchGetRequest struct {
+	Operations []BatchGetOperation `json:"operations" binding:"required,min=1,max=1000"`
+}
+
+// BatchGetOperation represents a single key to read in a batch
+type BatchGetOperation struct {
+	Namespace  string `json:"namespace" binding:"required"`
+	Collection string `json:"collection" binding:"required"`
+	Key        string `json:"key" binding:"required"`
+}
+
 // BatchOperationResult represents the result of a single batch operation
 type BatchOperationResult struct {
 	Namespace  string `json:"namespace"`
@@ -50,6 +69,8 @@ type BatchSetResponse struct {
 	Results      []BatchOperationResult `json:"results"`
 	SuccessCount int                    `json:"success_count"`
 	FailureCount int                    `json:"failure_count"`
+	Atomic       bool                   `json:"atomic,omitempty"`
+	RolledBack   bool                   `json:"rolled_back,omitempty"` // Atomic batch failed and nothing was written
 	Timestamp    string                 `json:"timestamp"`
 }
 
@@ -59,11 +80,34 @@ type BatchDeleteResponse struct {
 	Results      []BatchOperationResult `json:"results"`
 	SuccessCount int                    `json:"success_count"`
 	FailureCount int                    `json:"failure_count"`
+	Atomic       bool                   `json:"atomic,omitempty"`
+	RolledBack   bool                   `json:"rolled_back,omitempty"` // Atomic batch failed and nothing was written
 	Timestamp    string                 `json:"timestamp"`
 }
 
+// BatchGetResult represents the result of a single read in a batch get
+// Success is false with error "key not found" for missing keys
+type BatchGetResult struct {
+	Namespace  string      `json:"namespace"`
+	Collection string      `json:"collection"`
+	Key        string      `json:"key"`
+	Success    bool        `json:"success"`
+	Value      interface{} `json:"value,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// BatchGetResponse represents the response for a batch get operation
+type BatchGetResponse struct {
+	Message      string           `json:"message"`
+	Results      []BatchGetResult `json:"results"`
+	SuccessCount int              `json:"success_count"`
+	FailureCount int              `json:"failure_count"`
+	Timestamp    string           `json:"timestamp"`
+}
+
 // BatchSetHandler handles POST /api/v1/kv/batch (set)
 // Sets multiple key-value pairs in a single request
+// With "atomic": true the operations are applied all-or-nothing by backends implementing kv.Transactional
 func BatchSetHandler(kvStore kv.KV) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req BatchSetRequest
@@ -84,6 +128,50 @@ func BatchSetHandler(kvStore kv.KV) gin.HandlerFunc {
 			return
 		}
 
+		if req.Atomic {
+			tx, ok := transactional(c, kvStore)
+			if !ok {
+				return
+			}
+
+			results := make([]BatchOperationResult, 0, len(req.Operations))
+			ops := make([]kv.Op, 0, len(req.Operations))
+			for _, op := range req.Operations {
+				result := BatchOperationResult{
+					Namespace:  op.Namespace,
+					Collection: op.Collection,
+					Key:        op.Key,
+				}
+				namespace, valueJSON, errMsg := validateSetOperation(op)
+				result.Error = errMsg
+				results = append(results, result)
+				ops = append(ops, kv.Op{
+					Type:       kv.OpSet,
+					Namespace:  namespace,
+					Collection: op.Collection,
+					Key:        op.Key,
+					Value:      valueJSON,
+					TTL:        time.Duration(op.TTL) * time.Second,
+				})
+			}
+
+			rolledBack, ok := applyAtomic(c, tx, ops, results, "failed to set key: ")
+			if !ok {
+				return
+			}
+			successCount, failureCount := countResults(results)
+			c.JSON(http.StatusOK, BatchSetResponse{
+				Message:      atomicMessage(rolledBack),
+				Results:      results,
+				SuccessCount: successCount,
+				FailureCount: failureCount,
+				Atomic:       true,
+				RolledBack:   rolledBack,
+				Timestamp:    time.Now().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+
 		results := make([]BatchOperationResult, 0, len(req.Operations))
 		successCount := 0
 		failureCount := 0
@@ -99,27 +187,17 @@ func BatchSetHandler(kvStore kv.KV) gin.HandlerFunc {
 			}
 
 			// Validate operation
-			if op.Namespace == "" || op.Collection == "" || op.Key == "" {
-				result.Error = "namespace, collection, and key are required"
-				failureCount++
-				results = append(results, result)
-				continue
-			}
-
-			// Normalize namespace
-			namespace := kv.NormalizeNamespace(op.Namespace)
-
-			// Marshal value to JSON
-			valueJSON, err := marshalJSON(op.Value)
-			if err != nil {
-				result.Error = "failed to encode value: " + err.Error()
+			namespace, valueJSON, errMsg := validateSetOperation(op)
+			if errMsg != "" {
+				result.Error = errMsg
 				failureCount++
 				results = append(results, result)
 				continue
 			}
 
 			// Set value in KV store
-			if err := kvStore.Set(ctx, namespace, op.Collection, op.Key, valueJSON); err != nil {
+			ttl := time.Duration(op.TTL) * time.Second
+			if err := kvStore.SetWithTTL(ctx, namespace, op.Collection, op.Key, valueJSON, ttl); err != nil {
 				result.Error = "failed to set key: " + err.Error()
 				failureCount++
 				results = append(results, result)
@@ -143,6 +221,8 @@ func BatchSetHandler(kvStore kv.KV) gin.HandlerFunc {
 
 // BatchDeleteHandler handles DELETE /api/v1/kv/batch (delete)
 // Deletes multiple keys in a single request
+// With "atomic": true the operations are applied all-or-nothing by backends implementing kv.Transactional;
+// deleting a missing key then rolls back the whole batch
 func BatchDeleteHandler(kvStore kv.KV) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req BatchDeleteRequest
@@ -163,6 +243,52 @@ func BatchDeleteHandler(kvStore kv.KV) gin.HandlerFunc {
 			return
 		}
 
+		if req.Atomic {
+			tx, ok := transactional(c, kvStore)
+			if !ok {
+				return
+			}
+
+			results := make([]BatchOperationResult, 0, len(req.Operations))
+			ops := make([]kv.Op, 0, len(req.Operations))
+			for _, op := range req.Operations {
+				result := BatchOperationResult{
+					Namespace:  op.Namespace,
+					Collection: op.Collection,
+					Key:        op.Key,
+				}
+				if op.Namespace == "" || op.Collection == "" || op.Key == "" {
+					result.Error = "namespace, collection, and key are required"
+				}
+				if result.Error == "" && !validNames(op.Namespace, op.Collection) {
+					result.Error = invalidNameMessage
+				}
+				results = append(results, result)
+				ops = append(ops, kv.Op{
+					Type:       kv.OpDelete,
+					Namespace:  kv.NormalizeNamespace(op.Namespace),
+					Collection: op.Collection,
+					Key:        op.Key,
+				})
+			}
+
+			rolledBack, ok := applyAtomic(c, tx, ops, results, "failed to delete key: ")
+			if !ok {
+				return
+			}
+			successCount, failureCount := countResults(results)
+			c.JSON(http.StatusOK, BatchDeleteResponse{
+				Message:      atomicMessage(rolledBack),
+				Results:      results,
+				SuccessCount: successCount,
+				FailureCount: failureCount,
+				Atomic:       true,
+				RolledBack:   rolledBack,
+				Timestamp:    time.Now().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+
 		results := make([]BatchOperationResult, 0, len(req.Operations))
 		successCount := 0
 		failureCount := 0
@@ -184,6 +310,12 @@ func BatchDeleteHandler(kvStore kv.KV) gin.HandlerFunc {
 				results = append(results, result)
 				continue
 			}
+			if !validNames(op.Namespace, op.Collection) {
+				result.Error = invalidNameMessage
+				failureCount++
+				results = append(results, result)
+				continue
+			}
 
 			// Normalize namespace
 			namespace := kv.NormalizeNamespace(op.Namespace)
@@ -211,6 +343,190 @@ func BatchDeleteHandler(kvStore kv.KV) gin.HandlerFunc {
 	}
 }
 
+// BatchGetHandler handles POST /api/v1/kv/batch/get
+// Reads multiple keys in a single request using kv.GetMany (one backend round trip where supported)
+func BatchGetHandler(kvStore kv.KV) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req BatchGetRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "invalid request body: " + err.Error(),
+				Code:    "INVALID_BODY",
+			})
+			return
+		}
+
+		// Validate that we don't have too many operations
+		if len(req.Operations) == 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "at least one operation is required",
+				Code:    "EMPTY_OPERATIONS",
+			})
+			return
+		}
+
+		results := make([]BatchGetResult, len(req.Operations))
+		keys := make([]kv.Key, 0, len(req.Operations))
+		positions := make([]int, 0, len(req.Operations))
+		for i, op := range req.Operations {
+			results[i] = BatchGetResult{
+				Namespace:  op.Namespace,
+				Collection: op.Collection,
+				Key:        op.Key,
+			}
+
+			// Validate operation
+			if op.Namespace == "" || op.Collection == "" || op.Key == "" {
+				results[i].Error = "namespace, collection, and key are required"
+				continue
+			}
+			if !validNames(op.Namespace, op.Collection) {
+				results[i].Error = invalidNameMessage
+				continue
+			}
+
+			keys = append(keys, kv.Key{
+				Namespace:  kv.NormalizeNamespace(op.Namespace),
+				Collection: op.Collection,
+				Key:        op.Key,
+			})
+			positions = append(positions, i)
+		}
+
+		values, err := kv.GetMany(c.Request.Context(), kvStore, keys)
+		if err != nil {
+			log.Printf("[Batch] Failed to get keys: keys=%d, error=%v", len(keys), err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to retrieve keys",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		successCount := 0
+		for j, i := range positions {
+			if !values[j].Found {
+				results[i].Error = "key not found"
+				continue
+			}
+
+			// Decode value as JSON for response
+			var decodedValue interface{}
+			if err := unmarshalJSON(values[j].Value, &decodedValue); err != nil {
+				results[i].Error = "failed to decode value"
+				continue
+			}
+			results[i].Value = decodedValue
+			results[i].Success = true
+			successCount++
+		}
+
+		c.JSON(http.StatusOK, BatchGetResponse{
+			Message:      "Batch operation completed",
+			Results:      results,
+			SuccessCount: successCount,
+			FailureCount: len(results) - successCount,
+			Timestamp:    time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// validateSetOperation checks a batch set operation and encodes its value
+// Returns the normalized namespace and JSON value, or a non-empty error message
+func validateSetOperation(op BatchSetOperation) (namespace string, valueJSON []byte, errMsg string) {
+	if op.Namespace == "" || op.Collection == "" || op.Key == "" {
+		return "", nil, "namespace, collection, and key are required"
+	}
+	if !validNames(op.Namespace, op.Collection) {
+		return "", nil, invalidNameMessage
+	}
+	if op.TTL < 0 {
+		return "", nil, "ttl must not be negative"
+	}
+
+	valueJSON, err := marshalJSON(op.Value)
+	if err != nil {
+		return "", nil, "failed to encode value: " + err.Error()
+	}
+	return kv.NormalizeNamespace(op.Namespace), valueJSON, ""
+}
+
+// transactional returns the kv.Transactional capability of the store
+// It writes a 501 response and returns false if the backend does not support atomic batches
+func transactional(c *gin.Context, kvStore kv.KV) (kv.Transactional, bool) {
+	tx, ok := kvStore.(kv.Transactional)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, ErrorResponse{
+			Message: "atomic batches are not supported by this backend",
+			Code:    "NOT_IMPLEMENTED",
+		})
+	}
+	return tx, ok
+}
+
+// applyAtomic applies ops in one transaction and fills in results (results[i] belongs to ops[i])
+// Results that already carry a validation error abort the batch before anything is written
+// Returns whether the batch was rolled back; on a backend error not tied to an operation it writes
+// a 500 response (501 if the deployment cannot run transactions) and returns ok=false
+func applyAtomic(c *gin.Context, tx kv.Transactional, ops []kv.Op, results []BatchOperationResult, errPrefix string) (rolledBack, ok bool) {
+	rolledBack = slices.ContainsFunc(results, func(r BatchOperationResult) bool { return r.Error != "" })
+
+	if !rolledBack {
+		err := tx.Apply(c.Request.Context(), ops)
+		var txErr *kv.TxError
+		switch {
+		case err == nil:
+		case errors.Is(err, kv.ErrTransactionsUnsupported):
+			log.Printf("[Batch] Atomic batch rejected: operations=%d, error=%v", len(ops), err)
+			c.JSON(http.StatusNotImplemented, ErrorResponse{
+				Message: "atomic batches are not supported by this backend",
+				Code:    "NOT_IMPLEMENTED",
+			})
+			return false, false
+		case errors.As(err, &txErr) && txErr.Index >= 0 && txErr.Index < len(results):
+			results[txErr.Index].Error = errPrefix + txErr.Err.Error()
+			rolledBack = true
+		default:
+			log.Printf("[Batch] Atomic batch failed: operations=%d, error=%v", len(ops), err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to apply atomic batch",
+				Code:    "INTERNAL_ERROR",
+			})
+			return false, false
+		}
+	}
+
+	for i := range results {
+		switch {
+		case !rolledBack:
+			results[i].Success = true
+		case results[i].Error == "":
+			results[i].Error = "rolled back"
+		}
+	}
+	return rolledBack, true
+}
+
+// countResults returns the number of successful and failed operations
+func countResults(results []BatchOperationResult) (successCount, failureCount int) {
+	for _, result := range results {
+		if result.Success {
+			successCount++
+		} else {
+			failureCount++
+		}
+	}
+	return successCount, failureCount
+}
+
+// atomicMessage returns the response message for an atomic batch
+func atomicMessage(rolledBack bool) string {
+	if rolledBack {
+		return "Batch operation rolled back"
+	}
+	return "Batch operation completed"
+}
+
 // ListKeysRequest represents a request to list keys in a collection
 type ListKeysRequest struct {
 	Limit  int `json:"limit,omitempty" binding:"max=10000"`
@@ -222,15 +538,18 @@ type ListKeysResponse struct {
 	Message    string   `json:"message"`
 	Namespace  string   `json:"namespace"`
 	Collection string   `json:"collection"`
+	Prefix     string   `json:"prefix,omitempty"`
 	Keys       []string `json:"keys"`
 	Total      int      `json:"total"`
 	Limit      int      `json:"limit"`
 	Offset     int      `json:"offset"`
+	NextCursor string   `json:"next_cursor,omitempty"`
 	Timestamp  string   `json:"timestamp"`
 }
 
 // ListKeysHandler handles GET /api/v1/kv/{namespace}/{collection}
-// Lists all keys in a collection (backend-dependent, may not be available for all backends)
+// Lists keys in a collection, optionally filtered by prefix
+// Supports offset pagination (limit/offset) and cursor pagination (cursor/next_cursor)
 func ListKeysHandler(kvStore kv.KV) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		namespace := c.Param("namespace")
@@ -245,27 +564,80 @@ func ListKeysHandler(kvStore kv.KV) gin.HandlerFunc {
 			return
 		}
 
+		// Normalize namespace
+		namespace = kv.NormalizeNamespace(namespace)
+
 		// Parse query parameters
 		limit := 1000
 		offset := 0
 		if limitParam := c.Query("limit"); limitParam != "" {
-			if err := scanInt(limitParam, &limit); err != nil || limit > 10000 {
+			if err := scanInt(limitParam, &limit); err != nil || limit <= 0 || limit > 10000 {
 				limit = 1000
 			}
 		}
 		if offsetParam := c.Query("offset"); offsetParam != "" {
 			_ = scanInt(offsetParam, &offset) //nolint:errcheck // offset parsing failure is intentionally ignored, default 0 is used
 		}
+		if offset < 0 {
+			offset = 0
+		}
 
-		// Try to list keys (this may not be supported by all backends)
-		// For now, return a not-implemented response
-		c.JSON(http.StatusNotImplemented, ErrorResponse{
-			Message: "listing keys is not implemented for this backend",
-			Code:    "NOT_IMPLEMENTED",
+		opts := kv.ListOptions{
+			Prefix: c.Query("prefix"),
+			Cursor: c.Query("cursor"),
+			Limit:  limit,
+		}
+
+		ctx := c.Request.Context()
+		result, err := listKeysWithOffset(ctx, kvStore, namespace, collection, opts, offset)
+		if err != nil {
+			log.Printf("[ListKeys] Failed to list keys: namespace=%s, collection=%s, error=%v",
+				namespace, collection, err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to list keys",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, ListKeysResponse{
+			Message:    "Successfully",
+			Namespace:  namespace,
+			Collection: collection,
+			Prefix:     opts.Prefix,
+			Keys:       result.Keys,
+			Total:      len(result.Keys),
+			Limit:      limit,
+			Offset:     offset,
+			NextCursor: result.NextCursor,
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
 		})
 	}
 }
 
+// listKeysWithOffset skips the first offset keys by paging through them, then returns the next page
+func listKeysWithOffset(ctx context.Context, kvStore kv.KV, namespace, collection string, opts kv.ListOptions, offset int) (*kv.ListResult, error) {
+	for offset > 0 {
+		skip := min(offset, 10000)
+		page, err := kvStore.List(ctx, namespace, collection, kv.ListOptions{
+			Prefix: opts.Prefix,
+			Cursor: opts.Cursor,
+			Limit:  skip,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if page.NextCursor == "" {
+			// Offset is past the last key
+			return &kv.ListResult{Keys: make([]string, 0)}, nil
+		}
+		opts.Cursor = page.NextCursor
+		offset -= skip
+	}
+
+	return kvStore.List(ctx, namespace, collection, opts)
+}
+
 // Helper functions
 
 // scanInt parses a string as an integer
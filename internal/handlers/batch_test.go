@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"commander/internal/kv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestBatchSetHandler tests POST /api/v1/kv/batch (set)
@@ -102,6 +107,109 @@ func TestBatchSetHandler(t *testing.T) {
 	}
 }
 
+// TestBatchSetHandler_TTL tests that per-operation TTLs are passed to the KV store
+func TestBatchSetHandler_TTL(t *testing.T) {
+	mockKV := NewMockKV()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/kv/batch", BatchSetHandler(mockKV))
+
+	request := BatchSetRequest{
+		Operations: []BatchSetOperation{
+			{Namespace: "default", Collection: "guest_codes", Key: "code1", Value: "1234", TTL: 3600},
+			{Namespace: "default", Collection: "guest_codes", Key: "code2", Value: "5678"},
+			{Namespace: "default", Collection: "guest_codes", Key: "code3", Value: "9999", TTL: -5},
+		},
+	}
+	bodyJSON, _ := json.Marshal(request)
+	req, _ := http.NewRequest("POST", "/api/v1/kv/batch", bytes.NewBuffer(bodyJSON))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp BatchSetResponse
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, resp.SuccessCount)
+	assert.Equal(t, 1, resp.FailureCount)
+	assert.False(t, resp.Results[2].Success)
+
+	assert.Equal(t, time.Hour, mockKV.ttls["default:guest_codes:code1"])
+	assert.Equal(t, time.Duration(0), mockKV.ttls["default:guest_codes:code2"])
+	_, stored := mockKV.ttls["default:guest_codes:code3"]
+	assert.False(t, stored)
+}
+
+// TestBatchGetHandler tests POST /api/v1/kv/batch/get
+func TestBatchGetHandler(t *testing.T) {
+	mockKV := NewMockKV()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/kv/batch/get", BatchGetHandler(mockKV))
+
+	ctx := context.Background()
+	_ = mockKV.Set(ctx, "default", "cards", "c1", []byte(`{"number":"0001"}`))
+	_ = mockKV.Set(ctx, "hotel_a", "cards", "c2", []byte(`"plain"`))
+	_ = mockKV.Set(ctx, "default", "cards", "broken", []byte(`{`))
+
+	tests := []struct {
+		name            string
+		body            interface{}
+		expectedStatus  int
+		expectedSuccess int
+		expectedFailure int
+	}{
+		{
+			name: "mixed found and missing keys",
+			body: BatchGetRequest{Operations: []BatchGetOperation{
+				{Namespace: "default", Collection: "cards", Key: "c1"},
+				{Namespace: "hotel_a", Collection: "cards", Key: "c2"},
+				{Namespace: "default", Collection: "cards", Key: "missing"},
+				{Namespace: "default", Collection: "cards", Key: "broken"},
+			}},
+			expectedStatus:  http.StatusOK,
+			expectedSuccess: 2,
+			expectedFailure: 2,
+		},
+		{
+			name:           "empty operations",
+			body:           BatchGetRequest{Operations: []BatchGetOperation{}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid body",
+			body:           "not json",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodyJSON, _ := json.Marshal(tt.body)
+			req, _ := http.NewRequest("POST", "/api/v1/kv/batch/get", bytes.NewBuffer(bodyJSON))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp BatchGetResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.expectedSuccess, resp.SuccessCount)
+			assert.Equal(t, tt.expectedFailure, resp.FailureCount)
+			assert.Equal(t, map[string]interface{}{"number": "0001"}, resp.Results[0].Value)
+			assert.Equal(t, "plain", resp.Results[1].Value)
+			assert.Equal(t, "key not found", resp.Results[2].Error)
+			assert.Equal(t, "failed to decode value", resp.Results[3].Error)
+		})
+	}
+}
+
 // TestBatchDeleteHandler tests DELETE /api/v1/kv/batch (delete)
 func TestBatchDeleteHandler(t *testing.T) {
 	mockKV := NewMockKV()
@@ -184,35 +292,85 @@ func TestListKeysHandler(t *testing.T) {
 	router := gin.New()
 	router.GET("/api/v1/kv/:namespace/:collection", ListKeysHandler(mockKV))
 
+	ctx := context.Background()
+	for _, key := range []string{"user3", "user1", "admin1", "user2"} {
+		_ = mockKV.Set(ctx, "default", "users", key, []byte(`"v"`))
+	}
+
 	tests := []struct {
 		name           string
-		namespace      string
-		collection     string
+		url            string
 		expectedStatus int
+		expectedKeys   []string
+		expectedCursor string
 	}{
 		{
 			name:           "list keys in collection",
-			namespace:      "default",
-			collection:     "users",
-			expectedStatus: http.StatusNotImplemented,
+			url:            "/api/v1/kv/default/users",
+			expectedStatus: http.StatusOK,
+			expectedKeys:   []string{"admin1", "user1", "user2", "user3"},
+		},
+		{
+			name:           "list keys with prefix",
+			url:            "/api/v1/kv/default/users?prefix=user",
+			expectedStatus: http.StatusOK,
+			expectedKeys:   []string{"user1", "user2", "user3"},
+		},
+		{
+			name:           "list keys with limit",
+			url:            "/api/v1/kv/default/users?limit=2",
+			expectedStatus: http.StatusOK,
+			expectedKeys:   []string{"admin1", "user1"},
+			expectedCursor: "user1",
+		},
+		{
+			name:           "list keys with limit and offset",
+			url:            "/api/v1/kv/default/users?limit=2&offset=1",
+			expectedStatus: http.StatusOK,
+			expectedKeys:   []string{"user1", "user2"},
+			expectedCursor: "user2",
+		},
+		{
+			name:           "list keys with cursor",
+			url:            "/api/v1/kv/default/users?cursor=user1",
+			expectedStatus: http.StatusOK,
+			expectedKeys:   []string{"user2", "user3"},
+		},
+		{
+			name:           "offset past the end",
+			url:            "/api/v1/kv/default/users?offset=10",
+			expectedStatus: http.StatusOK,
+			expectedKeys:   []string{},
+		},
+		{
+			name:           "empty collection",
+			url:            "/api/v1/kv/default/empty",
+			expectedStatus: http.StatusOK,
+			expectedKeys:   []string{},
 		},
 		{
 			name:           "invalid namespace",
-			namespace:      "",
-			collection:     "users",
+			url:            "/api/v1/kv//users",
 			expectedStatus: http.StatusBadRequest,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req, _ := http.NewRequest("GET",
-				"/api/v1/kv/"+tt.namespace+"/"+tt.collection,
-				http.NoBody)
+			req, _ := http.NewRequest("GET", tt.url, http.NoBody)
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedStatus == http.StatusOK {
+				var resp ListKeysResponse
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedKeys, resp.Keys)
+				assert.Equal(t, len(tt.expectedKeys), resp.Total)
+				assert.Equal(t, tt.expectedCursor, resp.NextCursor)
+			}
 		})
 	}
 }
@@ -270,3 +428,177 @@ func TestScanInt(t *testing.T) {
 		})
 	}
 }
+
+// MockTransactionalKV extends MockKV with all-or-nothing batches (kv.Transactional)
+type MockTransactionalKV struct {
+	*MockKV
+}
+
+// Apply checks every operation against a copy of the data before writing anything
+func (m *MockTransactionalKV) Apply(ctx context.Context, ops []kv.Op) error {
+	exists := make(map[string]bool)
+	for i, op := range ops {
+		id := op.Namespace + ":" + op.Collection + ":" + op.Key
+		if op.Type == kv.OpSet {
+			exists[id] = true
+			continue
+		}
+		present, seen := exists[id]
+		if !seen {
+			present, _ = m.Exists(ctx, op.Namespace, op.Collection, op.Key)
+		}
+		if !present {
+			return &kv.TxError{Index: i, Err: kv.ErrKeyNotFound}
+		}
+		exists[id] = false
+	}
+
+	for _, op := range ops {
+		if op.Type == kv.OpSet {
+			_ = m.SetWithTTL(ctx, op.Namespace, op.Collection, op.Key, op.Value, op.TTL)
+		} else {
+			_ = m.Delete(ctx, op.Namespace, op.Collection, op.Key)
+		}
+	}
+	return nil
+}
+
+func TestBatchHandlers_Atomic(t *testing.T) {
+	mockKV := &MockTransactionalKV{MockKV: NewMockKV()}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/kv/batch", BatchSetHandler(mockKV))
+	router.DELETE("/api/v1/kv/batch", BatchDeleteHandler(mockKV))
+
+	do := func(method string, body interface{}) *httptest.ResponseRecorder {
+		bodyJSON, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, "/api/v1/kv/batch", bytes.NewBuffer(bodyJSON))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("atomic set succeeds", func(t *testing.T) {
+		w := do("POST", BatchSetRequest{
+			Atomic: true,
+			Operations: []BatchSetOperation{
+				{Namespace: "default", Collection: "cards", Key: "c1", Value: "a"},
+				{Namespace: "default", Collection: "cards", Key: "c2", Value: "b", TTL: 60},
+			},
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp BatchSetResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, resp.Atomic)
+		assert.False(t, resp.RolledBack)
+		assert.Equal(t, 2, resp.SuccessCount)
+		assert.Equal(t, time.Minute, mockKV.ttls["default:cards:c2"])
+	})
+
+	t.Run("invalid operation rolls back the whole set", func(t *testing.T) {
+		w := do("POST", BatchSetRequest{
+			Atomic: true,
+			Operations: []BatchSetOperation{
+				{Namespace: "default", Collection: "cards", Key: "c3", Value: "c"},
+				{Namespace: "default", Collection: "cards", Key: "c4", Value: "d", TTL: -1},
+			},
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp BatchSetResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, resp.RolledBack)
+		assert.Equal(t, 0, resp.SuccessCount)
+		assert.Equal(t, 2, resp.FailureCount)
+		assert.Equal(t, "rolled back", resp.Results[0].Error)
+		assert.Equal(t, "ttl must not be negative", resp.Results[1].Error)
+
+		exists, _ := mockKV.Exists(context.Background(), "default", "cards", "c3")
+		assert.False(t, exists)
+	})
+
+	t.Run("missing key rolls back the whole delete", func(t *testing.T) {
+		w := do("DELETE", BatchDeleteRequest{
+			Atomic: true,
+			Operations: []BatchDeleteOperation{
+				{Namespace: "default", Collection: "cards", Key: "c1"},
+				{Namespace: "default", Collection: "cards", Key: "missing"},
+			},
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp BatchDeleteResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, resp.RolledBack)
+		assert.Contains(t, resp.Results[1].Error, "key not found")
+
+		exists, _ := mockKV.Exists(context.Background(), "default", "cards", "c1")
+		assert.True(t, exists)
+	})
+
+	t.Run("atomic delete succeeds", func(t *testing.T) {
+		w := do("DELETE", BatchDeleteRequest{
+			Atomic: true,
+			Operations: []BatchDeleteOperation{
+				{Namespace: "default", Collection: "cards", Key: "c1"},
+				{Namespace: "default", Collection: "cards", Key: "c2"},
+			},
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp BatchDeleteResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.False(t, resp.RolledBack)
+		assert.Equal(t, 2, resp.SuccessCount)
+	})
+}
+
+func TestBatchSetHandler_AtomicNotSupported(t *testing.T) {
+	mockKV := NewMockKV()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/kv/batch", BatchSetHandler(mockKV))
+
+	bodyJSON, _ := json.Marshal(BatchSetRequest{
+		Atomic:     true,
+		Operations: []BatchSetOperation{{Namespace: "default", Collection: "cards", Key: "c1", Value: "a"}},
+	})
+	req, _ := http.NewRequest("POST", "/api/v1/kv/batch", bytes.NewBuffer(bodyJSON))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+// standaloneKV implements kv.Transactional on a deployment that cannot run transactions
+type standaloneKV struct {
+	*MockKV
+}
+
+func (s *standaloneKV) Apply(context.Context, []kv.Op) error {
+	return fmt.Errorf("%w: Transaction numbers are only allowed on a replica set member or mongos", kv.ErrTransactionsUnsupported)
+}
+
+func TestBatchSetHandler_AtomicUnsupportedDeployment(t *testing.T) {
+	mockKV := &standaloneKV{MockKV: NewMockKV()}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/kv/batch", BatchSetHandler(mockKV))
+
+	bodyJSON, _ := json.Marshal(BatchSetRequest{
+		Atomic:     true,
+		Operations: []BatchSetOperation{{Namespace: "default", Collection: "cards", Key: "c1", Value: "a"}},
+	})
+	req, _ := http.NewRequest("POST", "/api/v1/kv/batch", bytes.NewBuffer(bodyJSON))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.NotContains(t, w.Body.String(), "replica set")
+	exists, _ := mockKV.Exists(context.Background(), "default", "cards", "c1")
+	assert.False(t, exists)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 
+	"commander/internal/models"
 	"commander/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -49,7 +50,7 @@ func CardVerificationHandler(cardService *services.CardService) gin.HandlerFunc
 		}
 
 		// Verify card
-		err = cardService.VerifyCard(c.Request.Context(), namespace, deviceSN, cardNumber)
+		err = cardService.VerifyCard(c.Request.Context(), namespace, deviceSN, cardNumber, models.ProtocolStandard)
 		if err != nil {
 			// Error logging already done in CardService
 			c.Status(mapErrorToStatusCode(err))
@@ -90,7 +91,7 @@ func CardVerificationVguangHandler(cardService *services.CardService) gin.Handle
 		}
 
 		// Verify card
-		err = cardService.VerifyCard(c.Request.Context(), namespace, deviceName, cardNumber)
+		err = cardService.VerifyCard(c.Request.Context(), namespace, deviceName, cardNumber, models.ProtocolVguang)
 		if err != nil {
 			// Error logging already done in CardService
 			c.Status(http.StatusNotFound)
@@ -152,6 +153,14 @@ func mapErrorToStatusCode(err error) int {
 		return http.StatusForbidden
 	case errors.Is(err, services.ErrCardNotYetValid):
 		return http.StatusForbidden
+	case errors.Is(err, services.ErrOutsideSchedule):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrCardRevoked):
+		return http.StatusGone
+	case errors.Is(err, services.ErrCardUsageExhausted):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrAntiPassback):
+		return http.StatusForbidden
 	default:
 		return http.StatusInternalServerError
 	}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CardRequest is the JSON body for creating and updating cards
+type CardRequest struct {
+	ID             string    `json:"id,omitempty"` // Optional on create, ignored on update
+	OrganizationID string    `json:"organization_id,omitempty"`
+	Number         string    `json:"number" binding:"required"`
+	DisplayName    string    `json:"display_name,omitempty"`
+	Devices        []string  `json:"devices"`            // Device SNs; each must exist in the namespace
+	Groups         []string  `json:"groups,omitempty"`   // Device group IDs; each must exist in the namespace
+	MaxUses        int       `json:"max_uses,omitempty"` // Successful verifications allowed (0 = unlimited)
+	EffectiveAt    time.Time `json:"effective_at"`
+	InvalidAt      time.Time `json:"invalid_at"`
+	BarcodeType    string    `json:"barcode_type,omitempty"`
+
+	// Optional recurring access windows
+	Schedule *models.Schedule `json:"schedule,omitempty"`
+}
+
+// CardResponse represents the response for single card operations
+type CardResponse struct {
+	Message   string       `json:"message"`
+	Namespace string       `json:"namespace"`
+	Card      *models.Card `json:"card"`
+	Timestamp string       `json:"timestamp"`
+}
+
+// ListCardsResponse represents the response for listing cards
+type ListCardsResponse struct {
+	Message    string         `json:"message"`
+	Namespace  string         `json:"namespace"`
+	Cards      []*models.Card `json:"cards"`
+	Count      int            `json:"count"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Timestamp  string         `json:"timestamp"`
+}
+
+// DeleteCardResponse represents the response for deleting a card
+type DeleteCardResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+}
+
+// ListCardsHandler handles GET /api/v1/namespace/{namespace}/cards
+// Filters: device (SN), number (prefix), valid_at (RFC3339), revoked (true/false)
+// Pagination: limit (default 100, max 1000) and cursor/next_cursor
+func ListCardsHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		query := services.CardQuery{
+			Device: c.Query("device"),
+			Number: c.Query("number"),
+			Cursor: c.Query("cursor"),
+		}
+		limit, ok := parseListLimit(c)
+		if !ok {
+			return
+		}
+		query.Limit = limit
+		if query.ValidAt, ok = parseTimeParam(c, "valid_at"); !ok {
+			return
+		}
+		if revokedParam := c.Query("revoked"); revokedParam != "" {
+			revoked, err := strconv.ParseBool(revokedParam)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, ErrorResponse{
+					Message: "revoked must be true or false",
+					Code:    "INVALID_PARAMS",
+				})
+				return
+			}
+			query.Revoked = &revoked
+		}
+
+		cards, nextCursor, err := cardService.ListCards(c.Request.Context(), namespace, query)
+		if err != nil {
+			writeCardError(c, "list", namespace, "", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, ListCardsResponse{
+			Message:    "Successfully",
+			Namespace:  namespace,
+			Cards:      cards,
+			Count:      len(cards),
+			NextCursor: nextCursor,
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// GetCardHandler handles GET /api/v1/namespace/{namespace}/cards/{id}
+func GetCardHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		card, err := cardService.GetCard(c.Request.Context(), namespace, id)
+		if err != nil {
+			writeCardError(c, "get", namespace, id, err)
+			return
+		}
+
+		writeCard(c, http.StatusOK, namespace, card)
+	}
+}
+
+// CreateCardHandler handles POST /api/v1/namespace/{namespace}/cards
+// Returns 201 with the stored card, 409 if the ID or number is already used
+func CreateCardHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		var req CardRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "invalid request body: " + err.Error(),
+				Code:    "INVALID_BODY",
+			})
+			return
+		}
+
+		card, err := cardService.CreateCard(c.Request.Context(), namespace, req.toCard())
+		if err != nil {
+			writeCardError(c, "create", namespace, req.ID, err)
+			return
+		}
+
+		writeCard(c, http.StatusCreated, namespace, card)
+	}
+}
+
+// UpdateCardHandler handles PUT /api/v1/namespace/{namespace}/cards/{id}
+// Replaces the editable fields of the card
+func UpdateCardHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		var req CardRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "invalid request body: " + err.Error(),
+				Code:    "INVALID_BODY",
+			})
+			return
+		}
+
+		card, err := cardService.UpdateCard(c.Request.Context(), namespace, id, req.toCard())
+		if err != nil {
+			writeCardError(c, "update", namespace, id, err)
+			return
+		}
+
+		writeCard(c, http.StatusOK, namespace, card)
+	}
+}
+
+// DeleteCardHandler handles DELETE /api/v1/namespace/{namespace}/cards/{id}
+func DeleteCardHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		if err := cardService.DeleteCard(c.Request.Context(), namespace, id); err != nil {
+			writeCardError(c, "delete", namespace, id, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, DeleteCardResponse{
+			Message:   "Successfully",
+			Namespace: namespace,
+			ID:        id,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// RevokeCardHandler handles POST /api/v1/namespace/{namespace}/cards/{id}/revoke
+// Sets revoked_at and moves invalid_at to now, so the card stops verifying immediately
+func RevokeCardHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		card, err := cardService.RevokeCard(c.Request.Context(), namespace, id)
+		if err != nil {
+			writeCardError(c, "revoke", namespace, id, err)
+			return
+		}
+
+		writeCard(c, http.StatusOK, namespace, card)
+	}
+}
+
+// parseListLimit parses the optional limit query parameter (0 when absent)
+// On invalid input it writes a 400 response and returns false
+func parseListLimit(c *gin.Context) (int, bool) {
+	limitParam := c.Query("limit")
+	if limitParam == "" {
+		return 0, true
+	}
+
+	limit, err := strconv.Atoi(limitParam)
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Message: "limit must be a positive integer",
+			Code:    "INVALID_PARAMS",
+		})
+		return 0, false
+	}
+	return limit, true
+}
+
+// parseTimeParam parses the optional RFC3339 query parameter name (zero time when absent)
+// On invalid input it writes a 400 response and returns false
+func parseTimeParam(c *gin.Context, name string) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Message: name + " must be an RFC3339 timestamp",
+			Code:    "INVALID_PARAMS",
+		})
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// toCard converts the request body to a card model
+func (r *CardRequest) toCard() *models.Card {
+	return &models.Card{
+		ID:             r.ID,
+		OrganizationID: r.OrganizationID,
+		Number:         r.Number,
+		DisplayName:    r.DisplayName,
+		Devices:        r.Devices,
+		Groups:         r.Groups,
+		MaxUses:        r.MaxUses,
+		EffectiveAt:    r.EffectiveAt,
+		InvalidAt:      r.InvalidAt,
+		BarcodeType:    r.BarcodeType,
+		Schedule:       r.Schedule,
+	}
+}
+
+// writeCard writes a CardResponse
+func writeCard(c *gin.Context, status int, namespace string, card *models.Card) {
+	c.JSON(status, CardResponse{
+		Message:   "Successfully",
+		Namespace: namespace,
+		Card:      card,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// writeCardError maps card management errors to HTTP responses
+// Validation errors are returned to the client; anything else is logged and reported generically
+func writeCardError(c *gin.Context, operation, namespace, id string, err error) {
+	switch {
+	case errors.Is(err, services.ErrCardNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Message: "card not found",
+			Code:    "CARD_NOT_FOUND",
+		})
+	case errors.Is(err, services.ErrInvalidCard), errors.Is(err, services.ErrUnknownDevice),
+		errors.Is(err, services.ErrUnknownDeviceGroup):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Message: err.Error(),
+			Code:    "VALIDATION_ERROR",
+		})
+	case errors.Is(err, services.ErrCardExists), errors.Is(err, services.ErrCardNumberExists):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Message: err.Error(),
+			Code:    "CARD_EXISTS",
+		})
+	default:
+		log.Printf("[CardAdmin] Failed to %s card: namespace=%s, card_id=%s, error=%v", operation, namespace, id, err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Message: "failed to " + operation + " card",
+			Code:    "INTERNAL_ERROR",
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupCardAdminRouter registers the card management routes on a KV-backed service with one device (SN001)
+func setupCardAdminRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	repo := services.NewKVRepository(NewMockKV())
+	require.NoError(t, repo.SaveDevice(context.Background(), "hotel_a", &models.Device{ID: "device-1", SN: "SN001"}))
+	service := services.NewCardService(repo, services.Policy{})
+
+	router := gin.New()
+	router.GET("/api/v1/namespace/:namespace/cards", ListCardsHandler(service))
+	router.POST("/api/v1/namespace/:namespace/cards", CreateCardHandler(service))
+	router.GET("/api/v1/namespace/:namespace/cards/:id", GetCardHandler(service))
+	router.PUT("/api/v1/namespace/:namespace/cards/:id", UpdateCardHandler(service))
+	router.DELETE("/api/v1/namespace/:namespace/cards/:id", DeleteCardHandler(service))
+	router.POST("/api/v1/namespace/:namespace/cards/:id/revoke", RevokeCardHandler(service))
+	return router
+}
+
+// cardBody builds a JSON card request valid for the next day
+func cardBody(id, number string, devices ...string) string {
+	now := time.Now().UTC()
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":           id,
+		"number":       number,
+		"devices":      devices,
+		"effective_at": now.Add(-time.Hour).Format(time.RFC3339),
+		"invalid_at":   now.Add(24 * time.Hour).Format(time.RFC3339),
+	})
+	return string(body)
+}
+
+func serveCardRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateCardHandler(t *testing.T) {
+	router := setupCardAdminRouter(t)
+
+	w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/cards", cardBody("card-1", "GUEST-1", "SN001"))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var response CardResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Successfully", response.Message)
+	assert.Equal(t, "card-1", response.Card.ID)
+	assert.Equal(t, []string{"SN001"}, response.Card.Devices)
+
+	now := time.Now().UTC()
+	reversed, _ := json.Marshal(map[string]interface{}{
+		"number":       "GUEST-9",
+		"effective_at": now.Format(time.RFC3339),
+		"invalid_at":   now.Add(-time.Hour).Format(time.RFC3339),
+	})
+	badSchedule, _ := json.Marshal(map[string]interface{}{
+		"number":       "STAFF-1",
+		"effective_at": now.Format(time.RFC3339),
+		"invalid_at":   now.Add(time.Hour).Format(time.RFC3339),
+		"schedule":     map[string]interface{}{"timezone": "Asia/Tokyo", "windows": []map[string]string{{"start": "18:00", "end": "18:00"}}},
+	})
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"invalid json", "{", http.StatusBadRequest, "INVALID_BODY"},
+		{"missing number", `{"effective_at":"2026-01-01T00:00:00Z","invalid_at":"2026-01-02T00:00:00Z"}`, http.StatusBadRequest, "INVALID_BODY"},
+		{"reversed window", string(reversed), http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"unknown device", cardBody("", "GUEST-2", "SN404"), http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"invalid schedule", string(badSchedule), http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"duplicate number", cardBody("", "GUEST-1"), http.StatusConflict, "CARD_EXISTS"},
+		{"duplicate id", cardBody("card-1", "GUEST-3"), http.StatusConflict, "CARD_EXISTS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/cards", tt.body)
+			assert.Equal(t, tt.expectedCode, w.Code)
+
+			var errResp ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+			assert.Equal(t, tt.expectedErr, errResp.Code)
+		})
+	}
+}
+
+func TestCardAdminHandlers_Lifecycle(t *testing.T) {
+	router := setupCardAdminRouter(t)
+
+	w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/cards", cardBody("card-1", "GUEST-1", "SN001"))
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/cards", cardBody("card-2", "STAFF-1"))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// Get
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/cards/card-1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/cards/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// List with filters
+	var list ListCardsResponse
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/cards?device=SN001", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, 1, list.Count)
+	assert.Equal(t, "card-1", list.Cards[0].ID)
+
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/cards?limit=1", "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, 1, list.Count)
+	assert.Equal(t, "card-1", list.NextCursor)
+
+	// Update
+	w = serveCardRequest(router, http.MethodPut, "/api/v1/namespace/hotel_a/cards/card-1", cardBody("", "GUEST-1B", "SN001"))
+	require.Equal(t, http.StatusOK, w.Code)
+	var response CardResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "GUEST-1B", response.Card.Number)
+	w = serveCardRequest(router, http.MethodPut, "/api/v1/namespace/hotel_a/cards/card-1", cardBody("", "STAFF-1"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Revoke
+	w = serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/cards/card-1/revoke", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotNil(t, response.Card.RevokedAt)
+
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/cards?revoked=true", "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, 1, list.Count)
+
+	// Delete
+	w = serveCardRequest(router, http.MethodDelete, "/api/v1/namespace/hotel_a/cards/card-1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serveCardRequest(router, http.MethodDelete, "/api/v1/namespace/hotel_a/cards/card-1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestListCardsHandler_InvalidParams(t *testing.T) {
+	router := setupCardAdminRouter(t)
+
+	for _, query := range []string{"limit=0", "limit=abc", "valid_at=yesterday", "revoked=maybe"} {
+		t.Run(query, func(t *testing.T) {
+			w := serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/cards?"+query, "")
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
@@ -2,20 +2,23 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"commander/internal/models"
 	"commander/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCardVerificationHandler_POST_MissingHeader(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	mockService := services.NewCardService(&mongo.Client{})
+	mockService := services.NewCardService(services.NewKVRepository(NewMockKV()), services.Policy{})
 
 	router := gin.New()
 	router.POST("/api/v1/namespace/:namespace", CardVerificationHandler(mockService))
@@ -32,7 +35,7 @@ func TestCardVerificationHandler_POST_MissingHeader(t *testing.T) {
 
 func TestCardVerificationHandler_POST_EmptyBody(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	mockService := services.NewCardService(&mongo.Client{})
+	mockService := services.NewCardService(services.NewKVRepository(NewMockKV()), services.Policy{})
 
 	router := gin.New()
 	router.POST("/api/v1/namespace/:namespace", CardVerificationHandler(mockService))
@@ -50,7 +53,7 @@ func TestCardVerificationHandler_POST_EmptyBody(t *testing.T) {
 
 func TestCardVerificationHandler_POST_ValidRequest(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	mockService := services.NewCardService(&mongo.Client{})
+	mockService := services.NewCardService(services.NewKVRepository(NewMockKV()), services.Policy{})
 
 	router := gin.New()
 	router.POST("/api/v1/namespace/:namespace", CardVerificationHandler(mockService))
@@ -69,7 +72,7 @@ func TestCardVerificationHandler_POST_ValidRequest(t *testing.T) {
 
 func TestCardVerificationVguangHandler_POST_EmptyBody(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	mockService := services.NewCardService(&mongo.Client{})
+	mockService := services.NewCardService(services.NewKVRepository(NewMockKV()), services.Policy{})
 
 	router := gin.New()
 	router.POST("/api/v1/namespace/:namespace/device/:device_name/vguang", CardVerificationVguangHandler(mockService))
@@ -86,7 +89,7 @@ func TestCardVerificationVguangHandler_POST_EmptyBody(t *testing.T) {
 
 func TestCardVerificationVguangHandler_POST_ValidRequest(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	mockService := services.NewCardService(&mongo.Client{})
+	mockService := services.NewCardService(services.NewKVRepository(NewMockKV()), services.Policy{})
 
 	router := gin.New()
 	router.POST("/api/v1/namespace/:namespace/device/:device_name/vguang", CardVerificationVguangHandler(mockService))
@@ -102,6 +105,59 @@ func TestCardVerificationVguangHandler_POST_ValidRequest(t *testing.T) {
 	assert.Empty(t, w.Body.String())
 }
 
+func TestCardVerificationHandlers_KVRepository(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := services.NewKVRepository(NewMockKV())
+	ctx := context.Background()
+	now := time.Now()
+
+	err := repo.SaveDevice(ctx, "org_test", &models.Device{ID: "device-1", DeviceID: "device-1", SN: "SN001", Status: "active"})
+	require.NoError(t, err)
+	err = repo.SaveCard(ctx, "org_test", &models.Card{
+		ID:          "card-1",
+		Number:      "CARD001",
+		Devices:     []string{"SN001"},
+		EffectiveAt: now.Add(-time.Hour),
+		InvalidAt:   now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	service := services.NewCardService(repo, services.Policy{})
+	router := gin.New()
+	router.POST("/api/v1/namespace/:namespace", CardVerificationHandler(service))
+	router.POST("/api/v1/namespace/:namespace/device/:device_name/vguang", CardVerificationVguangHandler(service))
+
+	tests := []struct {
+		name         string
+		path         string
+		deviceSN     string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"standard success", "/api/v1/namespace/org_test", "SN001", "CARD001", http.StatusNoContent, ""},
+		{"standard unknown card", "/api/v1/namespace/org_test", "SN001", "CARD999", http.StatusNotFound, ""},
+		{"standard unknown device", "/api/v1/namespace/org_test", "SN999", "CARD001", http.StatusNotFound, ""},
+		{"vguang success", "/api/v1/namespace/org_test/device/SN001/vguang", "", "card001", http.StatusOK, "code=0000"},
+		{"vguang wrong namespace", "/api/v1/namespace/other/device/SN001/vguang", "", "card001", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.body))
+			if tt.deviceSN != "" {
+				req.Header.Set("X-Device-SN", tt.deviceSN)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
 func TestParseVguangCardNumber(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -250,6 +306,26 @@ func TestMapErrorToStatusCode(t *testing.T) {
 			err:          services.ErrCardNotYetValid,
 			expectedCode: http.StatusForbidden,
 		},
+		{
+			name:         "outside schedule",
+			err:          services.ErrOutsideSchedule,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "card revoked",
+			err:          services.ErrCardRevoked,
+			expectedCode: http.StatusGone,
+		},
+		{
+			name:         "card usage exhausted",
+			err:          services.ErrCardUsageExhausted,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "anti-passback violation",
+			err:          services.ErrAntiPassback,
+			expectedCode: http.StatusForbidden,
+		},
 	}
 
 	for _, tt := range tests {
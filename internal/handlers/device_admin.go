@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceRequest is the JSON body for registering and updating devices
+type DeviceRequest struct {
+	ID          string                 `json:"id,omitempty"` // Optional on register, ignored on update
+	TenantID    string                 `json:"tenant_id,omitempty"`
+	DeviceID    string                 `json:"device_id,omitempty"`
+	SN          string                 `json:"sn" binding:"required"`
+	DisplayName string                 `json:"display_name,omitempty"`
+	Direction   string                 `json:"direction,omitempty"` // Anti-passback: entry or exit, set together with zone
+	Zone        string                 `json:"zone,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// DeviceResponse represents the response for single device operations
+type DeviceResponse struct {
+	Message   string         `json:"message"`
+	Namespace string         `json:"namespace"`
+	Device    *models.Device `json:"device"`
+	Timestamp string         `json:"timestamp"`
+}
+
+// ListDevicesResponse represents the response for listing devices
+type ListDevicesResponse struct {
+	Message    string           `json:"message"`
+	Namespace  string           `json:"namespace"`
+	Devices    []*models.Device `json:"devices"`
+	Count      int              `json:"count"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	Timestamp  string           `json:"timestamp"`
+}
+
+// ListDevicesHandler handles GET /api/v1/namespace/{namespace}/devices
+// Filters: status; pagination: limit (default 100, max 1000) and cursor/next_cursor
+func ListDevicesHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		limit, ok := parseListLimit(c)
+		if !ok {
+			return
+		}
+		query := services.DeviceQuery{
+			Status: c.Query("status"),
+			Cursor: c.Query("cursor"),
+			Limit:  limit,
+		}
+
+		devices, nextCursor, err := cardService.ListDevices(c.Request.Context(), namespace, query)
+		if err != nil {
+			writeDeviceError(c, "list", namespace, "", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, ListDevicesResponse{
+			Message:    "Successfully",
+			Namespace:  namespace,
+			Devices:    devices,
+			Count:      len(devices),
+			NextCursor: nextCursor,
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// GetDeviceHandler handles GET /api/v1/namespace/{namespace}/devices/{id}
+func GetDeviceHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		device, err := cardService.GetDevice(c.Request.Context(), namespace, id)
+		if err != nil {
+			writeDeviceError(c, "get", namespace, id, err)
+			return
+		}
+
+		writeDevice(c, http.StatusOK, namespace, device)
+	}
+}
+
+// RegisterDeviceHandler handles POST /api/v1/namespace/{namespace}/devices
+// New devices start in the "pending" status; returns 409 if the ID or SN is already used
+func RegisterDeviceHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		var req DeviceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "invalid request body: " + err.Error(),
+				Code:    "INVALID_BODY",
+			})
+			return
+		}
+
+		device, err := cardService.RegisterDevice(c.Request.Context(), namespace, req.toDevice())
+		if err != nil {
+			writeDeviceError(c, "register", namespace, req.ID, err)
+			return
+		}
+
+		writeDevice(c, http.StatusCreated, namespace, device)
+	}
+}
+
+// UpdateDeviceHandler handles PUT /api/v1/namespace/{namespace}/devices/{id}
+// Replaces the descriptive fields of the device; the status is changed through the status endpoints
+func UpdateDeviceHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		var req DeviceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "invalid request body: " + err.Error(),
+				Code:    "INVALID_BODY",
+			})
+			return
+		}
+
+		device, err := cardService.UpdateDevice(c.Request.Context(), namespace, id, req.toDevice())
+		if err != nil {
+			writeDeviceError(c, "update", namespace, id, err)
+			return
+		}
+
+		writeDevice(c, http.StatusOK, namespace, device)
+	}
+}
+
+// DeviceStatusHandler handles the status endpoints of a device:
+// POST /api/v1/namespace/{namespace}/devices/{id}/activate, /deactivate and /decommission
+// Returns 409 INVALID_TRANSITION when the state machine does not allow the change
+func DeviceStatusHandler(cardService *services.CardService, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		device, err := cardService.SetDeviceStatus(c.Request.Context(), namespace, id, status)
+		if err != nil {
+			writeDeviceError(c, "change status of", namespace, id, err)
+			return
+		}
+
+		writeDevice(c, http.StatusOK, namespace, device)
+	}
+}
+
+// toDevice converts the request body to a device model
+func (r *DeviceRequest) toDevice() *models.Device {
+	return &models.Device{
+		ID:          r.ID,
+		TenantID:    r.TenantID,
+		DeviceID:    r.DeviceID,
+		SN:          r.SN,
+		DisplayName: r.DisplayName,
+		Direction:   r.Direction,
+		Zone:        r.Zone,
+		Metadata:    r.Metadata,
+	}
+}
+
+// writeDevice writes a DeviceResponse
+func writeDevice(c *gin.Context, status int, namespace string, device *models.Device) {
+	c.JSON(status, DeviceResponse{
+		Message:   "Successfully",
+		Namespace: namespace,
+		Device:    device,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// writeDeviceError maps device management errors to HTTP responses
+// Validation errors are returned to the client; anything else is logged and reported generically
+func writeDeviceError(c *gin.Context, operation, namespace, id string, err error) {
+	switch {
+	case errors.Is(err, services.ErrDeviceNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Message: "device not found",
+			Code:    "DEVICE_NOT_FOUND",
+		})
+	case errors.Is(err, services.ErrInvalidDevice):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Message: err.Error(),
+			Code:    "VALIDATION_ERROR",
+		})
+	case errors.Is(err, services.ErrDeviceExists), errors.Is(err, services.ErrDeviceSNExists):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Message: err.Error(),
+			Code:    "DEVICE_EXISTS",
+		})
+	case errors.Is(err, services.ErrInvalidStatusTransition):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Message: err.Error(),
+			Code:    "INVALID_TRANSITION",
+		})
+	default:
+		log.Printf("[DeviceAdmin] Failed to %s device: namespace=%s, device_id=%s, error=%v", operation, namespace, id, err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Message: "failed to " + operation + " device",
+			Code:    "INTERNAL_ERROR",
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDeviceAdminRouter registers the device management and policy routes on a KV-backed service
+func setupDeviceAdminRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	service := services.NewCardService(services.NewKVRepository(NewMockKV()), services.Policy{})
+
+	router := gin.New()
+	router.GET("/api/v1/namespace/:namespace/devices", ListDevicesHandler(service))
+	router.POST("/api/v1/namespace/:namespace/devices", RegisterDeviceHandler(service))
+	router.GET("/api/v1/namespace/:namespace/devices/:id", GetDeviceHandler(service))
+	router.PUT("/api/v1/namespace/:namespace/devices/:id", UpdateDeviceHandler(service))
+	router.POST("/api/v1/namespace/:namespace/devices/:id/activate", DeviceStatusHandler(service, "active"))
+	router.POST("/api/v1/namespace/:namespace/devices/:id/deactivate", DeviceStatusHandler(service, "inactive"))
+	router.POST("/api/v1/namespace/:namespace/devices/:id/decommission", DeviceStatusHandler(service, "decommissioned"))
+	router.GET("/api/v1/namespace/:namespace/policy", GetPolicyHandler(service))
+	router.PUT("/api/v1/namespace/:namespace/policy", SetPolicyHandler(service))
+	return router
+}
+
+func TestRegisterDeviceHandler(t *testing.T) {
+	router := setupDeviceAdminRouter(t)
+
+	w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/devices", `{"id":"device-1","sn":"SN001","display_name":"Lobby"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var response DeviceResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "device-1", response.Device.ID)
+	assert.Equal(t, "pending", response.Device.Status)
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"invalid json", `{`, http.StatusBadRequest, "INVALID_BODY"},
+		{"missing sn", `{"id":"device-2"}`, http.StatusBadRequest, "INVALID_BODY"},
+		{"blank sn", `{"sn":"  "}`, http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"duplicate id", `{"id":"device-1","sn":"SN002"}`, http.StatusConflict, "DEVICE_EXISTS"},
+		{"duplicate sn", `{"sn":"SN001"}`, http.StatusConflict, "DEVICE_EXISTS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/devices", tt.body)
+			assert.Equal(t, tt.expectedCode, w.Code)
+
+			var errResp ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+			assert.Equal(t, tt.expectedErr, errResp.Code)
+		})
+	}
+}
+
+func TestDeviceAdminHandlers_Lifecycle(t *testing.T) {
+	router := setupDeviceAdminRouter(t)
+
+	w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/devices", `{"id":"device-1","sn":"SN001"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/devices", `{"id":"device-2","sn":"SN002"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// Get
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/devices/device-1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/devices/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Update
+	w = serveCardRequest(router, http.MethodPut, "/api/v1/namespace/hotel_a/devices/device-1", `{"sn":"SN001","display_name":"Lobby"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response DeviceResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Lobby", response.Device.DisplayName)
+	w = serveCardRequest(router, http.MethodPut, "/api/v1/namespace/hotel_a/devices/device-1", `{"sn":"SN002"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Status transitions
+	w = serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/devices/device-1/deactivate", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var errResp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, "INVALID_TRANSITION", errResp.Code)
+
+	w = serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/devices/device-1/activate", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "active", response.Device.Status)
+
+	w = serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/devices/device-2/decommission", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/devices/missing/activate", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// List with status filter
+	var list ListDevicesResponse
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/devices?status=active", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Equal(t, 1, list.Count)
+	assert.Equal(t, "device-1", list.Devices[0].ID)
+
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/devices?limit=0", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPolicyHandlers(t *testing.T) {
+	router := setupDeviceAdminRouter(t)
+
+	var response PolicyResponse
+	w := serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/policy", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Policy.RequireActiveDevice)
+	assert.Nil(t, response.Overrides)
+
+	w = serveCardRequest(router, http.MethodPut, "/api/v1/namespace/hotel_a/policy", `{"require_active_device":true}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/policy", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Policy.RequireActiveDevice)
+	require.NotNil(t, response.Overrides)
+	require.NotNil(t, response.Overrides.RequireActiveDevice)
+
+	w = serveCardRequest(router, http.MethodPut, "/api/v1/namespace/hotel_a/policy", `{`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serveCardRequest(router, http.MethodPut, "/api/v1/namespace/hotel_a/policy", `{"clock_tolerance_seconds":-5}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceGroupRequest is the JSON body for creating and updating device groups
+type DeviceGroupRequest struct {
+	ID          string   `json:"id,omitempty"` // Required on create, ignored on update
+	DisplayName string   `json:"display_name,omitempty"`
+	Devices     []string `json:"devices"` // Device SNs; each must exist in the namespace
+}
+
+// DeviceGroupResponse represents the response for single device group operations
+type DeviceGroupResponse struct {
+	Message   string              `json:"message"`
+	Namespace string              `json:"namespace"`
+	Group     *models.DeviceGroup `json:"group"`
+	Timestamp string              `json:"timestamp"`
+}
+
+// ListDeviceGroupsResponse represents the response for listing device groups
+type ListDeviceGroupsResponse struct {
+	Message    string                `json:"message"`
+	Namespace  string                `json:"namespace"`
+	Groups     []*models.DeviceGroup `json:"groups"`
+	Count      int                   `json:"count"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+	Timestamp  string                `json:"timestamp"`
+}
+
+// DeleteDeviceGroupResponse represents the response for deleting a device group
+type DeleteDeviceGroupResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+}
+
+// ListDeviceGroupsHandler handles GET /api/v1/namespace/{namespace}/groups
+// Filters: device (SN); pagination: limit (default 100, max 1000) and cursor/next_cursor
+func ListDeviceGroupsHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		limit, ok := parseListLimit(c)
+		if !ok {
+			return
+		}
+		query := services.DeviceGroupQuery{
+			Device: c.Query("device"),
+			Cursor: c.Query("cursor"),
+			Limit:  limit,
+		}
+
+		groups, nextCursor, err := cardService.ListDeviceGroups(c.Request.Context(), namespace, query)
+		if err != nil {
+			writeDeviceGroupError(c, "list", namespace, "", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, ListDeviceGroupsResponse{
+			Message:    "Successfully",
+			Namespace:  namespace,
+			Groups:     groups,
+			Count:      len(groups),
+			NextCursor: nextCursor,
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// GetDeviceGroupHandler handles GET /api/v1/namespace/{namespace}/groups/{id}
+func GetDeviceGroupHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		group, err := cardService.GetDeviceGroup(c.Request.Context(), namespace, id)
+		if err != nil {
+			writeDeviceGroupError(c, "get", namespace, id, err)
+			return
+		}
+
+		writeDeviceGroup(c, http.StatusOK, namespace, group)
+	}
+}
+
+// CreateDeviceGroupHandler handles POST /api/v1/namespace/{namespace}/groups
+// Returns 409 if the group ID is already used
+func CreateDeviceGroupHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		var req DeviceGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "invalid request body: " + err.Error(),
+				Code:    "INVALID_BODY",
+			})
+			return
+		}
+
+		group, err := cardService.CreateDeviceGroup(c.Request.Context(), namespace, req.toDeviceGroup())
+		if err != nil {
+			writeDeviceGroupError(c, "create", namespace, req.ID, err)
+			return
+		}
+
+		writeDeviceGroup(c, http.StatusCreated, namespace, group)
+	}
+}
+
+// UpdateDeviceGroupHandler handles PUT /api/v1/namespace/{namespace}/groups/{id}
+// Replaces the display name and member devices of the group
+func UpdateDeviceGroupHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		var req DeviceGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "invalid request body: " + err.Error(),
+				Code:    "INVALID_BODY",
+			})
+			return
+		}
+
+		group, err := cardService.UpdateDeviceGroup(c.Request.Context(), namespace, id, req.toDeviceGroup())
+		if err != nil {
+			writeDeviceGroupError(c, "update", namespace, id, err)
+			return
+		}
+
+		writeDeviceGroup(c, http.StatusOK, namespace, group)
+	}
+}
+
+// DeleteDeviceGroupHandler handles DELETE /api/v1/namespace/{namespace}/groups/{id}
+func DeleteDeviceGroupHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		if err := cardService.DeleteDeviceGroup(c.Request.Context(), namespace, id); err != nil {
+			writeDeviceGroupError(c, "delete", namespace, id, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, DeleteDeviceGroupResponse{
+			Message:   "Successfully",
+			Namespace: namespace,
+			ID:        id,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// toDeviceGroup converts the request body to a device group model
+func (r *DeviceGroupRequest) toDeviceGroup() *models.DeviceGroup {
+	return &models.DeviceGroup{
+		ID:          r.ID,
+		DisplayName: r.DisplayName,
+		Devices:     r.Devices,
+	}
+}
+
+// writeDeviceGroup writes a DeviceGroupResponse
+func writeDeviceGroup(c *gin.Context, status int, namespace string, group *models.DeviceGroup) {
+	c.JSON(status, DeviceGroupResponse{
+		Message:   "Successfully",
+		Namespace: namespace,
+		Group:     group,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// writeDeviceGroupError maps device group errors to HTTP responses
+// Validation errors are returned to the client; anything else is logged and reported generically
+func writeDeviceGroupError(c *gin.Context, operation, namespace, id string, err error) {
+	switch {
+	case errors.Is(err, services.ErrDeviceGroupNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Message: "device group not found",
+			Code:    "DEVICE_GROUP_NOT_FOUND",
+		})
+	case errors.Is(err, services.ErrInvalidDeviceGroup), errors.Is(err, services.ErrUnknownDevice):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Message: err.Error(),
+			Code:    "VALIDATION_ERROR",
+		})
+	case errors.Is(err, services.ErrDeviceGroupExists):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Message: err.Error(),
+			Code:    "DEVICE_GROUP_EXISTS",
+		})
+	default:
+		log.Printf("[DeviceGroup] Failed to %s device group: namespace=%s, group_id=%s, error=%v", operation, namespace, id, err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Message: "failed to " + operation + " device group",
+			Code:    "INTERNAL_ERROR",
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDeviceGroupRouter registers the device group routes on a KV-backed service with two devices
+func setupDeviceGroupRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	repo := services.NewKVRepository(NewMockKV())
+	require.NoError(t, repo.SaveDevice(context.Background(), "hotel_a", &models.Device{ID: "device-1", SN: "SN001"}))
+	require.NoError(t, repo.SaveDevice(context.Background(), "hotel_a", &models.Device{ID: "device-2", SN: "SN002"}))
+	service := services.NewCardService(repo, services.Policy{})
+
+	router := gin.New()
+	router.GET("/api/v1/namespace/:namespace/groups", ListDeviceGroupsHandler(service))
+	router.POST("/api/v1/namespace/:namespace/groups", CreateDeviceGroupHandler(service))
+	router.GET("/api/v1/namespace/:namespace/groups/:id", GetDeviceGroupHandler(service))
+	router.PUT("/api/v1/namespace/:namespace/groups/:id", UpdateDeviceGroupHandler(service))
+	router.DELETE("/api/v1/namespace/:namespace/groups/:id", DeleteDeviceGroupHandler(service))
+	return router
+}
+
+func TestCreateDeviceGroupHandler(t *testing.T) {
+	router := setupDeviceGroupRouter(t)
+
+	w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/groups", `{"id":"floor-1","display_name":"Floor 1","devices":["SN001"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var response DeviceGroupResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "floor-1", response.Group.ID)
+	assert.Equal(t, []string{"SN001"}, response.Group.Devices)
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"invalid json", `{`, http.StatusBadRequest, "INVALID_BODY"},
+		{"missing id", `{"devices":["SN001"]}`, http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"unknown device", `{"id":"floor-2","devices":["SN999"]}`, http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"duplicate id", `{"id":"floor-1"}`, http.StatusConflict, "DEVICE_GROUP_EXISTS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/groups", tt.body)
+			assert.Equal(t, tt.expectedCode, w.Code)
+
+			var errResp ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+			assert.Equal(t, tt.expectedErr, errResp.Code)
+		})
+	}
+}
+
+func TestDeviceGroupHandlers_Lifecycle(t *testing.T) {
+	router := setupDeviceGroupRouter(t)
+
+	w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/groups", `{"id":"floor-1","devices":["SN001"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/groups", `{"id":"floor-2","devices":["SN002"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// Get
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/groups/floor-1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Update
+	w = serveCardRequest(router, http.MethodPut, "/api/v1/namespace/hotel_a/groups/floor-1", `{"devices":["SN001","SN002"]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// List filtered by device
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/groups?device=SN002", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListDeviceGroupsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, 2, list.Count)
+
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/groups?limit=abc", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Delete
+	w = serveCardRequest(router, http.MethodDelete, "/api/v1/namespace/hotel_a/groups/floor-1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+	}{
+		{"get deleted", http.MethodGet, ""},
+		{"update deleted", http.MethodPut, `{"devices":[]}`},
+		{"delete deleted", http.MethodDelete, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCardRequest(router, tt.method, "/api/v1/namespace/hotel_a/groups/floor-1", tt.body)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			var errResp ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+			assert.Equal(t, "DEVICE_GROUP_NOT_FOUND", errResp.Code)
+		})
+	}
+}
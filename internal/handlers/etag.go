@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// formatETag formats a key revision as a strong entity tag, e.g. "3"
+func formatETag(revision uint64) string {
+	return `"` + strconv.FormatUint(revision, 10) + `"`
+}
+
+// etagListMatches reports whether an If-Match / If-None-Match header value matches the current revision
+// The header holds "*" or a comma separated list of entity tags; weak tags (W/"3") compare by value
+// A revision of 0 means the key does not exist and matches nothing
+func etagListMatches(header string, revision uint64) bool {
+	if revision == 0 {
+		return false
+	}
+
+	current := formatETag(revision)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPreconditions reports whether the request carries If-Match or If-None-Match
+func hasPreconditions(c *gin.Context) bool {
+	return c.GetHeader("If-Match") != "" || c.GetHeader("If-None-Match") != ""
+}
+
+// preconditionsMet evaluates If-Match and If-None-Match against the current revision (0 = key missing)
+func preconditionsMet(c *gin.Context, revision uint64) bool {
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !etagListMatches(ifMatch, revision) {
+		return false
+	}
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagListMatches(ifNoneMatch, revision) {
+		return false
+	}
+	return true
+}
+
+// preconditionFailed writes the 412 response for a failed If-Match or If-None-Match check
+func preconditionFailed(c *gin.Context) {
+	c.JSON(http.StatusPreconditionFailed, ErrorResponse{
+		Message: "key was modified or does not match the requested version",
+		Code:    "PRECONDITION_FAILED",
+	})
+}
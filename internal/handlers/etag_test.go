@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtagListMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		revision uint64
+		expected bool
+	}{
+		{"exact match", `"3"`, 3, true},
+		{"mismatch", `"2"`, 3, false},
+		{"weak tag", `W/"3"`, 3, true},
+		{"list", `"1", "3"`, 3, true},
+		{"wildcard on existing key", "*", 1, true},
+		{"wildcard on missing key", "*", 0, false},
+		{"tag on missing key", `"0"`, 0, false},
+		{"unquoted tag", "3", 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, etagListMatches(tt.header, tt.revision))
+		})
+	}
+}
+
+func TestFormatETag(t *testing.T) {
+	assert.Equal(t, `"42"`, formatETag(42))
+}
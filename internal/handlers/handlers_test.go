@@ -2,6 +2,10 @@ package handlers
 
 import (
 	"commander/internal/config"
+	"commander/internal/database/cache"
+	"commander/internal/database/memory"
+	"commander/internal/services"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -73,6 +77,81 @@ func TestHealthHandler(t *testing.T) {
 	}
 }
 
+func TestStatusHealthHandler_EdgeCache(t *testing.T) {
+	edgeCache := services.NewEdgeCache(services.NewKVRepository(NewMockKV()), NewMockKV(), []string{"hotel_a"}, time.Minute, time.Second)
+
+	router := gin.New()
+	router.GET("/health", StatusHealthHandler(edgeCache, nil))
+
+	// A replica that never synced is stale
+	req, _ := http.NewRequest("GET", "/health", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	var response struct {
+		Status    string                   `json:"status"`
+		EdgeCache services.EdgeCacheStatus `json:"edge_cache"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Status != "degraded" {
+		t.Errorf("Expected status 'degraded', got '%s'", response.Status)
+	}
+	if len(response.EdgeCache.Namespaces) != 1 || response.EdgeCache.Namespaces[0].StaleSeconds != -1 {
+		t.Errorf("Expected one namespace that never synced, got %+v", response.EdgeCache.Namespaces)
+	}
+
+	if err := edgeCache.SyncNamespace(context.Background(), "hotel_a"); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Status != "healthy" || !response.EdgeCache.Online {
+		t.Errorf("Expected a healthy online edge cache, got '%s' %+v", response.Status, response.EdgeCache)
+	}
+}
+
+func TestStatusHealthHandler_KVCache(t *testing.T) {
+	store, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create memory KV: %v", err)
+	}
+	kvCache, err := cache.New(store, cache.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer func() { _ = kvCache.Close() }()
+	_, _ = kvCache.Get(context.Background(), "", "cards", "missing")
+
+	router := gin.New()
+	router.GET("/health", StatusHealthHandler(nil, kvCache))
+
+	req, _ := http.NewRequest("GET", "/health", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Status  string      `json:"status"`
+		KVCache cache.Stats `json:"kv_cache"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Status != "healthy" {
+		t.Errorf("Expected status 'healthy', got '%s'", response.Status)
+	}
+	if response.KVCache.Misses != 1 || response.KVCache.MaxEntries != cache.DefaultMaxEntries {
+		t.Errorf("Expected cache counters, got %+v", response.KVCache)
+	}
+}
+
 func TestRootHandler(t *testing.T) {
 	// Create test router
 	router := gin.New()
@@ -4,6 +4,9 @@ import (
 	"net/http"
 	"time"
 
+	"commander/internal/database/cache"
+	"commander/internal/services"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,3 +19,30 @@ func HealthHandler(c *gin.Context) {
 		"timestamp":   time.Now().UTC().Format(time.RFC3339),
 	})
 }
+
+// StatusHealthHandler handles health check requests on instances with an edge cache or a KV cache
+// Either may be nil. The response adds the replica state under edge_cache and the cache counters under
+// kv_cache; status is "degraded" while the primary is unreachable or the replica is stale. Verification
+// keeps working then, so the status code stays 200
+func StatusHealthHandler(edgeCache *services.EdgeCache, kvCache *cache.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response := gin.H{
+			"status":      "healthy",
+			"environment": "STANDARD",
+			"message":     "Commander service is running",
+			"timestamp":   time.Now().UTC().Format(time.RFC3339),
+		}
+		if edgeCache != nil {
+			status := edgeCache.Status()
+			if !status.Online || status.Stale {
+				response["status"] = "degraded"
+			}
+			response["edge_cache"] = status
+		}
+		if kvCache != nil {
+			response["kv_cache"] = kvCache.Stats()
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
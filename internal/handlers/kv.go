@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -13,7 +14,8 @@ import (
 
 // KVRequestBody represents the JSON body for KV operations
 type KVRequestBody struct {
-	Value interface{} `json:"value" binding:"required"` // The value to store (will be JSON-encoded)
+	Value interface{} `json:"value" binding:"required"`      // The value to store (will be JSON-encoded)
+	TTL   int64       `json:"ttl,omitempty" binding:"min=0"` // Optional expiry in seconds (0 = never expires)
 }
 
 // KVResponse represents a standard KV response
@@ -24,6 +26,7 @@ type KVResponse struct {
 	Key        string      `json:"key"`
 	Value      interface{} `json:"value,omitempty"`
 	Exists     bool        `json:"exists,omitempty"`
+	ExpiresAt  string      `json:"expires_at,omitempty"`
 	Timestamp  string      `json:"timestamp"`
 }
 
@@ -35,6 +38,7 @@ type ErrorResponse struct {
 
 // GetKVHandler handles GET /api/v1/kv/{namespace}/{collection}/{key}
 // Retrieves a value from the KV store
+// Backends implementing kv.Versioned add an ETag header and honor If-None-Match with 304 Not Modified
 func GetKVHandler(kvStore kv.KV) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		namespace := c.Param("namespace")
@@ -53,9 +57,16 @@ func GetKVHandler(kvStore kv.KV) gin.HandlerFunc {
 		// Normalize namespace
 		namespace = kv.NormalizeNamespace(namespace)
 
-		// Get value from KV store
+		// Get value from KV store, with its revision when the backend tracks one
 		ctx := c.Request.Context()
-		value, err := kvStore.Get(ctx, namespace, collection, key)
+		var value []byte
+		var revision uint64
+		var err error
+		if versioned, ok := kvStore.(kv.Versioned); ok {
+			value, revision, err = versioned.GetWithVersion(ctx, namespace, collection, key)
+		} else {
+			value, err = kvStore.Get(ctx, namespace, collection, key)
+		}
 		if err != nil {
 			if errors.Is(err, kv.ErrKeyNotFound) {
 				c.JSON(http.StatusNotFound, ErrorResponse{
@@ -71,6 +82,14 @@ func GetKVHandler(kvStore kv.KV) gin.HandlerFunc {
 			return
 		}
 
+		if revision > 0 {
+			c.Header("ETag", formatETag(revision))
+			if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagListMatches(ifNoneMatch, revision) {
+				c.Status(http.StatusNotModified)
+				return
+			}
+		}
+
 		// Decode value as JSON for response
 		var decodedValue interface{}
 		if err := unmarshalJSON(value, &decodedValue); err != nil {
@@ -94,6 +113,7 @@ func GetKVHandler(kvStore kv.KV) gin.HandlerFunc {
 
 // SetKVHandler handles POST /api/v1/kv/{namespace}/{collection}/{key}
 // Sets a value in the KV store
+// With If-Match or If-None-Match the write is a compare-and-set and returns 412 on conflict
 func SetKVHandler(kvStore kv.KV) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		namespace := c.Param("namespace")
@@ -134,7 +154,19 @@ func SetKVHandler(kvStore kv.KV) gin.HandlerFunc {
 
 		// Set value in KV store
 		ctx := c.Request.Context()
-		if err := kvStore.Set(ctx, namespace, collection, key, valueJSON); err != nil {
+		ttl := time.Duration(req.TTL) * time.Second
+		if hasPreconditions(c) {
+			if ttl > 0 {
+				c.JSON(http.StatusBadRequest, ErrorResponse{
+					Message: "ttl cannot be combined with If-Match or If-None-Match",
+					Code:    "INVALID_BODY",
+				})
+				return
+			}
+			if !conditionalSet(c, kvStore, namespace, collection, key, valueJSON) {
+				return
+			}
+		} else if err := kvStore.SetWithTTL(ctx, namespace, collection, key, valueJSON, ttl); err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{
 				Message: "failed to set key: " + err.Error(),
 				Code:    "INTERNAL_ERROR",
@@ -142,19 +174,25 @@ func SetKVHandler(kvStore kv.KV) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusCreated, KVResponse{
+		now := time.Now().UTC()
+		resp := KVResponse{
 			Message:    "Successfully",
 			Namespace:  namespace,
 			Collection: collection,
 			Key:        key,
 			Value:      req.Value,
-			Timestamp:  time.Now().UTC().Format(time.RFC3339),
-		})
+			Timestamp:  now.Format(time.RFC3339),
+		}
+		if ttl > 0 {
+			resp.ExpiresAt = now.Add(ttl).Format(time.RFC3339)
+		}
+		c.JSON(http.StatusCreated, resp)
 	}
 }
 
 // DeleteKVHandler handles DELETE /api/v1/kv/{namespace}/{collection}/{key}
 // Deletes a value from the KV store
+// With If-Match or If-None-Match the delete only happens if the key still has the expected revision
 func DeleteKVHandler(kvStore kv.KV) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		namespace := c.Param("namespace")
@@ -175,7 +213,18 @@ func DeleteKVHandler(kvStore kv.KV) gin.HandlerFunc {
 
 		// Delete value from KV store
 		ctx := c.Request.Context()
-		if err := kvStore.Delete(ctx, namespace, collection, key); err != nil {
+		if hasPreconditions(c) {
+			if !conditionalDelete(c, kvStore, namespace, collection, key) {
+				return
+			}
+		} else if err := kvStore.Delete(ctx, namespace, collection, key); err != nil {
+			if errors.Is(err, kv.ErrKeyNotFound) {
+				c.JSON(http.StatusNotFound, ErrorResponse{
+					Message: "key not found",
+					Code:    "KEY_NOT_FOUND",
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, ErrorResponse{
 				Message: "failed to delete key: " + err.Error(),
 				Code:    "INTERNAL_ERROR",
@@ -228,6 +277,100 @@ func HeadKVHandler(kvStore kv.KV) gin.HandlerFunc {
 
 // Helper functions
 
+// currentVersion returns the revision of a key for precondition checks (0 if it does not exist)
+// It writes the error response and returns false if the backend is not versioned or the read fails
+func currentVersion(c *gin.Context, kvStore kv.KV, namespace, collection, key string) (kv.Versioned, uint64, bool) {
+	versioned, ok := kvStore.(kv.Versioned)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, ErrorResponse{
+			Message: "conditional requests are not supported by this backend",
+			Code:    "NOT_IMPLEMENTED",
+		})
+		return nil, 0, false
+	}
+
+	_, revision, err := versioned.GetWithVersion(c.Request.Context(), namespace, collection, key)
+	if err != nil && !errors.Is(err, kv.ErrKeyNotFound) {
+		log.Printf("[KV] Failed to read version: namespace=%s, collection=%s, key=%s, error=%v",
+			namespace, collection, key, err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Message: "failed to read key version",
+			Code:    "INTERNAL_ERROR",
+		})
+		return nil, 0, false
+	}
+	return versioned, revision, true
+}
+
+// conditionalSet writes value if the request preconditions hold and sets the new ETag
+// It writes the error response and returns false otherwise
+func conditionalSet(c *gin.Context, kvStore kv.KV, namespace, collection, key string, value []byte) bool {
+	versioned, current, ok := currentVersion(c, kvStore, namespace, collection, key)
+	if !ok {
+		return false
+	}
+	if !preconditionsMet(c, current) {
+		preconditionFailed(c)
+		return false
+	}
+
+	revision, err := versioned.CompareAndSet(c.Request.Context(), namespace, collection, key, current, value)
+	if err != nil {
+		if errors.Is(err, kv.ErrVersionMismatch) {
+			// Another writer got in between the version read and the write
+			preconditionFailed(c)
+			return false
+		}
+		log.Printf("[KV] Failed to compare-and-set: namespace=%s, collection=%s, key=%s, error=%v",
+			namespace, collection, key, err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Message: "failed to set key",
+			Code:    "INTERNAL_ERROR",
+		})
+		return false
+	}
+
+	c.Header("ETag", formatETag(revision))
+	return true
+}
+
+// conditionalDelete deletes a key if the request preconditions hold
+// It writes the error response and returns false otherwise
+func conditionalDelete(c *gin.Context, kvStore kv.KV, namespace, collection, key string) bool {
+	versioned, current, ok := currentVersion(c, kvStore, namespace, collection, key)
+	if !ok {
+		return false
+	}
+	if !preconditionsMet(c, current) {
+		preconditionFailed(c)
+		return false
+	}
+	if current == 0 {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Message: "key not found",
+			Code:    "KEY_NOT_FOUND",
+		})
+		return false
+	}
+
+	err := versioned.CompareAndDelete(c.Request.Context(), namespace, collection, key, current)
+	if err != nil {
+		if errors.Is(err, kv.ErrVersionMismatch) || errors.Is(err, kv.ErrKeyNotFound) {
+			// Another writer got in between the version read and the delete
+			preconditionFailed(c)
+			return false
+		}
+		log.Printf("[KV] Failed to compare-and-delete: namespace=%s, collection=%s, key=%s, error=%v",
+			namespace, collection, key, err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Message: "failed to delete key",
+			Code:    "INTERNAL_ERROR",
+		})
+		return false
+	}
+	return true
+}
+
 // marshalJSON converts a value to JSON bytes
 func marshalJSON(value interface{}) ([]byte, error) {
 	return json.Marshal(value)
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"commander/internal/kv"
 
@@ -18,12 +19,14 @@ import (
 // MockKV is a mock implementation of kv.KV for testing
 type MockKV struct {
 	data map[string]map[string]map[string][]byte
+	ttls map[string]time.Duration // TTL passed to the last SetWithTTL, keyed by namespace:collection:key
 }
 
 // NewMockKV creates a new MockKV instance
 func NewMockKV() *MockKV {
 	return &MockKV{
 		data: make(map[string]map[string]map[string][]byte),
+		ttls: make(map[string]time.Duration),
 	}
 }
 
@@ -51,6 +54,12 @@ func (m *MockKV) Set(ctx context.Context, namespace, collection, key string, val
 	return nil
 }
 
+// SetWithTTL stores a value in the mock KV store and records the TTL (expiry is not simulated)
+func (m *MockKV) SetWithTTL(ctx context.Context, namespace, collection, key string, value []byte, ttl time.Duration) error {
+	m.ttls[namespace+":"+collection+":"+key] = ttl
+	return m.Set(ctx, namespace, collection, key, value)
+}
+
 // Delete removes a key from the mock KV store
 func (m *MockKV) Delete(ctx context.Context, namespace, collection, key string) error {
 	if ns, ok := m.data[namespace]; ok {
@@ -72,6 +81,17 @@ func (m *MockKV) Exists(ctx context.Context, namespace, collection, key string)
 	return false, nil
 }
 
+// List returns keys from the mock KV store
+func (m *MockKV) List(ctx context.Context, namespace, collection string, opts kv.ListOptions) (*kv.ListResult, error) {
+	keys := make([]string, 0)
+	if ns, ok := m.data[namespace]; ok {
+		for key := range ns[collection] {
+			keys = append(keys, key)
+		}
+	}
+	return kv.Paginate(keys, opts), nil
+}
+
 // Close is a no-op for mock KV
 func (m *MockKV) Close() error {
 	return nil
@@ -82,6 +102,63 @@ func (m *MockKV) Ping(ctx context.Context) error {
 	return nil
 }
 
+// MockVersionedKV extends MockKV with per-key revisions (kv.Versioned)
+type MockVersionedKV struct {
+	*MockKV
+	revisions map[string]uint64 // keyed by namespace:collection:key
+}
+
+// NewMockVersionedKV creates a new MockVersionedKV instance
+func NewMockVersionedKV() *MockVersionedKV {
+	return &MockVersionedKV{MockKV: NewMockKV(), revisions: make(map[string]uint64)}
+}
+
+// Set stores a value and bumps its revision
+func (m *MockVersionedKV) Set(ctx context.Context, namespace, collection, key string, value []byte) error {
+	m.revisions[namespace+":"+collection+":"+key]++
+	return m.MockKV.Set(ctx, namespace, collection, key, value)
+}
+
+// SetWithTTL stores a value and bumps its revision (expiry is not simulated)
+func (m *MockVersionedKV) SetWithTTL(ctx context.Context, namespace, collection, key string, value []byte, ttl time.Duration) error {
+	m.ttls[namespace+":"+collection+":"+key] = ttl
+	return m.Set(ctx, namespace, collection, key, value)
+}
+
+// GetWithVersion retrieves a value and its revision
+func (m *MockVersionedKV) GetWithVersion(ctx context.Context, namespace, collection, key string) ([]byte, uint64, error) {
+	value, err := m.Get(ctx, namespace, collection, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, m.revisions[namespace+":"+collection+":"+key], nil
+}
+
+// CompareAndSet stores a value if the current revision matches
+func (m *MockVersionedKV) CompareAndSet(ctx context.Context, namespace, collection, key string, expectedVersion uint64, value []byte) (uint64, error) {
+	id := namespace + ":" + collection + ":" + key
+	if m.revisions[id] != expectedVersion {
+		return 0, kv.ErrVersionMismatch
+	}
+	if err := m.Set(ctx, namespace, collection, key, value); err != nil {
+		return 0, err
+	}
+	return m.revisions[id], nil
+}
+
+// CompareAndDelete removes a key if the current revision matches
+func (m *MockVersionedKV) CompareAndDelete(ctx context.Context, namespace, collection, key string, expectedVersion uint64) error {
+	id := namespace + ":" + collection + ":" + key
+	if m.revisions[id] == 0 {
+		return kv.ErrKeyNotFound
+	}
+	if m.revisions[id] != expectedVersion {
+		return kv.ErrVersionMismatch
+	}
+	delete(m.revisions, id)
+	return m.Delete(ctx, namespace, collection, key)
+}
+
 // TestGetKVHandler tests GET /api/v1/kv/{namespace}/{collection}/{key}
 func TestGetKVHandler(t *testing.T) {
 	mockKV := NewMockKV()
@@ -187,6 +264,28 @@ func TestSetKVHandler(t *testing.T) {
 			},
 			expectedStatus: http.StatusCreated,
 		},
+		{
+			name:       "set value with ttl",
+			namespace:  "default",
+			collection: "sessions",
+			key:        "token1",
+			body: KVRequestBody{
+				Value: "abc",
+				TTL:   300,
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:       "negative ttl",
+			namespace:  "default",
+			collection: "sessions",
+			key:        "token2",
+			body: KVRequestBody{
+				Value: "abc",
+				TTL:   -1,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:       "invalid namespace",
 			namespace:  "",
@@ -219,6 +318,9 @@ func TestSetKVHandler(t *testing.T) {
 				assert.Equal(t, tt.collection, resp.Collection)
 				assert.Equal(t, tt.key, resp.Key)
 				assert.Equal(t, "Successfully", resp.Message)
+				assert.Equal(t, time.Duration(tt.body.TTL)*time.Second,
+					mockKV.ttls[tt.namespace+":"+tt.collection+":"+tt.key])
+				assert.Equal(t, tt.body.TTL > 0, resp.ExpiresAt != "")
 			}
 		})
 	}
@@ -273,6 +375,90 @@ func TestDeleteKVHandler(t *testing.T) {
 	}
 }
 
+// TestKVHandlers_Conditional tests ETag, If-Match and If-None-Match handling
+func TestKVHandlers_Conditional(t *testing.T) {
+	mockKV := NewMockVersionedKV()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/kv/:namespace/:collection/:key", GetKVHandler(mockKV))
+	router.POST("/api/v1/kv/:namespace/:collection/:key", SetKVHandler(mockKV))
+	router.DELETE("/api/v1/kv/:namespace/:collection/:key", DeleteKVHandler(mockKV))
+
+	const path = "/api/v1/kv/default/cards/card1"
+	do := func(method string, headers map[string]string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// If-None-Match: * creates the key only if it does not exist
+	w := do("POST", map[string]string{"If-None-Match": "*"}, `{"value":"v1"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+	w = do("POST", map[string]string{"If-None-Match": "*"}, `{"value":"v1"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	// GET returns the ETag and honors If-None-Match
+	w = do("GET", nil, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+	w = do("GET", map[string]string{"If-None-Match": `"1"`}, "")
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// If-Match with a stale ETag is rejected, the current one is accepted
+	w = do("POST", map[string]string{"If-Match": `"5"`}, `{"value":"v2"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = do("POST", map[string]string{"If-Match": `W/"1"`}, `{"value":"v2"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	// TTL cannot be combined with preconditions
+	w = do("POST", map[string]string{"If-Match": `"2"`}, `{"value":"v3","ttl":60}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Conditional delete
+	w = do("DELETE", map[string]string{"If-Match": `"1"`}, "")
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = do("DELETE", map[string]string{"If-Match": `"2"`}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = do("DELETE", map[string]string{"If-Match": "*"}, "")
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
+// TestKVHandlers_ConditionalNotSupported tests preconditions against a backend without kv.Versioned
+func TestKVHandlers_ConditionalNotSupported(t *testing.T) {
+	mockKV := NewMockKV()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/kv/:namespace/:collection/:key", SetKVHandler(mockKV))
+	router.GET("/api/v1/kv/:namespace/:collection/:key", GetKVHandler(mockKV))
+
+	req, _ := http.NewRequest("POST", "/api/v1/kv/default/cards/card1", bytes.NewBufferString(`{"value":"v1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+
+	// Unconditional reads work without an ETag
+	_ = mockKV.Set(context.Background(), "default", "cards", "card1", []byte(`"v1"`))
+	req, _ = http.NewRequest("GET", "/api/v1/kv/default/cards/card1", http.NoBody)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+}
+
 // TestHeadKVHandler tests HEAD /api/v1/kv/{namespace}/{collection}/{key}
 func TestHeadKVHandler(t *testing.T) {
 	mockKV := NewMockKV()
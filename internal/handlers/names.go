@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"commander/internal/kv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// invalidNameMessage is returned when a namespace or collection fails kv.ValidateName
+const invalidNameMessage = "invalid namespace or collection name"
+
+// ValidateNamesMiddleware rejects requests whose :namespace or :collection path parameter
+// could escape the backend's data directory (see kv.ValidateName)
+func ValidateNamesMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !validNames(c.Param("namespace"), c.Param("collection")) {
+			log.Printf("[KV] Rejected invalid name: path=%s, remote=%s", c.Request.URL.Path, c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+				Message: invalidNameMessage,
+				Code:    "INVALID_PARAMS",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// validNames reports whether every given namespace or collection name passes kv.ValidateName
+func validNames(names ...string) bool {
+	for _, name := range names {
+		if kv.ValidateName(name) != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateNamesMiddleware tests that traversal names are rejected before the handler runs
+func TestValidateNamesMiddleware(t *testing.T) {
+	mockKV := NewMockDropperKV()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.UseRawPath = true
+	v1 := router.Group("/api/v1", ValidateNamesMiddleware())
+	v1.DELETE("/namespace/:namespace", DeleteNamespaceHandler(mockKV))
+	v1.DELETE("/namespace/:namespace/collections/:collection", DeleteCollectionHandler(mockKV))
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{"encoded traversal namespace", "/api/v1/namespace/..%2F..%2Fvictim?confirm=..%2F..%2Fvictim", http.StatusBadRequest},
+		{"encoded backslash namespace", "/api/v1/namespace/..%5Cvictim?confirm=..%5Cvictim", http.StatusBadRequest},
+		{"parent namespace", "/api/v1/namespace/..?confirm=..", http.StatusBadRequest},
+		{"traversal collection", "/api/v1/namespace/default/collections/..%2Fcards?confirm=..%2Fcards", http.StatusBadRequest},
+		{"valid namespace", "/api/v1/namespace/hotel-a?confirm=hotel-a", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = mockKV.Set(context.Background(), "hotel-a", "cards", "c1", []byte(`{}`))
+
+			req, _ := http.NewRequest("DELETE", tt.url, http.NoBody)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusBadRequest {
+				var resp ErrorResponse
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Equal(t, "INVALID_PARAMS", resp.Code)
+			}
+		})
+	}
+}
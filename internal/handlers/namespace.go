@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 	"time"
 
@@ -42,20 +43,39 @@ type DeleteCollectionResponse struct {
 }
 
 // ListNamespacesHandler handles GET /api/v1/namespaces
-// Lists all namespaces (returns empty list with not-implemented message)
+// Lists all namespaces (501 if the backend does not implement kv.Lister)
 func ListNamespacesHandler(kvStore kv.KV) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Note: Listing namespaces is not implemented for all backends
-		// Each backend would need to implement namespace listing separately
-		c.JSON(http.StatusNotImplemented, ErrorResponse{
-			Message: "listing namespaces is not implemented for this backend",
-			Code:    "NOT_IMPLEMENTED",
+		lister, ok := kvStore.(kv.Lister)
+		if !ok {
+			c.JSON(http.StatusNotImplemented, ErrorResponse{
+				Message: "listing namespaces is not implemented for this backend",
+				Code:    "NOT_IMPLEMENTED",
+			})
+			return
+		}
+
+		namespaces, err := lister.ListNamespaces(c.Request.Context())
+		if err != nil {
+			log.Printf("[ListNamespaces] Failed to list namespaces: error=%v", err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to list namespaces",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, ListNamespacesResponse{
+			Message:    "Successfully",
+			Namespaces: namespaces,
+			Count:      len(namespaces),
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
 		})
 	}
 }
 
 // ListCollectionsHandler handles GET /api/v1/namespace/{namespace}/collections
-// Lists all collections in a namespace (returns empty list with not-implemented message)
+// Lists all collections in a namespace (501 if the backend does not implement kv.Lister)
 func ListCollectionsHandler(kvStore kv.KV) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		namespace := c.Param("namespace")
@@ -69,19 +89,44 @@ func ListCollectionsHandler(kvStore kv.KV) gin.HandlerFunc {
 			return
 		}
 
-		// Note: Listing collections is not implemented for all backends
-		c.JSON(http.StatusNotImplemented, ErrorResponse{
-			Message: "listing collections is not implemented for this backend",
-			Code:    "NOT_IMPLEMENTED",
+		lister, ok := kvStore.(kv.Lister)
+		if !ok {
+			c.JSON(http.StatusNotImplemented, ErrorResponse{
+				Message: "listing collections is not implemented for this backend",
+				Code:    "NOT_IMPLEMENTED",
+			})
+			return
+		}
+
+		// Normalize namespace
+		namespace = kv.NormalizeNamespace(namespace)
+
+		collections, err := lister.ListCollections(c.Request.Context(), namespace)
+		if err != nil {
+			log.Printf("[ListCollections] Failed to list collections: namespace=%s, error=%v", namespace, err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to list collections",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, ListCollectionsResponse{
+			Message:     "Successfully",
+			Namespace:   namespace,
+			Collections: collections,
+			Count:       len(collections),
+			Timestamp:   time.Now().UTC().Format(time.RFC3339),
 		})
 	}
 }
 
-// DeleteNamespaceHandler handles DELETE /api/v1/namespace/{namespace}
-// Deletes an entire namespace (backend-dependent)
-// Note: For BBolt, this would delete the entire .db file
-// For MongoDB, this would drop the database
-// For Redis, this would delete all keys with the namespace prefix
+// DeleteNamespaceHandler handles DELETE /api/v1/namespace/{namespace}?confirm={namespace}
+// Deletes an entire namespace (501 if the backend does not implement kv.Dropper)
+// For BBolt, this deletes the entire .db file
+// For MongoDB, this drops the database
+// For Redis, this deletes all keys with the namespace prefix
+// The confirm query parameter must repeat the namespace name to guard against accidental deletes
 func DeleteNamespaceHandler(kvStore kv.KV) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		namespace := c.Param("namespace")
@@ -95,16 +140,48 @@ func DeleteNamespaceHandler(kvStore kv.KV) gin.HandlerFunc {
 			return
 		}
 
-		// Note: Namespace deletion is not implemented for all backends
-		c.JSON(http.StatusNotImplemented, ErrorResponse{
-			Message: "deleting namespaces is not implemented for this backend",
-			Code:    "NOT_IMPLEMENTED",
+		dropper, ok := kvStore.(kv.Dropper)
+		if !ok {
+			c.JSON(http.StatusNotImplemented, ErrorResponse{
+				Message: "deleting namespaces is not implemented for this backend",
+				Code:    "NOT_IMPLEMENTED",
+			})
+			return
+		}
+
+		// Require explicit confirmation
+		if c.Query("confirm") != namespace {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "confirm query parameter must match the namespace name",
+				Code:    "CONFIRMATION_REQUIRED",
+			})
+			return
+		}
+
+		// Normalize namespace
+		namespace = kv.NormalizeNamespace(namespace)
+
+		if err := dropper.DropNamespace(c.Request.Context(), namespace); err != nil {
+			log.Printf("[DeleteNamespace] Failed to delete namespace: namespace=%s, error=%v", namespace, err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to delete namespace",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		log.Printf("[DeleteNamespace] Namespace deleted: namespace=%s", namespace)
+		c.JSON(http.StatusOK, DeleteNamespaceResponse{
+			Message:   "Successfully",
+			Namespace: namespace,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
 		})
 	}
 }
 
-// DeleteCollectionHandler handles DELETE /api/v1/namespace/{namespace}/collections/{collection}
-// Deletes all keys in a collection
+// DeleteCollectionHandler handles DELETE /api/v1/namespace/{namespace}/collections/{collection}?confirm={collection}
+// Deletes all keys in a collection (501 if the backend does not implement kv.Dropper)
+// The confirm query parameter must repeat the collection name to guard against accidental deletes
 func DeleteCollectionHandler(kvStore kv.KV) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		namespace := c.Param("namespace")
@@ -119,10 +196,43 @@ func DeleteCollectionHandler(kvStore kv.KV) gin.HandlerFunc {
 			return
 		}
 
-		// Note: Collection deletion is not implemented for all backends
-		c.JSON(http.StatusNotImplemented, ErrorResponse{
-			Message: "deleting collections is not implemented for this backend",
-			Code:    "NOT_IMPLEMENTED",
+		dropper, ok := kvStore.(kv.Dropper)
+		if !ok {
+			c.JSON(http.StatusNotImplemented, ErrorResponse{
+				Message: "deleting collections is not implemented for this backend",
+				Code:    "NOT_IMPLEMENTED",
+			})
+			return
+		}
+
+		// Require explicit confirmation
+		if c.Query("confirm") != collection {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "confirm query parameter must match the collection name",
+				Code:    "CONFIRMATION_REQUIRED",
+			})
+			return
+		}
+
+		// Normalize namespace
+		namespace = kv.NormalizeNamespace(namespace)
+
+		if err := dropper.DropCollection(c.Request.Context(), namespace, collection); err != nil {
+			log.Printf("[DeleteCollection] Failed to delete collection: namespace=%s, collection=%s, error=%v",
+				namespace, collection, err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to delete collection",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		log.Printf("[DeleteCollection] Collection deleted: namespace=%s, collection=%s", namespace, collection)
+		c.JSON(http.StatusOK, DeleteCollectionResponse{
+			Message:    "Successfully",
+			Namespace:  namespace,
+			Collection: collection,
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
 		})
 	}
 }
@@ -155,10 +265,26 @@ func GetNamespaceInfoHandler(kvStore kv.KV) gin.HandlerFunc {
 		// Normalize namespace
 		namespace = kv.NormalizeNamespace(namespace)
 
+		// Collections are included when the backend can enumerate them
+		var collections []string
+		if lister, ok := kvStore.(kv.Lister); ok {
+			var err error
+			collections, err = lister.ListCollections(c.Request.Context(), namespace)
+			if err != nil {
+				log.Printf("[NamespaceInfo] Failed to list collections: namespace=%s, error=%v", namespace, err)
+				c.JSON(http.StatusInternalServerError, ErrorResponse{
+					Message: "failed to retrieve namespace information",
+					Code:    "INTERNAL_ERROR",
+				})
+				return
+			}
+		}
+
 		c.JSON(http.StatusOK, NamespaceInfoResponse{
-			Message:   "Namespace information retrieved",
-			Namespace: namespace,
-			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Message:     "Namespace information retrieved",
+			Namespace:   namespace,
+			Collections: collections,
+			Timestamp:   time.Now().UTC().Format(time.RFC3339),
 		})
 	}
 }
@@ -1,15 +1,69 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
+// MockListerKV is a MockKV that also implements kv.Lister
+type MockListerKV struct {
+	*MockKV
+}
+
+// NewMockListerKV creates a new MockListerKV instance
+func NewMockListerKV() *MockListerKV {
+	return &MockListerKV{MockKV: NewMockKV()}
+}
+
+// ListNamespaces returns the namespaces in the mock KV store
+func (m *MockListerKV) ListNamespaces(ctx context.Context) ([]string, error) {
+	namespaces := make([]string, 0, len(m.data))
+	for namespace := range m.data {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// ListCollections returns the collections of a namespace in the mock KV store
+func (m *MockListerKV) ListCollections(ctx context.Context, namespace string) ([]string, error) {
+	collections := make([]string, 0)
+	for collection := range m.data[namespace] {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+	return collections, nil
+}
+
+// MockDropperKV is a MockKV that also implements kv.Dropper
+type MockDropperKV struct {
+	*MockKV
+}
+
+// NewMockDropperKV creates a new MockDropperKV instance
+func NewMockDropperKV() *MockDropperKV {
+	return &MockDropperKV{MockKV: NewMockKV()}
+}
+
+// DropNamespace removes a namespace from the mock KV store
+func (m *MockDropperKV) DropNamespace(ctx context.Context, namespace string) error {
+	delete(m.data, namespace)
+	return nil
+}
+
+// DropCollection removes a collection from the mock KV store
+func (m *MockDropperKV) DropCollection(ctx context.Context, namespace, collection string) error {
+	delete(m.data[namespace], collection)
+	return nil
+}
+
 // TestListNamespacesHandler tests GET /api/v1/namespaces
 func TestListNamespacesHandler(t *testing.T) {
 	mockKV := NewMockKV()
@@ -29,6 +83,30 @@ func TestListNamespacesHandler(t *testing.T) {
 	assert.Equal(t, "NOT_IMPLEMENTED", resp.Code)
 }
 
+// TestListNamespacesHandler_WithLister tests GET /api/v1/namespaces on a backend implementing kv.Lister
+func TestListNamespacesHandler_WithLister(t *testing.T) {
+	mockKV := NewMockListerKV()
+	ctx := context.Background()
+	_ = mockKV.Set(ctx, "hotel-b", "cards", "c1", []byte(`{}`))
+	_ = mockKV.Set(ctx, "hotel-a", "cards", "c1", []byte(`{}`))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/namespaces", ListNamespacesHandler(mockKV))
+
+	req, _ := http.NewRequest("GET", "/api/v1/namespaces", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp ListNamespacesResponse
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hotel-a", "hotel-b"}, resp.Namespaces)
+	assert.Equal(t, 2, resp.Count)
+}
+
 // TestListCollectionsHandler tests GET /api/v1/namespace/{namespace}/collections
 func TestListCollectionsHandler(t *testing.T) {
 	mockKV := NewMockKV()
@@ -64,6 +142,44 @@ func TestListCollectionsHandler(t *testing.T) {
 	}
 }
 
+// TestListCollectionsHandler_WithLister tests GET /api/v1/namespace/{namespace}/collections on a backend implementing kv.Lister
+func TestListCollectionsHandler_WithLister(t *testing.T) {
+	mockKV := NewMockListerKV()
+	ctx := context.Background()
+	_ = mockKV.Set(ctx, "default", "devices", "d1", []byte(`{}`))
+	_ = mockKV.Set(ctx, "default", "cards", "c1", []byte(`{}`))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/namespace/:namespace/collections", ListCollectionsHandler(mockKV))
+
+	tests := []struct {
+		name                string
+		namespace           string
+		expectedCollections []string
+	}{
+		{"populated namespace", "default", []string{"cards", "devices"}},
+		{"unknown namespace", "missing", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/v1/namespace/"+tt.namespace+"/collections", http.NoBody)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var resp ListCollectionsResponse
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.namespace, resp.Namespace)
+			assert.Equal(t, tt.expectedCollections, resp.Collections)
+			assert.Equal(t, len(tt.expectedCollections), resp.Count)
+		})
+	}
+}
+
 // TestDeleteNamespaceHandler tests DELETE /api/v1/namespace/{namespace}
 func TestDeleteNamespaceHandler(t *testing.T) {
 	mockKV := NewMockKV()
@@ -127,6 +243,83 @@ func TestDeleteCollectionHandler(t *testing.T) {
 	}
 }
 
+// TestDeleteNamespaceHandler_WithDropper tests DELETE /api/v1/namespace/{namespace} on a backend implementing kv.Dropper
+func TestDeleteNamespaceHandler_WithDropper(t *testing.T) {
+	mockKV := NewMockDropperKV()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/api/v1/namespace/:namespace", DeleteNamespaceHandler(mockKV))
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectDeleted  bool
+	}{
+		{"missing confirmation", "/api/v1/namespace/hotel-a", http.StatusBadRequest, false},
+		{"mismatched confirmation", "/api/v1/namespace/hotel-a?confirm=hotel-b", http.StatusBadRequest, false},
+		{"confirmed delete", "/api/v1/namespace/hotel-a?confirm=hotel-a", http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = mockKV.Set(context.Background(), "hotel-a", "cards", "c1", []byte(`{}`))
+
+			req, _ := http.NewRequest("DELETE", tt.url, http.NoBody)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			_, exists := mockKV.data["hotel-a"]
+			assert.Equal(t, !tt.expectDeleted, exists)
+
+			if tt.expectedStatus == http.StatusBadRequest {
+				var resp ErrorResponse
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				assert.NoError(t, err)
+				assert.Equal(t, "CONFIRMATION_REQUIRED", resp.Code)
+			}
+		})
+	}
+}
+
+// TestDeleteCollectionHandler_WithDropper tests DELETE /api/v1/namespace/{namespace}/collections/{collection}
+// on a backend implementing kv.Dropper
+func TestDeleteCollectionHandler_WithDropper(t *testing.T) {
+	mockKV := NewMockDropperKV()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/api/v1/namespace/:namespace/collections/:collection", DeleteCollectionHandler(mockKV))
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectDeleted  bool
+	}{
+		{"missing confirmation", "/api/v1/namespace/default/collections/cards", http.StatusBadRequest, false},
+		{"namespace is not a confirmation", "/api/v1/namespace/default/collections/cards?confirm=default", http.StatusBadRequest, false},
+		{"confirmed delete", "/api/v1/namespace/default/collections/cards?confirm=cards", http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = mockKV.Set(context.Background(), "default", "cards", "c1", []byte(`{}`))
+			_ = mockKV.Set(context.Background(), "default", "devices", "d1", []byte(`{}`))
+
+			req, _ := http.NewRequest("DELETE", tt.url, http.NoBody)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			_, exists := mockKV.data["default"]["cards"]
+			assert.Equal(t, !tt.expectDeleted, exists)
+			_, exists = mockKV.data["default"]["devices"]
+			assert.True(t, exists)
+		})
+	}
+}
+
 // TestGetNamespaceInfoHandler tests GET /api/v1/namespace/{namespace}/info
 func TestGetNamespaceInfoHandler(t *testing.T) {
 	mockKV := NewMockKV()
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PolicyRequest is the JSON body for replacing the policy overrides of a namespace
+// Omitted (or null) fields fall back to the server defaults
+type PolicyRequest struct {
+	RequireActiveDevice   *bool   `json:"require_active_device"`
+	ClockToleranceSeconds *int    `json:"clock_tolerance_seconds"`
+	AntiPassback          *string `json:"anti_passback"`
+}
+
+// PolicyResponse represents the effective policy of a namespace and its stored overrides
+type PolicyResponse struct {
+	Message   string                  `json:"message"`
+	Namespace string                  `json:"namespace"`
+	Policy    services.Policy         `json:"policy"`
+	Overrides *models.NamespacePolicy `json:"overrides,omitempty"`
+	Timestamp string                  `json:"timestamp"`
+}
+
+// GetPolicyHandler handles GET /api/v1/namespace/{namespace}/policy
+func GetPolicyHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		policy, overrides, err := cardService.Policy(c.Request.Context(), namespace)
+		if err != nil {
+			log.Printf("[Policy] Failed to get policy: namespace=%s, error=%v", namespace, err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to get policy",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, PolicyResponse{
+			Message:   "Successfully",
+			Namespace: namespace,
+			Policy:    policy,
+			Overrides: overrides,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// SetPolicyHandler handles PUT /api/v1/namespace/{namespace}/policy
+// Replaces all overrides of the namespace
+func SetPolicyHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		var req PolicyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "invalid request body: " + err.Error(),
+				Code:    "INVALID_BODY",
+			})
+			return
+		}
+
+		overrides := &models.NamespacePolicy{
+			RequireActiveDevice:   req.RequireActiveDevice,
+			ClockToleranceSeconds: req.ClockToleranceSeconds,
+			AntiPassback:          req.AntiPassback,
+		}
+		policy, err := cardService.SetPolicy(c.Request.Context(), namespace, overrides)
+		if errors.Is(err, services.ErrInvalidPolicy) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: err.Error(),
+				Code:    "VALIDATION_ERROR",
+			})
+			return
+		}
+		if err != nil {
+			log.Printf("[Policy] Failed to set policy: namespace=%s, error=%v", namespace, err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to set policy",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, PolicyResponse{
+			Message:   "Successfully",
+			Namespace: namespace,
+			Policy:    policy,
+			Overrides: overrides,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListPresenceResponse represents the anti-passback state of a card
+type ListPresenceResponse struct {
+	Message   string             `json:"message"`
+	Namespace string             `json:"namespace"`
+	CardID    string             `json:"card_id"`
+	Presence  []*models.Presence `json:"presence"`
+	Count     int                `json:"count"`
+	Timestamp string             `json:"timestamp"`
+}
+
+// ResetPresenceResponse represents the response for resetting the anti-passback state of a card
+type ResetPresenceResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	CardID    string `json:"card_id"`
+	Zone      string `json:"zone,omitempty"`
+	Reset     int    `json:"reset"` // Number of zones reset
+	Timestamp string `json:"timestamp"`
+}
+
+// ListPresenceHandler handles GET /api/v1/namespace/{namespace}/cards/{id}/presence
+// Returns whether the card is inside or outside each anti-passback zone it passed through
+func ListPresenceHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		presence, err := cardService.ListPresence(c.Request.Context(), namespace, id)
+		if err != nil {
+			writeCardError(c, "get presence of", namespace, id, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, ListPresenceResponse{
+			Message:   "Successfully",
+			Namespace: namespace,
+			CardID:    id,
+			Presence:  presence,
+			Count:     len(presence),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// ResetPresenceHandler handles DELETE /api/v1/namespace/{namespace}/cards/{id}/presence
+// Clears the anti-passback state of the card in the zone query parameter (every zone when omitted)
+func ResetPresenceHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+		zone := c.Query("zone")
+
+		reset, err := cardService.ResetPresence(c.Request.Context(), namespace, id, zone)
+		if err != nil {
+			writeCardError(c, "reset presence of", namespace, id, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, ResetPresenceResponse{
+			Message:   "Successfully",
+			Namespace: namespace,
+			CardID:    id,
+			Zone:      zone,
+			Reset:     reset,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresenceHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	repo := services.NewKVRepository(NewMockKV())
+	require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-1", SN: "SN001", Direction: models.DeviceDirectionEntry, Zone: "parking"}))
+	now := time.Now().UTC()
+	require.NoError(t, repo.SaveCard(ctx, "hotel_a", &models.Card{
+		ID:          "card-1",
+		Number:      "GUEST-1",
+		Devices:     []string{"SN001"},
+		EffectiveAt: now.Add(-time.Hour),
+		InvalidAt:   now.Add(time.Hour),
+	}))
+	service := services.NewCardService(repo, services.Policy{AntiPassback: models.AntiPassbackHard})
+	require.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN001", "GUEST-1", models.ProtocolStandard))
+
+	router := gin.New()
+	router.GET("/api/v1/namespace/:namespace/cards/:id/presence", ListPresenceHandler(service))
+	router.DELETE("/api/v1/namespace/:namespace/cards/:id/presence", ResetPresenceHandler(service))
+
+	w := serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/cards/card-1/presence", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListPresenceResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Equal(t, 1, list.Count)
+	assert.Equal(t, "parking", list.Presence[0].Zone)
+	assert.True(t, list.Presence[0].Inside)
+
+	w = serveCardRequest(router, http.MethodDelete, "/api/v1/namespace/hotel_a/cards/card-1/presence?zone=parking", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var reset ResetPresenceResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reset))
+	assert.Equal(t, 1, reset.Reset)
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN001", "GUEST-1", models.ProtocolStandard))
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		w = serveCardRequest(router, method, "/api/v1/namespace/hotel_a/cards/missing/presence", "")
+		assert.Equal(t, http.StatusNotFound, w.Code, method)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RevocationRequest is the JSON body for revoking a card; exactly one of card_number and card_id is required
+type RevocationRequest struct {
+	CardNumber string `json:"card_number,omitempty"`
+	CardID     string `json:"card_id,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// RevocationResponse represents the response for revoking a card
+type RevocationResponse struct {
+	Message    string             `json:"message"`
+	Namespace  string             `json:"namespace"`
+	Revocation *models.Revocation `json:"revocation"`
+	Timestamp  string             `json:"timestamp"`
+}
+
+// ListRevocationsResponse represents the response for listing the revocation list
+type ListRevocationsResponse struct {
+	Message     string               `json:"message"`
+	Namespace   string               `json:"namespace"`
+	Revocations []*models.Revocation `json:"revocations"`
+	Count       int                  `json:"count"`
+	NextCursor  string               `json:"next_cursor,omitempty"`
+	Timestamp   string               `json:"timestamp"`
+}
+
+// UnrevokeResponse represents the response for removing a revocation
+type UnrevokeResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+}
+
+// ListRevocationsHandler handles GET /api/v1/namespace/{namespace}/revocations
+// Pagination: limit (default 100, max 1000) and cursor/next_cursor
+func ListRevocationsHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		limit, ok := parseListLimit(c)
+		if !ok {
+			return
+		}
+		query := services.RevocationQuery{
+			Cursor: c.Query("cursor"),
+			Limit:  limit,
+		}
+
+		revocations, nextCursor, err := cardService.ListRevocations(c.Request.Context(), namespace, query)
+		if err != nil {
+			writeRevocationError(c, "list", namespace, "", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, ListRevocationsResponse{
+			Message:     "Successfully",
+			Namespace:   namespace,
+			Revocations: revocations,
+			Count:       len(revocations),
+			NextCursor:  nextCursor,
+			Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// RevokeHandler handles POST /api/v1/namespace/{namespace}/revocations
+// The card number or ID fails verification with 410 from the next attempt on
+func RevokeHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		var req RevocationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "invalid request body: " + err.Error(),
+				Code:    "INVALID_BODY",
+			})
+			return
+		}
+
+		revocation, err := cardService.Revoke(c.Request.Context(), namespace, &models.Revocation{
+			CardNumber: req.CardNumber,
+			CardID:     req.CardID,
+			Reason:     req.Reason,
+		})
+		if err != nil {
+			writeRevocationError(c, "revoke", namespace, "", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, RevocationResponse{
+			Message:    "Successfully",
+			Namespace:  namespace,
+			Revocation: revocation,
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// UnrevokeHandler handles DELETE /api/v1/namespace/{namespace}/revocations/{id}
+// The ID is "number:<card number>" or "card:<card id>"
+func UnrevokeHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		id := c.Param("id")
+
+		if err := cardService.Unrevoke(c.Request.Context(), namespace, id); err != nil {
+			writeRevocationError(c, "unrevoke", namespace, id, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, UnrevokeResponse{
+			Message:   "Successfully",
+			Namespace: namespace,
+			ID:        id,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// writeRevocationError maps revocation errors to HTTP responses
+// Validation errors are returned to the client; anything else is logged and reported generically
+func writeRevocationError(c *gin.Context, operation, namespace, id string, err error) {
+	switch {
+	case errors.Is(err, services.ErrRevocationNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Message: "revocation not found",
+			Code:    "REVOCATION_NOT_FOUND",
+		})
+	case errors.Is(err, services.ErrInvalidRevocation):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Message: err.Error(),
+			Code:    "VALIDATION_ERROR",
+		})
+	case errors.Is(err, services.ErrRevocationExists):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Message: err.Error(),
+			Code:    "ALREADY_REVOKED",
+		})
+	default:
+		log.Printf("[Revocation] Failed to %s: namespace=%s, revocation_id=%s, error=%v", operation, namespace, id, err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Message: "failed to " + operation + " revocation",
+			Code:    "INTERNAL_ERROR",
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRevocationRouter registers the revocation list routes on a KV-backed service
+func setupRevocationRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	service := services.NewCardService(services.NewKVRepository(NewMockKV()), services.Policy{})
+
+	router := gin.New()
+	router.GET("/api/v1/namespace/:namespace/revocations", ListRevocationsHandler(service))
+	router.POST("/api/v1/namespace/:namespace/revocations", RevokeHandler(service))
+	router.DELETE("/api/v1/namespace/:namespace/revocations/:id", UnrevokeHandler(service))
+	return router
+}
+
+func TestRevokeHandler(t *testing.T) {
+	router := setupRevocationRouter(t)
+
+	w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/revocations", `{"card_number":"GUEST-1","reason":"lost"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var response RevocationResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "number:GUEST-1", response.Revocation.ID)
+	assert.Equal(t, "lost", response.Revocation.Reason)
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"invalid json", `{`, http.StatusBadRequest, "INVALID_BODY"},
+		{"missing card", `{"reason":"lost"}`, http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"number and id", `{"card_number":"GUEST-2","card_id":"card-2"}`, http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"already revoked", `{"card_number":"GUEST-1"}`, http.StatusConflict, "ALREADY_REVOKED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/revocations", tt.body)
+			assert.Equal(t, tt.expectedCode, w.Code)
+
+			var errResp ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+			assert.Equal(t, tt.expectedErr, errResp.Code)
+		})
+	}
+}
+
+func TestRevocationHandlers_ListAndUnrevoke(t *testing.T) {
+	router := setupRevocationRouter(t)
+
+	w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/revocations", `{"card_number":"GUEST-1"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/revocations", `{"card_id":"card-2"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/revocations?limit=1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListRevocationsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, 1, list.Count)
+	assert.Equal(t, "card:card-2", list.NextCursor)
+
+	w = serveCardRequest(router, http.MethodDelete, "/api/v1/namespace/hotel_a/revocations/number:GUEST-1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serveCardRequest(router, http.MethodDelete, "/api/v1/namespace/hotel_a/revocations/number:GUEST-1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var errResp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, "REVOCATION_NOT_FOUND", errResp.Code)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SyncChangesResponse represents a page of the change feed pulled by edge instances
+type SyncChangesResponse struct {
+	Message    string           `json:"message"`
+	Namespace  string           `json:"namespace"`
+	Changes    []*models.Change `json:"changes"`
+	Count      int              `json:"count"`
+	Checkpoint int64            `json:"checkpoint"`
+	More       bool             `json:"more"`
+	Snapshot   bool             `json:"snapshot"`
+	Timestamp  string           `json:"timestamp"`
+}
+
+// SyncAccessLogsRequest is the JSON body of an access log push from an edge instance
+type SyncAccessLogsRequest struct {
+	EdgeID  string              `json:"edge_id"`
+	Entries []*models.AccessLog `json:"entries"`
+}
+
+// SyncAccessLogsResponse represents the response for an access log push
+type SyncAccessLogsResponse struct {
+	Message   string `json:"message"`
+	Namespace string `json:"namespace"`
+	Accepted  int    `json:"accepted"`
+	Timestamp string `json:"timestamp"`
+}
+
+// SyncAuthMiddleware rejects sync requests without "Authorization: Bearer <token>"
+// An empty token leaves the sync endpoints open
+func SyncAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			log.Printf("[Sync] Unauthorized request: namespace=%s, path=%s, remote=%s",
+				c.Param("namespace"), c.Request.URL.Path, c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Message: "invalid sync token",
+				Code:    "UNAUTHORIZED",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// SyncChangesHandler handles GET /api/v1/namespace/{namespace}/sync/changes
+// Returns the changes after revision since (default 0 = snapshot of every record); the client stores
+// checkpoint and passes it as since on the next call. Pagination: limit (default 100, max 1000)
+func SyncChangesHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		var since int64
+		if value := c.Query("since"); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed < 0 {
+				c.JSON(http.StatusBadRequest, ErrorResponse{
+					Message: "since must be a non-negative integer",
+					Code:    "INVALID_PARAMS",
+				})
+				return
+			}
+			since = parsed
+		}
+		limit, ok := parseListLimit(c)
+		if !ok {
+			return
+		}
+
+		batch, err := cardService.ListChanges(c.Request.Context(), namespace, since, limit)
+		if err != nil {
+			log.Printf("[Sync] Failed to list changes: namespace=%s, since=%d, error=%v", namespace, since, err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to list changes",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, SyncChangesResponse{
+			Message:    "Successfully",
+			Namespace:  namespace,
+			Changes:    batch.Changes,
+			Count:      len(batch.Changes),
+			Checkpoint: batch.Checkpoint,
+			More:       batch.More,
+			Snapshot:   batch.Snapshot,
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// SyncAccessLogsHandler handles POST /api/v1/namespace/{namespace}/sync/access-logs
+// Stores access log entries recorded by an edge instance (at most 1000 per request); entries keep their
+// IDs, so a retried push is stored once
+func SyncAccessLogsHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+
+		var req SyncAccessLogsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "invalid request body: " + err.Error(),
+				Code:    "INVALID_BODY",
+			})
+			return
+		}
+
+		accepted, err := cardService.ImportAccessLogs(c.Request.Context(), namespace, req.EdgeID, req.Entries)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAccessLog) {
+				c.JSON(http.StatusBadRequest, ErrorResponse{
+					Message: err.Error(),
+					Code:    "VALIDATION_ERROR",
+				})
+				return
+			}
+			log.Printf("[Sync] Failed to import access logs: namespace=%s, edge_id=%s, entries=%d, error=%v",
+				namespace, req.EdgeID, len(req.Entries), err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to import access logs",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, SyncAccessLogsResponse{
+			Message:   "Successfully",
+			Namespace: namespace,
+			Accepted:  accepted,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
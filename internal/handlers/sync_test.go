@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"commander/internal/models"
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupSyncRouter registers the sync routes, behind token, on a change-recording service with one device (SN001)
+func setupSyncRouter(t *testing.T, token string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	repo := services.NewChangeFeed(services.NewKVRepository(NewMockKV()))
+	require.NoError(t, repo.SaveDevice(context.Background(), "hotel_a", &models.Device{ID: "device-1", SN: "SN001"}))
+	service := services.NewCardService(repo, services.Policy{})
+
+	router := gin.New()
+	sync := router.Group("/api/v1/namespace/:namespace/sync", SyncAuthMiddleware(token))
+	sync.GET("/changes", SyncChangesHandler(service))
+	sync.POST("/access-logs", SyncAccessLogsHandler(service))
+	return router
+}
+
+func TestSyncChangesHandler(t *testing.T) {
+	router := setupSyncRouter(t, "")
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedCode int
+		snapshot     bool
+		count        int
+	}{
+		{"snapshot", "/api/v1/namespace/hotel_a/sync/changes", http.StatusOK, true, 1},
+		{"delta", "/api/v1/namespace/hotel_a/sync/changes?since=1", http.StatusOK, false, 0},
+		{"invalid since", "/api/v1/namespace/hotel_a/sync/changes?since=-1", http.StatusBadRequest, false, 0},
+		{"invalid limit", "/api/v1/namespace/hotel_a/sync/changes?since=1&limit=x", http.StatusBadRequest, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCardRequest(router, http.MethodGet, tt.path, "")
+			require.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var response SyncChangesResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.snapshot, response.Snapshot)
+			assert.Equal(t, tt.count, response.Count)
+			assert.Equal(t, int64(1), response.Checkpoint)
+		})
+	}
+}
+
+func TestSyncAccessLogsHandler(t *testing.T) {
+	router := setupSyncRouter(t, "")
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{"valid", `{"edge_id":"edge-1","entries":[{"id":"1","device_sn":"SN001","card_number":"GUEST-1","outcome":"granted","timestamp":"2026-10-01T12:00:00Z"}]}`, http.StatusOK},
+		{"missing edge id", `{"entries":[]}`, http.StatusBadRequest},
+		{"invalid entry", `{"edge_id":"edge-1","entries":[{"id":"2"}]}`, http.StatusBadRequest},
+		{"invalid body", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCardRequest(router, http.MethodPost, "/api/v1/namespace/hotel_a/sync/access-logs", tt.body)
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestSyncAuthMiddleware(t *testing.T) {
+	router := setupSyncRouter(t, "secret")
+
+	tests := []struct {
+		name          string
+		authorization string
+		expectedCode  int
+	}{
+		{"valid token", "Bearer secret", http.StatusOK},
+		{"wrong token", "Bearer other", http.StatusUnauthorized},
+		{"missing token", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/namespace/hotel_a/sync/changes", http.NoBody)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
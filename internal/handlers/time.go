@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimeResponse reports the server clock so devices can detect drift
+type TimeResponse struct {
+	Message               string `json:"message"`
+	Namespace             string `json:"namespace"`
+	ServerTime            string `json:"server_time"` // RFC3339 with nanoseconds, UTC
+	UnixMillis            int64  `json:"unix_ms"`
+	ClockToleranceSeconds int    `json:"clock_tolerance_seconds"`
+	DeviceTime            string `json:"device_time,omitempty"`
+	DriftMillis           *int64 `json:"drift_ms,omitempty"` // Device time minus server time
+	WithinTolerance       *bool  `json:"within_tolerance,omitempty"`
+	Timestamp             string `json:"timestamp"`
+}
+
+// TimeHandler handles GET /api/v1/namespace/{namespace}/time
+// Returns the server time and the clock tolerance of the namespace
+// With device_time (RFC3339 or Unix milliseconds) it also reports the drift and whether it is tolerated
+func TimeHandler(cardService *services.CardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		now := cardService.Now().UTC()
+
+		var deviceTime time.Time
+		if value := c.Query("device_time"); value != "" {
+			parsed, ok := parseDeviceTime(value)
+			if !ok {
+				c.JSON(http.StatusBadRequest, ErrorResponse{
+					Message: "device_time must be an RFC3339 timestamp or Unix milliseconds",
+					Code:    "INVALID_PARAMS",
+				})
+				return
+			}
+			deviceTime = parsed
+		}
+
+		policy, _, err := cardService.Policy(c.Request.Context(), namespace)
+		if err != nil {
+			log.Printf("[Time] Failed to get policy: namespace=%s, error=%v", namespace, err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to get policy",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		response := TimeResponse{
+			Message:               "Successfully",
+			Namespace:             namespace,
+			ServerTime:            now.Format(time.RFC3339Nano),
+			UnixMillis:            now.UnixMilli(),
+			ClockToleranceSeconds: policy.ClockToleranceSeconds,
+			Timestamp:             now.Format(time.RFC3339),
+		}
+		if !deviceTime.IsZero() {
+			drift := deviceTime.Sub(now)
+			driftMillis := drift.Milliseconds()
+			within := drift.Abs() <= policy.ClockTolerance()
+			response.DeviceTime = deviceTime.UTC().Format(time.RFC3339Nano)
+			response.DriftMillis = &driftMillis
+			response.WithinTolerance = &within
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// parseDeviceTime accepts an RFC3339 timestamp or Unix milliseconds
+func parseDeviceTime(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, true
+	}
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || millis <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(millis), true
+}
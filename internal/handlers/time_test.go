@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"commander/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedClock is a services.Clock that always returns the same time
+type fixedClock time.Time
+
+// Now returns the fixed time
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestTimeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2026, 8, 25, 15, 0, 0, 0, time.UTC)
+
+	service := services.NewCardService(services.NewKVRepository(NewMockKV()), services.Policy{ClockToleranceSeconds: 60})
+	service.SetClock(fixedClock(now))
+
+	router := gin.New()
+	router.GET("/api/v1/namespace/:namespace/time", TimeHandler(service))
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedCode   int
+		expectedDrift  *int64
+		expectedWithin *bool
+	}{
+		{"server time only", "", http.StatusOK, nil, nil},
+		{"device ahead within tolerance", "device_time=2026-08-25T15:00:30Z", http.StatusOK, ptr(int64(30000)), ptr(true)},
+		{"device behind beyond tolerance", "device_time=2026-08-25T14:58:00Z", http.StatusOK, ptr(int64(-120000)), ptr(false)},
+		{"unix milliseconds", "device_time=1787670000500", http.StatusOK, ptr(int64(500)), ptr(true)},
+		{"invalid device time", "device_time=noon", http.StatusBadRequest, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCardRequest(router, http.MethodGet, "/api/v1/namespace/hotel_a/time?"+tt.query, "")
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var response TimeResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "2026-08-25T15:00:00Z", response.ServerTime)
+			assert.Equal(t, now.UnixMilli(), response.UnixMillis)
+			assert.Equal(t, 60, response.ClockToleranceSeconds)
+			assert.Equal(t, tt.expectedDrift, response.DriftMillis)
+			assert.Equal(t, tt.expectedWithin, response.WithinTolerance)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"commander/internal/kv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// watchHeartbeatInterval is how often a comment line is sent to keep idle connections open
+const watchHeartbeatInterval = 15 * time.Second
+
+// WatchEvent is the data payload of a Server-Sent Event sent by WatchKVHandler
+type WatchEvent struct {
+	Type       string      `json:"type"`
+	Namespace  string      `json:"namespace"`
+	Collection string      `json:"collection"`
+	Key        string      `json:"key"`
+	Value      interface{} `json:"value,omitempty"`
+	Revision   uint64      `json:"revision,omitempty"`
+	Timestamp  string      `json:"timestamp"`
+}
+
+// WatchKVHandler handles GET /api/v1/kv/{namespace}/{collection}/watch
+// Streams changes to keys in a collection as Server-Sent Events ("set" and "delete" events)
+// The optional prefix query parameter restricts the stream to matching keys
+func WatchKVHandler(kvStore kv.KV) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		collection := c.Param("collection")
+
+		// Validate parameters
+		if namespace == "" || collection == "" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Message: "namespace and collection are required",
+				Code:    "INVALID_PARAMS",
+			})
+			return
+		}
+
+		watcher, ok := kvStore.(kv.Watcher)
+		if !ok {
+			c.JSON(http.StatusNotImplemented, ErrorResponse{
+				Message: "watching changes is not supported by this backend",
+				Code:    "NOT_IMPLEMENTED",
+			})
+			return
+		}
+
+		// Normalize namespace
+		namespace = kv.NormalizeNamespace(namespace)
+		prefix := c.Query("prefix")
+
+		ctx := c.Request.Context()
+		events, err := watcher.Watch(ctx, namespace, collection, prefix)
+		if err != nil {
+			log.Printf("[Watch] Failed to watch: namespace=%s, collection=%s, error=%v",
+				namespace, collection, err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Message: "failed to watch collection",
+				Code:    "INTERNAL_ERROR",
+			})
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(watchHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			case event, ok := <-events:
+				if !ok {
+					// The backend closed the stream; the client is expected to reconnect
+					return
+				}
+				if err := writeWatchEvent(c, event); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// writeWatchEvent writes a single Server-Sent Event; the revision (when known) is used as event id
+func writeWatchEvent(c *gin.Context, event kv.Event) error {
+	payload := WatchEvent{
+		Type:       string(event.Type),
+		Namespace:  event.Namespace,
+		Collection: event.Collection,
+		Key:        event.Key,
+		Revision:   event.Revision,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+	}
+	if event.Value != nil {
+		var decodedValue interface{}
+		if err := unmarshalJSON(event.Value, &decodedValue); err == nil {
+			payload.Value = decodedValue
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if event.Revision > 0 {
+		if _, err := fmt.Fprintf(c.Writer, "id: %d\n", event.Revision); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
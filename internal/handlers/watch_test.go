@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"commander/internal/kv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockWatcherKV extends MockKV with a fixed stream of change events (kv.Watcher)
+type MockWatcherKV struct {
+	*MockKV
+	events chan kv.Event
+	prefix string // prefix passed to the last Watch
+}
+
+// Watch returns the mock event channel
+func (m *MockWatcherKV) Watch(ctx context.Context, namespace, collection, prefix string) (<-chan kv.Event, error) {
+	m.prefix = prefix
+	return m.events, nil
+}
+
+func TestWatchKVHandler(t *testing.T) {
+	mockKV := &MockWatcherKV{MockKV: NewMockKV(), events: make(chan kv.Event, 2)}
+	mockKV.events <- kv.Event{Type: kv.EventSet, Namespace: "default", Collection: "cards", Key: "guest_1", Value: []byte(`{"room":"302"}`), Revision: 4}
+	mockKV.events <- kv.Event{Type: kv.EventDelete, Namespace: "default", Collection: "cards", Key: "guest_2"}
+	close(mockKV.events)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/kv/:namespace/:collection/watch", WatchKVHandler(mockKV))
+
+	req, _ := http.NewRequest("GET", "/api/v1/kv/default/cards/watch?prefix=guest_", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "guest_", mockKV.prefix)
+
+	body := w.Body.String()
+	assert.Contains(t, body, "id: 4\nevent: set\ndata: {")
+	assert.Contains(t, body, `"value":{"room":"302"}`)
+	assert.Contains(t, body, "event: delete\ndata: {")
+	assert.Equal(t, 2, strings.Count(body, "\n\n"))
+}
+
+func TestWatchKVHandler_NotSupported(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/kv/:namespace/:collection/watch", WatchKVHandler(NewMockKV()))
+
+	req, _ := http.NewRequest("GET", "/api/v1/kv/default/cards/watch", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
@@ -3,6 +3,10 @@ package kv
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 )
 
 var (
@@ -10,11 +14,21 @@ var (
 	ErrKeyNotFound = errors.New("key not found")
 	// ErrConnectionFailed is returned when connection to backend fails
 	ErrConnectionFailed = errors.New("connection failed")
+	// ErrVersionMismatch is returned when a conditional write does not match the stored version
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrTransactionsUnsupported is returned by Transactional.Apply when the deployment behind the backend
+	// cannot run transactions (such as a standalone MongoDB server); nothing was written
+	ErrTransactionsUnsupported = errors.New("transactions are not supported by this deployment")
+	// ErrInvalidName is returned for a namespace or collection name rejected by ValidateName
+	ErrInvalidName = errors.New("invalid namespace or collection name")
 
 	// DefaultNamespace is the default namespace used when namespace is empty
 	DefaultNamespace = "default"
 )
 
+// DefaultListLimit is the page size used when ListOptions.Limit is not set
+const DefaultListLimit = 1000
+
 // NormalizeNamespace returns the namespace, or "default" if empty
 func NormalizeNamespace(namespace string) string {
 	if namespace == "" {
@@ -23,6 +37,66 @@ func NormalizeNamespace(namespace string) string {
 	return namespace
 }
 
+// ValidateName checks a namespace or collection name before it reaches a backend
+// BBolt turns namespaces into file names, so path separators, ".." and NUL bytes are rejected,
+// as is a leading dot, which marks BBolt's own files; an empty name is valid (see NormalizeNamespace)
+func ValidateName(name string) error {
+	if strings.ContainsAny(name, "/\\\x00") || strings.Contains(name, "..") || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// ListOptions controls which keys are returned by List
+type ListOptions struct {
+	// Prefix restricts the result to keys starting with this value
+	Prefix string
+
+	// Cursor resumes listing after this key (exclusive)
+	// Use ListResult.NextCursor from the previous page, or empty to start from the beginning
+	Cursor string
+
+	// Limit is the maximum number of keys to return (DefaultListLimit if <= 0)
+	Limit int
+}
+
+// ListResult is a single page of keys returned by List
+type ListResult struct {
+	// Keys are sorted in ascending byte order
+	Keys []string
+
+	// NextCursor is set when more keys are available
+	NextCursor string
+}
+
+// Paginate applies ListOptions to a set of keys and returns a single page
+// Used by backends that cannot filter and page natively; keys is sorted in place
+func Paginate(keys []string, opts ListOptions) *ListResult {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+
+	sort.Strings(keys)
+
+	result := &ListResult{Keys: make([]string, 0)}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, opts.Prefix) {
+			continue
+		}
+		if opts.Cursor != "" && key <= opts.Cursor {
+			continue
+		}
+		if len(result.Keys) == limit {
+			result.NextCursor = result.Keys[limit-1]
+			break
+		}
+		result.Keys = append(result.Keys, key)
+	}
+
+	return result
+}
+
 // KV is the interface for key-value storage backends
 // Key is string, Value is JSON bytes
 // Supports namespace and collection for data organization
@@ -33,15 +107,174 @@ type KV interface {
 	// Set stores a JSON value by key in namespace and collection
 	Set(ctx context.Context, namespace, collection, key string, value []byte) error
 
+	// SetWithTTL stores a JSON value that expires after ttl (ttl <= 0 means never)
+	// Expired keys behave as if they were deleted
+	SetWithTTL(ctx context.Context, namespace, collection, key string, value []byte, ttl time.Duration) error
+
 	// Delete removes a key-value pair from namespace and collection
 	Delete(ctx context.Context, namespace, collection, key string) error
 
 	// Exists checks if a key exists in namespace and collection
 	Exists(ctx context.Context, namespace, collection, key string) (bool, error)
 
+	// List returns keys in namespace and collection, filtered by prefix and paged by cursor
+	List(ctx context.Context, namespace, collection string, opts ListOptions) (*ListResult, error)
+
 	// Close closes the connection to the backend
 	Close() error
 
 	// Ping checks if the connection is alive
 	Ping(ctx context.Context) error
 }
+
+// Lister is implemented by backends that can enumerate namespaces and collections
+// Handlers detect it with a type assertion and fall back to 501 when missing
+type Lister interface {
+	// ListNamespaces returns all namespaces in ascending order
+	ListNamespaces(ctx context.Context) ([]string, error)
+
+	// ListCollections returns all collections in a namespace in ascending order
+	ListCollections(ctx context.Context, namespace string) ([]string, error)
+}
+
+// Dropper is implemented by backends that can delete whole namespaces and collections
+// Dropping a namespace or collection that does not exist is not an error
+type Dropper interface {
+	// DropNamespace removes a namespace and every collection in it
+	DropNamespace(ctx context.Context, namespace string) error
+
+	// DropCollection removes a collection and every key in it
+	DropCollection(ctx context.Context, namespace, collection string) error
+}
+
+// Versioned is implemented by backends that keep a revision per key
+// Every write (including Set) stores the key under a new revision taken from a counter shared by the
+// whole namespace, so revisions increase on every write and a key that is deleted, expires or is
+// dropped and then written again never returns to a revision it had before
+// Version 0 means the key does not exist
+type Versioned interface {
+	// GetWithVersion retrieves a value together with its current version
+	GetWithVersion(ctx context.Context, namespace, collection, key string) ([]byte, uint64, error)
+
+	// CompareAndSet stores value only if the current version equals expectedVersion
+	// Use expectedVersion 0 to create a key that must not exist yet
+	// Returns the new version, or ErrVersionMismatch; the stored value has no expiry
+	CompareAndSet(ctx context.Context, namespace, collection, key string, expectedVersion uint64, value []byte) (uint64, error)
+
+	// CompareAndDelete removes a key only if the current version equals expectedVersion
+	// Returns ErrKeyNotFound if the key does not exist, or ErrVersionMismatch
+	CompareAndDelete(ctx context.Context, namespace, collection, key string, expectedVersion uint64) error
+}
+
+// Store is a backend with every optional capability; all built-in backends implement it
+// Decorators that forward each capability to the backend they wrap require it
+type Store interface {
+	KV
+	Lister
+	Dropper
+	Versioned
+	Transactional
+	BatchGetter
+	Watcher
+}
+
+// Unwrap returns the backend behind decorators such as caches and mirrors
+// A decorator exposes the backend it wraps with an Unwrap() KV method
+func Unwrap(store KV) KV {
+	for {
+		wrapper, ok := store.(interface{ Unwrap() KV })
+		if !ok {
+			return store
+		}
+		store = wrapper.Unwrap()
+	}
+}
+
+// OpType is the kind of write in a transaction
+type OpType int
+
+const (
+	// OpSet stores Value under Key, expiring after TTL (TTL <= 0 means never)
+	OpSet OpType = iota
+	// OpDelete removes Key; the transaction fails with ErrKeyNotFound if it does not exist
+	OpDelete
+)
+
+// Op is a single write applied by Transactional.Apply
+type Op struct {
+	Type       OpType
+	Namespace  string
+	Collection string
+	Key        string
+	Value      []byte
+	TTL        time.Duration
+}
+
+// TxError reports the operation that caused a transaction to be rolled back
+type TxError struct {
+	// Index is the position of the failed operation in the ops slice
+	Index int
+	Err   error
+}
+
+// Error implements the error interface
+func (e *TxError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the underlying error so errors.Is works on TxError
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// Transactional is implemented by backends that can apply several writes atomically
+// Operations run in order, so a later operation sees the effect of an earlier one on the same key
+type Transactional interface {
+	// Apply executes ops as a single all-or-nothing transaction
+	// If an operation fails, nothing is written and a *TxError identifies it
+	// Returns ErrTransactionsUnsupported, not tied to an operation, if the deployment cannot run transactions
+	Apply(ctx context.Context, ops []Op) error
+}
+
+// Key identifies a value by namespace, collection and key
+type Key struct {
+	Namespace  string
+	Collection string
+	Key        string
+}
+
+// GetResult is the outcome of reading a single key in GetMany
+type GetResult struct {
+	Value []byte
+	Found bool
+	// TTL is the remaining lifetime of a key written with a TTL (0 = never expires)
+	TTL time.Duration
+}
+
+// BatchGetter is implemented by backends that can read many keys in one round trip
+type BatchGetter interface {
+	// GetMany retrieves keys in one backend call; results[i] belongs to keys[i]
+	// Missing or expired keys are reported with Found = false, not as an error
+	GetMany(ctx context.Context, keys []Key) ([]GetResult, error)
+}
+
+// GetMany reads keys from store, using BatchGetter when the backend implements it
+// and falling back to one Get per key otherwise (without TTLs, since Get does not report them)
+func GetMany(ctx context.Context, store KV, keys []Key) ([]GetResult, error) {
+	if getter, ok := store.(BatchGetter); ok {
+		return getter.GetMany(ctx, keys)
+	}
+
+	results := make([]GetResult, len(keys))
+	for i, k := range keys {
+		value, err := store.Get(ctx, k.Namespace, k.Collection, k.Key)
+		if err != nil {
+			if errors.Is(err, ErrKeyNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		results[i] = GetResult{Value: value, Found: true}
+	}
+	return results, nil
+}
@@ -1,6 +1,8 @@
 package kv
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -67,3 +69,124 @@ func TestErrors(t *testing.T) {
 		t.Errorf("ErrConnectionFailed message = %q, want %q", ErrConnectionFailed.Error(), "connection failed")
 	}
 }
+
+func TestPaginate(t *testing.T) {
+	keys := []string{"b2", "a1", "b1", "c1", "b3"}
+
+	tests := []struct {
+		name           string
+		opts           ListOptions
+		expectedKeys   []string
+		expectedCursor string
+	}{
+		{
+			name:         "all keys sorted",
+			opts:         ListOptions{},
+			expectedKeys: []string{"a1", "b1", "b2", "b3", "c1"},
+		},
+		{
+			name:         "prefix filter",
+			opts:         ListOptions{Prefix: "b"},
+			expectedKeys: []string{"b1", "b2", "b3"},
+		},
+		{
+			name:           "limit sets next cursor",
+			opts:           ListOptions{Prefix: "b", Limit: 2},
+			expectedKeys:   []string{"b1", "b2"},
+			expectedCursor: "b2",
+		},
+		{
+			name:         "cursor resumes after key",
+			opts:         ListOptions{Prefix: "b", Cursor: "b2", Limit: 2},
+			expectedKeys: []string{"b3"},
+		},
+		{
+			name:         "exact page has no next cursor",
+			opts:         ListOptions{Limit: 5},
+			expectedKeys: []string{"a1", "b1", "b2", "b3", "c1"},
+		},
+		{
+			name:         "no match",
+			opts:         ListOptions{Prefix: "z"},
+			expectedKeys: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]string(nil), keys...)
+			result := Paginate(input, tt.opts)
+			if !reflect.DeepEqual(result.Keys, tt.expectedKeys) {
+				t.Errorf("Paginate keys = %v, want %v", result.Keys, tt.expectedKeys)
+			}
+			if result.NextCursor != tt.expectedCursor {
+				t.Errorf("Paginate cursor = %q, want %q", result.NextCursor, tt.expectedCursor)
+			}
+		})
+	}
+}
+
+func TestTxError(t *testing.T) {
+	err := error(&TxError{Index: 2, Err: ErrKeyNotFound})
+
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Error("Expected TxError to unwrap to ErrKeyNotFound")
+	}
+	if err.Error() != "operation 2: key not found" {
+		t.Errorf("Unexpected error message: %s", err.Error())
+	}
+}
+
+// plainStore is a store without Unwrap
+type plainStore struct {
+	KV
+}
+
+// wrapper is a decorator exposing the store it wraps
+type wrapper struct {
+	KV
+}
+
+func (w wrapper) Unwrap() KV {
+	return w.KV
+}
+
+func TestUnwrap(t *testing.T) {
+	var inner KV = &plainStore{}
+	if got := Unwrap(wrapper{KV: wrapper{KV: inner}}); got != inner {
+		t.Errorf("Expected innermost store, got %v", got)
+	}
+	if got := Unwrap(inner); got != inner {
+		t.Errorf("Expected store without Unwrap to be returned unchanged, got %v", got)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"plain name", "hotel_a", false},
+		{"empty name", "", false},
+		{"inner dot", "hotel.a", false},
+		{"parent directory", "..", true},
+		{"relative traversal", "../victim", true},
+		{"slash", "a/b", true},
+		{"backslash", `a\b`, true},
+		{"NUL byte", "a\x00b", true},
+		{"leading dot", ".lock", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateName(tt.input)
+			if tt.wantErr && !errors.Is(err, ErrInvalidName) {
+				t.Errorf("ValidateName(%q) = %v, want ErrInvalidName", tt.input, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("ValidateName(%q) = %v, want nil", tt.input, err)
+			}
+		})
+	}
+}
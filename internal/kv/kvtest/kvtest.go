@@ -0,0 +1,458 @@
+// Package kvtest provides a conformance suite for kv.KV implementations
+// Backends run it from their own tests so every store honors the contract the handlers rely on
+package kvtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"commander/internal/kv"
+)
+
+// Namespaces lists every namespace written by RunConformance
+// Factories backed by shared servers can drop these to start from an empty store
+var Namespaces = []string{kv.DefaultNamespace, "conformance_a", "conformance_b"}
+
+// Factory returns a new, empty store
+// RunConformance closes every store it creates
+type Factory func(t *testing.T) kv.KV
+
+// RunConformance runs the shared kv.KV contract tests against stores returned by factory
+func RunConformance(t *testing.T, factory Factory) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store kv.KV)
+	}{
+		{"CRUD", testCRUD},
+		{"DeleteMissing", testDeleteMissing},
+		{"Isolation", testIsolation},
+		{"NamespaceNormalization", testNamespaceNormalization},
+		{"EmptyValue", testEmptyValue},
+		{"LargeValue", testLargeValue},
+		{"BinaryKeysAndValues", testBinaryKeysAndValues},
+		{"List", testList},
+		{"ConcurrentWriters", testConcurrentWriters},
+		{"ContextCanceled", testContextCanceled},
+		{"RevisionsNotReused", testRevisionsNotReused},
+		{"GetManyTTL", testGetManyTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := factory(t)
+			t.Cleanup(func() {
+				if err := store.Close(); err != nil {
+					t.Errorf("Failed to close store: %v", err)
+				}
+			})
+			tt.run(t, store)
+		})
+	}
+}
+
+// mustSet stores a value and fails the test on error
+func mustSet(t *testing.T, store kv.KV, namespace, collection, key string, value []byte) {
+	t.Helper()
+	if err := store.Set(context.Background(), namespace, collection, key, value); err != nil {
+		t.Fatalf("Set(%q, %q, %q) failed: %v", namespace, collection, key, err)
+	}
+}
+
+// expectValue fails the test unless key holds want
+func expectValue(t *testing.T, store kv.KV, namespace, collection, key string, want []byte) {
+	t.Helper()
+	got, err := store.Get(context.Background(), namespace, collection, key)
+	if err != nil {
+		t.Fatalf("Get(%q, %q, %q) failed: %v", namespace, collection, key, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Get(%q, %q, %q) = %q, want %q", namespace, collection, key, got, want)
+	}
+}
+
+// expectMissing fails the test unless Get reports kv.ErrKeyNotFound and Exists reports false
+func expectMissing(t *testing.T, store kv.KV, namespace, collection, key string) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := store.Get(ctx, namespace, collection, key); !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Errorf("Get(%q, %q, %q) error = %v, want ErrKeyNotFound", namespace, collection, key, err)
+	}
+	exists, err := store.Exists(ctx, namespace, collection, key)
+	if err != nil {
+		t.Fatalf("Exists(%q, %q, %q) failed: %v", namespace, collection, key, err)
+	}
+	if exists {
+		t.Errorf("Exists(%q, %q, %q) = true, want false", namespace, collection, key)
+	}
+}
+
+func testCRUD(t *testing.T, store kv.KV) {
+	ctx := context.Background()
+
+	expectMissing(t, store, "conformance_a", "cards", "card_1")
+
+	mustSet(t, store, "conformance_a", "cards", "card_1", []byte(`{"room":"302"}`))
+	expectValue(t, store, "conformance_a", "cards", "card_1", []byte(`{"room":"302"}`))
+
+	exists, err := store.Exists(ctx, "conformance_a", "cards", "card_1")
+	if err != nil || !exists {
+		t.Errorf("Exists = %v (err %v), want true", exists, err)
+	}
+
+	// Overwrite replaces the value
+	mustSet(t, store, "conformance_a", "cards", "card_1", []byte(`{"room":"303"}`))
+	expectValue(t, store, "conformance_a", "cards", "card_1", []byte(`{"room":"303"}`))
+
+	// Mutating the returned slice must not change the stored value
+	got, _ := store.Get(ctx, "conformance_a", "cards", "card_1")
+	if len(got) > 0 {
+		got[0] = 'X'
+	}
+	expectValue(t, store, "conformance_a", "cards", "card_1", []byte(`{"room":"303"}`))
+
+	if err := store.Delete(ctx, "conformance_a", "cards", "card_1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	expectMissing(t, store, "conformance_a", "cards", "card_1")
+}
+
+func testDeleteMissing(t *testing.T, store kv.KV) {
+	ctx := context.Background()
+
+	// Missing namespace, missing collection and missing key all report ErrKeyNotFound
+	if err := store.Delete(ctx, "conformance_b", "cards", "nope"); !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Errorf("Delete in missing namespace error = %v, want ErrKeyNotFound", err)
+	}
+
+	mustSet(t, store, "conformance_a", "cards", "card_1", []byte(`1`))
+	if err := store.Delete(ctx, "conformance_a", "devices", "card_1"); !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Errorf("Delete in missing collection error = %v, want ErrKeyNotFound", err)
+	}
+	if err := store.Delete(ctx, "conformance_a", "cards", "card_2"); !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Errorf("Delete of missing key error = %v, want ErrKeyNotFound", err)
+	}
+
+	// Deleting twice reports the second delete as missing
+	if err := store.Delete(ctx, "conformance_a", "cards", "card_1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete(ctx, "conformance_a", "cards", "card_1"); !errors.Is(err, kv.ErrKeyNotFound) {
+		t.Errorf("Second Delete error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func testIsolation(t *testing.T, store kv.KV) {
+	mustSet(t, store, "conformance_a", "cards", "shared", []byte(`"a/cards"`))
+	mustSet(t, store, "conformance_a", "devices", "shared", []byte(`"a/devices"`))
+	mustSet(t, store, "conformance_b", "cards", "shared", []byte(`"b/cards"`))
+
+	expectValue(t, store, "conformance_a", "cards", "shared", []byte(`"a/cards"`))
+	expectValue(t, store, "conformance_a", "devices", "shared", []byte(`"a/devices"`))
+	expectValue(t, store, "conformance_b", "cards", "shared", []byte(`"b/cards"`))
+	expectMissing(t, store, "conformance_b", "devices", "shared")
+
+	if err := store.Delete(context.Background(), "conformance_a", "cards", "shared"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	expectValue(t, store, "conformance_a", "devices", "shared", []byte(`"a/devices"`))
+	expectValue(t, store, "conformance_b", "cards", "shared", []byte(`"b/cards"`))
+}
+
+func testNamespaceNormalization(t *testing.T, store kv.KV) {
+	ctx := context.Background()
+
+	mustSet(t, store, "", "cards", "card_1", []byte(`"empty"`))
+	expectValue(t, store, kv.DefaultNamespace, "cards", "card_1", []byte(`"empty"`))
+
+	mustSet(t, store, kv.DefaultNamespace, "cards", "card_2", []byte(`"default"`))
+	expectValue(t, store, "", "cards", "card_2", []byte(`"default"`))
+
+	result, err := store.List(ctx, "", "cards", kv.ListOptions{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Keys, []string{"card_1", "card_2"}) {
+		t.Errorf("List with empty namespace = %v, want [card_1 card_2]", result.Keys)
+	}
+
+	if err := store.Delete(ctx, "", "cards", "card_2"); err != nil {
+		t.Fatalf("Delete with empty namespace failed: %v", err)
+	}
+	expectMissing(t, store, kv.DefaultNamespace, "cards", "card_2")
+}
+
+func testEmptyValue(t *testing.T, store kv.KV) {
+	mustSet(t, store, "conformance_a", "cards", "empty", []byte{})
+
+	got, err := store.Get(context.Background(), "conformance_a", "cards", "empty")
+	if err != nil {
+		t.Fatalf("Get of empty value failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Get of empty value = %q, want empty", got)
+	}
+
+	exists, err := store.Exists(context.Background(), "conformance_a", "cards", "empty")
+	if err != nil || !exists {
+		t.Errorf("Exists of empty value = %v (err %v), want true", exists, err)
+	}
+}
+
+func testLargeValue(t *testing.T, store kv.KV) {
+	// 1 MiB, well above typical page and buffer sizes
+	value := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	mustSet(t, store, "conformance_a", "blobs", "large", value)
+	expectValue(t, store, "conformance_a", "blobs", "large", value)
+}
+
+func testBinaryKeysAndValues(t *testing.T, store kv.KV) {
+	keys := []string{
+		"with space",
+		"with:colon",
+		"with/slash",
+		"glob*?[chars]",
+		"unicode_キー_ключ",
+		"control\x00\x01\x7f",
+		"invalid_utf8_\xff\xfe",
+	}
+	value := make([]byte, 256)
+	for i := range value {
+		value[i] = byte(i)
+	}
+
+	for _, key := range keys {
+		mustSet(t, store, "conformance_a", "binary", key, value)
+	}
+	for _, key := range keys {
+		expectValue(t, store, "conformance_a", "binary", key, value)
+	}
+
+	result, err := store.List(context.Background(), "conformance_a", "binary", kv.ListOptions{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(result.Keys) != len(keys) {
+		t.Errorf("List returned %d keys, want %d: %q", len(result.Keys), len(keys), result.Keys)
+	}
+
+	for _, key := range keys {
+		if err := store.Delete(context.Background(), "conformance_a", "binary", key); err != nil {
+			t.Errorf("Delete(%q) failed: %v", key, err)
+		}
+	}
+}
+
+func testList(t *testing.T, store kv.KV) {
+	ctx := context.Background()
+
+	for _, key := range []string{"user_3", "user_1", "admin_1", "user_2"} {
+		mustSet(t, store, "conformance_a", "accounts", key, []byte(`{}`))
+	}
+	mustSet(t, store, "conformance_a", "other", "user_9", []byte(`{}`))
+
+	result, err := store.List(ctx, "conformance_a", "accounts", kv.ListOptions{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Keys, []string{"admin_1", "user_1", "user_2", "user_3"}) || result.NextCursor != "" {
+		t.Errorf("List = %v (cursor %q), want all keys in order", result.Keys, result.NextCursor)
+	}
+
+	// Walk the prefix one page at a time
+	var walked []string
+	opts := kv.ListOptions{Prefix: "user_", Limit: 2}
+	for page := 0; page < 10; page++ {
+		result, err := store.List(ctx, "conformance_a", "accounts", opts)
+		if err != nil {
+			t.Fatalf("List page %d failed: %v", page, err)
+		}
+		walked = append(walked, result.Keys...)
+		if result.NextCursor == "" {
+			break
+		}
+		opts.Cursor = result.NextCursor
+	}
+	if !reflect.DeepEqual(walked, []string{"user_1", "user_2", "user_3"}) {
+		t.Errorf("Paged List = %v, want [user_1 user_2 user_3]", walked)
+	}
+
+	result, err = store.List(ctx, "conformance_b", "missing", kv.ListOptions{})
+	if err != nil {
+		t.Fatalf("List of missing collection failed: %v", err)
+	}
+	if result.Keys == nil || len(result.Keys) != 0 {
+		t.Errorf("List of missing collection = %#v, want empty non-nil slice", result.Keys)
+	}
+}
+
+func testConcurrentWriters(t *testing.T, store kv.KV) {
+	const writers = 8
+	const writes = 25
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*writes*2)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				value := []byte(fmt.Sprintf(`{"writer":%d,"write":%d}`, w, i))
+				if err := store.Set(ctx, "conformance_a", "concurrent", fmt.Sprintf("w%d_%d", w, i), value); err != nil {
+					errs <- err
+				}
+				if err := store.Set(ctx, "conformance_a", "concurrent", "shared", value); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent Set failed: %v", err)
+	}
+
+	result, err := store.List(ctx, "conformance_a", "concurrent", kv.ListOptions{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(result.Keys) != writers*writes+1 {
+		t.Errorf("List returned %d keys, want %d", len(result.Keys), writers*writes+1)
+	}
+
+	// The shared key holds one complete write, never a mix of two
+	shared, err := store.Get(ctx, "conformance_a", "concurrent", "shared")
+	if err != nil {
+		t.Fatalf("Get of shared key failed: %v", err)
+	}
+	var writer, write int
+	if _, err := fmt.Sscanf(string(shared), `{"writer":%d,"write":%d}`, &writer, &write); err != nil {
+		t.Errorf("Shared key holds a torn value %q", shared)
+	}
+}
+
+func testContextCanceled(t *testing.T, store kv.KV) {
+	mustSet(t, store, "conformance_a", "cards", "card_1", []byte(`"before"`))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := store.Get(ctx, "conformance_a", "cards", "card_1"); err == nil {
+		t.Error("Get with canceled context succeeded, want error")
+	}
+	if err := store.Set(ctx, "conformance_a", "cards", "card_1", []byte(`"after"`)); err == nil {
+		t.Error("Set with canceled context succeeded, want error")
+	}
+	if err := store.Delete(ctx, "conformance_a", "cards", "card_1"); err == nil {
+		t.Error("Delete with canceled context succeeded, want error")
+	}
+	if _, err := store.Exists(ctx, "conformance_a", "cards", "card_1"); err == nil {
+		t.Error("Exists with canceled context succeeded, want error")
+	}
+	if _, err := store.List(ctx, "conformance_a", "cards", kv.ListOptions{}); err == nil {
+		t.Error("List with canceled context succeeded, want error")
+	}
+
+	// Nothing was written by the canceled calls
+	expectValue(t, store, "conformance_a", "cards", "card_1", []byte(`"before"`))
+}
+
+func testRevisionsNotReused(t *testing.T, store kv.KV) {
+	versioned, ok := store.(kv.Versioned)
+	if !ok {
+		t.Skip("store does not implement kv.Versioned")
+	}
+	ctx := context.Background()
+
+	// revision returns the current revision of card_1, failing the test unless it is above last
+	revision := func(step string, last uint64) uint64 {
+		t.Helper()
+		_, rev, err := versioned.GetWithVersion(ctx, "conformance_a", "cards", "card_1")
+		if err != nil {
+			t.Fatalf("GetWithVersion after %s failed: %v", step, err)
+		}
+		if rev <= last {
+			t.Errorf("Revision after %s = %d, want above %d", step, rev, last)
+		}
+		return rev
+	}
+
+	mustSet(t, store, "conformance_a", "cards", "card_1", []byte(`"v1"`))
+	mustSet(t, store, "conformance_a", "cards", "card_1", []byte(`"v2"`))
+	last := revision("two writes", 1)
+
+	// A recreated key continues above the revisions it had before, so a stale version never matches again
+	if err := store.Delete(ctx, "conformance_a", "cards", "card_1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	mustSet(t, store, "conformance_a", "cards", "card_1", []byte(`"v1"`))
+	last = revision("delete", last)
+	if _, err := versioned.CompareAndSet(ctx, "conformance_a", "cards", "card_1", 1, []byte(`"stale"`)); !errors.Is(err, kv.ErrVersionMismatch) {
+		t.Errorf("CompareAndSet with a revision of the deleted key = %v, want ErrVersionMismatch", err)
+	}
+
+	// Writes to other keys of the namespace never move a key back either
+	mustSet(t, store, "conformance_a", "devices", "device_1", []byte(`"v1"`))
+	if err := versioned.CompareAndDelete(ctx, "conformance_a", "cards", "card_1", last); err != nil {
+		t.Fatalf("CompareAndDelete failed: %v", err)
+	}
+	if _, err := versioned.CompareAndSet(ctx, "conformance_a", "cards", "card_1", 0, []byte(`"v1"`)); err != nil {
+		t.Fatalf("CompareAndSet of a new key failed: %v", err)
+	}
+	last = revision("compare and delete", last)
+
+	dropper, ok := store.(kv.Dropper)
+	if !ok {
+		return
+	}
+	if err := dropper.DropCollection(ctx, "conformance_a", "cards"); err != nil {
+		t.Fatalf("DropCollection failed: %v", err)
+	}
+	mustSet(t, store, "conformance_a", "cards", "card_1", []byte(`"v1"`))
+	last = revision("DropCollection", last)
+
+	if err := dropper.DropNamespace(ctx, "conformance_a"); err != nil {
+		t.Fatalf("DropNamespace failed: %v", err)
+	}
+	mustSet(t, store, "conformance_a", "cards", "card_1", []byte(`"v1"`))
+	revision("DropNamespace", last)
+}
+
+func testGetManyTTL(t *testing.T, store kv.KV) {
+	getter, ok := store.(kv.BatchGetter)
+	if !ok {
+		t.Skip("store does not implement kv.BatchGetter")
+	}
+	ctx := context.Background()
+
+	mustSet(t, store, "conformance_a", "cards", "card_1", []byte(`"forever"`))
+	if err := store.SetWithTTL(ctx, "conformance_a", "cards", "card_2", []byte(`"expiring"`), time.Hour); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+
+	results, err := getter.GetMany(ctx, []kv.Key{
+		{Namespace: "conformance_a", Collection: "cards", Key: "card_1"},
+		{Namespace: "conformance_a", Collection: "cards", Key: "card_2"},
+	})
+	if err != nil {
+		t.Fatalf("GetMany failed: %v", err)
+	}
+	if len(results) != 2 || !results[0].Found || !results[1].Found {
+		t.Fatalf("GetMany = %+v, want both keys found", results)
+	}
+	if results[0].TTL != 0 {
+		t.Errorf("TTL of a key without expiry = %v, want 0", results[0].TTL)
+	}
+	if ttl := results[1].TTL; ttl <= time.Hour-time.Minute || ttl > time.Hour {
+		t.Errorf("TTL of a key expiring in an hour = %v, want just under an hour", ttl)
+	}
+}
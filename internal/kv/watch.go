@@ -0,0 +1,138 @@
+package kv
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// EventType is the kind of change reported by Watch
+type EventType string
+
+const (
+	// EventSet is reported when a key is created or updated
+	EventSet EventType = "set"
+	// EventDelete is reported when a key is deleted or expires, and for each key of a dropped collection or namespace
+	EventDelete EventType = "delete"
+)
+
+// WatchBufferSize is the number of undelivered events kept per watcher
+// A watcher that falls further behind has its channel closed and must watch again
+const WatchBufferSize = 256
+
+// Event describes a change to a single key
+type Event struct {
+	Type       EventType
+	Namespace  string
+	Collection string
+	Key        string
+	// Value is the new value for EventSet and nil for EventDelete
+	Value []byte
+	// Revision is the new revision for EventSet (0 if the backend does not track revisions) and 0 for EventDelete
+	Revision uint64
+}
+
+// Watcher is implemented by backends that can stream changes to a collection
+type Watcher interface {
+	// Watch reports changes to keys in namespace and collection that start with prefix
+	// The channel is closed when ctx is done, the backend is closed or the watcher falls too far behind
+	Watch(ctx context.Context, namespace, collection, prefix string) (<-chan Event, error)
+}
+
+// subscription is a single Watch registered with a Broadcaster
+type subscription struct {
+	namespace  string
+	collection string
+	prefix     string
+	ch         chan Event
+}
+
+// matches reports whether the event belongs to the subscription
+func (s *subscription) matches(event Event) bool {
+	return event.Namespace == s.namespace &&
+		event.Collection == s.collection &&
+		strings.HasPrefix(event.Key, s.prefix)
+}
+
+// Broadcaster fans out events to in-process watchers
+// Backends without a native change feed publish every successful write to it
+type Broadcaster struct {
+	mu     sync.Mutex
+	subs   map[*subscription]struct{}
+	closed bool
+}
+
+// NewBroadcaster creates an empty Broadcaster
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subs: make(map[*subscription]struct{})}
+}
+
+// Subscribe registers a watcher until ctx is done (see Watcher.Watch)
+func (b *Broadcaster) Subscribe(ctx context.Context, namespace, collection, prefix string) <-chan Event {
+	sub := &subscription{
+		namespace:  NormalizeNamespace(namespace),
+		collection: collection,
+		prefix:     prefix,
+		ch:         make(chan Event, WatchBufferSize),
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(sub.ch)
+		return sub.ch
+	}
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.remove(sub)
+	}()
+
+	return sub.ch
+}
+
+// Publish delivers event to every matching watcher without blocking
+// Watchers whose buffer is full are dropped
+func (b *Broadcaster) Publish(event Event) {
+	event.Namespace = NormalizeNamespace(event.Namespace)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if !sub.matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Close closes every watcher channel; later subscriptions are closed immediately
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// remove unregisters a watcher and closes its channel if it is still registered
+func (b *Broadcaster) remove(sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
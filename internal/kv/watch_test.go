@@ -0,0 +1,88 @@
+package kv
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// receive waits for the next event or fails the test
+func receive(t *testing.T, ch <-chan Event) (Event, bool) {
+	t.Helper()
+	select {
+	case event, ok := <-ch:
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event")
+		return Event{}, false
+	}
+}
+
+func TestBroadcaster_Filtering(t *testing.T) {
+	b := NewBroadcaster()
+	defer b.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := b.Subscribe(ctx, "", "cards", "guest_")
+
+	b.Publish(Event{Type: EventSet, Namespace: "default", Collection: "cards", Key: "staff_1"})
+	b.Publish(Event{Type: EventSet, Namespace: "other", Collection: "cards", Key: "guest_1"})
+	b.Publish(Event{Type: EventSet, Namespace: "default", Collection: "devices", Key: "guest_1"})
+	b.Publish(Event{Type: EventSet, Namespace: "", Collection: "cards", Key: "guest_1", Revision: 2})
+
+	event, ok := receive(t, ch)
+	if !ok || event.Key != "guest_1" || event.Namespace != "default" || event.Revision != 2 {
+		t.Errorf("Expected guest_1 event in default namespace, got %+v", event)
+	}
+	select {
+	case event := <-ch:
+		t.Errorf("Expected no more events, got %+v", event)
+	default:
+	}
+}
+
+func TestBroadcaster_CancelClosesChannel(t *testing.T) {
+	b := NewBroadcaster()
+	defer b.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := b.Subscribe(ctx, "default", "cards", "")
+	cancel()
+
+	if _, ok := receive(t, ch); ok {
+		t.Error("Expected channel to be closed after cancel")
+	}
+}
+
+func TestBroadcaster_SlowWatcherDropped(t *testing.T) {
+	b := NewBroadcaster()
+	defer b.Close()
+
+	ch := b.Subscribe(context.Background(), "default", "cards", "")
+	for i := 0; i <= WatchBufferSize; i++ {
+		b.Publish(Event{Type: EventSet, Namespace: "default", Collection: "cards", Key: "k"})
+	}
+
+	received := 0
+	for range ch {
+		received++
+	}
+	if received != WatchBufferSize {
+		t.Errorf("Expected %d buffered events before close, got %d", WatchBufferSize, received)
+	}
+}
+
+func TestBroadcaster_Close(t *testing.T) {
+	b := NewBroadcaster()
+	ch := b.Subscribe(context.Background(), "default", "cards", "")
+	b.Close()
+
+	if _, ok := receive(t, ch); ok {
+		t.Error("Expected channel to be closed after Close")
+	}
+	if _, ok := receive(t, b.Subscribe(context.Background(), "default", "cards", "")); ok {
+		t.Error("Expected subscription after Close to be closed")
+	}
+}
@@ -0,0 +1,354 @@
+// Package migrate copies the keys of one KV backend to another
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"slices"
+
+	"commander/internal/kv"
+)
+
+// DefaultBatchSize is the number of keys read and written at once when Options leaves it unset
+const DefaultBatchSize = 500
+
+// Options configures a Migrator
+type Options struct {
+	// BatchSize is the number of keys per read and write (DefaultBatchSize if <= 0)
+	BatchSize int
+
+	// DryRun reads the source as a migration would but writes nothing
+	DryRun bool
+
+	// Namespaces restricts the migration to these namespaces (all if empty)
+	Namespaces []string
+
+	// Exclude lists collections skipped in every namespace
+	Exclude []string
+
+	// State is the progress of an earlier run to resume; it is updated as collections are copied
+	// StatePath is where it is saved after every batch ("" = not saved)
+	State     *State
+	StatePath string
+
+	// Progress is called after every batch
+	Progress func(Progress)
+}
+
+// Progress reports the keys copied so far
+type Progress struct {
+	Namespace  string
+	Collection string
+
+	// Keys is the number of keys copied from the collection, including those of earlier runs
+	Keys int64
+	// Total is the number of keys copied by this run
+	Total int64
+}
+
+// CollectionReport is the outcome of copying one collection
+type CollectionReport struct {
+	Namespace  string
+	Collection string
+
+	// Keys and Bytes count what this run copied
+	Keys  int64
+	Bytes int64
+
+	// Resumed is set when an earlier run had already completed the collection
+	Resumed bool
+}
+
+// Report is the outcome of a migration
+type Report struct {
+	Collections []CollectionReport
+	Keys        int64
+	Bytes       int64
+}
+
+// Verification compares one collection on both sides
+type Verification struct {
+	Namespace  string
+	Collection string
+
+	SourceKeys          int64
+	DestinationKeys     int64
+	SourceChecksum      string
+	DestinationChecksum string
+}
+
+// OK reports whether both sides hold the same keys and values
+func (v Verification) OK() bool {
+	return v.SourceKeys == v.DestinationKeys && v.SourceChecksum == v.DestinationChecksum
+}
+
+// Migrator copies every namespace, collection and key of a source backend to a destination backend
+// Values keep their remaining expiry, and existing destination keys are overwritten
+type Migrator struct {
+	source      kv.KV
+	lister      kv.Lister
+	getter      kv.BatchGetter
+	destination kv.KV
+	opts        Options
+
+	// batchWrites is cleared when the destination turns out to not support transactions
+	batchWrites bool
+}
+
+// New creates a Migrator; the source must implement kv.Lister, and kv.BatchGetter to read the
+// remaining TTL of its keys
+func New(source, destination kv.KV, opts Options) (*Migrator, error) {
+	lister, ok := source.(kv.Lister)
+	if !ok {
+		return nil, fmt.Errorf("migrate: source %T cannot list namespaces and collections", source)
+	}
+	getter, ok := source.(kv.BatchGetter)
+	if !ok {
+		return nil, fmt.Errorf("migrate: source %T cannot read the expiry of its keys", source)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	_, transactional := destination.(kv.Transactional)
+
+	return &Migrator{
+		source:      source,
+		lister:      lister,
+		getter:      getter,
+		destination: destination,
+		opts:        opts,
+		batchWrites: transactional,
+	}, nil
+}
+
+// Fingerprint identifies a source and destination pair without storing their URIs, which may hold credentials
+func Fingerprint(sourceURI, destinationURI string) string {
+	sum := sha256.Sum256([]byte(sourceURI + "\x00" + destinationURI))
+	return hex.EncodeToString(sum[:])
+}
+
+// Run copies every selected collection
+// With a State, collections it marks as done are skipped and an interrupted collection resumes after its
+// last copied key
+func (m *Migrator) Run(ctx context.Context) (*Report, error) {
+	collections, err := m.collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	for _, c := range collections {
+		var state *CollectionState
+		if m.opts.State != nil && !m.opts.DryRun {
+			state = m.opts.State.collection(c.Namespace, c.Collection)
+		} else {
+			state = &CollectionState{Namespace: c.Namespace, Collection: c.Collection}
+		}
+
+		result := CollectionReport{Namespace: c.Namespace, Collection: c.Collection, Resumed: state.Done}
+		if !state.Done {
+			if err := m.copyCollection(ctx, state, &result, report); err != nil {
+				return report, fmt.Errorf("namespace %s, collection %s: %w", c.Namespace, c.Collection, err)
+			}
+		}
+		report.Collections = append(report.Collections, result)
+	}
+	return report, nil
+}
+
+// Verify compares the key count and a checksum of the keys and values of every selected collection
+// on both sides; run it once writes to the source have stopped
+func (m *Migrator) Verify(ctx context.Context) ([]Verification, error) {
+	collections, err := m.collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Verification, 0, len(collections))
+	for _, c := range collections {
+		v := Verification{Namespace: c.Namespace, Collection: c.Collection}
+		if v.SourceKeys, v.SourceChecksum, err = checksum(ctx, m.source, c.Namespace, c.Collection, m.opts.BatchSize); err != nil {
+			return results, fmt.Errorf("source namespace %s, collection %s: %w", c.Namespace, c.Collection, err)
+		}
+		if v.DestinationKeys, v.DestinationChecksum, err = checksum(ctx, m.destination, c.Namespace, c.Collection, m.opts.BatchSize); err != nil {
+			return results, fmt.Errorf("destination namespace %s, collection %s: %w", c.Namespace, c.Collection, err)
+		}
+		results = append(results, v)
+	}
+	return results, nil
+}
+
+// collections returns the selected collections of the source in a stable order
+func (m *Migrator) collections(ctx context.Context) ([]kv.Key, error) {
+	namespaces, err := m.lister.ListNamespaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	slices.Sort(namespaces)
+
+	var collections []kv.Key
+	for _, namespace := range namespaces {
+		if len(m.opts.Namespaces) > 0 && !slices.Contains(m.opts.Namespaces, namespace) {
+			continue
+		}
+		names, err := m.lister.ListCollections(ctx, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list collections of namespace %s: %w", namespace, err)
+		}
+		slices.Sort(names)
+		for _, collection := range names {
+			if !slices.Contains(m.opts.Exclude, collection) {
+				collections = append(collections, kv.Key{Namespace: namespace, Collection: collection})
+			}
+		}
+	}
+	return collections, nil
+}
+
+// copyCollection copies a collection batch by batch, starting after state.Cursor
+func (m *Migrator) copyCollection(ctx context.Context, state *CollectionState, result *CollectionReport, report *Report) error {
+	for {
+		page, err := m.source.List(ctx, state.Namespace, state.Collection, kv.ListOptions{Cursor: state.Cursor, Limit: m.opts.BatchSize})
+		if err != nil {
+			return err
+		}
+
+		if len(page.Keys) > 0 {
+			keys := make([]kv.Key, len(page.Keys))
+			for i, key := range page.Keys {
+				keys[i] = kv.Key{Namespace: state.Namespace, Collection: state.Collection, Key: key}
+			}
+			values, err := m.getter.GetMany(ctx, keys)
+			if err != nil {
+				return err
+			}
+
+			// Keys that expired since they were listed are left out
+			ops := make([]kv.Op, 0, len(keys))
+			var size int64
+			for i, value := range values {
+				if value.Found {
+					ops = append(ops, kv.Op{
+						Type:       kv.OpSet,
+						Namespace:  state.Namespace,
+						Collection: state.Collection,
+						Key:        keys[i].Key,
+						Value:      value.Value,
+						TTL:        value.TTL,
+					})
+					size += int64(len(value.Value))
+				}
+			}
+			if !m.opts.DryRun {
+				if err := m.write(ctx, ops); err != nil {
+					return err
+				}
+			}
+
+			n := int64(len(ops))
+			result.Keys += n
+			result.Bytes += size
+			report.Keys += n
+			report.Bytes += size
+			state.Copied += n
+			state.Cursor = page.Keys[len(page.Keys)-1]
+		}
+
+		state.Done = page.NextCursor == ""
+		if err := m.saveState(); err != nil {
+			return err
+		}
+		if m.opts.Progress != nil {
+			m.opts.Progress(Progress{Namespace: state.Namespace, Collection: state.Collection, Keys: state.Copied, Total: report.Keys})
+		}
+		if state.Done {
+			return nil
+		}
+	}
+}
+
+// write stores a batch in one transaction when the destination supports it, one key at a time otherwise
+func (m *Migrator) write(ctx context.Context, ops []kv.Op) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	if m.batchWrites {
+		err := m.destination.(kv.Transactional).Apply(ctx, ops)
+		if !errors.Is(err, kv.ErrTransactionsUnsupported) {
+			return err
+		}
+		// MongoDB only runs transactions on a replica set
+		log.Printf("[Migrate] Destination does not support transactions, writing keys one by one: error=%v", err)
+		m.batchWrites = false
+	}
+
+	for _, op := range ops {
+		var err error
+		if op.TTL > 0 {
+			err = m.destination.SetWithTTL(ctx, op.Namespace, op.Collection, op.Key, op.Value, op.TTL)
+		} else {
+			err = m.destination.Set(ctx, op.Namespace, op.Collection, op.Key, op.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveState writes the state file when one is configured
+func (m *Migrator) saveState() error {
+	if m.opts.State == nil || m.opts.StatePath == "" || m.opts.DryRun {
+		return nil
+	}
+	if err := m.opts.State.Save(m.opts.StatePath); err != nil {
+		return fmt.Errorf("failed to save migration state: %w", err)
+	}
+	return nil
+}
+
+// checksum counts the keys of a collection and hashes its keys and values in key order
+func checksum(ctx context.Context, store kv.KV, namespace, collection string, batchSize int) (int64, string, error) {
+	h := sha256.New()
+	var count int64
+	cursor := ""
+	for {
+		page, err := store.List(ctx, namespace, collection, kv.ListOptions{Cursor: cursor, Limit: batchSize})
+		if err != nil {
+			return 0, "", err
+		}
+		keys := make([]kv.Key, len(page.Keys))
+		for i, key := range page.Keys {
+			keys[i] = kv.Key{Namespace: namespace, Collection: collection, Key: key}
+		}
+		values, err := kv.GetMany(ctx, store, keys)
+		if err != nil {
+			return 0, "", err
+		}
+		for i, value := range values {
+			if value.Found {
+				writeField(h, []byte(keys[i].Key))
+				writeField(h, value.Value)
+				count++
+			}
+		}
+		if page.NextCursor == "" {
+			return count, hex.EncodeToString(h.Sum(nil)), nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// writeField hashes a length-prefixed field, so key and value boundaries cannot shift
+func writeField(h hash.Hash, field []byte) {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(field)))
+	h.Write(length[:])
+	h.Write(field)
+}
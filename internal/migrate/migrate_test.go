@@ -0,0 +1,333 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"commander/internal/database/memory"
+	"commander/internal/kv"
+)
+
+// newStore returns an empty memory backend
+func newStore(t *testing.T) *memory.MemoryKV {
+	t.Helper()
+	store, err := memory.NewMemoryKV("")
+	if err != nil {
+		t.Fatalf("Failed to create memory KV: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+// seed writes count keys to each collection
+func seed(t *testing.T, store kv.KV, namespace string, collections []string, count int) {
+	t.Helper()
+	for _, collection := range collections {
+		for i := 0; i < count; i++ {
+			value := fmt.Sprintf(`{"collection":%q,"n":%d}`, collection, i)
+			if err := store.Set(context.Background(), namespace, collection, fmt.Sprintf("k%02d", i), []byte(value)); err != nil {
+				t.Fatalf("Failed to seed value: %v", err)
+			}
+		}
+	}
+}
+
+// failingKV rejects transactions like a MongoDB without replica set, and fails writes after failAfter sets
+type failingKV struct {
+	*memory.MemoryKV
+	sets      int
+	failAfter int
+}
+
+func (f *failingKV) Apply(context.Context, []kv.Op) error {
+	return kv.ErrTransactionsUnsupported
+}
+
+func (f *failingKV) Set(ctx context.Context, namespace, collection, key string, value []byte) error {
+	if f.failAfter > 0 && f.sets >= f.failAfter {
+		return errors.New("connection lost")
+	}
+	f.sets++
+	return f.MemoryKV.Set(ctx, namespace, collection, key, value)
+}
+
+// brokenTxKV fails every transaction for a reason other than missing transaction support
+type brokenTxKV struct {
+	*memory.MemoryKV
+}
+
+func (b *brokenTxKV) Apply(context.Context, []kv.Op) error {
+	return errors.New("connection reset")
+}
+
+func TestMigrator_Copy(t *testing.T) {
+	source := newStore(t)
+	seed(t, source, "hotel_a", []string{"cards", "devices"}, 5)
+	seed(t, source, "hotel_b", []string{"cards"}, 3)
+
+	tests := []struct {
+		name        string
+		destination kv.KV
+	}{
+		{"transactional destination", newStore(t)},
+		{"destination without transactions", &failingKV{MemoryKV: newStore(t)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			m, err := New(source, tt.destination, Options{BatchSize: 2, Progress: func(Progress) { calls++ }})
+			if err != nil {
+				t.Fatalf("Failed to create migrator: %v", err)
+			}
+			ctx := context.Background()
+
+			report, err := m.Run(ctx)
+			if err != nil {
+				t.Fatalf("Migration failed: %v", err)
+			}
+			if report.Keys != 13 || len(report.Collections) != 3 {
+				t.Errorf("Expected 13 keys in 3 collections, got %+v", report)
+			}
+			if calls != 8 { // 3 + 3 + 2 batches
+				t.Errorf("Expected 8 progress reports, got %d", calls)
+			}
+
+			value, err := tt.destination.Get(ctx, "hotel_b", "cards", "k02")
+			if err != nil || string(value) != `{"collection":"cards","n":2}` {
+				t.Errorf("Expected copied value, got %s (err %v)", value, err)
+			}
+
+			verifications, err := m.Verify(ctx)
+			if err != nil {
+				t.Fatalf("Verification failed: %v", err)
+			}
+			for _, v := range verifications {
+				if !v.OK() {
+					t.Errorf("Expected %s/%s to match, got %+v", v.Namespace, v.Collection, v)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrator_KeepsTTL(t *testing.T) {
+	ctx := context.Background()
+	source := newStore(t)
+	if err := source.SetWithTTL(ctx, "hotel_a", "access_logs", "l1", []byte(`{"id":"l1"}`), time.Hour); err != nil {
+		t.Fatalf("Failed to seed value: %v", err)
+	}
+	seed(t, source, "hotel_a", []string{"cards"}, 1)
+
+	tests := []struct {
+		name        string
+		destination kv.KV
+	}{
+		{"transactional destination", newStore(t)},
+		{"destination without transactions", &failingKV{MemoryKV: newStore(t)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(source, tt.destination, Options{})
+			if err != nil {
+				t.Fatalf("Failed to create migrator: %v", err)
+			}
+			if _, err := m.Run(ctx); err != nil {
+				t.Fatalf("Migration failed: %v", err)
+			}
+
+			results, err := kv.GetMany(ctx, tt.destination, []kv.Key{
+				{Namespace: "hotel_a", Collection: "access_logs", Key: "l1"},
+				{Namespace: "hotel_a", Collection: "cards", Key: "k00"},
+			})
+			if err != nil {
+				t.Fatalf("Failed to read destination: %v", err)
+			}
+			if ttl := results[0].TTL; !results[0].Found || ttl <= 0 || ttl > time.Hour {
+				t.Errorf("Expected the access log to keep its remaining TTL, got %+v", results[0])
+			}
+			if !results[1].Found || results[1].TTL != 0 {
+				t.Errorf("Expected the card to not expire, got %+v", results[1])
+			}
+		})
+	}
+}
+
+func TestMigrator_TransactionErrorsAreNotRetried(t *testing.T) {
+	source := newStore(t)
+	seed(t, source, "", []string{"cards"}, 2)
+	destination := &brokenTxKV{MemoryKV: newStore(t)}
+
+	m, err := New(source, destination, Options{})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	// Only kv.ErrTransactionsUnsupported switches to writing keys one by one
+	if _, err := m.Run(context.Background()); err == nil || !m.batchWrites {
+		t.Errorf("Expected the transaction error to stop the migration, got %v", err)
+	}
+}
+
+func TestMigrator_DryRun(t *testing.T) {
+	source := newStore(t)
+	seed(t, source, "", []string{"cards"}, 4)
+	destination := newStore(t)
+	state := &State{}
+
+	m, err := New(source, destination, Options{DryRun: true, State: state, StatePath: filepath.Join(t.TempDir(), "state.json")})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	report, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if report.Keys != 4 || report.Bytes == 0 {
+		t.Errorf("Expected 4 keys to be reported, got %+v", report)
+	}
+
+	namespaces, err := destination.ListNamespaces(context.Background())
+	if err != nil || len(namespaces) != 0 {
+		t.Errorf("Expected dry run to write nothing, got %v (err %v)", namespaces, err)
+	}
+	if len(state.Collections) != 0 {
+		t.Errorf("Expected dry run to leave the state untouched, got %+v", state.Collections)
+	}
+}
+
+func TestMigrator_Resume(t *testing.T) {
+	source := newStore(t)
+	seed(t, source, "", []string{"a", "b"}, 5)
+	destination := &failingKV{MemoryKV: newStore(t), failAfter: 7}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	ctx := context.Background()
+
+	// The first run stops in collection b after its first batch of 2 keys was saved
+	m, err := New(source, destination, Options{BatchSize: 2, State: &State{Fingerprint: "f"}, StatePath: statePath})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	if _, err := m.Run(ctx); err == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+
+	state, err := LoadState(statePath)
+	if err != nil || state == nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if state.Fingerprint != "f" || len(state.Collections) != 2 {
+		t.Fatalf("Unexpected state: %+v", state)
+	}
+	if a, b := state.Collections[0], state.Collections[1]; !a.Done || b.Done || b.Cursor != "k01" || b.Copied != 2 {
+		t.Errorf("Unexpected progress: %+v, %+v", a, b)
+	}
+
+	// The second run skips collection a and copies the last 3 keys of b
+	destination.failAfter = 0
+	m, err = New(source, destination, Options{BatchSize: 2, State: state, StatePath: statePath})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	report, err := m.Run(ctx)
+	if err != nil {
+		t.Fatalf("Resumed migration failed: %v", err)
+	}
+	if report.Keys != 3 || !report.Collections[0].Resumed || report.Collections[1].Keys != 3 {
+		t.Errorf("Expected only the rest of collection b to be copied, got %+v", report)
+	}
+
+	verifications, err := m.Verify(ctx)
+	if err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+	for _, v := range verifications {
+		if !v.OK() {
+			t.Errorf("Expected %s to match, got %+v", v.Collection, v)
+		}
+	}
+}
+
+func TestMigrator_FiltersAndMismatch(t *testing.T) {
+	source := newStore(t)
+	seed(t, source, "hotel_a", []string{"cards", "access_logs"}, 2)
+	seed(t, source, "hotel_b", []string{"cards"}, 2)
+	destination := newStore(t)
+	ctx := context.Background()
+
+	m, err := New(source, destination, Options{Namespaces: []string{"hotel_a"}, Exclude: []string{"access_logs"}})
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+	report, err := m.Run(ctx)
+	if err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+	if len(report.Collections) != 1 || report.Collections[0].Namespace != "hotel_a" || report.Collections[0].Collection != "cards" {
+		t.Errorf("Expected only hotel_a/cards to be copied, got %+v", report.Collections)
+	}
+
+	tests := []struct {
+		name   string
+		change func() error
+	}{
+		{"changed value", func() error { return destination.Set(ctx, "hotel_a", "cards", "k00", []byte(`{}`)) }},
+		{"extra key", func() error { return destination.Set(ctx, "hotel_a", "cards", "k99", []byte(`{}`)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Run(ctx); err != nil {
+				t.Fatalf("Migration failed: %v", err)
+			}
+			_ = destination.Delete(ctx, "hotel_a", "cards", "k99")
+			if err := tt.change(); err != nil {
+				t.Fatalf("Failed to change destination: %v", err)
+			}
+
+			verifications, err := m.Verify(ctx)
+			if err != nil {
+				t.Fatalf("Verification failed: %v", err)
+			}
+			if len(verifications) != 1 || verifications[0].OK() {
+				t.Errorf("Expected a mismatch, got %+v", verifications)
+			}
+		})
+	}
+}
+
+func TestNew_RequiresLister(t *testing.T) {
+	if _, err := New(struct{ kv.KV }{newStore(t)}, newStore(t), Options{}); err == nil {
+		t.Error("Expected an error for a source that cannot list collections")
+	}
+}
+
+func TestState_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := LoadState(path)
+	if err != nil || state != nil {
+		t.Fatalf("Expected no state for a missing file, got %+v (err %v)", state, err)
+	}
+
+	saved := &State{Fingerprint: Fingerprint("bbolt:///data", "redis://localhost:6379")}
+	saved.collection("hotel_a", "cards").Cursor = "c9"
+	if err := saved.Save(path); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	state, err = LoadState(path)
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if state.Fingerprint != saved.Fingerprint || state.collection("hotel_a", "cards").Cursor != "c9" {
+		t.Errorf("Expected saved state, got %+v", state)
+	}
+	if Fingerprint("bbolt:///data", "redis://other:6379") == saved.Fingerprint {
+		t.Error("Expected a different fingerprint for another destination")
+	}
+}
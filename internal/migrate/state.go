@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// State records how far a migration got, so an interrupted run can resume
+type State struct {
+	// Fingerprint identifies the source and destination (see Fingerprint); a state is only resumed
+	// by a run between the same backends
+	Fingerprint string `json:"fingerprint"`
+
+	Collections []*CollectionState `json:"collections"`
+}
+
+// CollectionState is the progress of one collection
+type CollectionState struct {
+	Namespace  string `json:"namespace"`
+	Collection string `json:"collection"`
+
+	// Cursor is the last key copied; copying resumes after it
+	Cursor string `json:"cursor,omitempty"`
+	Copied int64  `json:"copied"`
+	Done   bool   `json:"done"`
+}
+
+// collection returns the progress of a collection, adding it if it is not tracked yet
+func (s *State) collection(namespace, collection string) *CollectionState {
+	for _, c := range s.Collections {
+		if c.Namespace == namespace && c.Collection == collection {
+			return c
+		}
+	}
+	c := &CollectionState{Namespace: namespace, Collection: collection}
+	s.Collections = append(s.Collections, c)
+	return c
+}
+
+// LoadState reads a state file; a missing file returns nil
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// Save writes the state file atomically, so an interruption never leaves a truncated file
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Access log outcomes
+const (
+	AccessGranted = "granted"
+	AccessDenied  = "denied"
+)
+
+// Verification protocols recorded in access logs
+const (
+	ProtocolStandard = "standard" // POST /api/v1/namespace/:namespace with X-Device-SN
+	ProtocolVguang   = "vguang"   // POST /api/v1/namespace/:namespace/device/:device_name/vguang
+)
+
+// AccessLog is a single card verification attempt
+// IDs sort in timestamp order, so listing by ID is chronological
+type AccessLog struct {
+	ID         string    `json:"id" bson:"_id"`
+	DeviceSN   string    `json:"device_sn" bson:"device_sn"`
+	CardNumber string    `json:"card_number" bson:"card_number"`
+	CardID     string    `json:"card_id,omitempty" bson:"card_id,omitempty"` // Empty if the card was not found
+	Outcome    string    `json:"outcome" bson:"outcome"`                     // AccessGranted or AccessDenied
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`     // Reason of a denied attempt
+	Protocol   string    `json:"protocol" bson:"protocol"`
+	Timestamp  time.Time `json:"timestamp" bson:"timestamp"`
+	Edge       string    `json:"edge,omitempty" bson:"edge,omitempty"` // Edge instance that pushed the entry; empty if recorded here
+
+	// ExpiresAt drives the MongoDB TTL index; KV backends expire entries with SetWithTTL instead
+	ExpiresAt *time.Time `json:"-" bson:"expires_at,omitempty"`
+}
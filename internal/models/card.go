@@ -2,43 +2,62 @@ package models
 
 import "time"
 
-// Device represents a device document in MongoDB
+// Device represents a device document in MongoDB (or a JSON value in the devices collection)
 type Device struct {
-	ID          string                 `bson:"_id"`
-	TenantID    string                 `bson:"tenant_id"`
-	DeviceID    string                 `bson:"device_id"`
-	SN          string                 `bson:"sn"`
-	DisplayName string                 `bson:"display_name"`
-	Status      string                 `bson:"status"` // "active", "inactive", etc.
-	Metadata    map[string]interface{} `bson:"metadata"`
-	CreatedAt   time.Time              `bson:"created_at"`
-	UpdatedAt   time.Time              `bson:"updated_at"`
+	ID          string                 `json:"id" bson:"_id"`
+	TenantID    string                 `json:"tenant_id" bson:"tenant_id"`
+	DeviceID    string                 `json:"device_id" bson:"device_id"`
+	SN          string                 `json:"sn" bson:"sn"`
+	DisplayName string                 `json:"display_name" bson:"display_name"`
+	Status      string                 `json:"status" bson:"status"`                           // See the DeviceStatus constants
+	Direction   string                 `json:"direction,omitempty" bson:"direction,omitempty"` // Anti-passback: entry or exit (empty = not checked)
+	Zone        string                 `json:"zone,omitempty" bson:"zone,omitempty"`           // Anti-passback area guarded by the device
+	Metadata    map[string]interface{} `json:"metadata" bson:"metadata"`
+	CreatedAt   time.Time              `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at" bson:"updated_at"`
 }
 
-// Card represents a card document in MongoDB
+// Card represents a card document in MongoDB (or a JSON value in the cards collection)
 type Card struct {
-	ID             string    `bson:"_id"`
-	OrganizationID string    `bson:"organization_id"`
-	Number         string    `bson:"number"`
-	DisplayName    string    `bson:"display_name"`
-	Devices        []string  `bson:"devices"` // Array of device SNs
-	EffectiveAt    time.Time `bson:"effective_at"`
-	InvalidAt      time.Time `bson:"invalid_at"`
-	BarcodeType    string    `bson:"barcode_type"`
-	CreatedAt      time.Time `bson:"created_at"`
-	UpdatedAt      time.Time `bson:"updated_at"`
+	ID             string     `json:"id" bson:"_id"`
+	OrganizationID string     `json:"organization_id" bson:"organization_id"`
+	Number         string     `json:"number" bson:"number"`
+	DisplayName    string     `json:"display_name" bson:"display_name"`
+	Devices        []string   `json:"devices" bson:"devices"`                   // Array of device SNs
+	Groups         []string   `json:"groups,omitempty" bson:"groups,omitempty"` // Device group IDs; members are authorized too
+	EffectiveAt    time.Time  `json:"effective_at" bson:"effective_at"`
+	InvalidAt      time.Time  `json:"invalid_at" bson:"invalid_at"`
+	BarcodeType    string     `json:"barcode_type" bson:"barcode_type"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"` // Set when the card was revoked
+	Schedule       *Schedule  `json:"schedule,omitempty" bson:"schedule,omitempty"`     // Optional recurring access windows
+	MaxUses        int        `json:"max_uses,omitempty" bson:"max_uses,omitempty"`     // Successful verifications allowed (0 = unlimited, 1 = one-time)
+	UseCount       int        `json:"use_count" bson:"use_count"`                       // Successful verifications so far (counted when MaxUses is set)
+	CreatedAt      time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" bson:"updated_at"`
 }
 
+// DefaultClockTolerance is the NTP drift tolerance applied by IsValid
+const DefaultClockTolerance = 60 * time.Second
+
 // IsValid checks if the card is valid at the given time
 // Allows ±60 seconds tolerance for NTP drift
 func (c *Card) IsValid(now time.Time) bool {
-	tolerance := 60 * time.Second
+	return c.IsValidWithin(now, DefaultClockTolerance)
+}
+
+// IsValidWithin checks if the card is valid at the given time, widening the window by tolerance on both ends
+func (c *Card) IsValidWithin(now time.Time, tolerance time.Duration) bool {
 	effectiveWithTolerance := c.EffectiveAt.Add(-tolerance)
 	invalidWithTolerance := c.InvalidAt.Add(tolerance)
 
 	return now.After(effectiveWithTolerance) && now.Before(invalidWithTolerance)
 }
 
+// UsesExhausted reports whether a usage-limited card has no verifications left
+func (c *Card) UsesExhausted() bool {
+	return c.MaxUses > 0 && c.UseCount >= c.MaxUses
+}
+
 // HasDevice checks if the card is authorized for the given device SN
 func (c *Card) HasDevice(deviceSN string) bool {
 	if len(c.Devices) == 0 {
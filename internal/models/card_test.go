@@ -249,3 +249,46 @@ func TestCardHasDeviceCaseSensitive(t *testing.T) {
 		assert.False(t, card.HasDevice("sn001"))
 	})
 }
+
+func TestCardIsValidWithin(t *testing.T) {
+	effective := time.Date(2026, 8, 25, 15, 0, 0, 0, time.UTC)
+	card := &Card{EffectiveAt: effective, InvalidAt: effective.Add(time.Hour)}
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		tolerance time.Duration
+		expected  bool
+	}{
+		{"early within tolerance", effective.Add(-10 * time.Second), 30 * time.Second, true},
+		{"early without tolerance", effective.Add(-10 * time.Second), 0, false},
+		{"late within tolerance", effective.Add(time.Hour + 10*time.Second), 30 * time.Second, true},
+		{"late beyond tolerance", effective.Add(time.Hour + 31*time.Second), 30 * time.Second, false},
+		{"invalid_at is exclusive", effective.Add(time.Hour), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, card.IsValidWithin(tt.now, tt.tolerance))
+		})
+	}
+}
+
+func TestCardUsesExhausted(t *testing.T) {
+	tests := []struct {
+		name     string
+		card     Card
+		expected bool
+	}{
+		{"unlimited", Card{UseCount: 100}, false},
+		{"uses left", Card{MaxUses: 3, UseCount: 2}, false},
+		{"one-time card used", Card{MaxUses: 1, UseCount: 1}, true},
+		{"limit lowered below count", Card{MaxUses: 2, UseCount: 5}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.card.UsesExhausted())
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+// Device statuses
+//
+//	pending --> active <--> inactive
+//	   \          |            /
+//	    +--> decommissioned <-+
+//
+// decommissioned is terminal. Devices with any other (legacy) status may be
+// activated, deactivated or decommissioned.
+const (
+	DeviceStatusPending        = "pending"
+	DeviceStatusActive         = "active"
+	DeviceStatusInactive       = "inactive"
+	DeviceStatusDecommissioned = "decommissioned"
+)
+
+// Device directions for anti-passback
+// Devices without a direction and zone are not checked
+const (
+	DeviceDirectionEntry = "entry"
+	DeviceDirectionExit  = "exit"
+)
+
+// GuardsZone reports whether the device takes part in anti-passback
+func (d *Device) GuardsZone() bool {
+	return d.Zone != "" && (d.Direction == DeviceDirectionEntry || d.Direction == DeviceDirectionExit)
+}
+
+// deviceTransitions lists the statuses reachable from each known status
+var deviceTransitions = map[string][]string{
+	DeviceStatusPending:        {DeviceStatusActive, DeviceStatusDecommissioned},
+	DeviceStatusActive:         {DeviceStatusInactive, DeviceStatusDecommissioned},
+	DeviceStatusInactive:       {DeviceStatusActive, DeviceStatusDecommissioned},
+	DeviceStatusDecommissioned: {},
+}
+
+// CanTransition reports whether the device may move from its current status to status
+// Staying in the same status is allowed, except that nothing leaves decommissioned
+func (d *Device) CanTransition(status string) bool {
+	if d.Status == status {
+		return true
+	}
+
+	allowed, known := deviceTransitions[d.Status]
+	if !known {
+		return status == DeviceStatusActive || status == DeviceStatusInactive || status == DeviceStatusDecommissioned
+	}
+	for _, s := range allowed {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
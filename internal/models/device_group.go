@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// DeviceGroup is a named set of devices (a zone such as "floor-3" or "common-areas")
+// Cards listing the group in Groups are authorized for every member device
+type DeviceGroup struct {
+	ID          string    `json:"id" bson:"_id"` // Group name, referenced by Card.Groups
+	DisplayName string    `json:"display_name" bson:"display_name"`
+	Devices     []string  `json:"devices" bson:"devices"` // Array of device SNs
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// HasDevice checks if the group contains the given device SN (or device_id)
+func (g *DeviceGroup) HasDevice(device string) bool {
+	for _, sn := range g.Devices {
+		if sn == device {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceCanTransition(t *testing.T) {
+	tests := []struct {
+		from     string
+		to       string
+		expected bool
+	}{
+		{DeviceStatusPending, DeviceStatusActive, true},
+		{DeviceStatusPending, DeviceStatusInactive, false},
+		{DeviceStatusPending, DeviceStatusDecommissioned, true},
+		{DeviceStatusActive, DeviceStatusActive, true},
+		{DeviceStatusActive, DeviceStatusInactive, true},
+		{DeviceStatusActive, DeviceStatusPending, false},
+		{DeviceStatusInactive, DeviceStatusActive, true},
+		{DeviceStatusInactive, DeviceStatusDecommissioned, true},
+		{DeviceStatusDecommissioned, DeviceStatusDecommissioned, true},
+		{DeviceStatusDecommissioned, DeviceStatusActive, false},
+		{DeviceStatusDecommissioned, DeviceStatusInactive, false},
+		{"", DeviceStatusActive, true},
+		{"maintenance", DeviceStatusInactive, true},
+		{"maintenance", DeviceStatusPending, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			device := &Device{Status: tt.from}
+			assert.Equal(t, tt.expected, device.CanTransition(tt.to))
+		})
+	}
+}
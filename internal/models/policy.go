@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// NamespacePolicy holds per-namespace overrides of the card verification policy
+// Nil fields fall back to the server defaults
+type NamespacePolicy struct {
+	RequireActiveDevice   *bool     `json:"require_active_device,omitempty" bson:"require_active_device,omitempty"`
+	ClockToleranceSeconds *int      `json:"clock_tolerance_seconds,omitempty" bson:"clock_tolerance_seconds,omitempty"`
+	AntiPassback          *string   `json:"anti_passback,omitempty" bson:"anti_passback,omitempty"`
+	UpdatedAt             time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// Anti-passback modes
+const (
+	AntiPassbackOff  = "off"
+	AntiPassbackSoft = "soft" // Violations are logged, access is granted
+	AntiPassbackHard = "hard" // Violations are denied
+)
+
+// IsValidAntiPassbackMode reports whether mode is one of the anti-passback modes
+func IsValidAntiPassbackMode(mode string) bool {
+	return mode == AntiPassbackOff || mode == AntiPassbackSoft || mode == AntiPassbackHard
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Presence is the anti-passback state of a card in a zone: whether its last passage was an entry
+type Presence struct {
+	ID        string    `json:"id" bson:"_id"` // See PresenceID
+	CardID    string    `json:"card_id" bson:"card_id"`
+	Zone      string    `json:"zone" bson:"zone"`
+	Inside    bool      `json:"inside" bson:"inside"`
+	DeviceSN  string    `json:"device_sn" bson:"device_sn"` // Device of the last passage
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// PresenceID returns the ID of the presence of a card in a zone
+func PresenceID(cardID, zone string) string {
+	return cardID + "/" + zone
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Revocation kinds, used as the prefix of Revocation.ID
+const (
+	RevocationByNumber = "number"
+	RevocationByCardID = "card"
+)
+
+// Revocation blocks a card number or card ID regardless of the card's validity window
+// (a lost or stolen card). Exactly one of CardNumber and CardID is set
+type Revocation struct {
+	ID         string    `json:"id" bson:"_id"` // "<kind>:<value>", see RevocationID
+	CardNumber string    `json:"card_number,omitempty" bson:"card_number,omitempty"`
+	CardID     string    `json:"card_id,omitempty" bson:"card_id,omitempty"`
+	Reason     string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	RevokedAt  time.Time `json:"revoked_at" bson:"revoked_at"`
+}
+
+// RevocationID returns the ID of the revocation of a card number (RevocationByNumber) or card ID (RevocationByCardID)
+func RevocationID(kind, value string) string {
+	return kind + ":" + value
+}
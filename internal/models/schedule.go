@@ -0,0 +1,140 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule restricts a card to recurring time windows in a timezone
+// A card with a schedule is only valid during one of its windows (and within EffectiveAt/InvalidAt)
+type Schedule struct {
+	Timezone string           `json:"timezone,omitempty" bson:"timezone,omitempty"` // IANA name, e.g. "Asia/Tokyo" (default UTC)
+	Windows  []ScheduleWindow `json:"windows" bson:"windows"`
+}
+
+// ScheduleWindow is a daily time range on a set of weekdays
+// Start and End are "HH:MM" local times; End is exclusive and may be "24:00"
+// An End before Start spans midnight, and Days refers to the day the window starts
+type ScheduleWindow struct {
+	Days  []string `json:"days,omitempty" bson:"days,omitempty"` // "mon" ... "sun"; empty means every day
+	Start string   `json:"start" bson:"start"`
+	End   string   `json:"end" bson:"end"`
+}
+
+// weekdays maps day names to time.Weekday
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Validate checks the timezone, day names and times of the schedule
+func (s *Schedule) Validate() error {
+	if _, err := s.location(); err != nil {
+		return err
+	}
+	if len(s.Windows) == 0 {
+		return errors.New("schedule requires at least one window")
+	}
+
+	for i, w := range s.Windows {
+		for _, day := range w.Days {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("window %d: unknown day %q", i, day)
+			}
+		}
+		start, err := parseClock(w.Start)
+		if err != nil || start == 24*60 {
+			return fmt.Errorf("window %d: start must be between 00:00 and 23:59", i)
+		}
+		end, err := parseClock(w.End)
+		if err != nil || end == 0 {
+			return fmt.Errorf("window %d: end must be between 00:01 and 24:00", i)
+		}
+		if start == end {
+			return fmt.Errorf("window %d: start and end must differ", i)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether t falls into one of the windows of the schedule
+// Returns an error if the schedule is invalid
+func (s *Schedule) Allows(t time.Time) (bool, error) {
+	loc, err := s.location()
+	if err != nil {
+		return false, err
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	today := local.Weekday()
+	yesterday := (today + 6) % 7
+
+	for _, w := range s.Windows {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return false, err
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return false, err
+		}
+
+		if start < end {
+			if w.onDay(today) && minute >= start && minute < end {
+				return true, nil
+			}
+			continue
+		}
+
+		// Spans midnight: the evening part belongs to today, the morning part to the window of yesterday
+		if (w.onDay(today) && minute >= start) || (w.onDay(yesterday) && minute < end) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// location resolves the schedule timezone
+func (s *Schedule) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+	return loc, nil
+}
+
+// onDay reports whether the window starts on day
+func (w *ScheduleWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock converts "HH:MM" (00:00 to 24:00) to minutes since midnight
+func parseClock(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
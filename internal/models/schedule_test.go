@@ -0,0 +1,84 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		wantErr  bool
+	}{
+		{"weekday hours", Schedule{Timezone: "Asia/Tokyo", Windows: []ScheduleWindow{{Days: []string{"mon", "FRI"}, Start: "08:00", End: "18:00"}}}, false},
+		{"all day", Schedule{Windows: []ScheduleWindow{{Start: "00:00", End: "24:00"}}}, false},
+		{"overnight", Schedule{Windows: []ScheduleWindow{{Start: "22:00", End: "06:00"}}}, false},
+		{"no windows", Schedule{}, true},
+		{"unknown timezone", Schedule{Timezone: "Mars/Olympus", Windows: []ScheduleWindow{{Start: "08:00", End: "18:00"}}}, true},
+		{"unknown day", Schedule{Windows: []ScheduleWindow{{Days: []string{"monday"}, Start: "08:00", End: "18:00"}}}, true},
+		{"invalid start", Schedule{Windows: []ScheduleWindow{{Start: "8am", End: "18:00"}}}, true},
+		{"start at 24:00", Schedule{Windows: []ScheduleWindow{{Start: "24:00", End: "06:00"}}}, true},
+		{"end at 00:00", Schedule{Windows: []ScheduleWindow{{Start: "22:00", End: "00:00"}}}, true},
+		{"invalid end", Schedule{Windows: []ScheduleWindow{{Start: "08:00", End: "25:00"}}}, true},
+		{"empty window", Schedule{Windows: []ScheduleWindow{{Start: "08:00", End: "08:00"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schedule.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestScheduleAllows(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// Mon-Fri 08:00-18:00 Tokyo time, plus Friday night 22:00-02:00
+	schedule := Schedule{
+		Timezone: "Asia/Tokyo",
+		Windows: []ScheduleWindow{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "08:00", End: "18:00"},
+			{Days: []string{"fri"}, Start: "22:00", End: "02:00"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		expected bool
+	}{
+		{"monday morning", time.Date(2026, 8, 24, 8, 0, 0, 0, tokyo), true},
+		{"monday before opening", time.Date(2026, 8, 24, 7, 59, 59, 0, tokyo), false},
+		{"monday end is exclusive", time.Date(2026, 8, 24, 18, 0, 0, 0, tokyo), false},
+		{"saturday noon", time.Date(2026, 8, 29, 12, 0, 0, 0, tokyo), false},
+		{"same instant in UTC", time.Date(2026, 8, 24, 0, 30, 0, 0, time.UTC), true}, // 09:30 in Tokyo
+		{"friday night", time.Date(2026, 8, 28, 23, 0, 0, 0, tokyo), true},
+		{"after midnight belongs to friday", time.Date(2026, 8, 29, 1, 59, 0, 0, tokyo), true},
+		{"after overnight window", time.Date(2026, 8, 29, 2, 0, 0, 0, tokyo), false},
+		{"thursday night", time.Date(2026, 8, 27, 23, 0, 0, 0, tokyo), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := schedule.Allows(tt.at)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, allowed)
+		})
+	}
+
+	// Without a timezone the schedule is evaluated in UTC
+	utc := Schedule{Windows: []ScheduleWindow{{Start: "00:00", End: "01:00"}}}
+	allowed, err := utc.Allows(time.Date(2026, 8, 24, 9, 30, 0, 0, tokyo)) // 00:30 UTC
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Kinds of records carried by a Change
+const (
+	ChangeDevice      = "device"
+	ChangeCard        = "card"
+	ChangeDeviceGroup = "device_group"
+	ChangeRevocation  = "revocation"
+	ChangePolicy      = "policy"
+)
+
+// Change is an entry of a namespace's change feed: the new state of a record, or its deletion
+// Revisions are allocated without gaps in write order, so a consumer resumes after the last revision it applied
+type Change struct {
+	Revision  int64           `json:"revision" bson:"_id"`
+	Kind      string          `json:"kind" bson:"kind"` // ChangeDevice, ChangeCard, ...
+	ID        string          `json:"id" bson:"id"`     // Record ID ("" for ChangePolicy)
+	Deleted   bool            `json:"deleted,omitempty" bson:"deleted,omitempty"`
+	Record    json.RawMessage `json:"record,omitempty" bson:"record,omitempty"` // JSON of the record, unless Deleted
+	Timestamp time.Time       `json:"timestamp" bson:"timestamp"`
+}
+
+// SyncState is the progress of an edge instance syncing a namespace with its upstream
+type SyncState struct {
+	// PulledRevision is the upstream revision of the last applied change
+	PulledRevision int64 `json:"pulled_revision" bson:"pulled_revision"`
+	// PushedAccessLog is the ID of the last access log entry accepted upstream
+	PushedAccessLog string    `json:"pushed_access_log,omitempty" bson:"pushed_access_log,omitempty"`
+	LastSync        time.Time `json:"last_sync" bson:"last_sync"`
+	LastAttempt     time.Time `json:"last_attempt" bson:"last_attempt"`
+	LastError       string    `json:"last_error,omitempty" bson:"last_error,omitempty"`
+}
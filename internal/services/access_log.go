@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"commander/internal/models"
+)
+
+// MaxAccessLogListLimit is the largest page size accepted by ListAccessLogs
+const MaxAccessLogListLimit = 1000
+
+// EnableAccessLog makes VerifyCard record every attempt in the access log
+// Entries expire after retention (retention <= 0 keeps them forever)
+func (s *CardService) EnableAccessLog(retention time.Duration) {
+	s.accessLog = true
+	s.accessLogRetention = retention
+}
+
+// ListAccessLogs returns a page of access log entries in namespace matching query, oldest first
+func (s *CardService) ListAccessLogs(ctx context.Context, namespace string, query AccessLogQuery) ([]*models.AccessLog, string, error) {
+	if query.Limit > MaxAccessLogListLimit {
+		query.Limit = MaxAccessLogListLimit
+	}
+	return s.repo.ListAccessLogs(ctx, namespace, query)
+}
+
+// recordAccess appends the outcome of a verification attempt to the access log
+// Failures are logged and never change the verification result
+func (s *CardService) recordAccess(ctx context.Context, namespace, deviceSN, cardNumber, protocol string, card *models.Card, verifyErr error) {
+	now := s.clock.Now().UTC()
+	id, err := newAccessLogID(now)
+	if err != nil {
+		log.Printf("[AccessLog] Failed to generate id: namespace=%s, error=%v", namespace, err)
+		return
+	}
+
+	entry := &models.AccessLog{
+		ID:         id,
+		DeviceSN:   deviceSN,
+		CardNumber: cardNumber,
+		Outcome:    models.AccessGranted,
+		Protocol:   protocol,
+		Timestamp:  now,
+	}
+	if card != nil {
+		entry.CardID = card.ID
+	}
+	if verifyErr != nil {
+		entry.Outcome = models.AccessDenied
+		entry.Error = verifyErr.Error()
+	}
+
+	// The attempt is recorded even if the client disconnected meanwhile
+	if err := s.repo.AppendAccessLog(context.WithoutCancel(ctx), namespace, entry, s.accessLogRetention); err != nil {
+		log.Printf("[AccessLog] Failed to record attempt: namespace=%s, device_sn=%s, card_number=%s, error=%v",
+			namespace, deviceSN, cardNumber, err)
+	}
+}
+
+// newAccessLogID returns an ID that sorts by timestamp: zero-padded Unix nanoseconds and a random suffix
+func newAccessLogID(timestamp time.Time) (string, error) {
+	var suffix [4]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", accessLogKeyPrefix(timestamp), hex.EncodeToString(suffix[:])), nil
+}
+
+// accessLogKeyPrefix returns the timestamp part of access log IDs
+// Every ID at or after timestamp sorts after it, and every ID before timestamp sorts before it
+func accessLogKeyPrefix(timestamp time.Time) string {
+	return fmt.Sprintf("%020d", timestamp.UnixNano())
+}
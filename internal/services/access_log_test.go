@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAccessLogID(t *testing.T) {
+	earlier := time.Date(2026, 8, 25, 15, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Nanosecond)
+
+	a, err := newAccessLogID(earlier)
+	require.NoError(t, err)
+	b, err := newAccessLogID(later)
+	require.NoError(t, err)
+	assert.Less(t, a, b)
+
+	// The key prefix of a timestamp sorts between earlier and later IDs
+	assert.Less(t, a, accessLogKeyPrefix(later))
+	assert.GreaterOrEqual(t, b, accessLogKeyPrefix(later))
+}
+
+func TestCardService_AccessLog(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	card, err := service.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-302"))
+	require.NoError(t, err)
+
+	// Disabled by default
+	require.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-302", "GUEST-1", models.ProtocolStandard))
+	entries, _, err := service.ListAccessLogs(ctx, "hotel_a", AccessLogQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	service.EnableAccessLog(time.Hour)
+	require.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-302", "GUEST-1", models.ProtocolStandard))
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolVguang), ErrCardNotAuthorized)
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-302", "UNKNOWN", models.ProtocolStandard), ErrCardNotFound)
+
+	entries, cursor, err := service.ListAccessLogs(ctx, "hotel_a", AccessLogQuery{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Empty(t, cursor)
+
+	assert.Equal(t, models.AccessGranted, entries[0].Outcome)
+	assert.Equal(t, card.ID, entries[0].CardID)
+	assert.Equal(t, models.ProtocolStandard, entries[0].Protocol)
+	assert.Empty(t, entries[0].Error)
+
+	assert.Equal(t, models.AccessDenied, entries[1].Outcome)
+	assert.Equal(t, card.ID, entries[1].CardID)
+	assert.Equal(t, "SN-001", entries[1].DeviceSN)
+	assert.Equal(t, models.ProtocolVguang, entries[1].Protocol)
+	assert.Equal(t, ErrCardNotAuthorized.Error(), entries[1].Error)
+
+	assert.Empty(t, entries[2].CardID)
+	assert.Equal(t, "UNKNOWN", entries[2].CardNumber)
+
+	tests := []struct {
+		name     string
+		query    AccessLogQuery
+		expected []string // Card numbers
+	}{
+		{"device", AccessLogQuery{DeviceSN: "SN-302"}, []string{"GUEST-1", "UNKNOWN"}},
+		{"card id", AccessLogQuery{Card: card.ID}, []string{"GUEST-1", "GUEST-1"}},
+		{"card number", AccessLogQuery{Card: "UNKNOWN"}, []string{"UNKNOWN"}},
+		{"outcome", AccessLogQuery{Outcome: models.AccessDenied}, []string{"GUEST-1", "UNKNOWN"}},
+		{"from", AccessLogQuery{From: entries[1].Timestamp}, []string{"GUEST-1", "UNKNOWN"}},
+		{"to", AccessLogQuery{To: entries[1].Timestamp}, []string{"GUEST-1"}},
+		{"future", AccessLogQuery{From: time.Now().Add(time.Hour)}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, _, err := service.ListAccessLogs(ctx, "hotel_a", tt.query)
+			require.NoError(t, err)
+			numbers := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				numbers = append(numbers, entry.CardNumber)
+			}
+			assert.Equal(t, tt.expected, numbers)
+		})
+	}
+
+	// Paging
+	page, cursor, err := service.ListAccessLogs(ctx, "hotel_a", AccessLogQuery{Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, page, 2)
+	assert.Equal(t, entries[1].ID, cursor)
+	page, cursor, err = service.ListAccessLogs(ctx, "hotel_a", AccessLogQuery{Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+	assert.Len(t, page, 1)
+	assert.Empty(t, cursor)
+}
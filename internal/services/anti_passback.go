@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"commander/internal/models"
+)
+
+// ListPresence returns the anti-passback state of a card in every zone it passed through
+func (s *CardService) ListPresence(ctx context.Context, namespace, cardID string) ([]*models.Presence, error) {
+	if _, err := s.repo.GetCard(ctx, namespace, cardID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListPresence(ctx, namespace, cardID)
+}
+
+// ResetPresence clears the anti-passback state of a card in zone ("" = every zone), so its next
+// passage is accepted in either direction. Returns the number of zones reset
+func (s *CardService) ResetPresence(ctx context.Context, namespace, cardID, zone string) (int, error) {
+	presence, err := s.ListPresence(ctx, namespace, cardID)
+	if err != nil {
+		return 0, err
+	}
+
+	reset := 0
+	for _, p := range presence {
+		if zone != "" && p.Zone != zone {
+			continue
+		}
+		if err := s.repo.DeletePresence(ctx, namespace, cardID, p.Zone); err != nil {
+			return reset, err
+		}
+		reset++
+	}
+
+	log.Printf("[AntiPassback] Presence reset: namespace=%s, card_id=%s, zone=%q, zones=%d", namespace, cardID, zone, reset)
+	return reset, nil
+}
+
+// checkPassback enforces anti-passback for a passage of card through device
+// A card may not enter a zone it is inside of, nor exit a zone it is outside of; a card without
+// state in the zone may pass either way. In soft mode violations are only logged
+// When the state cannot be read (e.g. the edge cache is offline), soft mode logs and allows the
+// passage while hard mode fails closed and returns the lookup error
+func (s *CardService) checkPassback(ctx context.Context, namespace, mode string, card *models.Card, device *models.Device) error {
+	if !passbackEnforced(mode, device) {
+		return nil
+	}
+
+	presence, err := s.repo.GetPresence(ctx, namespace, card.ID, device.Zone)
+	if err != nil {
+		log.Printf("[CardVerification] Presence lookup failed: namespace=%s, card_id=%s, zone=%s, mode=%s, error=%v",
+			namespace, card.ID, device.Zone, mode, err)
+		if mode == models.AntiPassbackHard {
+			return err
+		}
+		return nil
+	}
+	if presence == nil || presence.Inside != (device.Direction == models.DeviceDirectionEntry) {
+		return nil
+	}
+
+	log.Printf("[CardVerification] Anti-passback violation: namespace=%s, card_number=%s, device_sn=%s, zone=%s, direction=%s, last_device_sn=%s, mode=%s",
+		namespace, card.Number, device.SN, device.Zone, device.Direction, presence.DeviceSN, mode)
+	if mode == models.AntiPassbackHard {
+		return ErrAntiPassback
+	}
+	return nil
+}
+
+// recordPassage stores the zone state after a granted passage through device
+// Failures are logged and never change the verification result
+func (s *CardService) recordPassage(ctx context.Context, namespace, mode string, card *models.Card, device *models.Device) {
+	if !passbackEnforced(mode, device) {
+		return
+	}
+
+	presence := &models.Presence{
+		CardID:    card.ID,
+		Zone:      device.Zone,
+		Inside:    device.Direction == models.DeviceDirectionEntry,
+		DeviceSN:  device.SN,
+		UpdatedAt: s.clock.Now().UTC(),
+	}
+	// The passage happened even if the client disconnected meanwhile
+	if err := s.repo.SavePresence(context.WithoutCancel(ctx), namespace, presence); err != nil {
+		log.Printf("[AntiPassback] Failed to record passage: namespace=%s, card_id=%s, zone=%s, error=%v",
+			namespace, card.ID, device.Zone, err)
+	}
+}
+
+// passbackEnforced reports whether mode checks passages through device
+func passbackEnforced(mode string, device *models.Device) bool {
+	return (mode == models.AntiPassbackSoft || mode == models.AntiPassbackHard) && device.GuardsZone()
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPassbackTestService returns a service with a parking entry (SN-IN) and exit (SN-OUT) reader and card GUEST-1
+func newPassbackTestService(t *testing.T, mode string) (*CardService, *models.Card) {
+	t.Helper()
+	repo, _ := newTestKVRepository(t, true)
+	ctx := context.Background()
+	require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-1", SN: "SN-IN", Direction: models.DeviceDirectionEntry, Zone: "parking"}))
+	require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-2", SN: "SN-OUT", Direction: models.DeviceDirectionExit, Zone: "parking"}))
+	require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-3", SN: "SN-LOBBY"}))
+
+	service := NewCardService(repo, Policy{AntiPassback: mode})
+	card, err := service.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-IN", "SN-OUT", "SN-LOBBY"))
+	require.NoError(t, err)
+	return service, card
+}
+
+func TestCardServiceVerifyCard_AntiPassback(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		passages []string // Device SNs in order
+		expected []error
+	}{
+		{"hard: enter and exit alternate", models.AntiPassbackHard, []string{"SN-IN", "SN-OUT", "SN-IN"}, []error{nil, nil, nil}},
+		{"hard: double entry denied", models.AntiPassbackHard, []string{"SN-IN", "SN-IN", "SN-OUT"}, []error{nil, ErrAntiPassback, nil}},
+		{"hard: double exit denied", models.AntiPassbackHard, []string{"SN-OUT", "SN-OUT"}, []error{nil, ErrAntiPassback}},
+		{"hard: other devices not checked", models.AntiPassbackHard, []string{"SN-IN", "SN-LOBBY", "SN-LOBBY"}, []error{nil, nil, nil}},
+		{"soft: violations allowed", models.AntiPassbackSoft, []string{"SN-IN", "SN-IN"}, []error{nil, nil}},
+		{"off: not checked", models.AntiPassbackOff, []string{"SN-IN", "SN-IN"}, []error{nil, nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := newPassbackTestService(t, tt.mode)
+			ctx := context.Background()
+
+			for i, sn := range tt.passages {
+				err := service.VerifyCard(ctx, "hotel_a", sn, "GUEST-1", models.ProtocolStandard)
+				if tt.expected[i] == nil {
+					assert.NoError(t, err, "passage %d through %s", i, sn)
+				} else {
+					assert.ErrorIs(t, err, tt.expected[i], "passage %d through %s", i, sn)
+				}
+			}
+		})
+	}
+}
+
+func TestCardServiceVerifyCard_AntiPassbackOffline(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		expected error
+	}{
+		{"soft: allowed while presence is unavailable", models.AntiPassbackSoft, nil},
+		{"hard: denied while presence is unavailable", models.AntiPassbackHard, ErrPrimaryUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, edge, primary := newTestEdgeCache(t)
+			ctx := context.Background()
+			_, err := admin.RegisterDevice(ctx, "hotel_a", &models.Device{ID: "device-2", DeviceID: "parking-in", SN: "SN-IN", Direction: models.DeviceDirectionEntry, Zone: "parking"})
+			require.NoError(t, err)
+			_, err = admin.CreateCard(ctx, "hotel_a", testCard("GUEST-2", "SN-IN"))
+			require.NoError(t, err)
+			require.NoError(t, edge.SyncNamespace(ctx, "hotel_a"))
+
+			service := NewCardService(edge, Policy{AntiPassback: tt.mode})
+			primary.down.Store(true)
+
+			err = service.VerifyCard(ctx, "hotel_a", "SN-IN", "GUEST-2", models.ProtocolStandard)
+			assert.False(t, edge.Online())
+			if tt.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCardService_ResetPresence(t *testing.T) {
+	service, card := newPassbackTestService(t, models.AntiPassbackHard)
+	ctx := context.Background()
+
+	require.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-IN", "GUEST-1", models.ProtocolStandard))
+	presence, err := service.ListPresence(ctx, "hotel_a", card.ID)
+	require.NoError(t, err)
+	require.Len(t, presence, 1)
+	assert.True(t, presence[0].Inside)
+	assert.Equal(t, "SN-IN", presence[0].DeviceSN)
+
+	// Resetting another zone keeps the state
+	reset, err := service.ResetPresence(ctx, "hotel_a", card.ID, "gym")
+	require.NoError(t, err)
+	assert.Zero(t, reset)
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-IN", "GUEST-1", models.ProtocolStandard), ErrAntiPassback)
+
+	reset, err = service.ResetPresence(ctx, "hotel_a", card.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, reset)
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-IN", "GUEST-1", models.ProtocolStandard))
+
+	_, err = service.ResetPresence(ctx, "hotel_a", "missing", "")
+	assert.ErrorIs(t, err, ErrCardNotFound)
+}
+
+func TestCardService_PassbackSettingsValidation(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		device *models.Device
+	}{
+		{"direction without zone", &models.Device{SN: "SN-1", Direction: models.DeviceDirectionEntry}},
+		{"zone without direction", &models.Device{SN: "SN-2", Zone: "parking"}},
+		{"unknown direction", &models.Device{SN: "SN-3", Direction: "sideways", Zone: "parking"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.RegisterDevice(ctx, "hotel_a", tt.device)
+			assert.ErrorIs(t, err, ErrInvalidDevice)
+		})
+	}
+
+	mode := "strict"
+	_, err := service.SetPolicy(ctx, "hotel_a", &models.NamespacePolicy{AntiPassback: &mode})
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+
+	mode = models.AntiPassbackSoft
+	policy, err := service.SetPolicy(ctx, "hotel_a", &models.NamespacePolicy{AntiPassback: &mode})
+	require.NoError(t, err)
+	assert.Equal(t, models.AntiPassbackSoft, policy.AntiPassback)
+}
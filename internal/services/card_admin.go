@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"commander/internal/models"
+)
+
+// Card management errors.
+var (
+	ErrInvalidCard      = errors.New("invalid card")
+	ErrCardExists       = errors.New("card already exists")
+	ErrCardNumberExists = errors.New("card number already exists")
+	ErrUnknownDevice    = errors.New("unknown device")
+)
+
+// MaxCardListLimit is the largest page size accepted by ListCards
+const MaxCardListLimit = 1000
+
+// ListCards returns a page of cards in namespace matching query
+func (s *CardService) ListCards(ctx context.Context, namespace string, query CardQuery) ([]*models.Card, string, error) {
+	if query.Limit > MaxCardListLimit {
+		query.Limit = MaxCardListLimit
+	}
+	return s.repo.ListCards(ctx, namespace, query)
+}
+
+// GetCard retrieves a card by ID
+func (s *CardService) GetCard(ctx context.Context, namespace, id string) (*models.Card, error) {
+	return s.repo.GetCard(ctx, namespace, id)
+}
+
+// CreateCard validates and stores a new card
+// An ID is generated when card.ID is empty; CreatedAt and UpdatedAt are set to the current time
+func (s *CardService) CreateCard(ctx context.Context, namespace string, card *models.Card) (*models.Card, error) {
+	if card.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return nil, err
+		}
+		card.ID = id
+	} else {
+		_, err := s.repo.GetCard(ctx, namespace, card.ID)
+		if err == nil {
+			return nil, ErrCardExists
+		}
+		if !errors.Is(err, ErrCardNotFound) {
+			return nil, err
+		}
+	}
+
+	if err := s.validateCard(ctx, namespace, card); err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now().UTC()
+	card.RevokedAt = nil
+	card.UseCount = 0
+	card.CreatedAt = now
+	card.UpdatedAt = now
+	if err := s.repo.SaveCard(ctx, namespace, card); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[CardAdmin] Card created: namespace=%s, card_id=%s, card_number=%s", namespace, card.ID, card.Number)
+	return card, nil
+}
+
+// UpdateCard replaces the editable fields of an existing card
+// ID, CreatedAt, RevokedAt and UseCount are kept from the stored card (raise MaxUses to grant more uses)
+func (s *CardService) UpdateCard(ctx context.Context, namespace, id string, card *models.Card) (*models.Card, error) {
+	existing, err := s.repo.GetCard(ctx, namespace, id)
+	if err != nil {
+		return nil, err
+	}
+
+	card.ID = existing.ID
+	card.CreatedAt = existing.CreatedAt
+	card.RevokedAt = existing.RevokedAt
+	card.UseCount = existing.UseCount
+	if err := s.validateCard(ctx, namespace, card); err != nil {
+		return nil, err
+	}
+
+	card.UpdatedAt = s.clock.Now().UTC()
+	if err := s.repo.SaveCard(ctx, namespace, card); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[CardAdmin] Card updated: namespace=%s, card_id=%s, card_number=%s", namespace, card.ID, card.Number)
+	return card, nil
+}
+
+// DeleteCard removes a card by ID
+func (s *CardService) DeleteCard(ctx context.Context, namespace, id string) error {
+	if err := s.repo.DeleteCard(ctx, namespace, id); err != nil {
+		return err
+	}
+
+	log.Printf("[CardAdmin] Card deleted: namespace=%s, card_id=%s", namespace, id)
+	return nil
+}
+
+// RevokeCard marks a card as revoked and ends its validity immediately
+// Revoking an already revoked card returns it unchanged
+func (s *CardService) RevokeCard(ctx context.Context, namespace, id string) (*models.Card, error) {
+	card, err := s.repo.GetCard(ctx, namespace, id)
+	if err != nil {
+		return nil, err
+	}
+	if card.RevokedAt != nil {
+		return card, nil
+	}
+
+	now := s.clock.Now().UTC()
+	card.RevokedAt = &now
+	if card.InvalidAt.After(now) {
+		card.InvalidAt = now
+	}
+	card.UpdatedAt = now
+	if err := s.repo.SaveCard(ctx, namespace, card); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[CardAdmin] Card revoked: namespace=%s, card_id=%s, card_number=%s", namespace, card.ID, card.Number)
+	return card, nil
+}
+
+// validateCard checks required fields, the validity window and that every listed device SN and group exists
+func (s *CardService) validateCard(ctx context.Context, namespace string, card *models.Card) error {
+	card.Number = strings.TrimSpace(card.Number)
+	if card.Number == "" {
+		return fmt.Errorf("%w: number is required", ErrInvalidCard)
+	}
+	if card.EffectiveAt.IsZero() || card.InvalidAt.IsZero() {
+		return fmt.Errorf("%w: effective_at and invalid_at are required", ErrInvalidCard)
+	}
+	if !card.EffectiveAt.Before(card.InvalidAt) {
+		return fmt.Errorf("%w: effective_at must be before invalid_at", ErrInvalidCard)
+	}
+	if card.MaxUses < 0 {
+		return fmt.Errorf("%w: max_uses must not be negative", ErrInvalidCard)
+	}
+	if card.Schedule != nil {
+		if err := card.Schedule.Validate(); err != nil {
+			return fmt.Errorf("%w: schedule: %v", ErrInvalidCard, err)
+		}
+	}
+
+	var unknown []string
+	for _, sn := range card.Devices {
+		_, err := s.repo.GetDeviceBySN(ctx, namespace, sn)
+		if errors.Is(err, ErrDeviceNotFound) {
+			unknown = append(unknown, sn)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownDevice, strings.Join(unknown, ", "))
+	}
+
+	for _, id := range card.Groups {
+		_, err := s.repo.GetDeviceGroup(ctx, namespace, id)
+		if errors.Is(err, ErrDeviceGroupNotFound) {
+			unknown = append(unknown, id)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownDeviceGroup, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// newID returns a random (version 4) UUID
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCardService(t *testing.T) *CardService {
+	t.Helper()
+	repo, _ := newTestKVRepository(t, true)
+	ctx := context.Background()
+	require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-1", DeviceID: "lobby", SN: "SN-001"}))
+	require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-2", DeviceID: "room-302", SN: "SN-302"}))
+	return NewCardService(repo, Policy{})
+}
+
+func testCard(number string, devices ...string) *models.Card {
+	now := time.Now().UTC()
+	return &models.Card{
+		Number:      number,
+		Devices:     devices,
+		EffectiveAt: now.Add(-time.Hour),
+		InvalidAt:   now.Add(24 * time.Hour),
+	}
+}
+
+func TestCardService_CreateCard(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	card, err := service.CreateCard(ctx, "hotel_a", testCard(" GUEST-1 ", "SN-001", "SN-302"))
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), card.ID)
+	assert.Equal(t, "GUEST-1", card.Number)
+	assert.False(t, card.CreatedAt.IsZero())
+
+	stored, err := service.GetCard(ctx, "hotel_a", card.ID)
+	require.NoError(t, err)
+	assert.Equal(t, card.Number, stored.Number)
+
+	// The new card verifies immediately
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-302", "GUEST-1", models.ProtocolStandard))
+
+	tests := []struct {
+		name     string
+		card     *models.Card
+		expected error
+	}{
+		{"duplicate number", testCard("GUEST-1"), ErrCardNumberExists},
+		{"duplicate id", &models.Card{ID: card.ID, Number: "OTHER", EffectiveAt: card.EffectiveAt, InvalidAt: card.InvalidAt}, ErrCardExists},
+		{"missing number", testCard("  "), ErrInvalidCard},
+		{"missing window", &models.Card{Number: "NO-WINDOW"}, ErrInvalidCard},
+		{"window reversed", &models.Card{Number: "REVERSED", EffectiveAt: card.InvalidAt, InvalidAt: card.EffectiveAt}, ErrInvalidCard},
+		{"empty window", &models.Card{Number: "EMPTY", EffectiveAt: card.EffectiveAt, InvalidAt: card.EffectiveAt}, ErrInvalidCard},
+		{"unknown device", testCard("GUEST-2", "SN-001", "SN-404"), ErrUnknownDevice},
+		{"invalid schedule", &models.Card{Number: "STAFF-1", EffectiveAt: card.EffectiveAt, InvalidAt: card.InvalidAt, Schedule: &models.Schedule{}}, ErrInvalidCard},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateCard(ctx, "hotel_a", tt.card)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+
+	// Numbers are unique per namespace only
+	require.NoError(t, service.repo.(*KVRepository).SaveDevice(ctx, "hotel_b", &models.Device{ID: "device-1", SN: "SN-001"}))
+	_, err = service.CreateCard(ctx, "hotel_b", testCard("GUEST-1", "SN-001"))
+	assert.NoError(t, err)
+}
+
+func TestCardService_UpdateCard(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	created, err := service.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-001"))
+	require.NoError(t, err)
+	_, err = service.CreateCard(ctx, "hotel_a", testCard("GUEST-2", "SN-001"))
+	require.NoError(t, err)
+
+	update := testCard("GUEST-1B", "SN-302")
+	update.ID = "ignored"
+	updated, err := service.UpdateCard(ctx, "hotel_a", created.ID, update)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, updated.ID)
+	assert.True(t, created.CreatedAt.Equal(updated.CreatedAt))
+
+	_, err = service.repo.GetCardByNumber(ctx, "hotel_a", "GUEST-1")
+	assert.ErrorIs(t, err, ErrCardNotFound)
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-302", "GUEST-1B", models.ProtocolStandard))
+
+	_, err = service.UpdateCard(ctx, "hotel_a", created.ID, testCard("GUEST-2"))
+	assert.ErrorIs(t, err, ErrCardNumberExists)
+	_, err = service.UpdateCard(ctx, "hotel_a", "missing", testCard("GUEST-3"))
+	assert.ErrorIs(t, err, ErrCardNotFound)
+}
+
+func TestCardService_DeleteCard(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	card, err := service.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-001"))
+	require.NoError(t, err)
+
+	require.NoError(t, service.DeleteCard(ctx, "hotel_a", card.ID))
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard), ErrCardNotFound)
+	assert.ErrorIs(t, service.DeleteCard(ctx, "hotel_a", card.ID), ErrCardNotFound)
+
+	// The number can be reused after deletion
+	_, err = service.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-001"))
+	assert.NoError(t, err)
+}
+
+func TestCardService_RevokeCard(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	card, err := service.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-001"))
+	require.NoError(t, err)
+
+	revoked, err := service.RevokeCard(ctx, "hotel_a", card.ID)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+	assert.False(t, revoked.InvalidAt.After(*revoked.RevokedAt))
+
+	// Revoking again keeps the original timestamp
+	again, err := service.RevokeCard(ctx, "hotel_a", card.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked.RevokedAt.Equal(*again.RevokedAt))
+
+	_, err = service.RevokeCard(ctx, "hotel_a", "missing")
+	assert.ErrorIs(t, err, ErrCardNotFound)
+}
+
+func TestCardService_ListCards(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	for _, card := range []*models.Card{
+		{ID: "a", Number: "GUEST-1", Devices: []string{"SN-001"}, EffectiveAt: now.Add(-time.Hour), InvalidAt: now.Add(time.Hour)},
+		{ID: "b", Number: "GUEST-2", Devices: []string{"SN-302"}, EffectiveAt: now.Add(-time.Hour), InvalidAt: now.Add(time.Hour)},
+		{ID: "c", Number: "STAFF-1", Devices: []string{"SN-001", "SN-302"}, EffectiveAt: now.Add(time.Hour), InvalidAt: now.Add(2 * time.Hour)},
+		{ID: "d", Number: "GUEST-3", Devices: []string{"SN-001"}, EffectiveAt: now.Add(-time.Hour), InvalidAt: now.Add(time.Hour)},
+	} {
+		_, err := service.CreateCard(ctx, "hotel_a", card)
+		require.NoError(t, err)
+	}
+	_, err := service.RevokeCard(ctx, "hotel_a", "d")
+	require.NoError(t, err)
+
+	revoked := true
+	notRevoked := false
+	tests := []struct {
+		name     string
+		query    CardQuery
+		expected []string
+		cursor   string
+	}{
+		{"all", CardQuery{}, []string{"a", "b", "c", "d"}, ""},
+		{"by device", CardQuery{Device: "SN-302"}, []string{"b", "c"}, ""},
+		{"by number prefix", CardQuery{Number: "GUEST-"}, []string{"a", "b", "d"}, ""},
+		{"valid in half an hour", CardQuery{ValidAt: now.Add(30 * time.Minute)}, []string{"a", "b"}, ""},
+		{"revoked", CardQuery{Revoked: &revoked}, []string{"d"}, ""},
+		{"not revoked", CardQuery{Revoked: &notRevoked}, []string{"a", "b", "c"}, ""},
+		{"first page", CardQuery{Limit: 2}, []string{"a", "b"}, "b"},
+		{"second page", CardQuery{Limit: 2, Cursor: "b"}, []string{"c", "d"}, ""},
+		{"filtered page", CardQuery{Device: "SN-001", Limit: 1}, []string{"a"}, "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards, cursor, err := service.ListCards(ctx, "hotel_a", tt.query)
+			require.NoError(t, err)
+
+			ids := make([]string, len(cards))
+			for i, card := range cards {
+				ids[i] = card.ID
+			}
+			assert.Equal(t, tt.expected, ids)
+			assert.Equal(t, tt.cursor, cursor)
+		})
+	}
+}
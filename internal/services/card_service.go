@@ -3,124 +3,174 @@ package services
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log"
 	"time"
 
 	"commander/internal/models"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Card verification errors.
 var (
-	ErrDeviceNotFound    = errors.New("device not found")
-	ErrDeviceNotActive   = errors.New("device not active")
-	ErrCardNotFound      = errors.New("card not found")
-	ErrCardNotAuthorized = errors.New("card not authorized for this device")
-	ErrCardExpired       = errors.New("card has expired")
-	ErrCardNotYetValid   = errors.New("card is not yet valid")
+	ErrDeviceNotFound     = errors.New("device not found")
+	ErrDeviceNotActive    = errors.New("device not active")
+	ErrCardNotFound       = errors.New("card not found")
+	ErrCardNotAuthorized  = errors.New("card not authorized for this device")
+	ErrCardExpired        = errors.New("card has expired")
+	ErrCardNotYetValid    = errors.New("card is not yet valid")
+	ErrOutsideSchedule    = errors.New("card is outside its access schedule")
+	ErrCardRevoked        = errors.New("card has been revoked")
+	ErrCardUsageExhausted = errors.New("card has no uses left")
+	ErrAntiPassback       = errors.New("anti-passback violation")
 )
 
 // CardService handles card verification business logic
 type CardService struct {
-	client *mongo.Client
+	repo     Repository
+	defaults Policy
+	clock    Clock
+	groups   *groupCache
+
+	// Access log settings; see EnableAccessLog
+	accessLog          bool
+	accessLogRetention time.Duration
 }
 
-// NewCardService creates a new card service
-func NewCardService(client *mongo.Client) *CardService {
+// NewCardService creates a new card service reading devices and cards from repo
+// defaults is the policy of namespaces without stored overrides
+func NewCardService(repo Repository, defaults Policy) *CardService {
 	return &CardService{
-		client: client,
+		repo:     repo,
+		defaults: defaults,
+		clock:    SystemClock,
+		groups:   newGroupCache(DefaultGroupCacheTTL),
 	}
 }
 
 // VerifyCard verifies if a card is valid for a device
+// protocol names the device API the attempt came through (models.ProtocolStandard, models.ProtocolVguang)
+// and is recorded in the access log together with the outcome
 // Returns nil if valid, error otherwise
-func (s *CardService) VerifyCard(ctx context.Context, namespace, deviceSN, cardNumber string) error {
+func (s *CardService) VerifyCard(ctx context.Context, namespace, deviceSN, cardNumber, protocol string) error {
+	card, err := s.verify(ctx, namespace, deviceSN, cardNumber)
+	if s.accessLog {
+		s.recordAccess(ctx, namespace, deviceSN, cardNumber, protocol, card, err)
+	}
+	return err
+}
+
+// verify runs the verification steps and returns the card once it has been found
+func (s *CardService) verify(ctx context.Context, namespace, deviceSN, cardNumber string) (*models.Card, error) {
 	// Step 1: Verify device exists and is active
-	device, err := s.getDevice(ctx, namespace, deviceSN)
+	device, err := s.repo.GetDeviceBySN(ctx, namespace, deviceSN)
 	if err != nil {
 		log.Printf("[CardVerification] Device check failed: namespace=%s, device_sn=%s, error=%v",
 			namespace, deviceSN, err)
-		return err
+		return nil, err
 	}
 
-	// Status check disabled - accept devices regardless of status
-	// if device.Status != "active" {
-	// 	log.Printf("[CardVerification] Device not active: namespace=%s, device_sn=%s, status=%s",
-	// 		namespace, deviceSN, device.Status)
-	// 	return ErrDeviceNotActive
-	// }
+	// Decommissioned devices are always rejected; other statuses depend on the namespace policy
+	policy, _, err := s.Policy(ctx, namespace)
+	if err != nil {
+		log.Printf("[CardVerification] Policy lookup failed: namespace=%s, error=%v", namespace, err)
+		return nil, err
+	}
+	if device.Status == models.DeviceStatusDecommissioned ||
+		(policy.RequireActiveDevice && device.Status != models.DeviceStatusActive) {
+		log.Printf("[CardVerification] Device not active: namespace=%s, device_sn=%s, status=%s",
+			namespace, deviceSN, device.Status)
+		return nil, ErrDeviceNotActive
+	}
 
 	log.Printf("[CardVerification] Device verified: namespace=%s, device_sn=%s, device_id=%s",
 		namespace, deviceSN, device.DeviceID)
 
-	// Step 2: Find card by number
-	card, err := s.getCard(ctx, namespace, cardNumber)
+	// Step 2: Reject revoked card numbers, find card by number, then reject revoked cards
+	if err := s.checkRevoked(ctx, namespace, models.RevocationByNumber, cardNumber); err != nil {
+		return nil, err
+	}
+
+	card, err := s.repo.GetCardByNumber(ctx, namespace, cardNumber)
 	if err != nil {
 		log.Printf("[CardVerification] Card not found: namespace=%s, card_number=%s, error=%v",
 			namespace, cardNumber, err)
-		return err
+		return nil, err
 	}
 
-	// Step 3: Verify card is authorized for this device (check both SN and device_id)
-	if !card.HasDevice(deviceSN) && !card.HasDevice(device.DeviceID) {
-		log.Printf("[CardVerification] Card not authorized: namespace=%s, card_number=%s, device_sn=%s, device_id=%s, authorized_devices=%v",
-			namespace, cardNumber, deviceSN, device.DeviceID, card.Devices)
-		return ErrCardNotAuthorized
+	if card.RevokedAt != nil {
+		log.Printf("[CardVerification] Card revoked: namespace=%s, card_number=%s, card_id=%s, revoked_at=%s",
+			namespace, cardNumber, card.ID, card.RevokedAt.Format(time.RFC3339))
+		return card, ErrCardRevoked
+	}
+	if err := s.checkRevoked(ctx, namespace, models.RevocationByCardID, card.ID); err != nil {
+		return card, err
+	}
+
+	// Step 3: Verify card is authorized for this device (check both SN and device_id, directly or through a group)
+	authorized, err := s.cardAuthorizes(ctx, namespace, card, device)
+	if err != nil {
+		log.Printf("[CardVerification] Group lookup failed: namespace=%s, card_number=%s, groups=%v, error=%v",
+			namespace, cardNumber, card.Groups, err)
+		return card, err
+	}
+	if !authorized {
+		log.Printf("[CardVerification] Card not authorized: namespace=%s, card_number=%s, device_sn=%s, device_id=%s, authorized_devices=%v, groups=%v",
+			namespace, cardNumber, deviceSN, device.DeviceID, card.Devices, card.Groups)
+		return card, ErrCardNotAuthorized
 	}
 
-	// Step 4: Verify card is within valid time range (with ±60s tolerance)
-	now := time.Now()
-	if !card.IsValid(now) {
-		if now.Before(card.EffectiveAt.Add(-60 * time.Second)) {
+	// Step 4: Verify card is within valid time range (widened by the namespace clock tolerance)
+	now := s.clock.Now()
+	tolerance := policy.ClockTolerance()
+	if !card.IsValidWithin(now, tolerance) {
+		if now.Before(card.EffectiveAt.Add(-tolerance)) {
 			log.Printf("[CardVerification] Card not yet valid: namespace=%s, card_number=%s, device_sn=%s, effective_at=%s, current_time=%s",
 				namespace, cardNumber, deviceSN, card.EffectiveAt.Format(time.RFC3339), now.Format(time.RFC3339))
-			return ErrCardNotYetValid
+			return card, ErrCardNotYetValid
 		}
 
 		log.Printf("[CardVerification] Card expired: namespace=%s, card_number=%s, device_sn=%s, invalid_at=%s, current_time=%s",
 			namespace, cardNumber, deviceSN, card.InvalidAt.Format(time.RFC3339), now.Format(time.RFC3339))
-		return ErrCardExpired
+		return card, ErrCardExpired
 	}
 
-	// Success
-	log.Printf("[CardVerification] SUCCESS: namespace=%s, card_number=%s, device_sn=%s, card_id=%s, effective=%s, invalid=%s",
-		namespace, cardNumber, deviceSN, card.ID,
-		card.EffectiveAt.Format(time.RFC3339), card.InvalidAt.Format(time.RFC3339))
-
-	return nil
-}
-
-// getDevice retrieves a device by SN from the devices collection
-func (s *CardService) getDevice(ctx context.Context, namespace, deviceSN string) (*models.Device, error) {
-	collection := s.client.Database(namespace).Collection("devices")
-
-	var device models.Device
-	err := collection.FindOne(ctx, bson.M{"sn": deviceSN}).Decode(&device)
-	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, ErrDeviceNotFound
+	// Step 5: Verify the current time falls into the card's schedule (if any)
+	if card.Schedule != nil {
+		allowed, err := card.Schedule.Allows(now)
+		if err != nil {
+			log.Printf("[CardVerification] Invalid schedule: namespace=%s, card_number=%s, card_id=%s, error=%v",
+				namespace, cardNumber, card.ID, err)
+			return card, ErrOutsideSchedule
+		}
+		if !allowed {
+			log.Printf("[CardVerification] Outside schedule: namespace=%s, card_number=%s, device_sn=%s, timezone=%s, current_time=%s",
+				namespace, cardNumber, deviceSN, card.Schedule.Timezone, now.Format(time.RFC3339))
+			return card, ErrOutsideSchedule
 		}
-		return nil, fmt.Errorf("failed to query device: %w", err)
 	}
 
-	return &device, nil
-}
-
-// getCard retrieves a card by number from the cards collection
-func (s *CardService) getCard(ctx context.Context, namespace, cardNumber string) (*models.Card, error) {
-	collection := s.client.Database(namespace).Collection("cards")
+	// Step 6: Enforce anti-passback on devices guarding a zone
+	if err := s.checkPassback(ctx, namespace, policy.AntiPassback, card, device); err != nil {
+		return card, err
+	}
 
-	var card models.Card
-	err := collection.FindOne(ctx, bson.M{"number": cardNumber}).Decode(&card)
-	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, ErrCardNotFound
+	// Step 7: Count the use of usage-limited cards; the increment is atomic, so concurrent
+	// attempts never get past max_uses
+	if card.MaxUses > 0 {
+		updated, err := s.repo.ConsumeCardUse(ctx, namespace, card.ID)
+		if err != nil {
+			log.Printf("[CardVerification] Card use not counted: namespace=%s, card_number=%s, card_id=%s, max_uses=%d, error=%v",
+				namespace, cardNumber, card.ID, card.MaxUses, err)
+			return card, err
 		}
-		return nil, fmt.Errorf("failed to query card: %w", err)
+		card = updated
 	}
 
-	return &card, nil
+	s.recordPassage(ctx, namespace, policy.AntiPassback, card, device)
+
+	// Success
+	log.Printf("[CardVerification] SUCCESS: namespace=%s, card_number=%s, device_sn=%s, card_id=%s, effective=%s, invalid=%s",
+		namespace, cardNumber, deviceSN, card.ID,
+		card.EffectiveAt.Format(time.RFC3339), card.InvalidAt.Format(time.RFC3339))
+
+	return card, nil
 }
@@ -2,12 +2,14 @@ package services
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"commander/internal/models"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -150,7 +152,7 @@ func TestVerifyCardBehaviorLogic(t *testing.T) {
 		client, err := mongo.Connect(context.Background(), opts)
 		if err == nil {
 			defer client.Disconnect(context.Background())
-			service := NewCardService(client)
+			service := NewCardService(NewMongoRepository(client), Policy{})
 			assert.NotNil(t, service)
 		} else {
 			// Skip if MongoDB is not available
@@ -161,7 +163,7 @@ func TestVerifyCardBehaviorLogic(t *testing.T) {
 	t.Run("NewCardService properly initializes with nil client handled", func(t *testing.T) {
 		// This validates the service handles nil gracefully
 		// (though in practice, nil would cause panics on VerifyCard call)
-		service := NewCardService(nil)
+		service := NewCardService(nil, Policy{})
 		assert.NotNil(t, service)
 	})
 }
@@ -363,3 +365,123 @@ func TestVerifyCardFlowConditions(t *testing.T) {
 		assert.False(t, isExpired)
 	})
 }
+
+// === CardService.VerifyCard Tests (KV repository) ===
+
+func TestCardServiceVerifyCard_KVRepository(t *testing.T) {
+	repo, _ := newTestKVRepository(t, true)
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-1", DeviceID: "lobby-door", SN: "SN-001"}))
+	require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-2", DeviceID: "gym-door", SN: "SN-002"}))
+
+	cards := []*models.Card{
+		{ID: "card-1", Number: "VALID", Devices: []string{"SN-001"}, EffectiveAt: now.Add(-time.Hour), InvalidAt: now.Add(time.Hour)},
+		{ID: "card-2", Number: "BY-DEVICE-ID", Devices: []string{"lobby-door"}, EffectiveAt: now.Add(-time.Hour), InvalidAt: now.Add(time.Hour)},
+		{ID: "card-3", Number: "FUTURE", Devices: []string{"SN-001"}, EffectiveAt: now.Add(time.Hour), InvalidAt: now.Add(2 * time.Hour)},
+		{ID: "card-4", Number: "EXPIRED", Devices: []string{"SN-001"}, EffectiveAt: now.Add(-2 * time.Hour), InvalidAt: now.Add(-time.Hour)},
+	}
+	for _, card := range cards {
+		require.NoError(t, repo.SaveCard(ctx, "hotel_a", card))
+	}
+
+	service := NewCardService(repo, Policy{})
+
+	tests := []struct {
+		name       string
+		namespace  string
+		deviceSN   string
+		cardNumber string
+		expected   error
+	}{
+		{"valid card", "hotel_a", "SN-001", "VALID", nil},
+		{"authorized by device_id", "hotel_a", "SN-001", "BY-DEVICE-ID", nil},
+		{"unknown device", "hotel_a", "SN-999", "VALID", ErrDeviceNotFound},
+		{"unknown card", "hotel_a", "SN-001", "UNKNOWN", ErrCardNotFound},
+		{"card not authorized for device", "hotel_a", "SN-002", "VALID", ErrCardNotAuthorized},
+		{"card not yet valid", "hotel_a", "SN-001", "FUTURE", ErrCardNotYetValid},
+		{"card expired", "hotel_a", "SN-001", "EXPIRED", ErrCardExpired},
+		{"other namespace", "hotel_b", "SN-001", "VALID", ErrDeviceNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.VerifyCard(ctx, tt.namespace, tt.deviceSN, tt.cardNumber, models.ProtocolStandard)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCardServiceVerifyCard_Schedule(t *testing.T) {
+	now := time.Now().UTC()
+	tomorrow := strings.ToLower(now.Add(24 * time.Hour).Weekday().String()[:3])
+
+	tests := []struct {
+		name     string
+		schedule *models.Schedule
+		expected error
+	}{
+		{"no schedule", nil, nil},
+		{"all day", &models.Schedule{Windows: []models.ScheduleWindow{{Start: "00:00", End: "24:00"}}}, nil},
+		{"other weekday", &models.Schedule{Windows: []models.ScheduleWindow{{Days: []string{tomorrow}, Start: "00:00", End: "24:00"}}}, ErrOutsideSchedule},
+		{"invalid stored schedule", &models.Schedule{Timezone: "Mars/Olympus", Windows: []models.ScheduleWindow{{Start: "00:00", End: "24:00"}}}, ErrOutsideSchedule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, _ := newTestKVRepository(t, true)
+			ctx := context.Background()
+			require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-1", SN: "SN-001"}))
+			require.NoError(t, repo.SaveCard(ctx, "hotel_a", &models.Card{
+				ID:          "card-1",
+				Number:      "STAFF-1",
+				Devices:     []string{"SN-001"},
+				EffectiveAt: now.Add(-time.Hour),
+				InvalidAt:   now.Add(time.Hour),
+				Schedule:    tt.schedule,
+			}))
+
+			err := NewCardService(repo, Policy{}).VerifyCard(ctx, "hotel_a", "SN-001", "STAFF-1", models.ProtocolStandard)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCardServiceVerifyCard_UsageLimit(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	card := testCard("COURIER-1", "SN-001")
+	card.MaxUses = 2
+	created, err := service.CreateCard(ctx, "hotel_a", card)
+	require.NoError(t, err)
+	assert.Zero(t, created.UseCount)
+
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "COURIER-1", models.ProtocolStandard))
+	// Denied attempts do not use up the card
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-302", "COURIER-1", models.ProtocolStandard), ErrCardNotAuthorized)
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "COURIER-1", models.ProtocolStandard))
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "COURIER-1", models.ProtocolStandard), ErrCardUsageExhausted)
+
+	// Updates keep the counter; raising the limit grants more uses
+	card = testCard("COURIER-1", "SN-001")
+	card.MaxUses = 3
+	updated, err := service.UpdateCard(ctx, "hotel_a", created.ID, card)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.UseCount)
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "COURIER-1", models.ProtocolStandard))
+
+	card = testCard("COURIER-2")
+	card.MaxUses = -1
+	_, err = service.CreateCard(ctx, "hotel_a", card)
+	assert.ErrorIs(t, err, ErrInvalidCard)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"commander/internal/models"
+)
+
+// ChangeFeed is a Repository that appends a models.Change to the namespace change feed after every
+// successful write of a record edge instances replicate (devices, cards, device groups, revocations
+// and the policy). Use counts are included, since ConsumeCardUse rewrites the card
+//
+// The record is written first; if the change cannot be appended the error is returned and the write
+// is left in place, so a retried request records it again
+type ChangeFeed struct {
+	Repository
+	clock Clock
+}
+
+// NewChangeFeed creates a change feed recording the writes made through repo
+func NewChangeFeed(repo Repository) *ChangeFeed {
+	return &ChangeFeed{
+		Repository: repo,
+		clock:      SystemClock,
+	}
+}
+
+// SaveDevice stores the device and records it
+func (f *ChangeFeed) SaveDevice(ctx context.Context, namespace string, device *models.Device) error {
+	if err := f.Repository.SaveDevice(ctx, namespace, device); err != nil {
+		return err
+	}
+	return f.record(ctx, namespace, models.ChangeDevice, device.ID, device)
+}
+
+// DeleteDevice removes the device and records the deletion
+func (f *ChangeFeed) DeleteDevice(ctx context.Context, namespace, id string) error {
+	if err := f.Repository.DeleteDevice(ctx, namespace, id); err != nil {
+		return err
+	}
+	return f.record(ctx, namespace, models.ChangeDevice, id, nil)
+}
+
+// SaveCard stores the card and records it
+func (f *ChangeFeed) SaveCard(ctx context.Context, namespace string, card *models.Card) error {
+	if err := f.Repository.SaveCard(ctx, namespace, card); err != nil {
+		return err
+	}
+	return f.record(ctx, namespace, models.ChangeCard, card.ID, card)
+}
+
+// DeleteCard removes the card and records the deletion
+func (f *ChangeFeed) DeleteCard(ctx context.Context, namespace, id string) error {
+	if err := f.Repository.DeleteCard(ctx, namespace, id); err != nil {
+		return err
+	}
+	return f.record(ctx, namespace, models.ChangeCard, id, nil)
+}
+
+// ConsumeCardUse counts the use and records the updated card
+// The use is counted either way, so a failure to record it is only logged and never denies the passage
+func (f *ChangeFeed) ConsumeCardUse(ctx context.Context, namespace, id string) (*models.Card, error) {
+	card, err := f.Repository.ConsumeCardUse(ctx, namespace, id)
+	if err != nil {
+		return nil, err
+	}
+	_ = f.record(ctx, namespace, models.ChangeCard, card.ID, card) //nolint:errcheck // Logged by record
+	return card, nil
+}
+
+// SaveDeviceGroup stores the device group and records it
+func (f *ChangeFeed) SaveDeviceGroup(ctx context.Context, namespace string, group *models.DeviceGroup) error {
+	if err := f.Repository.SaveDeviceGroup(ctx, namespace, group); err != nil {
+		return err
+	}
+	return f.record(ctx, namespace, models.ChangeDeviceGroup, group.ID, group)
+}
+
+// DeleteDeviceGroup removes the device group and records the deletion
+func (f *ChangeFeed) DeleteDeviceGroup(ctx context.Context, namespace, id string) error {
+	if err := f.Repository.DeleteDeviceGroup(ctx, namespace, id); err != nil {
+		return err
+	}
+	return f.record(ctx, namespace, models.ChangeDeviceGroup, id, nil)
+}
+
+// SaveRevocation stores the revocation and records it
+func (f *ChangeFeed) SaveRevocation(ctx context.Context, namespace string, revocation *models.Revocation) error {
+	if err := f.Repository.SaveRevocation(ctx, namespace, revocation); err != nil {
+		return err
+	}
+	return f.record(ctx, namespace, models.ChangeRevocation, revocation.ID, revocation)
+}
+
+// DeleteRevocation removes the revocation and records the deletion
+func (f *ChangeFeed) DeleteRevocation(ctx context.Context, namespace, id string) error {
+	if err := f.Repository.DeleteRevocation(ctx, namespace, id); err != nil {
+		return err
+	}
+	return f.record(ctx, namespace, models.ChangeRevocation, id, nil)
+}
+
+// SavePolicy stores the policy overrides and records them
+func (f *ChangeFeed) SavePolicy(ctx context.Context, namespace string, policy *models.NamespacePolicy) error {
+	if err := f.Repository.SavePolicy(ctx, namespace, policy); err != nil {
+		return err
+	}
+	return f.record(ctx, namespace, models.ChangePolicy, "", policy)
+}
+
+// record appends a change carrying record, or a deletion if record is nil
+// The write already happened, so the change is appended even if the client disconnected meanwhile
+func (f *ChangeFeed) record(ctx context.Context, namespace, kind, id string, record interface{}) error {
+	change := &models.Change{Kind: kind, ID: id, Deleted: record == nil, Timestamp: f.clock.Now().UTC()}
+	if record != nil {
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		change.Record = value
+	}
+
+	if err := f.Repository.AppendChange(context.WithoutCancel(ctx), namespace, change); err != nil {
+		log.Printf("[ChangeFeed] Failed to record change: namespace=%s, kind=%s, id=%s, error=%v",
+			namespace, kind, id, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package services
+
+import "time"
+
+// Clock provides the current time to CardService
+// Tests replace it to exercise validity and schedule boundaries deterministically
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock backed by time.Now
+var SystemClock Clock = systemClock{}
+
+// systemClock reads the system time
+type systemClock struct{}
+
+// Now returns time.Now()
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SetClock replaces the clock used for verification and record timestamps
+func (s *CardService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// Now returns the current time of the service clock
+func (s *CardService) Now() time.Time {
+	return s.clock.Now()
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedClock is a Clock that always returns the same time
+type fixedClock struct {
+	now time.Time
+}
+
+// Now returns the fixed time
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestCardServiceVerifyCard_ClockTolerance(t *testing.T) {
+	effective := time.Date(2026, 8, 25, 15, 0, 0, 0, time.UTC)
+	thirty := 30
+	zero := 0
+
+	tests := []struct {
+		name      string
+		defaults  Policy
+		overrides *models.NamespacePolicy
+		now       time.Time
+		expected  error
+	}{
+		{"within default tolerance", Policy{ClockToleranceSeconds: 60}, nil, effective.Add(-45 * time.Second), nil},
+		{"no tolerance", Policy{}, nil, effective.Add(-time.Second), ErrCardNotYetValid},
+		{"at effective_at", Policy{}, nil, effective.Add(time.Nanosecond), nil},
+		{"namespace tolerance narrower", Policy{ClockToleranceSeconds: 60}, &models.NamespacePolicy{ClockToleranceSeconds: &thirty}, effective.Add(-45 * time.Second), ErrCardNotYetValid},
+		{"namespace tolerance disabled", Policy{ClockToleranceSeconds: 60}, &models.NamespacePolicy{ClockToleranceSeconds: &zero}, effective.Add(time.Hour), ErrCardExpired},
+		{"expired within tolerance", Policy{ClockToleranceSeconds: 60}, nil, effective.Add(time.Hour + 59*time.Second), nil},
+		{"expired beyond tolerance", Policy{ClockToleranceSeconds: 60}, nil, effective.Add(time.Hour + time.Minute), ErrCardExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, _ := newTestKVRepository(t, true)
+			ctx := context.Background()
+			require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-1", SN: "SN-001"}))
+			require.NoError(t, repo.SaveCard(ctx, "hotel_a", &models.Card{
+				ID:          "card-1",
+				Number:      "GUEST-1",
+				Devices:     []string{"SN-001"},
+				EffectiveAt: effective,
+				InvalidAt:   effective.Add(time.Hour),
+			}))
+			if tt.overrides != nil {
+				require.NoError(t, repo.SavePolicy(ctx, "hotel_a", tt.overrides))
+			}
+
+			service := NewCardService(repo, tt.defaults)
+			service.SetClock(fixedClock{now: tt.now})
+			err := service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCardService_ClockTimestamps(t *testing.T) {
+	service := newTestCardService(t)
+	now := time.Date(2026, 8, 25, 15, 0, 0, 0, time.UTC)
+	service.SetClock(fixedClock{now: now})
+	ctx := context.Background()
+
+	assert.Equal(t, now, service.Now())
+
+	device, err := service.RegisterDevice(ctx, "hotel_a", &models.Device{SN: "SN-500"})
+	require.NoError(t, err)
+	assert.Equal(t, now, device.CreatedAt)
+
+	card, err := service.CreateCard(ctx, "hotel_a", &models.Card{Number: "GUEST-1", EffectiveAt: now, InvalidAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	card, err = service.RevokeCard(ctx, "hotel_a", card.ID)
+	require.NoError(t, err)
+	require.NotNil(t, card.RevokedAt)
+	assert.Equal(t, now, *card.RevokedAt)
+	assert.Equal(t, now, card.InvalidAt)
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"commander/internal/models"
+)
+
+// Device management errors.
+var (
+	ErrInvalidDevice           = errors.New("invalid device")
+	ErrDeviceExists            = errors.New("device already exists")
+	ErrDeviceSNExists          = errors.New("device SN already exists")
+	ErrInvalidStatusTransition = errors.New("invalid device status transition")
+)
+
+// MaxDeviceListLimit is the largest page size accepted by ListDevices
+const MaxDeviceListLimit = 1000
+
+// ListDevices returns a page of devices in namespace matching query
+func (s *CardService) ListDevices(ctx context.Context, namespace string, query DeviceQuery) ([]*models.Device, string, error) {
+	if query.Limit > MaxDeviceListLimit {
+		query.Limit = MaxDeviceListLimit
+	}
+	return s.repo.ListDevices(ctx, namespace, query)
+}
+
+// GetDevice retrieves a device by ID
+func (s *CardService) GetDevice(ctx context.Context, namespace, id string) (*models.Device, error) {
+	return s.repo.GetDevice(ctx, namespace, id)
+}
+
+// RegisterDevice validates and stores a new device in the pending status
+// An ID is generated when device.ID is empty
+func (s *CardService) RegisterDevice(ctx context.Context, namespace string, device *models.Device) (*models.Device, error) {
+	if device.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return nil, err
+		}
+		device.ID = id
+	} else {
+		_, err := s.repo.GetDevice(ctx, namespace, device.ID)
+		if err == nil {
+			return nil, ErrDeviceExists
+		}
+		if !errors.Is(err, ErrDeviceNotFound) {
+			return nil, err
+		}
+	}
+
+	if err := validateDevice(device); err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now().UTC()
+	device.Status = models.DeviceStatusPending
+	device.CreatedAt = now
+	device.UpdatedAt = now
+	if err := s.repo.SaveDevice(ctx, namespace, device); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[DeviceAdmin] Device registered: namespace=%s, device_id=%s, device_sn=%s", namespace, device.ID, device.SN)
+	return device, nil
+}
+
+// UpdateDevice replaces the descriptive fields of an existing device
+// ID, Status and CreatedAt are kept from the stored device; use SetDeviceStatus to change the status
+func (s *CardService) UpdateDevice(ctx context.Context, namespace, id string, device *models.Device) (*models.Device, error) {
+	existing, err := s.repo.GetDevice(ctx, namespace, id)
+	if err != nil {
+		return nil, err
+	}
+
+	device.ID = existing.ID
+	device.Status = existing.Status
+	device.CreatedAt = existing.CreatedAt
+	if err := validateDevice(device); err != nil {
+		return nil, err
+	}
+
+	device.UpdatedAt = s.clock.Now().UTC()
+	if err := s.repo.SaveDevice(ctx, namespace, device); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[DeviceAdmin] Device updated: namespace=%s, device_id=%s, device_sn=%s", namespace, device.ID, device.SN)
+	return device, nil
+}
+
+// SetDeviceStatus moves a device to status following the device status state machine
+// Returns ErrInvalidStatusTransition for transitions the state machine does not allow
+func (s *CardService) SetDeviceStatus(ctx context.Context, namespace, id, status string) (*models.Device, error) {
+	device, err := s.repo.GetDevice(ctx, namespace, id)
+	if err != nil {
+		return nil, err
+	}
+	if device.Status == status {
+		return device, nil
+	}
+	if !device.CanTransition(status) {
+		return nil, fmt.Errorf("%w: %q to %q", ErrInvalidStatusTransition, device.Status, status)
+	}
+
+	previous := device.Status
+	device.Status = status
+	device.UpdatedAt = s.clock.Now().UTC()
+	if err := s.repo.SaveDevice(ctx, namespace, device); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[DeviceAdmin] Device status changed: namespace=%s, device_id=%s, device_sn=%s, from=%s, to=%s",
+		namespace, device.ID, device.SN, previous, status)
+	return device, nil
+}
+
+// validateDevice checks the required fields and the anti-passback direction and zone of a device
+func validateDevice(device *models.Device) error {
+	device.SN = strings.TrimSpace(device.SN)
+	if device.SN == "" {
+		return fmt.Errorf("%w: sn is required", ErrInvalidDevice)
+	}
+
+	device.Zone = strings.TrimSpace(device.Zone)
+	switch device.Direction {
+	case "":
+		if device.Zone != "" {
+			return fmt.Errorf("%w: zone requires a direction", ErrInvalidDevice)
+		}
+	case models.DeviceDirectionEntry, models.DeviceDirectionExit:
+		if device.Zone == "" {
+			return fmt.Errorf("%w: direction requires a zone", ErrInvalidDevice)
+		}
+	default:
+		return fmt.Errorf("%w: direction must be entry or exit", ErrInvalidDevice)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCardService_RegisterDevice(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	device, err := service.RegisterDevice(ctx, "hotel_a", &models.Device{SN: " SN-500 ", DisplayName: "Room 500"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, device.ID)
+	assert.Equal(t, "SN-500", device.SN)
+	assert.Equal(t, models.DeviceStatusPending, device.Status)
+	assert.False(t, device.CreatedAt.IsZero())
+
+	tests := []struct {
+		name     string
+		device   *models.Device
+		expected error
+	}{
+		{"duplicate id", &models.Device{ID: device.ID, SN: "SN-501"}, ErrDeviceExists},
+		{"duplicate sn", &models.Device{SN: "SN-500"}, ErrDeviceSNExists},
+		{"missing sn", &models.Device{SN: "  "}, ErrInvalidDevice},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.RegisterDevice(ctx, "hotel_a", tt.device)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
+
+func TestCardService_UpdateDevice(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	device, err := service.RegisterDevice(ctx, "hotel_a", &models.Device{SN: "SN-500"})
+	require.NoError(t, err)
+	_, err = service.SetDeviceStatus(ctx, "hotel_a", device.ID, models.DeviceStatusActive)
+	require.NoError(t, err)
+
+	// The status is kept and the SN index follows the new SN
+	updated, err := service.UpdateDevice(ctx, "hotel_a", device.ID, &models.Device{SN: "SN-600", Status: models.DeviceStatusDecommissioned})
+	require.NoError(t, err)
+	assert.Equal(t, models.DeviceStatusActive, updated.Status)
+	assert.Equal(t, device.CreatedAt, updated.CreatedAt)
+
+	_, err = service.repo.GetDeviceBySN(ctx, "hotel_a", "SN-500")
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+	found, err := service.repo.GetDeviceBySN(ctx, "hotel_a", "SN-600")
+	require.NoError(t, err)
+	assert.Equal(t, device.ID, found.ID)
+
+	_, err = service.UpdateDevice(ctx, "hotel_a", device.ID, &models.Device{SN: "SN-001"})
+	assert.ErrorIs(t, err, ErrDeviceSNExists)
+	_, err = service.UpdateDevice(ctx, "hotel_a", "missing", &models.Device{SN: "SN-700"})
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+}
+
+func TestCardService_SetDeviceStatus(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	device, err := service.RegisterDevice(ctx, "hotel_a", &models.Device{SN: "SN-500"})
+	require.NoError(t, err)
+
+	steps := []struct {
+		status   string
+		expected error
+	}{
+		{models.DeviceStatusInactive, ErrInvalidStatusTransition},
+		{models.DeviceStatusActive, nil},
+		{models.DeviceStatusActive, nil}, // No-op
+		{models.DeviceStatusInactive, nil},
+		{models.DeviceStatusPending, ErrInvalidStatusTransition},
+		{models.DeviceStatusDecommissioned, nil},
+		{models.DeviceStatusActive, ErrInvalidStatusTransition},
+	}
+
+	for _, step := range steps {
+		updated, err := service.SetDeviceStatus(ctx, "hotel_a", device.ID, step.status)
+		if step.expected != nil {
+			assert.ErrorIs(t, err, step.expected, "to %s", step.status)
+			continue
+		}
+		require.NoError(t, err, "to %s", step.status)
+		assert.Equal(t, step.status, updated.Status)
+	}
+
+	stored, err := service.GetDevice(ctx, "hotel_a", device.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeviceStatusDecommissioned, stored.Status)
+}
+
+func TestCardService_ListDevices(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	_, err := service.SetDeviceStatus(ctx, "hotel_a", "device-1", models.DeviceStatusActive)
+	require.NoError(t, err)
+
+	devices, cursor, err := service.ListDevices(ctx, "hotel_a", DeviceQuery{})
+	require.NoError(t, err)
+	assert.Len(t, devices, 2)
+	assert.Empty(t, cursor)
+
+	devices, _, err = service.ListDevices(ctx, "hotel_a", DeviceQuery{Status: models.DeviceStatusActive})
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, "device-1", devices[0].ID)
+
+	devices, cursor, err = service.ListDevices(ctx, "hotel_a", DeviceQuery{Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, devices, 1)
+	assert.Equal(t, "device-1", cursor)
+}
+
+func TestCardService_Policy(t *testing.T) {
+	repo, _ := newTestKVRepository(t, true)
+	service := NewCardService(repo, Policy{RequireActiveDevice: true})
+	ctx := context.Background()
+
+	policy, overrides, err := service.Policy(ctx, "hotel_a")
+	require.NoError(t, err)
+	assert.True(t, policy.RequireActiveDevice)
+	assert.Nil(t, overrides)
+
+	disabled := false
+	policy, err = service.SetPolicy(ctx, "hotel_a", &models.NamespacePolicy{RequireActiveDevice: &disabled})
+	require.NoError(t, err)
+	assert.False(t, policy.RequireActiveDevice)
+
+	policy, overrides, err = service.Policy(ctx, "hotel_a")
+	require.NoError(t, err)
+	assert.False(t, policy.RequireActiveDevice)
+	require.NotNil(t, overrides)
+	assert.False(t, overrides.UpdatedAt.IsZero())
+
+	negative := -1
+	_, err = service.SetPolicy(ctx, "hotel_a", &models.NamespacePolicy{ClockToleranceSeconds: &negative})
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+
+	// Other namespaces keep the defaults
+	policy, _, err = service.Policy(ctx, "hotel_b")
+	require.NoError(t, err)
+	assert.True(t, policy.RequireActiveDevice)
+}
+
+func TestCardService_VerifyCardDeviceStatus(t *testing.T) {
+	enabled := true
+	tests := []struct {
+		name      string
+		defaults  Policy
+		overrides *models.NamespacePolicy
+		status    string
+		expected  error
+	}{
+		{"legacy device allowed by default", Policy{}, nil, "", nil},
+		{"pending device allowed by default", Policy{}, nil, models.DeviceStatusPending, nil},
+		{"decommissioned device always rejected", Policy{}, nil, models.DeviceStatusDecommissioned, ErrDeviceNotActive},
+		{"active device required globally", Policy{RequireActiveDevice: true}, nil, models.DeviceStatusInactive, ErrDeviceNotActive},
+		{"active device passes", Policy{RequireActiveDevice: true}, nil, models.DeviceStatusActive, nil},
+		{"active device required by namespace", Policy{}, &models.NamespacePolicy{RequireActiveDevice: &enabled}, models.DeviceStatusPending, ErrDeviceNotActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, _ := newTestKVRepository(t, true)
+			ctx := context.Background()
+			now := time.Now().UTC()
+			require.NoError(t, repo.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-1", SN: "SN-001", Status: tt.status}))
+			require.NoError(t, repo.SaveCard(ctx, "hotel_a", &models.Card{
+				ID:          "card-1",
+				Number:      "GUEST-1",
+				Devices:     []string{"SN-001"},
+				EffectiveAt: now.Add(-time.Hour),
+				InvalidAt:   now.Add(time.Hour),
+			}))
+			if tt.overrides != nil {
+				require.NoError(t, repo.SavePolicy(ctx, "hotel_a", tt.overrides))
+			}
+
+			service := NewCardService(repo, tt.defaults)
+			err := service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
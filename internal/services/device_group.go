@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"commander/internal/models"
+)
+
+// Device group errors.
+var (
+	ErrDeviceGroupNotFound = errors.New("device group not found")
+	ErrInvalidDeviceGroup  = errors.New("invalid device group")
+	ErrDeviceGroupExists   = errors.New("device group already exists")
+	ErrUnknownDeviceGroup  = errors.New("unknown device group")
+)
+
+// MaxDeviceGroupListLimit is the largest page size accepted by ListDeviceGroups
+const MaxDeviceGroupListLimit = 1000
+
+// DefaultGroupCacheTTL is how long VerifyCard reuses a device group read from the repository
+const DefaultGroupCacheTTL = 30 * time.Second
+
+// ListDeviceGroups returns a page of device groups in namespace matching query
+func (s *CardService) ListDeviceGroups(ctx context.Context, namespace string, query DeviceGroupQuery) ([]*models.DeviceGroup, string, error) {
+	if query.Limit > MaxDeviceGroupListLimit {
+		query.Limit = MaxDeviceGroupListLimit
+	}
+	return s.repo.ListDeviceGroups(ctx, namespace, query)
+}
+
+// GetDeviceGroup retrieves a device group by ID
+func (s *CardService) GetDeviceGroup(ctx context.Context, namespace, id string) (*models.DeviceGroup, error) {
+	return s.repo.GetDeviceGroup(ctx, namespace, id)
+}
+
+// CreateDeviceGroup validates and stores a new device group
+// Returns ErrDeviceGroupExists if the ID is already used
+func (s *CardService) CreateDeviceGroup(ctx context.Context, namespace string, group *models.DeviceGroup) (*models.DeviceGroup, error) {
+	group.ID = strings.TrimSpace(group.ID)
+	if group.ID == "" {
+		return nil, fmt.Errorf("%w: id is required", ErrInvalidDeviceGroup)
+	}
+	_, err := s.repo.GetDeviceGroup(ctx, namespace, group.ID)
+	if err == nil {
+		return nil, ErrDeviceGroupExists
+	}
+	if !errors.Is(err, ErrDeviceGroupNotFound) {
+		return nil, err
+	}
+
+	if err := s.validateDeviceGroup(ctx, namespace, group); err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now().UTC()
+	group.CreatedAt = now
+	group.UpdatedAt = now
+	if err := s.repo.SaveDeviceGroup(ctx, namespace, group); err != nil {
+		return nil, err
+	}
+	s.groups.invalidate(namespace, group.ID)
+
+	log.Printf("[DeviceGroup] Group created: namespace=%s, group_id=%s, devices=%d", namespace, group.ID, len(group.Devices))
+	return group, nil
+}
+
+// UpdateDeviceGroup replaces the display name and members of an existing device group
+func (s *CardService) UpdateDeviceGroup(ctx context.Context, namespace, id string, group *models.DeviceGroup) (*models.DeviceGroup, error) {
+	existing, err := s.repo.GetDeviceGroup(ctx, namespace, id)
+	if err != nil {
+		return nil, err
+	}
+
+	group.ID = existing.ID
+	group.CreatedAt = existing.CreatedAt
+	if err := s.validateDeviceGroup(ctx, namespace, group); err != nil {
+		return nil, err
+	}
+
+	group.UpdatedAt = s.clock.Now().UTC()
+	if err := s.repo.SaveDeviceGroup(ctx, namespace, group); err != nil {
+		return nil, err
+	}
+	s.groups.invalidate(namespace, group.ID)
+
+	log.Printf("[DeviceGroup] Group updated: namespace=%s, group_id=%s, devices=%d", namespace, group.ID, len(group.Devices))
+	return group, nil
+}
+
+// DeleteDeviceGroup removes a device group
+// Cards still referencing the group lose access to its devices
+func (s *CardService) DeleteDeviceGroup(ctx context.Context, namespace, id string) error {
+	if err := s.repo.DeleteDeviceGroup(ctx, namespace, id); err != nil {
+		return err
+	}
+	s.groups.invalidate(namespace, id)
+
+	log.Printf("[DeviceGroup] Group deleted: namespace=%s, group_id=%s", namespace, id)
+	return nil
+}
+
+// SetGroupCacheTTL changes how long device groups are cached for verification (ttl <= 0 disables the cache)
+// Changes made through this service are visible immediately; changes made elsewhere after at most ttl
+func (s *CardService) SetGroupCacheTTL(ttl time.Duration) {
+	s.groups = newGroupCache(ttl)
+}
+
+// validateDeviceGroup checks that every member device SN exists
+func (s *CardService) validateDeviceGroup(ctx context.Context, namespace string, group *models.DeviceGroup) error {
+	var unknown []string
+	for _, sn := range group.Devices {
+		_, err := s.repo.GetDeviceBySN(ctx, namespace, sn)
+		if errors.Is(err, ErrDeviceNotFound) {
+			unknown = append(unknown, sn)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownDevice, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// cardAuthorizes reports whether card grants access to the device, directly or through one of its groups
+// Groups that no longer exist grant nothing
+func (s *CardService) cardAuthorizes(ctx context.Context, namespace string, card *models.Card, device *models.Device) (bool, error) {
+	if card.HasDevice(device.SN) || card.HasDevice(device.DeviceID) {
+		return true, nil
+	}
+
+	for _, id := range card.Groups {
+		group, err := s.deviceGroup(ctx, namespace, id)
+		if err != nil {
+			return false, err
+		}
+		if group != nil && (group.HasDevice(device.SN) || (device.DeviceID != "" && group.HasDevice(device.DeviceID))) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// deviceGroup returns a device group through the cache, or nil if it does not exist
+func (s *CardService) deviceGroup(ctx context.Context, namespace, id string) (*models.DeviceGroup, error) {
+	now := s.clock.Now()
+	if group, ok := s.groups.get(namespace, id, now); ok {
+		return group, nil
+	}
+
+	group, err := s.repo.GetDeviceGroup(ctx, namespace, id)
+	if errors.Is(err, ErrDeviceGroupNotFound) {
+		group, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.groups.put(namespace, id, group, now)
+	return group, nil
+}
+
+// groupCacheKey identifies a cached device group
+type groupCacheKey struct {
+	namespace string
+	id        string
+}
+
+// groupCacheEntry is a cached device group; a nil group records that it does not exist
+type groupCacheEntry struct {
+	group     *models.DeviceGroup
+	expiresAt time.Time
+}
+
+// groupCache keeps device groups in memory for a fixed TTL
+type groupCache struct {
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[groupCacheKey]groupCacheEntry
+}
+
+// newGroupCache creates a cache; ttl <= 0 disables caching
+func newGroupCache(ttl time.Duration) *groupCache {
+	return &groupCache{
+		ttl:     ttl,
+		entries: make(map[groupCacheKey]groupCacheEntry),
+	}
+}
+
+// get returns the cached group and whether a live entry was found
+func (c *groupCache) get(namespace, id string, now time.Time) (*models.DeviceGroup, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[groupCacheKey{namespace, id}]
+	if !ok || !now.Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.group, true
+}
+
+// put caches group (nil = missing) until now + ttl
+func (c *groupCache) put(namespace, id string, group *models.DeviceGroup, now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[groupCacheKey{namespace, id}] = groupCacheEntry{group: group, expiresAt: now.Add(c.ttl)}
+}
+
+// invalidate drops the cached entry of a group
+func (c *groupCache) invalidate(namespace, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, groupCacheKey{namespace, id})
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCardService_CreateDeviceGroup(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	group, err := service.CreateDeviceGroup(ctx, "hotel_a", &models.DeviceGroup{ID: " floor-3 ", Devices: []string{"SN-302"}})
+	require.NoError(t, err)
+	assert.Equal(t, "floor-3", group.ID)
+	assert.False(t, group.CreatedAt.IsZero())
+
+	tests := []struct {
+		name     string
+		group    *models.DeviceGroup
+		expected error
+	}{
+		{"duplicate id", &models.DeviceGroup{ID: "floor-3"}, ErrDeviceGroupExists},
+		{"missing id", &models.DeviceGroup{ID: "  "}, ErrInvalidDeviceGroup},
+		{"unknown device", &models.DeviceGroup{ID: "pool", Devices: []string{"SN-999"}}, ErrUnknownDevice},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateDeviceGroup(ctx, "hotel_a", tt.group)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
+
+func TestCardService_UpdateDeleteDeviceGroup(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	created, err := service.CreateDeviceGroup(ctx, "hotel_a", &models.DeviceGroup{ID: "floor-3", Devices: []string{"SN-302"}})
+	require.NoError(t, err)
+
+	updated, err := service.UpdateDeviceGroup(ctx, "hotel_a", "floor-3", &models.DeviceGroup{ID: "ignored", Devices: []string{"SN-001", "SN-302"}})
+	require.NoError(t, err)
+	assert.Equal(t, "floor-3", updated.ID)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+	assert.Len(t, updated.Devices, 2)
+
+	groups, _, err := service.ListDeviceGroups(ctx, "hotel_a", DeviceGroupQuery{Device: "SN-001"})
+	require.NoError(t, err)
+	assert.Len(t, groups, 1)
+
+	_, err = service.UpdateDeviceGroup(ctx, "hotel_a", "missing", &models.DeviceGroup{})
+	assert.ErrorIs(t, err, ErrDeviceGroupNotFound)
+
+	require.NoError(t, service.DeleteDeviceGroup(ctx, "hotel_a", "floor-3"))
+	assert.ErrorIs(t, service.DeleteDeviceGroup(ctx, "hotel_a", "floor-3"), ErrDeviceGroupNotFound)
+}
+
+func TestCardService_CardGroupsValidation(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	card := testCard("GUEST-1")
+	card.Groups = []string{"floor-3"}
+	_, err := service.CreateCard(ctx, "hotel_a", card)
+	assert.ErrorIs(t, err, ErrUnknownDeviceGroup)
+
+	_, err = service.CreateDeviceGroup(ctx, "hotel_a", &models.DeviceGroup{ID: "floor-3", Devices: []string{"SN-302"}})
+	require.NoError(t, err)
+	_, err = service.CreateCard(ctx, "hotel_a", card)
+	assert.NoError(t, err)
+}
+
+func TestCardServiceVerifyCard_DeviceGroups(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	_, err := service.CreateDeviceGroup(ctx, "hotel_a", &models.DeviceGroup{ID: "floor-3", Devices: []string{"SN-302"}})
+	require.NoError(t, err)
+	card := testCard("GUEST-1")
+	card.Groups = []string{"floor-3"}
+	_, err = service.CreateCard(ctx, "hotel_a", card)
+	require.NoError(t, err)
+
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-302", "GUEST-1", models.ProtocolStandard))
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard), ErrCardNotAuthorized)
+
+	// Changes made through the service take effect immediately
+	_, err = service.UpdateDeviceGroup(ctx, "hotel_a", "floor-3", &models.DeviceGroup{Devices: []string{"SN-001"}})
+	require.NoError(t, err)
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard))
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-302", "GUEST-1", models.ProtocolStandard), ErrCardNotAuthorized)
+
+	// A deleted group grants nothing
+	require.NoError(t, service.DeleteDeviceGroup(ctx, "hotel_a", "floor-3"))
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard), ErrCardNotAuthorized)
+}
+
+func TestCardServiceVerifyCard_GroupCacheTTL(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	service.SetClock(fixedClock{now})
+	service.SetGroupCacheTTL(time.Minute)
+
+	_, err := service.CreateDeviceGroup(ctx, "hotel_a", &models.DeviceGroup{ID: "floor-3", Devices: []string{"SN-302"}})
+	require.NoError(t, err)
+	card := testCard("GUEST-1")
+	card.Groups = []string{"floor-3"}
+	_, err = service.CreateCard(ctx, "hotel_a", card)
+	require.NoError(t, err)
+	require.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-302", "GUEST-1", models.ProtocolStandard))
+
+	// A change written behind the service's back is only seen once the entry expires
+	require.NoError(t, service.repo.SaveDeviceGroup(ctx, "hotel_a", &models.DeviceGroup{ID: "floor-3"}))
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-302", "GUEST-1", models.ProtocolStandard))
+
+	service.SetClock(fixedClock{now.Add(time.Minute)})
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-302", "GUEST-1", models.ProtocolStandard), ErrCardNotAuthorized)
+}
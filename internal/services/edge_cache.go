@@ -0,0 +1,453 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"commander/internal/kv"
+	"commander/internal/models"
+)
+
+// ErrPrimaryUnavailable is returned by EdgeCache for operations that need the primary repository while it is offline
+var ErrPrimaryUnavailable = errors.New("primary repository unavailable")
+
+// Edge cache defaults
+const (
+	DefaultEdgeSyncInterval   = time.Minute
+	DefaultEdgePrimaryTimeout = 2 * time.Second
+
+	// edgeSyncPageSize is the page size used to read the primary during a sync
+	edgeSyncPageSize = 500
+
+	// edgeSyncKey stores the sync bookkeeping of a namespace in the local settings collection
+	edgeSyncKey = "edge_sync"
+)
+
+// EdgeCache is a Repository that keeps a local replica of the records card verification reads
+// (devices, cards, device groups, revocations and the namespace policy)
+//
+// Verification reads go to the primary with a short timeout. When the primary fails with anything but a
+// not-found error, the cache goes offline and serves those reads from the replica until the next successful
+// sync. Writes, presence and use counting always need the primary; while offline they fail with
+// ErrPrimaryUnavailable instead of waiting for it. Access log entries are kept in the replica while offline
+// and pushed to the primary by the next successful sync. Run refreshes the replica in the background
+type EdgeCache struct {
+	Repository // primary
+
+	local    *KVRepository
+	interval time.Duration
+	timeout  time.Duration
+	clock    Clock
+
+	mu           sync.Mutex
+	offlineSince time.Time // zero while the primary is reachable
+	lastError    string
+	namespaces   map[string]*EdgeSyncStatus
+}
+
+// EdgeSyncStatus is the sync bookkeeping of one namespace
+type EdgeSyncStatus struct {
+	Namespace   string    `json:"namespace"`
+	LastSync    time.Time `json:"last_sync"`
+	LastAttempt time.Time `json:"last_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	Devices     int       `json:"devices"`
+	Cards       int       `json:"cards"`
+}
+
+// EdgeCacheStatus describes the freshness of the replica, as reported on /health
+type EdgeCacheStatus struct {
+	// Online is false while reads are served from the replica
+	Online       bool       `json:"online"`
+	OfflineSince *time.Time `json:"offline_since,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	// Stale is true if a namespace has not synced within three sync intervals
+	Stale      bool                  `json:"stale"`
+	Namespaces []EdgeNamespaceStatus `json:"namespaces"`
+}
+
+// EdgeNamespaceStatus is EdgeSyncStatus with the age of the replica
+type EdgeNamespaceStatus struct {
+	EdgeSyncStatus
+	// StaleSeconds is the time since the last successful sync (-1 if the namespace never synced)
+	StaleSeconds int64 `json:"stale_seconds"`
+}
+
+// NewEdgeCache creates an edge cache in front of primary, replicating into the local store
+// namespaces are synced from the start; other namespaces are added when they are first verified against
+func NewEdgeCache(primary Repository, local kv.KV, namespaces []string, interval, timeout time.Duration) *EdgeCache {
+	if interval <= 0 {
+		interval = DefaultEdgeSyncInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultEdgePrimaryTimeout
+	}
+
+	e := &EdgeCache{
+		Repository: primary,
+		local:      NewKVRepository(local),
+		interval:   interval,
+		timeout:    timeout,
+		clock:      SystemClock,
+		namespaces: make(map[string]*EdgeSyncStatus),
+	}
+	for _, namespace := range namespaces {
+		e.track(namespace)
+	}
+	return e
+}
+
+// SetClock replaces the clock used for sync bookkeeping
+func (e *EdgeCache) SetClock(clock Clock) {
+	e.clock = clock
+}
+
+// Run loads the stored bookkeeping, then syncs every tracked namespace each interval until ctx is cancelled
+func (e *EdgeCache) Run(ctx context.Context) {
+	e.loadStatus(ctx)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.Sync(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync refreshes the replica of every tracked namespace
+// Failures are recorded in the namespace status and logged
+func (e *EdgeCache) Sync(ctx context.Context) {
+	for _, namespace := range e.trackedNamespaces() {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := e.SyncNamespace(ctx, namespace); err != nil {
+			log.Printf("[EdgeCache] Sync failed: namespace=%s, error=%v", namespace, err)
+		}
+	}
+}
+
+// SyncNamespace copies the devices, cards, device groups, revocations and policy of namespace from the
+// primary into the replica, removing local records the primary no longer has, and pushes the access log
+// entries kept while offline. A successful sync brings the cache back online
+func (e *EdgeCache) SyncNamespace(ctx context.Context, namespace string) error {
+	status := e.track(namespace)
+	now := e.clock.Now().UTC()
+
+	devices, cards, err := e.syncNamespace(ctx, namespace)
+
+	e.mu.Lock()
+	status.LastAttempt = now
+	if err != nil {
+		status.LastError = err.Error()
+	} else {
+		status.LastSync = now
+		status.LastError = ""
+		status.Devices = devices
+		status.Cards = cards
+		if !e.offlineSince.IsZero() {
+			log.Printf("[EdgeCache] Primary reachable again: namespace=%s, offline_since=%s",
+				namespace, e.offlineSince.Format(time.RFC3339))
+		}
+		e.offlineSince = time.Time{}
+		e.lastError = ""
+	}
+	saved := *status
+	e.mu.Unlock()
+
+	if err := e.saveStatus(ctx, &saved); err != nil {
+		log.Printf("[EdgeCache] Failed to save sync status: namespace=%s, error=%v", namespace, err)
+	}
+	return err
+}
+
+// syncNamespace mirrors namespace and returns the number of devices and cards replicated
+func (e *EdgeCache) syncNamespace(ctx context.Context, namespace string) (int, int, error) {
+	snapshot, err := readSnapshot(ctx, e.Repository, namespace)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := e.flushAccessLogs(ctx, namespace); err != nil {
+		return 0, 0, fmt.Errorf("failed to push access logs: %w", err)
+	}
+	if err := applySnapshot(ctx, e.local, namespace, snapshot); err != nil {
+		return 0, 0, err
+	}
+	return len(snapshot.devices), len(snapshot.cards), nil
+}
+
+// GetDeviceBySN reads the device from the primary, or from the replica while offline
+func (e *EdgeCache) GetDeviceBySN(ctx context.Context, namespace, sn string) (*models.Device, error) {
+	e.track(namespace)
+	return edgeRead(ctx, e, namespace, ErrDeviceNotFound, func(ctx context.Context, repo Repository) (*models.Device, error) {
+		return repo.GetDeviceBySN(ctx, namespace, sn)
+	})
+}
+
+// GetCardByNumber reads the card from the primary, or from the replica while offline
+func (e *EdgeCache) GetCardByNumber(ctx context.Context, namespace, number string) (*models.Card, error) {
+	return edgeRead(ctx, e, namespace, ErrCardNotFound, func(ctx context.Context, repo Repository) (*models.Card, error) {
+		return repo.GetCardByNumber(ctx, namespace, number)
+	})
+}
+
+// GetDeviceGroup reads the device group from the primary, or from the replica while offline
+func (e *EdgeCache) GetDeviceGroup(ctx context.Context, namespace, id string) (*models.DeviceGroup, error) {
+	return edgeRead(ctx, e, namespace, ErrDeviceGroupNotFound, func(ctx context.Context, repo Repository) (*models.DeviceGroup, error) {
+		return repo.GetDeviceGroup(ctx, namespace, id)
+	})
+}
+
+// GetRevocation reads the revocation from the primary, or from the replica while offline
+func (e *EdgeCache) GetRevocation(ctx context.Context, namespace, id string) (*models.Revocation, error) {
+	return edgeRead(ctx, e, namespace, ErrRevocationNotFound, func(ctx context.Context, repo Repository) (*models.Revocation, error) {
+		return repo.GetRevocation(ctx, namespace, id)
+	})
+}
+
+// GetPolicy reads the policy overrides from the primary, or from the replica while offline
+func (e *EdgeCache) GetPolicy(ctx context.Context, namespace string) (*models.NamespacePolicy, error) {
+	return edgeRead(ctx, e, namespace, nil, func(ctx context.Context, repo Repository) (*models.NamespacePolicy, error) {
+		return repo.GetPolicy(ctx, namespace)
+	})
+}
+
+// GetPresence reads the anti-passback state from the primary; fails while offline
+func (e *EdgeCache) GetPresence(ctx context.Context, namespace, cardID, zone string) (*models.Presence, error) {
+	var presence *models.Presence
+	err := e.primary(ctx, namespace, func(ctx context.Context) error {
+		var err error
+		presence, err = e.Repository.GetPresence(ctx, namespace, cardID, zone)
+		return err
+	})
+	return presence, err
+}
+
+// SavePresence stores the anti-passback state on the primary; fails while offline
+func (e *EdgeCache) SavePresence(ctx context.Context, namespace string, presence *models.Presence) error {
+	return e.primary(ctx, namespace, func(ctx context.Context) error {
+		return e.Repository.SavePresence(ctx, namespace, presence)
+	})
+}
+
+// ConsumeCardUse counts the use on the primary; fails while offline, as counts cannot be merged later
+func (e *EdgeCache) ConsumeCardUse(ctx context.Context, namespace, id string) (*models.Card, error) {
+	var card *models.Card
+	err := e.primary(ctx, namespace, func(ctx context.Context) error {
+		var err error
+		card, err = e.Repository.ConsumeCardUse(ctx, namespace, id)
+		return err
+	})
+	return card, err
+}
+
+// AppendAccessLog stores the entry on the primary, or in the replica while offline
+// Entries kept in the replica are pushed to the primary by the next successful sync
+func (e *EdgeCache) AppendAccessLog(ctx context.Context, namespace string, entry *models.AccessLog, retention time.Duration) error {
+	e.track(namespace)
+	err := e.primary(ctx, namespace, func(ctx context.Context) error {
+		return e.Repository.AppendAccessLog(ctx, namespace, entry, retention)
+	})
+	if err == nil || e.Online() {
+		return err
+	}
+
+	if err := e.local.AppendAccessLog(ctx, namespace, entry, retention); err != nil {
+		return fmt.Errorf("failed to keep access log in replica: %w", err)
+	}
+	return nil
+}
+
+// flushAccessLogs pushes the access log entries kept in the replica while offline to the primary, oldest first
+// Each entry is removed locally once the primary has it; a retried push of the same ID is not a duplicate
+func (e *EdgeCache) flushAccessLogs(ctx context.Context, namespace string) error {
+	for {
+		entries, _, err := e.local.ListAccessLogs(ctx, namespace, AccessLogQuery{Limit: edgeSyncPageSize})
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		// The remaining TTL of an entry in the replica gives back its retention
+		expiries, err := accessLogExpiries(ctx, e.local, namespace, entries)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for i, entry := range entries {
+			// Entries that expired in the replica are not pushed
+			var retention time.Duration
+			if !expiries[i].IsZero() {
+				retention = expiries[i].Sub(entry.Timestamp)
+			}
+			if expiries[i].IsZero() || expiries[i].After(now) {
+				if err := e.Repository.AppendAccessLog(ctx, namespace, entry, retention); err != nil {
+					return err
+				}
+			}
+			err := e.local.store.Delete(ctx, namespace, AccessLogsCollection, entry.ID)
+			if err != nil && !errors.Is(err, kv.ErrKeyNotFound) {
+				return err
+			}
+		}
+		log.Printf("[EdgeCache] Pushed access logs kept offline: namespace=%s, count=%d", namespace, len(entries))
+	}
+}
+
+// edgeRead runs read against the primary with the primary timeout and falls back to the replica
+// when the cache is offline or the primary fails with anything but notFound
+func edgeRead[T any](ctx context.Context, e *EdgeCache, namespace string, notFound error, read func(context.Context, Repository) (T, error)) (T, error) {
+	if e.Online() {
+		primaryCtx, cancel := context.WithTimeout(ctx, e.timeout)
+		value, err := read(primaryCtx, e.Repository)
+		cancel()
+		if err == nil || (notFound != nil && errors.Is(err, notFound)) || ctx.Err() != nil {
+			return value, err
+		}
+		e.goOffline(namespace, err)
+	}
+	return read(ctx, e.local)
+}
+
+// primary runs fn against the primary with the primary timeout, or fails with ErrPrimaryUnavailable while offline
+func (e *EdgeCache) primary(ctx context.Context, namespace string, fn func(context.Context) error) error {
+	if !e.Online() {
+		return ErrPrimaryUnavailable
+	}
+	primaryCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	err := fn(primaryCtx)
+	// Deadline of the primary call, not of the caller: the primary is unresponsive
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		e.goOffline(namespace, err)
+	}
+	return err
+}
+
+// Online reports whether reads currently go to the primary
+func (e *EdgeCache) Online() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.offlineSince.IsZero()
+}
+
+// MarkOffline serves reads from the replica until the next successful sync
+// Used when the instance starts while the primary is unreachable
+func (e *EdgeCache) MarkOffline(err error) {
+	e.goOffline("", err)
+}
+
+// goOffline switches reads to the replica until the next successful sync
+func (e *EdgeCache) goOffline(namespace string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastError = err.Error()
+	if e.offlineSince.IsZero() {
+		e.offlineSince = e.clock.Now().UTC()
+		log.Printf("[EdgeCache] Primary unavailable, serving from replica: namespace=%s, error=%v", namespace, err)
+	}
+}
+
+// Status returns the freshness of the replica
+func (e *EdgeCache) Status() EdgeCacheStatus {
+	now := e.clock.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status := EdgeCacheStatus{
+		Online:     e.offlineSince.IsZero(),
+		LastError:  e.lastError,
+		Namespaces: make([]EdgeNamespaceStatus, 0, len(e.namespaces)),
+	}
+	if !status.Online {
+		offlineSince := e.offlineSince
+		status.OfflineSince = &offlineSince
+	}
+	for _, ns := range e.namespaces {
+		entry := EdgeNamespaceStatus{EdgeSyncStatus: *ns, StaleSeconds: -1}
+		if !ns.LastSync.IsZero() {
+			entry.StaleSeconds = int64(now.Sub(ns.LastSync) / time.Second)
+		}
+		if ns.LastSync.IsZero() || now.Sub(ns.LastSync) > 3*e.interval {
+			status.Stale = true
+		}
+		status.Namespaces = append(status.Namespaces, entry)
+	}
+	sort.Slice(status.Namespaces, func(i, j int) bool {
+		return status.Namespaces[i].Namespace < status.Namespaces[j].Namespace
+	})
+	return status
+}
+
+// track adds namespace to the synced namespaces and returns its status
+func (e *EdgeCache) track(namespace string) *EdgeSyncStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status, ok := e.namespaces[namespace]
+	if !ok {
+		status = &EdgeSyncStatus{Namespace: namespace}
+		e.namespaces[namespace] = status
+	}
+	return status
+}
+
+// trackedNamespaces returns the synced namespaces in order
+func (e *EdgeCache) trackedNamespaces() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	namespaces := make([]string, 0, len(e.namespaces))
+	for namespace := range e.namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// loadStatus restores the bookkeeping of tracked namespaces from the replica, so a restarted
+// instance reports the age of the data it already has
+func (e *EdgeCache) loadStatus(ctx context.Context) {
+	for _, namespace := range e.trackedNamespaces() {
+		var stored EdgeSyncStatus
+		found, err := e.local.get(ctx, namespace, settingsCollection, edgeSyncKey, &stored)
+		if err != nil {
+			log.Printf("[EdgeCache] Failed to load sync status: namespace=%s, error=%v", namespace, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+
+		e.mu.Lock()
+		status := e.namespaces[namespace]
+		if status.LastAttempt.IsZero() {
+			*status = stored
+			status.Namespace = namespace
+		}
+		e.mu.Unlock()
+	}
+}
+
+// saveStatus stores the bookkeeping of a namespace in the replica
+func (e *EdgeCache) saveStatus(ctx context.Context, status *EdgeSyncStatus) error {
+	value, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return e.local.store.Set(ctx, status.Namespace, settingsCollection, edgeSyncKey, value)
+}
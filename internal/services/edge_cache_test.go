@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"commander/internal/database/memory"
+	"commander/internal/kv"
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errUplinkDown = errors.New("uplink down")
+
+// flakyKV is a KV store whose reads and writes fail while down is set
+type flakyKV struct {
+	kv.KV
+	down atomic.Bool
+}
+
+func (f *flakyKV) Get(ctx context.Context, namespace, collection, key string) ([]byte, error) {
+	if f.down.Load() {
+		return nil, errUplinkDown
+	}
+	return f.KV.Get(ctx, namespace, collection, key)
+}
+
+func (f *flakyKV) Set(ctx context.Context, namespace, collection, key string, value []byte) error {
+	if f.down.Load() {
+		return errUplinkDown
+	}
+	return f.KV.Set(ctx, namespace, collection, key, value)
+}
+
+func (f *flakyKV) SetWithTTL(ctx context.Context, namespace, collection, key string, value []byte, ttl time.Duration) error {
+	if f.down.Load() {
+		return errUplinkDown
+	}
+	return f.KV.SetWithTTL(ctx, namespace, collection, key, value, ttl)
+}
+
+func (f *flakyKV) List(ctx context.Context, namespace, collection string, opts kv.ListOptions) (*kv.ListResult, error) {
+	if f.down.Load() {
+		return nil, errUplinkDown
+	}
+	return f.KV.List(ctx, namespace, collection, opts)
+}
+
+// newTestEdgeCache returns an admin service writing to the primary, the edge cache in front of it
+// and the primary store, seeded with device SN-001 and card GUEST-1
+func newTestEdgeCache(t *testing.T) (*CardService, *EdgeCache, *flakyKV) {
+	t.Helper()
+	store, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	local, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = local.Close() })
+
+	primary := &flakyKV{KV: store}
+	admin := NewCardService(NewKVRepository(primary), Policy{})
+	ctx := context.Background()
+	_, err = admin.RegisterDevice(ctx, "hotel_a", &models.Device{ID: "device-1", DeviceID: "lobby", SN: "SN-001"})
+	require.NoError(t, err)
+	_, err = admin.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-001"))
+	require.NoError(t, err)
+
+	edge := NewEdgeCache(NewKVRepository(primary), local, []string{"hotel_a"}, time.Minute, time.Second)
+	return admin, edge, primary
+}
+
+func TestEdgeCache_OfflineFallback(t *testing.T) {
+	_, edge, primary := newTestEdgeCache(t)
+	service := NewCardService(edge, Policy{})
+	ctx := context.Background()
+
+	require.NoError(t, edge.SyncNamespace(ctx, "hotel_a"))
+	require.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard))
+	assert.True(t, edge.Online())
+
+	primary.down.Store(true)
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard))
+	assert.False(t, edge.Online())
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "UNKNOWN", models.ProtocolStandard), ErrCardNotFound)
+
+	// A failed sync keeps the replica and records the error
+	assert.Error(t, edge.SyncNamespace(ctx, "hotel_a"))
+	status := edge.Status()
+	assert.False(t, status.Online)
+	require.Len(t, status.Namespaces, 1)
+	assert.NotEmpty(t, status.Namespaces[0].LastError)
+	assert.Equal(t, 1, status.Namespaces[0].Cards)
+
+	// The next successful sync brings the primary back
+	primary.down.Store(false)
+	require.NoError(t, edge.SyncNamespace(ctx, "hotel_a"))
+	assert.True(t, edge.Online())
+}
+
+func TestEdgeCache_MarkOffline(t *testing.T) {
+	admin, edge, _ := newTestEdgeCache(t)
+	service := NewCardService(edge, Policy{})
+	ctx := context.Background()
+	require.NoError(t, edge.SyncNamespace(ctx, "hotel_a"))
+
+	// A card created after the last sync is only on the primary
+	_, err := admin.CreateCard(ctx, "hotel_a", testCard("GUEST-2", "SN-001"))
+	require.NoError(t, err)
+
+	// Starting offline serves the replica without trying the primary
+	edge.MarkOffline(errUplinkDown)
+	assert.False(t, edge.Online())
+	assert.Equal(t, errUplinkDown.Error(), edge.Status().LastError)
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard))
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-2", models.ProtocolStandard), ErrCardNotFound)
+
+	require.NoError(t, edge.SyncNamespace(ctx, "hotel_a"))
+	assert.True(t, edge.Online())
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-2", models.ProtocolStandard))
+}
+
+func TestEdgeCache_AccessLogOffline(t *testing.T) {
+	_, edge, primary := newTestEdgeCache(t)
+	service := NewCardService(edge, Policy{})
+	service.EnableAccessLog(time.Hour)
+	ctx := context.Background()
+	require.NoError(t, edge.SyncNamespace(ctx, "hotel_a"))
+
+	// Attempts verified from the replica are kept locally
+	primary.down.Store(true)
+	require.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard))
+	require.False(t, edge.Online())
+	kept, _, err := edge.local.ListAccessLogs(ctx, "hotel_a", AccessLogQuery{})
+	require.NoError(t, err)
+	require.Len(t, kept, 1)
+	assert.Equal(t, models.AccessGranted, kept[0].Outcome)
+
+	// The next successful sync pushes them to the primary and clears the replica
+	primary.down.Store(false)
+	require.NoError(t, edge.SyncNamespace(ctx, "hotel_a"))
+	assert.True(t, edge.Online())
+	pushed, _, err := NewKVRepository(primary).ListAccessLogs(ctx, "hotel_a", AccessLogQuery{})
+	require.NoError(t, err)
+	require.Len(t, pushed, 1)
+	assert.Equal(t, kept[0].ID, pushed[0].ID)
+	kept, _, err = edge.local.ListAccessLogs(ctx, "hotel_a", AccessLogQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, kept)
+}
+
+func TestEdgeCache_SyncMirrorsPrimary(t *testing.T) {
+	admin, edge, primary := newTestEdgeCache(t)
+	service := NewCardService(edge, Policy{})
+	ctx := context.Background()
+	require.NoError(t, edge.SyncNamespace(ctx, "hotel_a"))
+
+	limited := testCard("ONCE", "SN-001")
+	limited.MaxUses = 1
+	_, err := admin.CreateCard(ctx, "hotel_a", limited)
+	require.NoError(t, err)
+	removed, err := admin.CreateCard(ctx, "hotel_a", testCard("GUEST-2", "SN-001"))
+	require.NoError(t, err)
+	require.NoError(t, edge.SyncNamespace(ctx, "hotel_a"))
+
+	// Revocations and deletions reach the replica with the next sync
+	_, err = admin.Revoke(ctx, "hotel_a", &models.Revocation{CardNumber: "GUEST-1"})
+	require.NoError(t, err)
+	require.NoError(t, admin.DeleteCard(ctx, "hotel_a", removed.ID))
+	require.NoError(t, edge.SyncNamespace(ctx, "hotel_a"))
+
+	primary.down.Store(true)
+	tests := []struct {
+		name     string
+		number   string
+		expected error
+	}{
+		{"revoked card", "GUEST-1", ErrCardRevoked},
+		{"deleted card", "GUEST-2", ErrCardNotFound},
+		{"usage-limited card", "ONCE", ErrPrimaryUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.VerifyCard(ctx, "hotel_a", "SN-001", tt.number, models.ProtocolStandard)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
+
+func TestEdgeCache_Status(t *testing.T) {
+	_, edge, _ := newTestEdgeCache(t)
+	ctx := context.Background()
+	synced := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	status := edge.Status()
+	assert.True(t, status.Stale)
+	require.Len(t, status.Namespaces, 1)
+	assert.Equal(t, int64(-1), status.Namespaces[0].StaleSeconds)
+
+	edge.SetClock(fixedClock{synced})
+	require.NoError(t, edge.SyncNamespace(ctx, "hotel_a"))
+
+	edge.SetClock(fixedClock{synced.Add(90 * time.Second)})
+	status = edge.Status()
+	assert.False(t, status.Stale)
+	assert.Equal(t, int64(90), status.Namespaces[0].StaleSeconds)
+	assert.Equal(t, 1, status.Namespaces[0].Devices)
+
+	edge.SetClock(fixedClock{synced.Add(time.Hour)})
+	assert.True(t, edge.Status().Stale)
+
+	// A restarted cache reports the stored bookkeeping
+	restarted := NewEdgeCache(edge.Repository, edge.local.store, []string{"hotel_a"}, time.Minute, time.Second)
+	restarted.loadStatus(ctx)
+	assert.Equal(t, synced, restarted.Status().Namespaces[0].LastSync)
+}
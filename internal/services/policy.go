@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"commander/internal/models"
+)
+
+// ErrInvalidPolicy is returned by SetPolicy for out-of-range overrides
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// MaxClockToleranceSeconds is the largest accepted clock tolerance
+const MaxClockToleranceSeconds = 3600
+
+// Policy is the effective card verification policy of a namespace
+type Policy struct {
+	// RequireActiveDevice rejects devices whose status is not "active" with ErrDeviceNotActive
+	RequireActiveDevice bool `json:"require_active_device"`
+
+	// ClockToleranceSeconds widens the card validity window on both ends to absorb device clock drift
+	ClockToleranceSeconds int `json:"clock_tolerance_seconds"`
+
+	// AntiPassback is the anti-passback mode (models.AntiPassbackOff, Soft or Hard; "" = off)
+	AntiPassback string `json:"anti_passback"`
+}
+
+// ClockTolerance returns the clock tolerance as a duration
+func (p Policy) ClockTolerance() time.Duration {
+	return time.Duration(p.ClockToleranceSeconds) * time.Second
+}
+
+// Merge returns p with the non-nil overrides applied
+func (p Policy) Merge(overrides *models.NamespacePolicy) Policy {
+	if overrides == nil {
+		return p
+	}
+	if overrides.RequireActiveDevice != nil {
+		p.RequireActiveDevice = *overrides.RequireActiveDevice
+	}
+	if overrides.ClockToleranceSeconds != nil {
+		p.ClockToleranceSeconds = *overrides.ClockToleranceSeconds
+	}
+	if overrides.AntiPassback != nil {
+		p.AntiPassback = *overrides.AntiPassback
+	}
+	return p
+}
+
+// Policy returns the effective policy of namespace and its stored overrides (nil if none)
+func (s *CardService) Policy(ctx context.Context, namespace string) (Policy, *models.NamespacePolicy, error) {
+	overrides, err := s.repo.GetPolicy(ctx, namespace)
+	if err != nil {
+		return Policy{}, nil, err
+	}
+	return s.defaults.Merge(overrides), overrides, nil
+}
+
+// SetPolicy replaces the overrides of namespace and returns the new effective policy
+// Returns ErrInvalidPolicy if an override is out of range
+func (s *CardService) SetPolicy(ctx context.Context, namespace string, overrides *models.NamespacePolicy) (Policy, error) {
+	if tolerance := overrides.ClockToleranceSeconds; tolerance != nil && (*tolerance < 0 || *tolerance > MaxClockToleranceSeconds) {
+		return Policy{}, fmt.Errorf("%w: clock_tolerance_seconds must be between 0 and %d", ErrInvalidPolicy, MaxClockToleranceSeconds)
+	}
+	if mode := overrides.AntiPassback; mode != nil && !models.IsValidAntiPassbackMode(*mode) {
+		return Policy{}, fmt.Errorf("%w: anti_passback must be off, soft or hard", ErrInvalidPolicy)
+	}
+
+	overrides.UpdatedAt = s.clock.Now().UTC()
+	if err := s.repo.SavePolicy(ctx, namespace, overrides); err != nil {
+		return Policy{}, err
+	}
+	return s.defaults.Merge(overrides), nil
+}
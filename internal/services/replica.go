@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"commander/internal/models"
+)
+
+// replicaPageSize is the page size used to read a repository when copying it
+const replicaPageSize = 500
+
+// replicaSnapshot holds the records card verification reads in one namespace
+type replicaSnapshot struct {
+	devices     []*models.Device
+	cards       []*models.Card
+	groups      []*models.DeviceGroup
+	revocations []*models.Revocation
+	policy      *models.NamespacePolicy
+}
+
+// readSnapshot reads the devices, cards, device groups, revocations and policy of namespace from repo
+func readSnapshot(ctx context.Context, repo Repository, namespace string) (*replicaSnapshot, error) {
+	var snapshot replicaSnapshot
+	var err error
+	snapshot.devices, err = listAll(func(cursor string) ([]*models.Device, string, error) {
+		return repo.ListDevices(ctx, namespace, DeviceQuery{Cursor: cursor, Limit: replicaPageSize})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read devices: %w", err)
+	}
+	snapshot.cards, err = listAll(func(cursor string) ([]*models.Card, string, error) {
+		return repo.ListCards(ctx, namespace, CardQuery{Cursor: cursor, Limit: replicaPageSize})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cards: %w", err)
+	}
+	snapshot.groups, err = listAll(func(cursor string) ([]*models.DeviceGroup, string, error) {
+		return repo.ListDeviceGroups(ctx, namespace, DeviceGroupQuery{Cursor: cursor, Limit: replicaPageSize})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device groups: %w", err)
+	}
+	snapshot.revocations, err = listAll(func(cursor string) ([]*models.Revocation, string, error) {
+		return repo.ListRevocations(ctx, namespace, RevocationQuery{Cursor: cursor, Limit: replicaPageSize})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revocations: %w", err)
+	}
+	snapshot.policy, err = repo.GetPolicy(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// applySnapshot makes the records of namespace in local equal to snapshot
+func applySnapshot(ctx context.Context, local *KVRepository, namespace string, snapshot *replicaSnapshot) error {
+	// Revocations first, so a revoked card never passes on the replica in between
+	if err := mirrorRevocations(ctx, local, namespace, snapshot.revocations); err != nil {
+		return fmt.Errorf("failed to replicate revocations: %w", err)
+	}
+	if err := mirrorDevices(ctx, local, namespace, snapshot.devices); err != nil {
+		return fmt.Errorf("failed to replicate devices: %w", err)
+	}
+	if err := mirrorDeviceGroups(ctx, local, namespace, snapshot.groups); err != nil {
+		return fmt.Errorf("failed to replicate device groups: %w", err)
+	}
+	if err := mirrorCards(ctx, local, namespace, snapshot.cards); err != nil {
+		return fmt.Errorf("failed to replicate cards: %w", err)
+	}
+	if err := replicatePolicy(ctx, local, namespace, snapshot.policy); err != nil {
+		return fmt.Errorf("failed to replicate policy: %w", err)
+	}
+	return nil
+}
+
+// mirrorDevices makes the local devices of namespace equal to devices
+func mirrorDevices(ctx context.Context, local *KVRepository, namespace string, devices []*models.Device) error {
+	existing, err := listAll(func(cursor string) ([]*models.Device, string, error) {
+		return local.ListDevices(ctx, namespace, DeviceQuery{Cursor: cursor, Limit: replicaPageSize})
+	})
+	if err != nil {
+		return err
+	}
+	return mirror(existing, devices, func(d *models.Device) string { return d.ID },
+		func(device *models.Device) error { return replicateDevice(ctx, local, namespace, device) },
+		func(device *models.Device) error { return local.DeleteDevice(ctx, namespace, device.ID) })
+}
+
+// mirrorCards makes the local cards of namespace equal to cards
+func mirrorCards(ctx context.Context, local *KVRepository, namespace string, cards []*models.Card) error {
+	existing, err := listAll(func(cursor string) ([]*models.Card, string, error) {
+		return local.ListCards(ctx, namespace, CardQuery{Cursor: cursor, Limit: replicaPageSize})
+	})
+	if err != nil {
+		return err
+	}
+	return mirror(existing, cards, func(c *models.Card) string { return c.ID },
+		func(card *models.Card) error { return replicateCard(ctx, local, namespace, card) },
+		func(card *models.Card) error { return local.DeleteCard(ctx, namespace, card.ID) })
+}
+
+// mirrorDeviceGroups makes the local device groups of namespace equal to groups
+func mirrorDeviceGroups(ctx context.Context, local *KVRepository, namespace string, groups []*models.DeviceGroup) error {
+	existing, err := listAll(func(cursor string) ([]*models.DeviceGroup, string, error) {
+		return local.ListDeviceGroups(ctx, namespace, DeviceGroupQuery{Cursor: cursor, Limit: replicaPageSize})
+	})
+	if err != nil {
+		return err
+	}
+	return mirror(existing, groups, func(g *models.DeviceGroup) string { return g.ID },
+		func(group *models.DeviceGroup) error { return local.SaveDeviceGroup(ctx, namespace, group) },
+		func(group *models.DeviceGroup) error { return local.DeleteDeviceGroup(ctx, namespace, group.ID) })
+}
+
+// mirrorRevocations makes the local revocations of namespace equal to revocations
+func mirrorRevocations(ctx context.Context, local *KVRepository, namespace string, revocations []*models.Revocation) error {
+	existing, err := listAll(func(cursor string) ([]*models.Revocation, string, error) {
+		return local.ListRevocations(ctx, namespace, RevocationQuery{Cursor: cursor, Limit: replicaPageSize})
+	})
+	if err != nil {
+		return err
+	}
+	return mirror(existing, revocations, func(r *models.Revocation) string { return r.ID },
+		func(revocation *models.Revocation) error { return local.SaveRevocation(ctx, namespace, revocation) },
+		func(revocation *models.Revocation) error {
+			return local.DeleteRevocation(ctx, namespace, revocation.ID)
+		})
+}
+
+// replicateDevice saves a device read from the primary
+func replicateDevice(ctx context.Context, local *KVRepository, namespace string, device *models.Device) error {
+	err := local.SaveDevice(ctx, namespace, device)
+	if !errors.Is(err, ErrDeviceSNExists) {
+		return err
+	}
+	// The SN moved to this device on the primary; drop the local device still holding it
+	owner, err := local.GetDeviceBySN(ctx, namespace, device.SN)
+	if err != nil {
+		return err
+	}
+	if err := local.DeleteDevice(ctx, namespace, owner.ID); err != nil {
+		return err
+	}
+	return local.SaveDevice(ctx, namespace, device)
+}
+
+// replicateCard saves a card read from the primary
+func replicateCard(ctx context.Context, local *KVRepository, namespace string, card *models.Card) error {
+	err := local.SaveCard(ctx, namespace, card)
+	if !errors.Is(err, ErrCardNumberExists) {
+		return err
+	}
+	// The number moved to this card on the primary; drop the local card still holding it
+	owner, err := local.GetCardByNumber(ctx, namespace, card.Number)
+	if err != nil {
+		return err
+	}
+	if err := local.DeleteCard(ctx, namespace, owner.ID); err != nil {
+		return err
+	}
+	return local.SaveCard(ctx, namespace, card)
+}
+
+// replicatePolicy saves the policy overrides read from the primary
+// Without stored overrides the namespace uses the defaults, which an empty policy merges to
+func replicatePolicy(ctx context.Context, local *KVRepository, namespace string, policy *models.NamespacePolicy) error {
+	if policy == nil {
+		policy = &models.NamespacePolicy{}
+	}
+	return local.SavePolicy(ctx, namespace, policy)
+}
+
+// mirror removes the local records missing from primary, then saves the primary records that differ locally
+func mirror[T any](local, primary []*T, id func(*T) string, save, remove func(*T) error) error {
+	wanted := make(map[string]*T, len(primary))
+	for _, record := range primary {
+		wanted[id(record)] = record
+	}
+
+	existing := make(map[string][]byte, len(local))
+	for _, record := range local {
+		if _, ok := wanted[id(record)]; !ok {
+			if err := remove(record); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		existing[id(record)] = value
+	}
+
+	for _, record := range primary {
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if current, ok := existing[id(record)]; ok && string(current) == string(value) {
+			continue
+		}
+		if err := save(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listAll reads every page of a paged list function
+func listAll[T any](list func(cursor string) ([]*T, string, error)) ([]*T, error) {
+	var records []*T
+	cursor := ""
+	for {
+		page, next, err := list(cursor)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, page...)
+		if next == "" {
+			return records, nil
+		}
+		cursor = next
+	}
+}
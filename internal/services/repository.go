@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"commander/internal/models"
+)
+
+// Repository stores the devices and cards used by card verification
+// Implementations return ErrDeviceNotFound, ErrCardNotFound, ErrDeviceGroupNotFound and ErrRevocationNotFound
+// for missing records
+type Repository interface {
+	// GetDeviceBySN retrieves a device by serial number
+	GetDeviceBySN(ctx context.Context, namespace, sn string) (*models.Device, error)
+
+	// GetDevice retrieves a device by ID
+	GetDevice(ctx context.Context, namespace, id string) (*models.Device, error)
+
+	// ListDevices returns devices matching query ordered by ID, and the cursor of the next page ("" on the last page)
+	ListDevices(ctx context.Context, namespace string, query DeviceQuery) ([]*models.Device, string, error)
+
+	// SaveDevice creates or replaces a device by ID
+	// Returns ErrDeviceSNExists if another device already uses the same SN
+	SaveDevice(ctx context.Context, namespace string, device *models.Device) error
+
+	// DeleteDevice removes a device by ID
+	DeleteDevice(ctx context.Context, namespace, id string) error
+
+	// GetCardByNumber retrieves a card by card number
+	GetCardByNumber(ctx context.Context, namespace, number string) (*models.Card, error)
+
+	// GetCard retrieves a card by ID
+	GetCard(ctx context.Context, namespace, id string) (*models.Card, error)
+
+	// ListCards returns cards matching query ordered by ID, and the cursor of the next page ("" on the last page)
+	ListCards(ctx context.Context, namespace string, query CardQuery) ([]*models.Card, string, error)
+
+	// SaveCard creates or replaces a card by ID
+	// Returns ErrCardNumberExists if another card already uses the same number
+	SaveCard(ctx context.Context, namespace string, card *models.Card) error
+
+	// DeleteCard removes a card by ID
+	DeleteCard(ctx context.Context, namespace, id string) error
+
+	// ConsumeCardUse atomically increments the UseCount of a usage-limited card and returns the updated card
+	// Returns ErrCardUsageExhausted, without writing, if UseCount already reached MaxUses
+	ConsumeCardUse(ctx context.Context, namespace, id string) (*models.Card, error)
+
+	// GetDeviceGroup retrieves a device group by ID
+	GetDeviceGroup(ctx context.Context, namespace, id string) (*models.DeviceGroup, error)
+
+	// ListDeviceGroups returns groups matching query ordered by ID, and the cursor of the next page ("" on the last page)
+	ListDeviceGroups(ctx context.Context, namespace string, query DeviceGroupQuery) ([]*models.DeviceGroup, string, error)
+
+	// SaveDeviceGroup creates or replaces a device group by ID
+	SaveDeviceGroup(ctx context.Context, namespace string, group *models.DeviceGroup) error
+
+	// DeleteDeviceGroup removes a device group by ID
+	DeleteDeviceGroup(ctx context.Context, namespace, id string) error
+
+	// GetRevocation retrieves a revocation by ID (see models.RevocationID)
+	GetRevocation(ctx context.Context, namespace, id string) (*models.Revocation, error)
+
+	// ListRevocations returns revocations ordered by ID, and the cursor of the next page ("" on the last page)
+	ListRevocations(ctx context.Context, namespace string, query RevocationQuery) ([]*models.Revocation, string, error)
+
+	// SaveRevocation creates or replaces a revocation by ID
+	SaveRevocation(ctx context.Context, namespace string, revocation *models.Revocation) error
+
+	// DeleteRevocation removes a revocation by ID
+	DeleteRevocation(ctx context.Context, namespace, id string) error
+
+	// GetPresence retrieves the anti-passback state of a card in a zone (nil if none is stored)
+	GetPresence(ctx context.Context, namespace, cardID, zone string) (*models.Presence, error)
+
+	// ListPresence returns the anti-passback state of a card in every zone, ordered by zone
+	ListPresence(ctx context.Context, namespace, cardID string) ([]*models.Presence, error)
+
+	// SavePresence creates or replaces the anti-passback state of a card in a zone
+	SavePresence(ctx context.Context, namespace string, presence *models.Presence) error
+
+	// DeletePresence removes the anti-passback state of a card in a zone; a missing state is not an error
+	DeletePresence(ctx context.Context, namespace, cardID, zone string) error
+
+	// GetPolicy retrieves the policy overrides of a namespace (nil if none are stored)
+	GetPolicy(ctx context.Context, namespace string) (*models.NamespacePolicy, error)
+
+	// SavePolicy replaces the policy overrides of a namespace
+	SavePolicy(ctx context.Context, namespace string, policy *models.NamespacePolicy) error
+
+	// AppendAccessLog stores a new access log entry that expires after retention (<= 0 means never)
+	AppendAccessLog(ctx context.Context, namespace string, entry *models.AccessLog, retention time.Duration) error
+
+	// ListAccessLogs returns entries matching query ordered by ID (oldest first), and the cursor of the next page
+	ListAccessLogs(ctx context.Context, namespace string, query AccessLogQuery) ([]*models.AccessLog, string, error)
+
+	// AppendChange assigns the next revision of the namespace change feed to change and stores it
+	AppendChange(ctx context.Context, namespace string, change *models.Change) error
+
+	// ListChanges returns up to limit changes with a revision greater than since, ordered by revision
+	ListChanges(ctx context.Context, namespace string, since int64, limit int) ([]*models.Change, error)
+
+	// ChangeRevision returns the last revision assigned by AppendChange (0 if none)
+	ChangeRevision(ctx context.Context, namespace string) (int64, error)
+
+	// GetSyncState retrieves the upstream sync progress of a namespace (nil if none is stored)
+	GetSyncState(ctx context.Context, namespace string) (*models.SyncState, error)
+
+	// SaveSyncState replaces the upstream sync progress of a namespace
+	SaveSyncState(ctx context.Context, namespace string, state *models.SyncState) error
+}
+
+// DeviceQuery filters and pages ListDevices results
+type DeviceQuery struct {
+	// Status restricts the result to devices with this status
+	Status string
+	// Cursor resumes listing after this device ID
+	Cursor string
+	// Limit is the maximum number of devices returned (<= 0 means DefaultDeviceListLimit)
+	Limit int
+}
+
+// DefaultDeviceListLimit is the page size used when DeviceQuery.Limit is not set
+const DefaultDeviceListLimit = 100
+
+// limit returns the effective page size
+func (q DeviceQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultDeviceListLimit
+	}
+	return q.Limit
+}
+
+// Matches reports whether device passes the query filters (Cursor and Limit are not considered)
+func (q DeviceQuery) Matches(device *models.Device) bool {
+	return q.Status == "" || device.Status == q.Status
+}
+
+// DeviceGroupQuery filters and pages ListDeviceGroups results
+type DeviceGroupQuery struct {
+	// Device restricts the result to groups containing this device SN
+	Device string
+	// Cursor resumes listing after this group ID
+	Cursor string
+	// Limit is the maximum number of groups returned (<= 0 means DefaultDeviceGroupListLimit)
+	Limit int
+}
+
+// DefaultDeviceGroupListLimit is the page size used when DeviceGroupQuery.Limit is not set
+const DefaultDeviceGroupListLimit = 100
+
+// limit returns the effective page size
+func (q DeviceGroupQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultDeviceGroupListLimit
+	}
+	return q.Limit
+}
+
+// Matches reports whether group passes the query filters (Cursor and Limit are not considered)
+func (q DeviceGroupQuery) Matches(group *models.DeviceGroup) bool {
+	return q.Device == "" || group.HasDevice(q.Device)
+}
+
+// RevocationQuery pages ListRevocations results
+type RevocationQuery struct {
+	// Cursor resumes listing after this revocation ID
+	Cursor string
+	// Limit is the maximum number of revocations returned (<= 0 means DefaultRevocationListLimit)
+	Limit int
+}
+
+// DefaultRevocationListLimit is the page size used when RevocationQuery.Limit is not set
+const DefaultRevocationListLimit = 100
+
+// limit returns the effective page size
+func (q RevocationQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultRevocationListLimit
+	}
+	return q.Limit
+}
+
+// CardQuery filters and pages ListCards results
+type CardQuery struct {
+	// Device restricts the result to cards listing this device SN (or device_id)
+	Device string
+	// Number restricts the result to card numbers starting with this prefix
+	Number string
+	// ValidAt restricts the result to cards with EffectiveAt <= ValidAt < InvalidAt (zero = any time)
+	ValidAt time.Time
+	// Revoked restricts the result to revoked (true) or non-revoked (false) cards
+	Revoked *bool
+	// Cursor resumes listing after this card ID
+	Cursor string
+	// Limit is the maximum number of cards returned (<= 0 means DefaultCardListLimit)
+	Limit int
+}
+
+// DefaultCardListLimit is the page size used when CardQuery.Limit is not set
+const DefaultCardListLimit = 100
+
+// limit returns the effective page size
+func (q CardQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultCardListLimit
+	}
+	return q.Limit
+}
+
+// Matches reports whether card passes the query filters (Cursor and Limit are not considered)
+func (q CardQuery) Matches(card *models.Card) bool {
+	if q.Device != "" && !card.HasDevice(q.Device) {
+		return false
+	}
+	if !strings.HasPrefix(card.Number, q.Number) {
+		return false
+	}
+	if !q.ValidAt.IsZero() && (q.ValidAt.Before(card.EffectiveAt) || !q.ValidAt.Before(card.InvalidAt)) {
+		return false
+	}
+	if q.Revoked != nil && *q.Revoked != (card.RevokedAt != nil) {
+		return false
+	}
+	return true
+}
+
+// AccessLogQuery filters and pages ListAccessLogs results
+type AccessLogQuery struct {
+	// DeviceSN restricts the result to attempts on this device
+	DeviceSN string
+	// Card restricts the result to attempts with this card number or card ID
+	Card string
+	// Outcome restricts the result to models.AccessGranted or models.AccessDenied attempts
+	Outcome string
+	// From and To restrict the result to From <= Timestamp < To (zero = unbounded)
+	From time.Time
+	To   time.Time
+	// Cursor resumes listing after this entry ID
+	Cursor string
+	// Limit is the maximum number of entries returned (<= 0 means DefaultAccessLogListLimit)
+	Limit int
+}
+
+// DefaultAccessLogListLimit is the page size used when AccessLogQuery.Limit is not set
+const DefaultAccessLogListLimit = 100
+
+// limit returns the effective page size
+func (q AccessLogQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultAccessLogListLimit
+	}
+	return q.Limit
+}
+
+// Matches reports whether entry passes the query filters (Cursor and Limit are not considered)
+func (q AccessLogQuery) Matches(entry *models.AccessLog) bool {
+	if q.DeviceSN != "" && entry.DeviceSN != q.DeviceSN {
+		return false
+	}
+	if q.Card != "" && entry.CardNumber != q.Card && entry.CardID != q.Card {
+		return false
+	}
+	if q.Outcome != "" && entry.Outcome != q.Outcome {
+		return false
+	}
+	if !q.From.IsZero() && entry.Timestamp.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !entry.Timestamp.Before(q.To) {
+		return false
+	}
+	return true
+}
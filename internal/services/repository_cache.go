@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"commander/internal/database/cache"
+	"commander/internal/models"
+)
+
+// repositoryInvalidationChannel is the pub/sub channel CachingRepository instances announce writes on
+const repositoryInvalidationChannel = "commander:repocache:invalidate"
+
+// CachingRepositoryOptions configures a CachingRepository
+type CachingRepositoryOptions struct {
+	// TTL is how long a device or card is served from memory (cache.DefaultTTL if <= 0)
+	TTL time.Duration
+
+	// MaxEntries bounds the number of cached records (cache.DefaultMaxEntries if <= 0)
+	MaxEntries int
+
+	// RedisURI announces writes to every instance using the same Redis, which drop the affected
+	// records right away (empty = other instances see writes after at most TTL)
+	RedisURI string
+}
+
+// CachingRepository is a Repository that keeps the devices and cards read by card verification
+// (GetDeviceBySN and GetCardByNumber) in memory, for repositories that cannot sit behind the KV
+// cache such as MongoRepository. Every other read goes to the wrapped repository; revocations in
+// particular are never cached, so they take effect on every instance immediately
+//
+// A device write drops every cached device of the namespace and a card write every cached card,
+// since the SN or number a record was cached under may have changed. Not found results are not cached
+type CachingRepository struct {
+	Repository
+	ttl        time.Duration
+	maxEntries int
+	clock      Clock
+	bus        *cache.InvalidationBus
+
+	mu      sync.Mutex
+	entries map[string]repositoryCacheEntry
+	// generation increases on every invalidation; a read only fills the cache if it did not change
+	// meanwhile, so a record read before a concurrent write is never cached after it
+	generation uint64
+}
+
+// repositoryCacheEntry is a cached device or card
+type repositoryCacheEntry struct {
+	device    *models.Device
+	card      *models.Card
+	expiresAt time.Time
+}
+
+// Cache key kinds
+const (
+	cachedDevice = "device"
+	cachedCard   = "card"
+)
+
+// NewCachingRepository wraps repo with an in-memory cache of devices and cards
+func NewCachingRepository(repo Repository, opts CachingRepositoryOptions) (*CachingRepository, error) {
+	if opts.TTL <= 0 {
+		opts.TTL = cache.DefaultTTL
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = cache.DefaultMaxEntries
+	}
+
+	r := &CachingRepository{
+		Repository: repo,
+		ttl:        opts.TTL,
+		maxEntries: opts.MaxEntries,
+		clock:      SystemClock,
+		entries:    make(map[string]repositoryCacheEntry),
+	}
+	if opts.RedisURI != "" {
+		bus, err := cache.NewInvalidationBus(opts.RedisURI, repositoryInvalidationChannel, r.drop)
+		if err != nil {
+			return nil, err
+		}
+		r.bus = bus
+	}
+	return r, nil
+}
+
+// Close stops listening for writes made by other instances
+func (r *CachingRepository) Close() error {
+	if r.bus == nil {
+		return nil
+	}
+	return r.bus.Close()
+}
+
+// GetDeviceBySN returns the cached device, or reads it from the wrapped repository
+func (r *CachingRepository) GetDeviceBySN(ctx context.Context, namespace, sn string) (*models.Device, error) {
+	key := repositoryCacheKey(cachedDevice, namespace, sn)
+	if entry, ok := r.lookup(key); ok {
+		return entry.device, nil
+	}
+
+	generation := r.currentGeneration()
+	device, err := r.Repository.GetDeviceBySN(ctx, namespace, sn)
+	if err != nil {
+		return nil, err
+	}
+	r.fill(generation, key, repositoryCacheEntry{device: device})
+	return device, nil
+}
+
+// GetCardByNumber returns the cached card, or reads it from the wrapped repository
+func (r *CachingRepository) GetCardByNumber(ctx context.Context, namespace, number string) (*models.Card, error) {
+	key := repositoryCacheKey(cachedCard, namespace, number)
+	if entry, ok := r.lookup(key); ok {
+		return entry.card, nil
+	}
+
+	generation := r.currentGeneration()
+	card, err := r.Repository.GetCardByNumber(ctx, namespace, number)
+	if err != nil {
+		return nil, err
+	}
+	r.fill(generation, key, repositoryCacheEntry{card: card})
+	return card, nil
+}
+
+// SaveDevice stores the device and drops the cached devices of the namespace
+func (r *CachingRepository) SaveDevice(ctx context.Context, namespace string, device *models.Device) error {
+	defer r.invalidate(ctx, cache.Invalidation{Prefix: repositoryCacheKey(cachedDevice, namespace, "")})
+	return r.Repository.SaveDevice(ctx, namespace, device)
+}
+
+// DeleteDevice removes the device and drops the cached devices of the namespace
+func (r *CachingRepository) DeleteDevice(ctx context.Context, namespace, id string) error {
+	defer r.invalidate(ctx, cache.Invalidation{Prefix: repositoryCacheKey(cachedDevice, namespace, "")})
+	return r.Repository.DeleteDevice(ctx, namespace, id)
+}
+
+// SaveCard stores the card (including revoking it) and drops the cached cards of the namespace
+func (r *CachingRepository) SaveCard(ctx context.Context, namespace string, card *models.Card) error {
+	defer r.invalidate(ctx, cache.Invalidation{Prefix: repositoryCacheKey(cachedCard, namespace, "")})
+	return r.Repository.SaveCard(ctx, namespace, card)
+}
+
+// DeleteCard removes the card and drops the cached cards of the namespace
+func (r *CachingRepository) DeleteCard(ctx context.Context, namespace, id string) error {
+	defer r.invalidate(ctx, cache.Invalidation{Prefix: repositoryCacheKey(cachedCard, namespace, "")})
+	return r.Repository.DeleteCard(ctx, namespace, id)
+}
+
+// ConsumeCardUse counts the use and drops the cached card, whose number does not change
+func (r *CachingRepository) ConsumeCardUse(ctx context.Context, namespace, id string) (*models.Card, error) {
+	card, err := r.Repository.ConsumeCardUse(ctx, namespace, id)
+	if card != nil {
+		r.invalidate(ctx, cache.Invalidation{Keys: []string{repositoryCacheKey(cachedCard, namespace, card.Number)}})
+	}
+	return card, err
+}
+
+// repositoryCacheKey joins the parts with NUL bytes, which keeps kinds and namespaces apart
+func repositoryCacheKey(kind, namespace, value string) string {
+	return kind + "\x00" + namespace + "\x00" + value
+}
+
+// lookup returns a live cached entry
+func (r *CachingRepository) lookup(key string) (repositoryCacheEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[key]
+	if !ok || !r.clock.Now().Before(entry.expiresAt) {
+		return repositoryCacheEntry{}, false
+	}
+	return entry, true
+}
+
+// currentGeneration returns the invalidation counter before a read of the wrapped repository
+func (r *CachingRepository) currentGeneration() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generation
+}
+
+// fill caches a read unless a record was invalidated since generation
+// When the cache is full, expired entries are dropped first and the record is not cached if none were
+func (r *CachingRepository) fill(generation uint64, key string, entry repositoryCacheEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.generation != generation {
+		return
+	}
+
+	now := r.clock.Now()
+	if len(r.entries) >= r.maxEntries {
+		for k, e := range r.entries {
+			if !now.Before(e.expiresAt) {
+				delete(r.entries, k)
+			}
+		}
+		if len(r.entries) >= r.maxEntries {
+			return
+		}
+	}
+	entry.expiresAt = now.Add(r.ttl)
+	r.entries[key] = entry
+}
+
+// invalidate drops records after a write and tells the other instances to drop them too
+func (r *CachingRepository) invalidate(ctx context.Context, inv cache.Invalidation) {
+	r.drop(inv)
+	if r.bus == nil {
+		return
+	}
+	if err := r.bus.Publish(context.WithoutCancel(ctx), inv); err != nil {
+		log.Printf("[RepositoryCache] Invalidation broadcast failed: keys=%d, prefix=%q, error=%v",
+			len(inv.Keys), inv.Prefix, err)
+	}
+}
+
+// drop removes invalidated records, whether written here or announced by another instance
+func (r *CachingRepository) drop(inv cache.Invalidation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	for _, key := range inv.Keys {
+		delete(r.entries, key)
+	}
+	if inv.Prefix != "" {
+		for key := range r.entries {
+			if strings.HasPrefix(key, inv.Prefix) {
+				delete(r.entries, key)
+			}
+		}
+	}
+}
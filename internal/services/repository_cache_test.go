@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"commander/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRepository counts the device and card lookups that reach the wrapped repository
+type countingRepository struct {
+	Repository
+	deviceReads int
+	cardReads   int
+}
+
+func (c *countingRepository) GetDeviceBySN(ctx context.Context, namespace, sn string) (*models.Device, error) {
+	c.deviceReads++
+	return c.Repository.GetDeviceBySN(ctx, namespace, sn)
+}
+
+func (c *countingRepository) GetCardByNumber(ctx context.Context, namespace, number string) (*models.Card, error) {
+	c.cardReads++
+	return c.Repository.GetCardByNumber(ctx, namespace, number)
+}
+
+// newTestCachingRepository returns a caching repository over a counting KV repository with device SN-001
+func newTestCachingRepository(t *testing.T, opts CachingRepositoryOptions) (*CachingRepository, *countingRepository) {
+	t.Helper()
+	repo, _ := newTestKVRepository(t, true)
+	require.NoError(t, repo.SaveDevice(context.Background(), "hotel_a", &models.Device{ID: "device-1", SN: "SN-001"}))
+
+	counting := &countingRepository{Repository: repo}
+	cached, err := NewCachingRepository(counting, opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cached.Close() })
+	return cached, counting
+}
+
+func TestCachingRepository_VerificationReadsAreCached(t *testing.T) {
+	cached, counting := newTestCachingRepository(t, CachingRepositoryOptions{TTL: time.Minute})
+	service := NewCardService(cached, Policy{})
+	ctx := context.Background()
+	_, err := service.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-001"))
+	require.NoError(t, err)
+
+	for range 3 {
+		require.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard))
+	}
+	assert.Equal(t, 1, counting.deviceReads)
+	assert.Equal(t, 1, counting.cardReads)
+
+	// Missing cards are not cached, so a card created meanwhile is found right away
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-2", models.ProtocolStandard), ErrCardNotFound)
+	_, err = service.CreateCard(ctx, "hotel_a", testCard("GUEST-2", "SN-001"))
+	require.NoError(t, err)
+	assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-2", models.ProtocolStandard))
+}
+
+func TestCachingRepository_WritesInvalidate(t *testing.T) {
+	cached, _ := newTestCachingRepository(t, CachingRepositoryOptions{TTL: time.Hour})
+	service := NewCardService(cached, Policy{})
+	ctx := context.Background()
+	card, err := service.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-001"))
+	require.NoError(t, err)
+	require.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard))
+
+	// Revoking rewrites the card, so the cached copy is dropped
+	_, err = service.RevokeCard(ctx, "hotel_a", card.ID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard), ErrCardRevoked)
+
+	_, err = service.SetDeviceStatus(ctx, "hotel_a", "device-1", models.DeviceStatusDecommissioned)
+	require.NoError(t, err)
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard), ErrDeviceNotActive)
+}
+
+func TestCachingRepository_CrossInstanceInvalidation(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	opts := CachingRepositoryOptions{TTL: time.Hour, RedisURI: "redis://" + mr.Addr()}
+	first, _ := newTestCachingRepository(t, opts)
+	second, err := NewCachingRepository(first.Repository, opts)
+	require.NoError(t, err)
+	defer func() { _ = second.Close() }()
+
+	admin := NewCardService(first, Policy{})
+	verifier := NewCardService(second, Policy{})
+	ctx := context.Background()
+	card, err := admin.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-001"))
+	require.NoError(t, err)
+	require.NoError(t, verifier.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard))
+
+	// A revocation on one instance reaches the card cached by the other long before the TTL
+	_, err = admin.RevokeCard(ctx, "hotel_a", card.ID)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return errors.Is(verifier.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard), ErrCardRevoked)
+	}, time.Second, 5*time.Millisecond)
+}
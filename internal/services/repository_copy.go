@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"slices"
+	"time"
+
+	"commander/internal/kv"
+	"commander/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// copyPageSize is the number of records read at once when copying or checksumming a repository
+const copyPageSize = 500
+
+// RecordCount is the number of records of one collection copied between repositories
+type RecordCount struct {
+	Collection string
+	Records    int64
+}
+
+// RecordChecksum is the number of records of one collection and a checksum of their content
+// Records are hashed as stored by MongoDB (times in milliseconds), so repositories on different backends
+// holding the same records have the same checksum
+type RecordChecksum struct {
+	Collection string
+	Records    int64
+	Checksum   string
+}
+
+// CopyOptions configures CopyRecords
+type CopyOptions struct {
+	// DryRun counts the records of the source but writes nothing
+	DryRun bool
+
+	// Exclude lists collections whose records are not copied (see RepositoryCollections)
+	Exclude []string
+}
+
+// CopyRecords copies the devices, cards, device groups, revocations, presence, settings, access logs and
+// change feed of namespace from source to destination, which may be backed by different backends
+// Records are saved by ID, so copying again overwrites instead of duplicating; the change feed continues
+// after the last revision the destination already has and fails if the revisions would differ
+// Access log entries keep their expiry
+func CopyRecords(ctx context.Context, source, destination Repository, namespace string, opts CopyOptions) ([]RecordCount, error) {
+	var counts []RecordCount
+	for _, kind := range recordKinds {
+		if slices.Contains(opts.Exclude, kind.collection) {
+			continue
+		}
+		n, err := kind.copy(ctx, source, destination, namespace, opts.DryRun)
+		if err != nil {
+			return counts, fmt.Errorf("collection %s: %w", kind.collection, err)
+		}
+		counts = append(counts, RecordCount{Collection: kind.collection, Records: n})
+	}
+	return counts, nil
+}
+
+// ChecksumRecords counts and hashes the records CopyRecords copies, per collection
+func ChecksumRecords(ctx context.Context, repo Repository, namespace string, exclude []string) ([]RecordChecksum, error) {
+	var checksums []RecordChecksum
+	for _, kind := range recordKinds {
+		if slices.Contains(exclude, kind.collection) {
+			continue
+		}
+		h := sha256.New()
+		n, err := kind.hash(ctx, repo, namespace, h)
+		if err != nil {
+			return checksums, fmt.Errorf("collection %s: %w", kind.collection, err)
+		}
+		checksums = append(checksums, RecordChecksum{Collection: kind.collection, Records: n, Checksum: hex.EncodeToString(h.Sum(nil))})
+	}
+	return checksums, nil
+}
+
+// recordKind copies and hashes the records of one collection
+type recordKind struct {
+	collection string
+	copy       func(ctx context.Context, source, destination Repository, namespace string, dryRun bool) (int64, error)
+	hash       func(ctx context.Context, repo Repository, namespace string, h hash.Hash) (int64, error)
+}
+
+// eachFunc calls fn with every record of a collection in a stable order
+type eachFunc[T any] func(ctx context.Context, repo Repository, namespace string, fn func(*T) error) error
+
+// recordKinds are copied in order: revocations before cards, so a revoked card never passes on the
+// destination in between
+var recordKinds = []recordKind{
+	newRecordKind(RevocationsCollection, eachRevocation, func(ctx context.Context, repo Repository, namespace string, revocation *models.Revocation) error {
+		return repo.SaveRevocation(ctx, namespace, revocation)
+	}),
+	newRecordKind(DevicesCollection, eachDevice, func(ctx context.Context, repo Repository, namespace string, device *models.Device) error {
+		return repo.SaveDevice(ctx, namespace, device)
+	}),
+	newRecordKind(DeviceGroupsCollection, eachDeviceGroup, func(ctx context.Context, repo Repository, namespace string, group *models.DeviceGroup) error {
+		return repo.SaveDeviceGroup(ctx, namespace, group)
+	}),
+	newRecordKind(CardsCollection, eachCard, func(ctx context.Context, repo Repository, namespace string, card *models.Card) error {
+		return repo.SaveCard(ctx, namespace, card)
+	}),
+	newRecordKind(PresenceCollection, eachPresence, func(ctx context.Context, repo Repository, namespace string, presence *models.Presence) error {
+		return repo.SavePresence(ctx, namespace, presence)
+	}),
+	{collection: settingsCollection, copy: copySettings, hash: hashSettings},
+	{collection: AccessLogsCollection, copy: copyAccessLogs, hash: hashWith(eachAccessLog)},
+	{collection: ChangesCollection, copy: copyChanges, hash: hashWith(eachChange)},
+}
+
+// newRecordKind copies the records listed by each with save
+func newRecordKind[T any](collection string, each eachFunc[T], save func(ctx context.Context, repo Repository, namespace string, record *T) error) recordKind {
+	return recordKind{
+		collection: collection,
+		copy: func(ctx context.Context, source, destination Repository, namespace string, dryRun bool) (int64, error) {
+			var count int64
+			err := each(ctx, source, namespace, func(record *T) error {
+				if !dryRun {
+					if err := save(ctx, destination, namespace, record); err != nil {
+						return err
+					}
+				}
+				count++
+				return nil
+			})
+			return count, err
+		},
+		hash: hashWith(each),
+	}
+}
+
+// hashWith hashes the records listed by each in order
+func hashWith[T any](each eachFunc[T]) func(ctx context.Context, repo Repository, namespace string, h hash.Hash) (int64, error) {
+	return func(ctx context.Context, repo Repository, namespace string, h hash.Hash) (int64, error) {
+		var count int64
+		err := each(ctx, repo, namespace, func(record *T) error {
+			count++
+			return hashRecord(h, record)
+		})
+		return count, err
+	}
+}
+
+// eachRevocation lists the revocations of namespace
+func eachRevocation(ctx context.Context, repo Repository, namespace string, fn func(*models.Revocation) error) error {
+	return eachPage(func(cursor string) ([]*models.Revocation, string, error) {
+		return repo.ListRevocations(ctx, namespace, RevocationQuery{Cursor: cursor, Limit: copyPageSize})
+	}, fn)
+}
+
+// eachDevice lists the devices of namespace
+func eachDevice(ctx context.Context, repo Repository, namespace string, fn func(*models.Device) error) error {
+	return eachPage(func(cursor string) ([]*models.Device, string, error) {
+		return repo.ListDevices(ctx, namespace, DeviceQuery{Cursor: cursor, Limit: copyPageSize})
+	}, fn)
+}
+
+// eachDeviceGroup lists the device groups of namespace
+func eachDeviceGroup(ctx context.Context, repo Repository, namespace string, fn func(*models.DeviceGroup) error) error {
+	return eachPage(func(cursor string) ([]*models.DeviceGroup, string, error) {
+		return repo.ListDeviceGroups(ctx, namespace, DeviceGroupQuery{Cursor: cursor, Limit: copyPageSize})
+	}, fn)
+}
+
+// eachCard lists the cards of namespace
+func eachCard(ctx context.Context, repo Repository, namespace string, fn func(*models.Card) error) error {
+	return eachPage(func(cursor string) ([]*models.Card, string, error) {
+		return repo.ListCards(ctx, namespace, CardQuery{Cursor: cursor, Limit: copyPageSize})
+	}, fn)
+}
+
+// eachAccessLog lists the access log entries of namespace
+func eachAccessLog(ctx context.Context, repo Repository, namespace string, fn func(*models.AccessLog) error) error {
+	return eachPage(func(cursor string) ([]*models.AccessLog, string, error) {
+		return repo.ListAccessLogs(ctx, namespace, AccessLogQuery{Cursor: cursor, Limit: copyPageSize})
+	}, fn)
+}
+
+// eachPresence lists the anti-passback state of every card, in card order
+func eachPresence(ctx context.Context, repo Repository, namespace string, fn func(*models.Presence) error) error {
+	return eachCard(ctx, repo, namespace, func(card *models.Card) error {
+		presence, err := repo.ListPresence(ctx, namespace, card.ID)
+		if err != nil {
+			return err
+		}
+		for _, p := range presence {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// eachChange lists the whole change feed
+func eachChange(ctx context.Context, repo Repository, namespace string, fn func(*models.Change) error) error {
+	return eachChangeSince(ctx, repo, namespace, 0, fn)
+}
+
+// eachChangeSince lists the change feed after revision since
+func eachChangeSince(ctx context.Context, repo Repository, namespace string, since int64, fn func(*models.Change) error) error {
+	for {
+		changes, err := repo.ListChanges(ctx, namespace, since, copyPageSize)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			if err := fn(change); err != nil {
+				return err
+			}
+		}
+		if len(changes) < copyPageSize {
+			return nil
+		}
+		since = changes[len(changes)-1].Revision
+	}
+}
+
+// eachPage calls fn with every record of a paged list function
+func eachPage[T any](list func(cursor string) ([]*T, string, error), fn func(*T) error) error {
+	cursor := ""
+	for {
+		page, next, err := list(cursor)
+		if err != nil {
+			return err
+		}
+		for _, record := range page {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// hashRecord hashes the JSON of record after a BSON round trip, which MongoDB applies when storing it
+func hashRecord[T any](h hash.Hash, record *T) error {
+	data, err := bson.Marshal(record)
+	if err != nil {
+		return err
+	}
+	var stored T
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	value, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(value)))
+	h.Write(length[:])
+	h.Write(value)
+	return nil
+}
+
+// copySettings copies the policy overrides and the upstream sync progress of namespace
+func copySettings(ctx context.Context, source, destination Repository, namespace string, dryRun bool) (int64, error) {
+	var count int64
+	policy, err := source.GetPolicy(ctx, namespace)
+	if err != nil {
+		return 0, err
+	}
+	if policy != nil {
+		if !dryRun {
+			if err := destination.SavePolicy(ctx, namespace, policy); err != nil {
+				return 0, err
+			}
+		}
+		count++
+	}
+	state, err := source.GetSyncState(ctx, namespace)
+	if err != nil {
+		return count, err
+	}
+	if state != nil {
+		if !dryRun {
+			if err := destination.SaveSyncState(ctx, namespace, state); err != nil {
+				return count, err
+			}
+		}
+		count++
+	}
+	return count, nil
+}
+
+// hashSettings hashes the policy overrides and the upstream sync progress of namespace
+func hashSettings(ctx context.Context, repo Repository, namespace string, h hash.Hash) (int64, error) {
+	var count int64
+	policy, err := repo.GetPolicy(ctx, namespace)
+	if err != nil {
+		return 0, err
+	}
+	if policy != nil {
+		if err := hashRecord(h, policy); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	state, err := repo.GetSyncState(ctx, namespace)
+	if err != nil {
+		return count, err
+	}
+	if state != nil {
+		if err := hashRecord(h, state); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// copyAccessLogs copies the access log entries of namespace, keeping their expiry
+// Entries that expire while they are copied are left out
+func copyAccessLogs(ctx context.Context, source, destination Repository, namespace string, dryRun bool) (int64, error) {
+	var count int64
+	cursor := ""
+	for {
+		entries, next, err := source.ListAccessLogs(ctx, namespace, AccessLogQuery{Cursor: cursor, Limit: copyPageSize})
+		if err != nil {
+			return count, err
+		}
+		expiries, err := accessLogExpiries(ctx, source, namespace, entries)
+		if err != nil {
+			return count, err
+		}
+		now := time.Now()
+		for i, entry := range entries {
+			var retention time.Duration
+			if !expiries[i].IsZero() {
+				if !expiries[i].After(now) {
+					continue
+				}
+				// KV repositories count the retention from the write, MongoDB from the entry timestamp
+				if _, ok := destination.(*KVRepository); ok {
+					retention = expiries[i].Sub(now)
+				} else {
+					retention = expiries[i].Sub(entry.Timestamp)
+				}
+			}
+			if !dryRun {
+				if err := destination.AppendAccessLog(ctx, namespace, entry, retention); err != nil {
+					return count, err
+				}
+			}
+			count++
+		}
+		if next == "" {
+			return count, nil
+		}
+		cursor = next
+	}
+}
+
+// accessLogExpiries returns when each entry read from repo expires (zero if never)
+// KV repositories expire entries through the TTL of their key, so an entry that expired since it was
+// listed is reported as expiring now; MongoDB keeps the expiry in ExpiresAt
+func accessLogExpiries(ctx context.Context, repo Repository, namespace string, entries []*models.AccessLog) ([]time.Time, error) {
+	expiries := make([]time.Time, len(entries))
+	kvRepo, ok := repo.(*KVRepository)
+	if !ok {
+		for i, entry := range entries {
+			if entry.ExpiresAt != nil {
+				expiries[i] = *entry.ExpiresAt
+			}
+		}
+		return expiries, nil
+	}
+
+	keys := make([]kv.Key, len(entries))
+	for i, entry := range entries {
+		keys[i] = kv.Key{Namespace: namespace, Collection: AccessLogsCollection, Key: entry.ID}
+	}
+	results, err := kv.GetMany(ctx, kvRepo.store, keys)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i, result := range results {
+		switch {
+		case !result.Found:
+			expiries[i] = now
+		case result.TTL > 0:
+			expiries[i] = now.Add(result.TTL)
+		}
+	}
+	return expiries, nil
+}
+
+// copyChanges appends the changes the destination does not have yet, keeping their revisions
+func copyChanges(ctx context.Context, source, destination Repository, namespace string, dryRun bool) (int64, error) {
+	since, err := destination.ChangeRevision(ctx, namespace)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = eachChangeSince(ctx, source, namespace, since, func(change *models.Change) error {
+		if !dryRun {
+			copied := *change
+			if err := destination.AppendChange(ctx, namespace, &copied); err != nil {
+				return err
+			}
+			if copied.Revision != change.Revision {
+				return fmt.Errorf("destination change feed diverged: revision %d stored as %d", change.Revision, copied.Revision)
+			}
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"commander/internal/database/memory"
+	"commander/internal/kv"
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCopyRepositories returns a source repository holding one record of every kind and an empty destination
+func newTestCopyRepositories(t *testing.T) (*KVRepository, *KVRepository, *memory.MemoryKV) {
+	t.Helper()
+	sourceStore, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sourceStore.Close() })
+	destinationStore, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = destinationStore.Close() })
+
+	source := NewKVRepository(sourceStore)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	require.NoError(t, source.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-1", DeviceID: "lobby", SN: "SN-001", CreatedAt: now}))
+	require.NoError(t, source.SaveDeviceGroup(ctx, "hotel_a", &models.DeviceGroup{ID: "group-1", DisplayName: "Lobby"}))
+	card := testCard("GUEST-1", "SN-001")
+	card.ID = "card-1"
+	require.NoError(t, source.SaveCard(ctx, "hotel_a", card))
+	require.NoError(t, source.SaveRevocation(ctx, "hotel_a", &models.Revocation{
+		ID: models.RevocationID(models.RevocationByNumber, "OLD-1"), CardNumber: "OLD-1", RevokedAt: now,
+	}))
+	require.NoError(t, source.SavePresence(ctx, "hotel_a", &models.Presence{CardID: "card-1", Zone: "pool", Inside: true, UpdatedAt: now}))
+	require.NoError(t, source.SavePolicy(ctx, "hotel_a", &models.NamespacePolicy{UpdatedAt: now}))
+	require.NoError(t, source.AppendAccessLog(ctx, "hotel_a", &models.AccessLog{
+		ID: "log-1", DeviceSN: "SN-001", CardNumber: "GUEST-1", Outcome: models.AccessGranted, Timestamp: now,
+	}, time.Hour))
+	for _, id := range []string{"device-1", "card-1"} {
+		require.NoError(t, source.AppendChange(ctx, "hotel_a", &models.Change{Kind: models.ChangeDevice, ID: id, Timestamp: now}))
+	}
+	return source, NewKVRepository(destinationStore), destinationStore
+}
+
+func TestCopyRecords(t *testing.T) {
+	source, destination, destinationStore := newTestCopyRepositories(t)
+	ctx := context.Background()
+
+	counts, err := CopyRecords(ctx, source, destination, "hotel_a", CopyOptions{})
+	require.NoError(t, err)
+	copied := make(map[string]int64)
+	for _, c := range counts {
+		copied[c.Collection] = c.Records
+	}
+	assert.Equal(t, map[string]int64{
+		RevocationsCollection: 1, DevicesCollection: 1, DeviceGroupsCollection: 1, CardsCollection: 1,
+		PresenceCollection: 1, settingsCollection: 1, AccessLogsCollection: 1, ChangesCollection: 2,
+	}, copied)
+
+	card, err := destination.GetCardByNumber(ctx, "hotel_a", "GUEST-1")
+	require.NoError(t, err, "cards should be found by number on the destination")
+	assert.Equal(t, "card-1", card.ID)
+	results, err := kv.GetMany(ctx, destinationStore, []kv.Key{{Namespace: "hotel_a", Collection: AccessLogsCollection, Key: "log-1"}})
+	require.NoError(t, err)
+	assert.True(t, results[0].TTL > 0 && results[0].TTL <= time.Hour, "access log entries should keep their expiry, got %s", results[0].TTL)
+
+	sourceSums, err := ChecksumRecords(ctx, source, "hotel_a", nil)
+	require.NoError(t, err)
+	destinationSums, err := ChecksumRecords(ctx, destination, "hotel_a", nil)
+	require.NoError(t, err)
+	assert.Equal(t, sourceSums, destinationSums)
+
+	// Copying again overwrites records and appends no change twice
+	counts, err = CopyRecords(ctx, source, destination, "hotel_a", CopyOptions{})
+	require.NoError(t, err)
+	for _, c := range counts {
+		if c.Collection == ChangesCollection {
+			assert.Zero(t, c.Records)
+		}
+	}
+	revision, err := destination.ChangeRevision(ctx, "hotel_a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), revision)
+}
+
+func TestCopyRecords_DryRunAndExclude(t *testing.T) {
+	source, destination, _ := newTestCopyRepositories(t)
+	ctx := context.Background()
+
+	counts, err := CopyRecords(ctx, source, destination, "hotel_a", CopyOptions{DryRun: true, Exclude: []string{AccessLogsCollection}})
+	require.NoError(t, err)
+	for _, c := range counts {
+		assert.NotEqual(t, AccessLogsCollection, c.Collection)
+	}
+	_, err = destination.GetCard(ctx, "hotel_a", "card-1")
+	assert.ErrorIs(t, err, ErrCardNotFound, "a dry run should write nothing")
+}
+
+func TestChecksumRecords_MillisecondTimes(t *testing.T) {
+	_, first, _ := newTestCopyRepositories(t)
+	_, second, _ := newTestCopyRepositories(t)
+	ctx := context.Background()
+
+	// MongoDB keeps times in milliseconds; the same record read back from it has the same checksum
+	revokedAt := time.Date(2026, 1, 2, 3, 4, 5, 6789012, time.UTC)
+	require.NoError(t, first.SaveRevocation(ctx, "hotel_a", &models.Revocation{ID: "number:A", CardNumber: "A", RevokedAt: revokedAt}))
+	require.NoError(t, second.SaveRevocation(ctx, "hotel_a", &models.Revocation{ID: "number:A", CardNumber: "A", RevokedAt: revokedAt.Truncate(time.Millisecond)}))
+
+	firstSums, err := ChecksumRecords(ctx, first, "hotel_a", nil)
+	require.NoError(t, err)
+	secondSums, err := ChecksumRecords(ctx, second, "hotel_a", nil)
+	require.NoError(t, err)
+	assert.Equal(t, firstSums, secondSums)
+}
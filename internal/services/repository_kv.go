@@ -0,0 +1,930 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"commander/internal/kv"
+	"commander/internal/models"
+)
+
+// Collections used to store devices and cards
+const (
+	DevicesCollection      = "devices"
+	CardsCollection        = "cards"
+	DeviceGroupsCollection = "device_groups"
+	RevocationsCollection  = "revocations"
+	PresenceCollection     = "presence"
+
+	// Secondary indexes: SN -> device ID and number -> card ID
+	devicesBySNCollection   = "devices_by_sn"
+	cardsByNumberCollection = "cards_by_number"
+
+	// Per-namespace settings; the policy overrides are stored under policyKey
+	settingsCollection = "settings"
+	policyKey          = "policy"
+
+	// AccessLogsCollection stores verification attempts keyed by their time-ordered ID
+	AccessLogsCollection = "access_logs"
+
+	// ChangesCollection stores the change feed keyed by zero-padded revision
+	ChangesCollection = "changes"
+
+	// Settings keys of the last change feed revision and of the upstream sync progress
+	changeRevisionKey = "change_revision"
+	syncStateKey      = "sync_state"
+)
+
+// RepositoryCollections returns every collection a repository stores records in, index collections included
+func RepositoryCollections() []string {
+	return []string{
+		DevicesCollection, CardsCollection, DeviceGroupsCollection, RevocationsCollection, PresenceCollection,
+		devicesBySNCollection, cardsByNumberCollection, settingsCollection, AccessLogsCollection, ChangesCollection,
+	}
+}
+
+// KVRepository stores devices and cards as JSON values in any kv.KV backend
+// Records are keyed by ID; lookups by SN and number go through index collections
+// whose values are the JSON-encoded record ID
+type KVRepository struct {
+	store kv.KV
+
+	// consumeMu serializes ConsumeCardUse on backends without kv.Versioned
+	consumeMu sync.Mutex
+
+	// saveMu serializes SaveDevice and SaveCard on backends without kv.Versioned
+	saveMu sync.Mutex
+
+	// changeMu serializes AppendChange, so revisions of this process are stored in order
+	changeMu sync.Mutex
+}
+
+// NewKVRepository creates a repository on top of a KV store
+func NewKVRepository(store kv.KV) *KVRepository {
+	return &KVRepository{
+		store: store,
+	}
+}
+
+// GetDeviceBySN retrieves a device through the devices_by_sn index
+func (r *KVRepository) GetDeviceBySN(ctx context.Context, namespace, sn string) (*models.Device, error) {
+	var device models.Device
+	found, err := r.lookup(ctx, namespace, devicesBySNCollection, DevicesCollection, sn, &device)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device: %w", err)
+	}
+	// A stale index entry (SN changed) counts as missing
+	if !found || device.SN != sn {
+		return nil, ErrDeviceNotFound
+	}
+	return &device, nil
+}
+
+// GetCardByNumber retrieves a card through the cards_by_number index
+func (r *KVRepository) GetCardByNumber(ctx context.Context, namespace, number string) (*models.Card, error) {
+	var card models.Card
+	found, err := r.lookup(ctx, namespace, cardsByNumberCollection, CardsCollection, number, &card)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query card: %w", err)
+	}
+	// A stale index entry (number changed) counts as missing
+	if !found || card.Number != number {
+		return nil, ErrCardNotFound
+	}
+	return &card, nil
+}
+
+// GetDevice retrieves a device by ID
+func (r *KVRepository) GetDevice(ctx context.Context, namespace, id string) (*models.Device, error) {
+	var device models.Device
+	found, err := r.get(ctx, namespace, DevicesCollection, id, &device)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device: %w", err)
+	}
+	if !found {
+		return nil, ErrDeviceNotFound
+	}
+	return &device, nil
+}
+
+// ListDevices pages through the devices collection in key order and filters the decoded devices
+func (r *KVRepository) ListDevices(ctx context.Context, namespace string, query DeviceQuery) ([]*models.Device, string, error) {
+	devices, next, err := listRecords(ctx, r.store, namespace, DevicesCollection, query.Cursor, query.limit(),
+		func(device *models.Device) bool { return query.Matches(device) })
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list devices: %w", err)
+	}
+	return devices, next, nil
+}
+
+// SaveDevice stores a device and claims its SN index entry
+func (r *KVRepository) SaveDevice(ctx context.Context, namespace string, device *models.Device) error {
+	return r.save(ctx, namespace, DevicesCollection, devicesBySNCollection, device.ID, device.SN, device, deviceSN, ErrDeviceSNExists)
+}
+
+// DeleteDevice removes a device and its SN index entry
+func (r *KVRepository) DeleteDevice(ctx context.Context, namespace, id string) error {
+	device, err := r.GetDevice(ctx, namespace, id)
+	if err != nil {
+		return err
+	}
+	return r.remove(ctx, namespace, DevicesCollection, devicesBySNCollection, id, device.SN)
+}
+
+// GetCard retrieves a card by ID
+func (r *KVRepository) GetCard(ctx context.Context, namespace, id string) (*models.Card, error) {
+	var card models.Card
+	found, err := r.get(ctx, namespace, CardsCollection, id, &card)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query card: %w", err)
+	}
+	if !found {
+		return nil, ErrCardNotFound
+	}
+	return &card, nil
+}
+
+// ListCards pages through the cards collection in key order and filters the decoded cards
+func (r *KVRepository) ListCards(ctx context.Context, namespace string, query CardQuery) ([]*models.Card, string, error) {
+	cards, next, err := listRecords(ctx, r.store, namespace, CardsCollection, query.Cursor, query.limit(),
+		func(card *models.Card) bool { return query.Matches(card) })
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list cards: %w", err)
+	}
+	return cards, next, nil
+}
+
+// DeleteCard removes a card and its number index entry
+func (r *KVRepository) DeleteCard(ctx context.Context, namespace, id string) error {
+	card, err := r.GetCard(ctx, namespace, id)
+	if err != nil {
+		return err
+	}
+	return r.remove(ctx, namespace, CardsCollection, cardsByNumberCollection, id, card.Number)
+}
+
+// SaveCard stores a card and claims its number index entry
+func (r *KVRepository) SaveCard(ctx context.Context, namespace string, card *models.Card) error {
+	return r.save(ctx, namespace, CardsCollection, cardsByNumberCollection, card.ID, card.Number, card, cardNumber, ErrCardNumberExists)
+}
+
+// ConsumeCardUse increments the use count of a card
+// On kv.Versioned backends the card is updated with compare-and-set and retried on conflicts, which is
+// safe across processes; other backends are only serialized within this process
+func (r *KVRepository) ConsumeCardUse(ctx context.Context, namespace, id string) (*models.Card, error) {
+	versioned, ok := r.store.(kv.Versioned)
+	if !ok {
+		r.consumeMu.Lock()
+		defer r.consumeMu.Unlock()
+
+		card, err := r.GetCard(ctx, namespace, id)
+		if err != nil {
+			return nil, err
+		}
+		if card.UsesExhausted() {
+			return nil, ErrCardUsageExhausted
+		}
+		card.UseCount++
+		value, err := json.Marshal(card)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.store.Set(ctx, namespace, CardsCollection, id, value); err != nil {
+			return nil, fmt.Errorf("failed to save card: %w", err)
+		}
+		return card, nil
+	}
+
+	// Every conflict means another writer succeeded, so the loop always makes progress
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		data, version, err := versioned.GetWithVersion(ctx, namespace, CardsCollection, id)
+		if errors.Is(err, kv.ErrKeyNotFound) {
+			return nil, ErrCardNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query card: %w", err)
+		}
+
+		var card models.Card
+		if err := json.Unmarshal(data, &card); err != nil {
+			return nil, fmt.Errorf("failed to decode %s/%s: %w", CardsCollection, id, err)
+		}
+		if card.UsesExhausted() {
+			return nil, ErrCardUsageExhausted
+		}
+
+		card.UseCount++
+		value, err := json.Marshal(&card)
+		if err != nil {
+			return nil, err
+		}
+		_, err = versioned.CompareAndSet(ctx, namespace, CardsCollection, id, version, value)
+		if errors.Is(err, kv.ErrVersionMismatch) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to save card: %w", err)
+		}
+		return &card, nil
+	}
+}
+
+// GetDeviceGroup retrieves a device group by ID
+func (r *KVRepository) GetDeviceGroup(ctx context.Context, namespace, id string) (*models.DeviceGroup, error) {
+	var group models.DeviceGroup
+	found, err := r.get(ctx, namespace, DeviceGroupsCollection, id, &group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device group: %w", err)
+	}
+	if !found {
+		return nil, ErrDeviceGroupNotFound
+	}
+	return &group, nil
+}
+
+// ListDeviceGroups pages through the device_groups collection in key order and filters the decoded groups
+func (r *KVRepository) ListDeviceGroups(ctx context.Context, namespace string, query DeviceGroupQuery) ([]*models.DeviceGroup, string, error) {
+	groups, next, err := listRecords(ctx, r.store, namespace, DeviceGroupsCollection, query.Cursor, query.limit(),
+		func(group *models.DeviceGroup) bool { return query.Matches(group) })
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list device groups: %w", err)
+	}
+	return groups, next, nil
+}
+
+// SaveDeviceGroup stores a device group
+func (r *KVRepository) SaveDeviceGroup(ctx context.Context, namespace string, group *models.DeviceGroup) error {
+	if group.ID == "" {
+		return fmt.Errorf("%s record requires an id", DeviceGroupsCollection)
+	}
+	value, err := json.Marshal(group)
+	if err != nil {
+		return err
+	}
+	return r.store.Set(ctx, namespace, DeviceGroupsCollection, group.ID, value)
+}
+
+// DeleteDeviceGroup removes a device group
+func (r *KVRepository) DeleteDeviceGroup(ctx context.Context, namespace, id string) error {
+	if _, err := r.GetDeviceGroup(ctx, namespace, id); err != nil {
+		return err
+	}
+	err := r.store.Delete(ctx, namespace, DeviceGroupsCollection, id)
+	if errors.Is(err, kv.ErrKeyNotFound) {
+		return ErrDeviceGroupNotFound
+	}
+	return err
+}
+
+// GetRevocation retrieves a revocation by ID
+func (r *KVRepository) GetRevocation(ctx context.Context, namespace, id string) (*models.Revocation, error) {
+	var revocation models.Revocation
+	found, err := r.get(ctx, namespace, RevocationsCollection, id, &revocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query revocation: %w", err)
+	}
+	if !found {
+		return nil, ErrRevocationNotFound
+	}
+	return &revocation, nil
+}
+
+// ListRevocations pages through the revocations collection in key order
+func (r *KVRepository) ListRevocations(ctx context.Context, namespace string, query RevocationQuery) ([]*models.Revocation, string, error) {
+	revocations, next, err := listRecords(ctx, r.store, namespace, RevocationsCollection, query.Cursor, query.limit(),
+		func(*models.Revocation) bool { return true })
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list revocations: %w", err)
+	}
+	return revocations, next, nil
+}
+
+// SaveRevocation stores a revocation
+func (r *KVRepository) SaveRevocation(ctx context.Context, namespace string, revocation *models.Revocation) error {
+	if revocation.ID == "" {
+		return fmt.Errorf("%s record requires an id", RevocationsCollection)
+	}
+	value, err := json.Marshal(revocation)
+	if err != nil {
+		return err
+	}
+	return r.store.Set(ctx, namespace, RevocationsCollection, revocation.ID, value)
+}
+
+// DeleteRevocation removes a revocation
+func (r *KVRepository) DeleteRevocation(ctx context.Context, namespace, id string) error {
+	if _, err := r.GetRevocation(ctx, namespace, id); err != nil {
+		return err
+	}
+	err := r.store.Delete(ctx, namespace, RevocationsCollection, id)
+	if errors.Is(err, kv.ErrKeyNotFound) {
+		return ErrRevocationNotFound
+	}
+	return err
+}
+
+// maxPresenceZones bounds the zones returned by ListPresence for one card
+const maxPresenceZones = 1000
+
+// GetPresence retrieves the anti-passback state of a card in a zone
+func (r *KVRepository) GetPresence(ctx context.Context, namespace, cardID, zone string) (*models.Presence, error) {
+	var presence models.Presence
+	found, err := r.get(ctx, namespace, PresenceCollection, models.PresenceID(cardID, zone), &presence)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query presence: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &presence, nil
+}
+
+// ListPresence scans the "<card id>/" key range of the presence collection
+func (r *KVRepository) ListPresence(ctx context.Context, namespace, cardID string) ([]*models.Presence, error) {
+	// Keys of the card sort after "<card id>/" and before "<card id>0" ('0' follows '/')
+	presence, _, err := listRecordsUntil(ctx, r.store, namespace, PresenceCollection, cardID+"/", cardID+"0", maxPresenceZones,
+		func(p *models.Presence) bool { return p.CardID == cardID })
+	if err != nil {
+		return nil, fmt.Errorf("failed to list presence: %w", err)
+	}
+	return presence, nil
+}
+
+// SavePresence stores the anti-passback state of a card in a zone
+func (r *KVRepository) SavePresence(ctx context.Context, namespace string, presence *models.Presence) error {
+	presence.ID = models.PresenceID(presence.CardID, presence.Zone)
+	value, err := json.Marshal(presence)
+	if err != nil {
+		return err
+	}
+	return r.store.Set(ctx, namespace, PresenceCollection, presence.ID, value)
+}
+
+// DeletePresence removes the anti-passback state of a card in a zone
+func (r *KVRepository) DeletePresence(ctx context.Context, namespace, cardID, zone string) error {
+	err := r.store.Delete(ctx, namespace, PresenceCollection, models.PresenceID(cardID, zone))
+	if errors.Is(err, kv.ErrKeyNotFound) {
+		return nil
+	}
+	return err
+}
+
+// GetPolicy retrieves the policy overrides from the settings collection
+func (r *KVRepository) GetPolicy(ctx context.Context, namespace string) (*models.NamespacePolicy, error) {
+	var policy models.NamespacePolicy
+	found, err := r.get(ctx, namespace, settingsCollection, policyKey, &policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &policy, nil
+}
+
+// SavePolicy stores the policy overrides in the settings collection
+func (r *KVRepository) SavePolicy(ctx context.Context, namespace string, policy *models.NamespacePolicy) error {
+	value, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	return r.store.Set(ctx, namespace, settingsCollection, policyKey, value)
+}
+
+// AppendAccessLog stores an entry in the access_logs collection, expiring it through the backend TTL
+func (r *KVRepository) AppendAccessLog(ctx context.Context, namespace string, entry *models.AccessLog, retention time.Duration) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return r.store.SetWithTTL(ctx, namespace, AccessLogsCollection, entry.ID, value, retention)
+}
+
+// ListAccessLogs pages through the access_logs collection in key (time) order
+// The time range bounds the scan itself, since keys start with the timestamp
+func (r *KVRepository) ListAccessLogs(ctx context.Context, namespace string, query AccessLogQuery) ([]*models.AccessLog, string, error) {
+	cursor := query.Cursor
+	if !query.From.IsZero() {
+		if from := accessLogKeyPrefix(query.From); from > cursor {
+			cursor = from
+		}
+	}
+	until := ""
+	if !query.To.IsZero() {
+		until = accessLogKeyPrefix(query.To)
+	}
+
+	entries, next, err := listRecordsUntil(ctx, r.store, namespace, AccessLogsCollection, cursor, until, query.limit(),
+		func(entry *models.AccessLog) bool { return query.Matches(entry) })
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list access logs: %w", err)
+	}
+	return entries, next, nil
+}
+
+// AppendChange stores change under the next revision of the namespace counter
+// On kv.Versioned backends the counter is incremented with compare-and-set, which is safe across processes
+func (r *KVRepository) AppendChange(ctx context.Context, namespace string, change *models.Change) error {
+	r.changeMu.Lock()
+	defer r.changeMu.Unlock()
+
+	revision, err := r.nextRevision(ctx, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to allocate revision: %w", err)
+	}
+	change.Revision = revision
+	value, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return r.store.Set(ctx, namespace, ChangesCollection, changeKey(revision), value)
+}
+
+// nextRevision increments the change revision counter and returns the new value
+func (r *KVRepository) nextRevision(ctx context.Context, namespace string) (int64, error) {
+	versioned, ok := r.store.(kv.Versioned)
+	if !ok {
+		revision, err := r.ChangeRevision(ctx, namespace)
+		if err != nil {
+			return 0, err
+		}
+		revision++
+		value, err := json.Marshal(revision)
+		if err != nil {
+			return 0, err
+		}
+		return revision, r.store.Set(ctx, namespace, settingsCollection, changeRevisionKey, value)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		var revision int64
+		data, version, err := versioned.GetWithVersion(ctx, namespace, settingsCollection, changeRevisionKey)
+		switch {
+		case errors.Is(err, kv.ErrKeyNotFound):
+			version = 0
+		case err != nil:
+			return 0, err
+		default:
+			if err := json.Unmarshal(data, &revision); err != nil {
+				return 0, fmt.Errorf("failed to decode %s/%s: %w", settingsCollection, changeRevisionKey, err)
+			}
+		}
+
+		revision++
+		value, err := json.Marshal(revision)
+		if err != nil {
+			return 0, err
+		}
+		_, err = versioned.CompareAndSet(ctx, namespace, settingsCollection, changeRevisionKey, version, value)
+		if errors.Is(err, kv.ErrVersionMismatch) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return revision, nil
+	}
+}
+
+// ListChanges pages through the changes collection after the key of since
+func (r *KVRepository) ListChanges(ctx context.Context, namespace string, since int64, limit int) ([]*models.Change, error) {
+	changes, _, err := listRecords(ctx, r.store, namespace, ChangesCollection, changeKey(since), limit,
+		func(*models.Change) bool { return true })
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+	return changes, nil
+}
+
+// ChangeRevision reads the change revision counter from the settings collection
+func (r *KVRepository) ChangeRevision(ctx context.Context, namespace string) (int64, error) {
+	var revision int64
+	if _, err := r.get(ctx, namespace, settingsCollection, changeRevisionKey, &revision); err != nil {
+		return 0, fmt.Errorf("failed to query change revision: %w", err)
+	}
+	return revision, nil
+}
+
+// GetSyncState retrieves the upstream sync progress from the settings collection
+func (r *KVRepository) GetSyncState(ctx context.Context, namespace string) (*models.SyncState, error) {
+	var state models.SyncState
+	found, err := r.get(ctx, namespace, settingsCollection, syncStateKey, &state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync state: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// SaveSyncState stores the upstream sync progress in the settings collection
+func (r *KVRepository) SaveSyncState(ctx context.Context, namespace string, state *models.SyncState) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.store.Set(ctx, namespace, settingsCollection, syncStateKey, value)
+}
+
+// changeKey returns the key of a change; zero padding makes key order match revision order
+func changeKey(revision int64) string {
+	return fmt.Sprintf("%020d", revision)
+}
+
+// listRecords pages through collection in key order starting after cursor and returns up to limit
+// decoded records accepted by match, plus the key of the last record if more matches follow
+func listRecords[T any](ctx context.Context, store kv.KV, namespace, collection, cursor string, limit int, match func(*T) bool) ([]*T, string, error) {
+	return listRecordsUntil(ctx, store, namespace, collection, cursor, "", limit, match)
+}
+
+// listRecordsUntil is listRecords that stops at the first key >= until ("" = no bound)
+func listRecordsUntil[T any](ctx context.Context, store kv.KV, namespace, collection, cursor, until string, limit int, match func(*T) bool) ([]*T, string, error) {
+	records := make([]*T, 0)
+	var last string
+
+	for {
+		page, err := store.List(ctx, namespace, collection, kv.ListOptions{Cursor: cursor, Limit: kv.DefaultListLimit})
+		if err != nil {
+			return nil, "", err
+		}
+
+		keys := make([]kv.Key, len(page.Keys))
+		for i, key := range page.Keys {
+			keys[i] = kv.Key{Namespace: namespace, Collection: collection, Key: key}
+		}
+		results, err := kv.GetMany(ctx, store, keys)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for i, result := range results {
+			if until != "" && page.Keys[i] >= until {
+				return records, "", nil
+			}
+			// Deleted between List and GetMany
+			if !result.Found {
+				continue
+			}
+			record := new(T)
+			if err := json.Unmarshal(result.Value, record); err != nil {
+				return nil, "", fmt.Errorf("failed to decode %s/%s: %w", collection, page.Keys[i], err)
+			}
+			if !match(record) {
+				continue
+			}
+			if len(records) == limit {
+				return records, last, nil
+			}
+			records = append(records, record)
+			last = page.Keys[i]
+		}
+
+		if page.NextCursor == "" {
+			return records, "", nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// indexOwner returns the record ID stored at indexKey, or "" if there is none
+func (r *KVRepository) indexOwner(ctx context.Context, namespace, indexCollection, indexKey string) (string, error) {
+	var owner string
+	if _, err := r.get(ctx, namespace, indexCollection, indexKey, &owner); err != nil {
+		return "", err
+	}
+	return owner, nil
+}
+
+// lookup resolves indexKey through indexCollection and decodes the referenced record into v
+func (r *KVRepository) lookup(ctx context.Context, namespace, indexCollection, collection, indexKey string, v interface{}) (bool, error) {
+	var id string
+	found, err := r.get(ctx, namespace, indexCollection, indexKey, &id)
+	if err != nil || !found {
+		return false, err
+	}
+	return r.get(ctx, namespace, collection, id, v)
+}
+
+// get decodes the JSON value at key into v; a missing key is reported as found == false
+func (r *KVRepository) get(ctx context.Context, namespace, collection, key string, v interface{}) (bool, error) {
+	data, err := r.store.Get(ctx, namespace, collection, key)
+	if err != nil {
+		if errors.Is(err, kv.ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to decode %s/%s: %w", collection, key, err)
+	}
+	return true, nil
+}
+
+// save writes a record and claims its index entry, returning taken if another record owns indexKey
+// On kv.Versioned backends the record is written with compare-and-set and the index entry is claimed
+// with create-if-absent, so two concurrent saves of the same indexKey cannot both succeed; the loser's
+// record is rolled back. Other backends are only serialized within this process
+func (r *KVRepository) save(ctx context.Context, namespace, collection, indexCollection, id, indexKey string, record interface{}, indexOf indexFunc, taken error) error {
+	if id == "" || indexKey == "" {
+		return fmt.Errorf("%s record requires an id and a %s key", collection, indexCollection)
+	}
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	indexValue, err := json.Marshal(id)
+	if err != nil {
+		return err
+	}
+
+	versioned, ok := r.store.(kv.Versioned)
+	if !ok {
+		r.saveMu.Lock()
+		defer r.saveMu.Unlock()
+	}
+
+	// On kv.Versioned backends this only avoids writing a record that is rolled back right away
+	owner, err := r.indexOwner(ctx, namespace, indexCollection, indexKey)
+	if err != nil {
+		return err
+	}
+	if owner != "" && owner != id {
+		live, err := r.indexLive(ctx, namespace, collection, owner, indexKey, indexOf)
+		if err != nil {
+			return err
+		}
+		if live {
+			return taken
+		}
+	}
+
+	if !ok {
+		return r.saveOrdered(ctx, namespace, collection, indexCollection, id, indexKey, value, indexValue, indexOf)
+	}
+	return r.saveVersioned(ctx, versioned, namespace, collection, indexCollection, id, indexKey, value, indexValue, indexOf, taken)
+}
+
+// saveOrdered writes a record and its index entry, removing the entry of the previous indexed value
+// The writes are applied atomically when the backend implements kv.Transactional
+func (r *KVRepository) saveOrdered(ctx context.Context, namespace, collection, indexCollection, id, indexKey string, value, indexValue []byte, indexOf indexFunc) error {
+	staleIndex, err := r.staleIndex(ctx, namespace, collection, indexCollection, id, indexKey, indexOf)
+	if err != nil {
+		return err
+	}
+
+	ops := []kv.Op{
+		{Type: kv.OpSet, Namespace: namespace, Collection: collection, Key: id, Value: value},
+		{Type: kv.OpSet, Namespace: namespace, Collection: indexCollection, Key: indexKey, Value: indexValue},
+	}
+	if staleIndex != "" {
+		ops = append(ops, kv.Op{Type: kv.OpDelete, Namespace: namespace, Collection: indexCollection, Key: staleIndex})
+	}
+
+	// Record first, so a crash in between leaves at most a dangling index entry (treated as missing)
+	return r.apply(ctx, ops)
+}
+
+// staleIndex returns the index key of the stored record if it differs from indexKey and still points at id
+func (r *KVRepository) staleIndex(ctx context.Context, namespace, collection, indexCollection, id, indexKey string, indexOf indexFunc) (string, error) {
+	data, err := r.store.Get(ctx, namespace, collection, id)
+	if errors.Is(err, kv.ErrKeyNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	previous, err := indexOf(data)
+	if err != nil || previous == indexKey {
+		return "", err
+	}
+
+	// Only drop the old index entry if it still points at this record
+	owner, err := r.indexOwner(ctx, namespace, indexCollection, previous)
+	if err != nil || owner != id {
+		return "", err
+	}
+	return previous, nil
+}
+
+// saveVersioned writes the record with compare-and-set, then claims its index entry
+// The record goes first, so a claimed entry always points at a stored record and an entry whose record
+// is missing or no longer matches can be taken over
+func (r *KVRepository) saveVersioned(ctx context.Context, versioned kv.Versioned, namespace, collection, indexCollection, id, indexKey string, value, indexValue []byte, indexOf indexFunc, taken error) error {
+	var previous []byte
+	var version uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		data, current, err := versioned.GetWithVersion(ctx, namespace, collection, id)
+		if errors.Is(err, kv.ErrKeyNotFound) {
+			data, current = nil, 0
+		} else if err != nil {
+			return err
+		}
+
+		version, err = versioned.CompareAndSet(ctx, namespace, collection, id, current, value)
+		if errors.Is(err, kv.ErrVersionMismatch) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		previous = data
+		break
+	}
+
+	claimed, err := r.claimIndex(ctx, versioned, namespace, collection, indexCollection, id, indexKey, indexValue, indexOf)
+	if err != nil || !claimed {
+		r.rollback(ctx, versioned, namespace, collection, id, version, previous)
+		if err != nil {
+			return err
+		}
+		return taken
+	}
+
+	if previous == nil {
+		return nil
+	}
+	previousKey, err := indexOf(previous)
+	if err != nil || previousKey == indexKey {
+		return err
+	}
+	return r.releaseIndex(ctx, versioned, namespace, indexCollection, id, previousKey)
+}
+
+// claimIndex points indexKey at id unless another stored record with that indexed value owns it
+func (r *KVRepository) claimIndex(ctx context.Context, versioned kv.Versioned, namespace, collection, indexCollection, id, indexKey string, indexValue []byte, indexOf indexFunc) (bool, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		data, current, err := versioned.GetWithVersion(ctx, namespace, indexCollection, indexKey)
+		switch {
+		case errors.Is(err, kv.ErrKeyNotFound):
+			current = 0
+		case err != nil:
+			return false, err
+		default:
+			var owner string
+			if err := json.Unmarshal(data, &owner); err != nil {
+				return false, fmt.Errorf("failed to decode %s/%s: %w", indexCollection, indexKey, err)
+			}
+			if owner == id {
+				return true, nil
+			}
+			live, err := r.indexLive(ctx, namespace, collection, owner, indexKey, indexOf)
+			if err != nil || live {
+				return false, err
+			}
+		}
+
+		_, err = versioned.CompareAndSet(ctx, namespace, indexCollection, indexKey, current, indexValue)
+		if errors.Is(err, kv.ErrVersionMismatch) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+// releaseIndex deletes the entry at indexKey if it still points at id
+func (r *KVRepository) releaseIndex(ctx context.Context, versioned kv.Versioned, namespace, indexCollection, id, indexKey string) error {
+	data, version, err := versioned.GetWithVersion(ctx, namespace, indexCollection, indexKey)
+	if errors.Is(err, kv.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var owner string
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return fmt.Errorf("failed to decode %s/%s: %w", indexCollection, indexKey, err)
+	}
+	if owner != id {
+		return nil
+	}
+
+	// A concurrent save claimed or released the entry in the meantime
+	err = versioned.CompareAndDelete(ctx, namespace, indexCollection, indexKey, version)
+	if errors.Is(err, kv.ErrVersionMismatch) || errors.Is(err, kv.ErrKeyNotFound) {
+		return nil
+	}
+	return err
+}
+
+// rollback restores the record written at version to previous (nil = delete it)
+// A record that was written again in the meantime is left alone
+func (r *KVRepository) rollback(ctx context.Context, versioned kv.Versioned, namespace, collection, id string, version uint64, previous []byte) {
+	var err error
+	if previous == nil {
+		err = versioned.CompareAndDelete(ctx, namespace, collection, id, version)
+	} else {
+		_, err = versioned.CompareAndSet(ctx, namespace, collection, id, version, previous)
+	}
+	if err != nil && !errors.Is(err, kv.ErrVersionMismatch) && !errors.Is(err, kv.ErrKeyNotFound) {
+		log.Printf("[Repository] Failed to roll back record: namespace=%s, collection=%s, id=%s, error=%v",
+			namespace, collection, id, err)
+	}
+}
+
+// indexLive reports whether the record owner still exists and has indexKey as its indexed value
+func (r *KVRepository) indexLive(ctx context.Context, namespace, collection, owner, indexKey string, indexOf indexFunc) (bool, error) {
+	data, err := r.store.Get(ctx, namespace, collection, owner)
+	if errors.Is(err, kv.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	value, err := indexOf(data)
+	if err != nil {
+		return false, err
+	}
+	return value == indexKey, nil
+}
+
+// indexFunc returns the indexed value of a stored JSON record
+type indexFunc func(data []byte) (string, error)
+
+// deviceSN is the indexFunc of devices_by_sn
+func deviceSN(data []byte) (string, error) {
+	var device models.Device
+	if err := json.Unmarshal(data, &device); err != nil {
+		return "", fmt.Errorf("failed to decode %s record: %w", DevicesCollection, err)
+	}
+	return device.SN, nil
+}
+
+// cardNumber is the indexFunc of cards_by_number
+func cardNumber(data []byte) (string, error) {
+	var card models.Card
+	if err := json.Unmarshal(data, &card); err != nil {
+		return "", fmt.Errorf("failed to decode %s record: %w", CardsCollection, err)
+	}
+	return card.Number, nil
+}
+
+// remove deletes a record and, if it still points at the record, its index entry
+func (r *KVRepository) remove(ctx context.Context, namespace, collection, indexCollection, id, indexKey string) error {
+	ops := []kv.Op{{Type: kv.OpDelete, Namespace: namespace, Collection: collection, Key: id}}
+
+	owner, err := r.indexOwner(ctx, namespace, indexCollection, indexKey)
+	if err != nil {
+		return err
+	}
+	if owner == id {
+		ops = append(ops, kv.Op{Type: kv.OpDelete, Namespace: namespace, Collection: indexCollection, Key: indexKey})
+	}
+
+	return r.apply(ctx, ops)
+}
+
+// apply runs ops atomically when the backend implements kv.Transactional, otherwise in order
+// A deployment that cannot run transactions (kv.ErrTransactionsUnsupported) also writes in order;
+// the writes are ordered so an interruption leaves at most a dangling index entry
+// In the sequential fallback, deleting a key that is already gone is not an error
+func (r *KVRepository) apply(ctx context.Context, ops []kv.Op) error {
+	if tx, ok := r.store.(kv.Transactional); ok {
+		err := tx.Apply(ctx, ops)
+		if !errors.Is(err, kv.ErrTransactionsUnsupported) {
+			return err
+		}
+	}
+
+	var err error
+	for _, op := range ops {
+		switch op.Type {
+		case kv.OpSet:
+			err = r.store.Set(ctx, op.Namespace, op.Collection, op.Key, op.Value)
+		case kv.OpDelete:
+			err = r.store.Delete(ctx, op.Namespace, op.Collection, op.Key)
+			if errors.Is(err, kv.ErrKeyNotFound) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
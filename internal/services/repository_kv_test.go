@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"commander/internal/database/memory"
+	"commander/internal/kv"
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plainKV hides optional capabilities such as kv.Transactional
+type plainKV struct {
+	kv.KV
+}
+
+// standaloneKV is a kv.Transactional backend on a deployment that cannot run transactions
+type standaloneKV struct {
+	*memory.MemoryKV
+}
+
+func (s standaloneKV) Apply(context.Context, []kv.Op) error {
+	return kv.ErrTransactionsUnsupported
+}
+
+func newTestKVRepository(t *testing.T, transactional bool) (*KVRepository, kv.KV) {
+	t.Helper()
+	store, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	if !transactional {
+		return NewKVRepository(plainKV{store}), store
+	}
+	return NewKVRepository(store), store
+}
+
+func TestKVRepository_Lookup(t *testing.T) {
+	for _, transactional := range []bool{true, false} {
+		name := "non-transactional"
+		if transactional {
+			name = "transactional"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			repo, store := newTestKVRepository(t, transactional)
+			ctx := context.Background()
+
+			device := &models.Device{ID: "device-1", DeviceID: "device-1", SN: "SN-001", Status: "active"}
+			require.NoError(t, repo.SaveDevice(ctx, "hotel_a", device))
+
+			card := &models.Card{
+				ID:          "card-1",
+				Number:      "12345",
+				Devices:     []string{"SN-001"},
+				EffectiveAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+				InvalidAt:   time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+			}
+			require.NoError(t, repo.SaveCard(ctx, "hotel_a", card))
+
+			gotDevice, err := repo.GetDeviceBySN(ctx, "hotel_a", "SN-001")
+			require.NoError(t, err)
+			assert.Equal(t, device.ID, gotDevice.ID)
+			assert.Equal(t, "active", gotDevice.Status)
+
+			gotCard, err := repo.GetCardByNumber(ctx, "hotel_a", "12345")
+			require.NoError(t, err)
+			assert.Equal(t, card.Devices, gotCard.Devices)
+			assert.True(t, card.EffectiveAt.Equal(gotCard.EffectiveAt))
+
+			// Namespaces are isolated
+			_, err = repo.GetCardByNumber(ctx, "hotel_b", "12345")
+			assert.ErrorIs(t, err, ErrCardNotFound)
+			_, err = repo.GetDeviceBySN(ctx, "hotel_a", "SN-999")
+			assert.ErrorIs(t, err, ErrDeviceNotFound)
+
+			// Changing the number moves the index entry
+			card.Number = "67890"
+			require.NoError(t, repo.SaveCard(ctx, "hotel_a", card))
+			_, err = repo.GetCardByNumber(ctx, "hotel_a", "12345")
+			assert.ErrorIs(t, err, ErrCardNotFound)
+			exists, err := store.Exists(ctx, "hotel_a", cardsByNumberCollection, "12345")
+			require.NoError(t, err)
+			assert.False(t, exists, "old index entry should be removed")
+			_, err = repo.GetCardByNumber(ctx, "hotel_a", "67890")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestKVRepository_TransactionsUnsupported(t *testing.T) {
+	store, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	repo := NewKVRepository(standaloneKV{store})
+	ctx := context.Background()
+
+	// Writes fall back to the ordered sequence used by backends without transactions
+	card := &models.Card{ID: "card-1", Number: "12345"}
+	require.NoError(t, repo.SaveCard(ctx, "hotel_a", card))
+	got, err := repo.GetCardByNumber(ctx, "hotel_a", "12345")
+	require.NoError(t, err)
+	assert.Equal(t, "card-1", got.ID)
+
+	require.NoError(t, repo.DeleteCard(ctx, "hotel_a", "card-1"))
+	_, err = repo.GetCardByNumber(ctx, "hotel_a", "12345")
+	assert.ErrorIs(t, err, ErrCardNotFound)
+}
+
+func TestKVRepository_StaleIndex(t *testing.T) {
+	repo, store := newTestKVRepository(t, true)
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveCard(ctx, "default", &models.Card{ID: "card-1", Number: "111"}))
+
+	// Index entry pointing at a record with a different number is treated as missing
+	require.NoError(t, store.Set(ctx, "default", cardsByNumberCollection, "222", []byte(`"card-1"`)))
+	_, err := repo.GetCardByNumber(ctx, "default", "222")
+	assert.ErrorIs(t, err, ErrCardNotFound)
+
+	// Index entry pointing at a deleted record is treated as missing
+	require.NoError(t, store.Delete(ctx, "default", CardsCollection, "card-1"))
+	_, err = repo.GetCardByNumber(ctx, "default", "111")
+	assert.ErrorIs(t, err, ErrCardNotFound)
+}
+
+func TestKVRepository_SaveKeepsForeignIndex(t *testing.T) {
+	repo, store := newTestKVRepository(t, true)
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveCard(ctx, "default", &models.Card{ID: "card-1", Number: "111"}))
+	require.NoError(t, repo.SaveCard(ctx, "default", &models.Card{ID: "card-2", Number: "222"}))
+
+	// A number taken by another card is rejected
+	assert.ErrorIs(t, repo.SaveCard(ctx, "default", &models.Card{ID: "card-2", Number: "111"}), ErrCardNumberExists)
+
+	// An index entry owned by another record is left alone when card-1 moves away from it
+	require.NoError(t, store.Set(ctx, "default", cardsByNumberCollection, "111", []byte(`"card-2"`)))
+	require.NoError(t, repo.SaveCard(ctx, "default", &models.Card{ID: "card-1", Number: "333"}))
+	owner, err := repo.indexOwner(ctx, "default", cardsByNumberCollection, "111")
+	require.NoError(t, err)
+	assert.Equal(t, "card-2", owner)
+}
+
+func TestKVRepository_DeleteCard(t *testing.T) {
+	for _, transactional := range []bool{true, false} {
+		repo, store := newTestKVRepository(t, transactional)
+		ctx := context.Background()
+
+		require.NoError(t, repo.SaveCard(ctx, "default", &models.Card{ID: "card-1", Number: "111"}))
+		require.NoError(t, repo.DeleteCard(ctx, "default", "card-1"))
+
+		_, err := repo.GetCard(ctx, "default", "card-1")
+		assert.ErrorIs(t, err, ErrCardNotFound)
+		exists, err := store.Exists(ctx, "default", cardsByNumberCollection, "111")
+		require.NoError(t, err)
+		assert.False(t, exists, "index entry should be removed")
+
+		assert.ErrorIs(t, repo.DeleteCard(ctx, "default", "card-1"), ErrCardNotFound)
+	}
+}
+
+func TestKVRepository_ListCardsPaging(t *testing.T) {
+	repo, _ := newTestKVRepository(t, false)
+	ctx := context.Background()
+
+	// More cards than one backend page
+	for i := 0; i < 1500; i++ {
+		card := &models.Card{ID: fmt.Sprintf("card-%04d", i), Number: fmt.Sprintf("%04d", i)}
+		require.NoError(t, repo.SaveCard(ctx, "default", card))
+	}
+
+	cards, cursor, err := repo.ListCards(ctx, "default", CardQuery{Number: "14", Limit: 50})
+	require.NoError(t, err)
+	assert.Len(t, cards, 50)
+	assert.Equal(t, "card-1449", cursor)
+
+	cards, cursor, err = repo.ListCards(ctx, "default", CardQuery{Number: "14", Limit: 50, Cursor: cursor})
+	require.NoError(t, err)
+	assert.Len(t, cards, 50)
+	assert.Empty(t, cursor)
+}
+
+func TestKVRepository_SaveValidation(t *testing.T) {
+	repo, _ := newTestKVRepository(t, true)
+	ctx := context.Background()
+
+	assert.Error(t, repo.SaveCard(ctx, "default", &models.Card{Number: "111"}))
+	assert.Error(t, repo.SaveDevice(ctx, "default", &models.Device{ID: "device-1"}))
+}
+
+func TestKVRepository_ListDeviceGroups(t *testing.T) {
+	repo, _ := newTestKVRepository(t, false)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		group := &models.DeviceGroup{ID: fmt.Sprintf("group-%d", i), Devices: []string{fmt.Sprintf("SN-%d", i%2)}}
+		require.NoError(t, repo.SaveDeviceGroup(ctx, "default", group))
+	}
+
+	groups, cursor, err := repo.ListDeviceGroups(ctx, "default", DeviceGroupQuery{Device: "SN-0", Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, groups, 2)
+	assert.Equal(t, "group-2", cursor)
+
+	groups, cursor, err = repo.ListDeviceGroups(ctx, "default", DeviceGroupQuery{Device: "SN-0", Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+	assert.Len(t, groups, 1)
+	assert.Empty(t, cursor)
+
+	_, err = repo.GetDeviceGroup(ctx, "default", "group-9")
+	assert.ErrorIs(t, err, ErrDeviceGroupNotFound)
+}
+
+func TestKVRepository_SaveCardConcurrent(t *testing.T) {
+	for _, versioned := range []bool{true, false} {
+		t.Run(fmt.Sprintf("versioned=%v", versioned), func(t *testing.T) {
+			repo, store := newTestKVRepository(t, versioned)
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			winners := make([]string, 0, 1)
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(id string) {
+					defer wg.Done()
+					err := repo.SaveCard(ctx, "default", &models.Card{ID: id, Number: "111"})
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						winners = append(winners, id)
+					case !errors.Is(err, ErrCardNumberExists):
+						t.Errorf("unexpected error: %v", err)
+					}
+				}(fmt.Sprintf("card-%d", i))
+			}
+			wg.Wait()
+
+			require.Len(t, winners, 1)
+			card, err := repo.GetCardByNumber(ctx, "default", "111")
+			require.NoError(t, err)
+			assert.Equal(t, winners[0], card.ID)
+
+			// The losing records are not left behind
+			page, err := store.List(ctx, "default", CardsCollection, kv.ListOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, winners, page.Keys)
+		})
+	}
+}
+
+func TestKVRepository_ConsumeCardUseConcurrent(t *testing.T) {
+	for _, versioned := range []bool{true, false} {
+		t.Run(fmt.Sprintf("versioned=%v", versioned), func(t *testing.T) {
+			repo, _ := newTestKVRepository(t, versioned)
+			ctx := context.Background()
+			require.NoError(t, repo.SaveCard(ctx, "default", &models.Card{ID: "card-1", Number: "111", MaxUses: 5}))
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			granted, exhausted := 0, 0
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := repo.ConsumeCardUse(ctx, "default", "card-1")
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						granted++
+					case errors.Is(err, ErrCardUsageExhausted):
+						exhausted++
+					default:
+						t.Errorf("unexpected error: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, 5, granted)
+			assert.Equal(t, 15, exhausted)
+			card, err := repo.GetCard(ctx, "default", "card-1")
+			require.NoError(t, err)
+			assert.Equal(t, 5, card.UseCount)
+
+			_, err = repo.ConsumeCardUse(ctx, "default", "missing")
+			assert.ErrorIs(t, err, ErrCardNotFound)
+		})
+	}
+}
+
+func TestKVRepository_ListPresence(t *testing.T) {
+	repo, _ := newTestKVRepository(t, false)
+	ctx := context.Background()
+
+	// card-1 is a prefix of card-10, whose zones must not be returned for card-1
+	for _, p := range []*models.Presence{
+		{CardID: "card-1", Zone: "parking", Inside: true},
+		{CardID: "card-1", Zone: "gym"},
+		{CardID: "card-10", Zone: "parking"},
+		{CardID: "card-2", Zone: "parking"},
+	} {
+		require.NoError(t, repo.SavePresence(ctx, "default", p))
+	}
+
+	presence, err := repo.ListPresence(ctx, "default", "card-1")
+	require.NoError(t, err)
+	require.Len(t, presence, 2)
+	assert.Equal(t, "gym", presence[0].Zone)
+	assert.Equal(t, "parking", presence[1].Zone)
+
+	require.NoError(t, repo.DeletePresence(ctx, "default", "card-1", "parking"))
+	require.NoError(t, repo.DeletePresence(ctx, "default", "card-1", "parking"))
+	p, err := repo.GetPresence(ctx, "default", "card-1", "parking")
+	require.NoError(t, err)
+	assert.Nil(t, p)
+}
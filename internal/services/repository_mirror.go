@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"commander/internal/kv"
+	"commander/internal/models"
+)
+
+// Repository mirror defaults
+const (
+	DefaultMirrorResyncInterval = 10 * time.Minute
+
+	// mirrorResyncAttempts is how often a namespace is read again when writes keep interleaving with a resync
+	mirrorResyncAttempts = 3
+)
+
+// MirroredRepository keeps a local copy of the records card verification reads (devices, cards, device
+// groups, revocations and the namespace policy) of a primary repository, the source of truth
+// It mirrors a primary whose records a KV mirror cannot copy, such as the native documents of a MongoRepository
+//
+// Verification reads are served by the local copy and fall back to the primary when it does not have the
+// record or fails; records found that way are copied locally. Writes go to the primary first and are then
+// applied to the local copy, so an instance always reads its own writes. Listing, presence, access logs and
+// the change feed always use the primary
+//
+// Writes made to the primary by other instances reach the local copy with the next resync, which copies
+// every record of the primary and removes the records it no longer has. Until then the local copy can
+// serve the previous record
+type MirroredRepository struct {
+	Repository // primary
+
+	local    *KVRepository
+	lister   kv.Lister
+	interval time.Duration
+
+	// mu orders writes against copies made outside them (read repair and resync): generation increases
+	// with every write, and a copy is only applied if it did not change since the copied records were
+	// read from the primary
+	mu         sync.Mutex
+	generation uint64
+}
+
+// NewMirroredRepository mirrors primary into the local store
+// lister names the namespaces compared by a resync; interval is DefaultMirrorResyncInterval if 0 and
+// negative to resync only at startup
+func NewMirroredRepository(primary Repository, local kv.KV, lister kv.Lister, interval time.Duration) *MirroredRepository {
+	if interval == 0 {
+		interval = DefaultMirrorResyncInterval
+	}
+	return &MirroredRepository{
+		Repository: primary,
+		local:      NewKVRepository(local),
+		lister:     lister,
+		interval:   interval,
+	}
+}
+
+// Run resyncs at startup and then every resync interval until ctx is cancelled
+func (m *MirroredRepository) Run(ctx context.Context) {
+	var ticker <-chan time.Time
+	if m.interval > 0 {
+		timer := time.NewTicker(m.interval)
+		defer timer.Stop()
+		ticker = timer.C
+	}
+
+	for {
+		start := time.Now()
+		if err := m.Resync(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[RepositoryMirror] Resync failed: error=%v", err)
+		} else if err == nil {
+			log.Printf("[RepositoryMirror] Resync finished: duration=%s", time.Since(start).Round(time.Millisecond))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker:
+		}
+	}
+}
+
+// Resync makes the local copy of every namespace of the primary equal to the primary
+func (m *MirroredRepository) Resync(ctx context.Context) error {
+	namespaces, err := m.lister.ListNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		if err := m.resyncNamespace(ctx, namespace); err != nil {
+			return fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+	}
+	return nil
+}
+
+// resyncNamespace copies namespace unless a write happens while it is read from the primary
+// After mirrorResyncAttempts the namespace is left to the next resync
+func (m *MirroredRepository) resyncNamespace(ctx context.Context, namespace string) error {
+	for attempt := 0; attempt < mirrorResyncAttempts; attempt++ {
+		generation := m.currentGeneration()
+		snapshot, err := readSnapshot(ctx, m.Repository, namespace)
+		if err != nil {
+			return err
+		}
+
+		m.mu.Lock()
+		if m.generation == generation {
+			err := applySnapshot(ctx, m.local, namespace, snapshot)
+			m.mu.Unlock()
+			return err
+		}
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// currentGeneration returns the write counter before a primary read that will be copied
+func (m *MirroredRepository) currentGeneration() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.generation
+}
+
+// repair copies a record read from the primary unless a write happened since generation
+func (m *MirroredRepository) repair(ctx context.Context, namespace string, generation uint64, copyRecord func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.generation != generation {
+		return
+	}
+	if err := copyRecord(context.WithoutCancel(ctx)); err != nil {
+		log.Printf("[RepositoryMirror] Read repair failed: namespace=%s, error=%v", namespace, err)
+	}
+}
+
+// mirrorWrite applies a write that succeeded on the primary to the local copy
+// Failures are logged; the next resync repairs the local copy
+func (m *MirroredRepository) mirrorWrite(ctx context.Context, namespace string, write func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generation++
+	if err := write(context.WithoutCancel(ctx)); err != nil && !isNotFound(err) {
+		log.Printf("[RepositoryMirror] Mirror write failed: namespace=%s, error=%v", namespace, err)
+	}
+}
+
+// isNotFound reports whether err is the not-found error of a record kind
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrDeviceNotFound) || errors.Is(err, ErrCardNotFound) ||
+		errors.Is(err, ErrDeviceGroupNotFound) || errors.Is(err, ErrRevocationNotFound)
+}
+
+// mirroredRead runs read against the local copy and falls back to the primary, copying what it finds
+func mirroredRead[T any](ctx context.Context, m *MirroredRepository, namespace string, read func(Repository) (T, error), copyRecord func(context.Context, T) error) (T, error) {
+	value, err := read(m.local)
+	if err == nil {
+		return value, nil
+	}
+	if !isNotFound(err) && ctx.Err() == nil {
+		log.Printf("[RepositoryMirror] Local read failed, using primary: namespace=%s, error=%v", namespace, err)
+	}
+
+	generation := m.currentGeneration()
+	value, err = read(m.Repository)
+	if err != nil {
+		return value, err
+	}
+	m.repair(ctx, namespace, generation, func(ctx context.Context) error { return copyRecord(ctx, value) })
+	return value, nil
+}
+
+// GetDeviceBySN reads the device from the local copy, falling back to the primary
+func (m *MirroredRepository) GetDeviceBySN(ctx context.Context, namespace, sn string) (*models.Device, error) {
+	return mirroredRead(ctx, m, namespace, func(repo Repository) (*models.Device, error) {
+		return repo.GetDeviceBySN(ctx, namespace, sn)
+	}, func(ctx context.Context, device *models.Device) error {
+		return replicateDevice(ctx, m.local, namespace, device)
+	})
+}
+
+// GetDevice reads the device from the local copy, falling back to the primary
+func (m *MirroredRepository) GetDevice(ctx context.Context, namespace, id string) (*models.Device, error) {
+	return mirroredRead(ctx, m, namespace, func(repo Repository) (*models.Device, error) {
+		return repo.GetDevice(ctx, namespace, id)
+	}, func(ctx context.Context, device *models.Device) error {
+		return replicateDevice(ctx, m.local, namespace, device)
+	})
+}
+
+// GetCardByNumber reads the card from the local copy, falling back to the primary
+func (m *MirroredRepository) GetCardByNumber(ctx context.Context, namespace, number string) (*models.Card, error) {
+	return mirroredRead(ctx, m, namespace, func(repo Repository) (*models.Card, error) {
+		return repo.GetCardByNumber(ctx, namespace, number)
+	}, func(ctx context.Context, card *models.Card) error {
+		return replicateCard(ctx, m.local, namespace, card)
+	})
+}
+
+// GetCard reads the card from the local copy, falling back to the primary
+func (m *MirroredRepository) GetCard(ctx context.Context, namespace, id string) (*models.Card, error) {
+	return mirroredRead(ctx, m, namespace, func(repo Repository) (*models.Card, error) {
+		return repo.GetCard(ctx, namespace, id)
+	}, func(ctx context.Context, card *models.Card) error {
+		return replicateCard(ctx, m.local, namespace, card)
+	})
+}
+
+// GetDeviceGroup reads the device group from the local copy, falling back to the primary
+func (m *MirroredRepository) GetDeviceGroup(ctx context.Context, namespace, id string) (*models.DeviceGroup, error) {
+	return mirroredRead(ctx, m, namespace, func(repo Repository) (*models.DeviceGroup, error) {
+		return repo.GetDeviceGroup(ctx, namespace, id)
+	}, func(ctx context.Context, group *models.DeviceGroup) error {
+		return m.local.SaveDeviceGroup(ctx, namespace, group)
+	})
+}
+
+// GetRevocation reads the revocation from the local copy, falling back to the primary
+// A revocation the local copy does not have is always looked up on the primary, so new revocations
+// apply before the next resync
+func (m *MirroredRepository) GetRevocation(ctx context.Context, namespace, id string) (*models.Revocation, error) {
+	return mirroredRead(ctx, m, namespace, func(repo Repository) (*models.Revocation, error) {
+		return repo.GetRevocation(ctx, namespace, id)
+	}, func(ctx context.Context, revocation *models.Revocation) error {
+		return m.local.SaveRevocation(ctx, namespace, revocation)
+	})
+}
+
+// GetPolicy reads the policy overrides from the local copy, falling back to the primary
+// A namespace without overrides is copied as an empty policy, so it is not read from the primary again
+func (m *MirroredRepository) GetPolicy(ctx context.Context, namespace string) (*models.NamespacePolicy, error) {
+	policy, err := m.local.GetPolicy(ctx, namespace)
+	if err == nil && policy != nil {
+		return policy, nil
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Printf("[RepositoryMirror] Local read failed, using primary: namespace=%s, error=%v", namespace, err)
+	}
+
+	generation := m.currentGeneration()
+	policy, err = m.Repository.GetPolicy(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	m.repair(ctx, namespace, generation, func(ctx context.Context) error {
+		return replicatePolicy(ctx, m.local, namespace, policy)
+	})
+	return policy, nil
+}
+
+// SaveDevice stores the device on the primary and in the local copy
+func (m *MirroredRepository) SaveDevice(ctx context.Context, namespace string, device *models.Device) error {
+	if err := m.Repository.SaveDevice(ctx, namespace, device); err != nil {
+		return err
+	}
+	m.mirrorWrite(ctx, namespace, func(ctx context.Context) error { return replicateDevice(ctx, m.local, namespace, device) })
+	return nil
+}
+
+// DeleteDevice removes the device from the primary and from the local copy
+func (m *MirroredRepository) DeleteDevice(ctx context.Context, namespace, id string) error {
+	if err := m.Repository.DeleteDevice(ctx, namespace, id); err != nil {
+		return err
+	}
+	m.mirrorWrite(ctx, namespace, func(ctx context.Context) error { return m.local.DeleteDevice(ctx, namespace, id) })
+	return nil
+}
+
+// SaveCard stores the card on the primary and in the local copy
+func (m *MirroredRepository) SaveCard(ctx context.Context, namespace string, card *models.Card) error {
+	if err := m.Repository.SaveCard(ctx, namespace, card); err != nil {
+		return err
+	}
+	m.mirrorWrite(ctx, namespace, func(ctx context.Context) error { return replicateCard(ctx, m.local, namespace, card) })
+	return nil
+}
+
+// DeleteCard removes the card from the primary and from the local copy
+func (m *MirroredRepository) DeleteCard(ctx context.Context, namespace, id string) error {
+	if err := m.Repository.DeleteCard(ctx, namespace, id); err != nil {
+		return err
+	}
+	m.mirrorWrite(ctx, namespace, func(ctx context.Context) error { return m.local.DeleteCard(ctx, namespace, id) })
+	return nil
+}
+
+// ConsumeCardUse counts the use on the primary and copies the updated card
+func (m *MirroredRepository) ConsumeCardUse(ctx context.Context, namespace, id string) (*models.Card, error) {
+	card, err := m.Repository.ConsumeCardUse(ctx, namespace, id)
+	if err != nil {
+		return nil, err
+	}
+	m.mirrorWrite(ctx, namespace, func(ctx context.Context) error { return replicateCard(ctx, m.local, namespace, card) })
+	return card, nil
+}
+
+// SaveDeviceGroup stores the device group on the primary and in the local copy
+func (m *MirroredRepository) SaveDeviceGroup(ctx context.Context, namespace string, group *models.DeviceGroup) error {
+	if err := m.Repository.SaveDeviceGroup(ctx, namespace, group); err != nil {
+		return err
+	}
+	m.mirrorWrite(ctx, namespace, func(ctx context.Context) error { return m.local.SaveDeviceGroup(ctx, namespace, group) })
+	return nil
+}
+
+// DeleteDeviceGroup removes the device group from the primary and from the local copy
+func (m *MirroredRepository) DeleteDeviceGroup(ctx context.Context, namespace, id string) error {
+	if err := m.Repository.DeleteDeviceGroup(ctx, namespace, id); err != nil {
+		return err
+	}
+	m.mirrorWrite(ctx, namespace, func(ctx context.Context) error { return m.local.DeleteDeviceGroup(ctx, namespace, id) })
+	return nil
+}
+
+// SaveRevocation stores the revocation on the primary and in the local copy
+func (m *MirroredRepository) SaveRevocation(ctx context.Context, namespace string, revocation *models.Revocation) error {
+	if err := m.Repository.SaveRevocation(ctx, namespace, revocation); err != nil {
+		return err
+	}
+	m.mirrorWrite(ctx, namespace, func(ctx context.Context) error {
+		return m.local.SaveRevocation(ctx, namespace, revocation)
+	})
+	return nil
+}
+
+// DeleteRevocation removes the revocation from the primary and from the local copy
+func (m *MirroredRepository) DeleteRevocation(ctx context.Context, namespace, id string) error {
+	if err := m.Repository.DeleteRevocation(ctx, namespace, id); err != nil {
+		return err
+	}
+	m.mirrorWrite(ctx, namespace, func(ctx context.Context) error { return m.local.DeleteRevocation(ctx, namespace, id) })
+	return nil
+}
+
+// SavePolicy stores the policy overrides on the primary and in the local copy
+func (m *MirroredRepository) SavePolicy(ctx context.Context, namespace string, policy *models.NamespacePolicy) error {
+	if err := m.Repository.SavePolicy(ctx, namespace, policy); err != nil {
+		return err
+	}
+	m.mirrorWrite(ctx, namespace, func(ctx context.Context) error { return replicatePolicy(ctx, m.local, namespace, policy) })
+	return nil
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"commander/internal/database/memory"
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMirror returns a mirror over a KV repository standing in for the primary, the primary itself
+// and the local repository, seeded on the primary with device SN-001 and card GUEST-1
+func newTestMirror(t *testing.T) (*MirroredRepository, *KVRepository, *KVRepository) {
+	t.Helper()
+	store, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	localStore, err := memory.NewMemoryKV("")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = localStore.Close() })
+
+	primary := NewKVRepository(store)
+	ctx := context.Background()
+	require.NoError(t, primary.SaveDevice(ctx, "hotel_a", &models.Device{ID: "device-1", DeviceID: "lobby", SN: "SN-001"}))
+	card := testCard("GUEST-1", "SN-001")
+	card.ID = "card-1"
+	require.NoError(t, primary.SaveCard(ctx, "hotel_a", card))
+
+	return NewMirroredRepository(primary, localStore, store, -1), primary, NewKVRepository(localStore)
+}
+
+func TestMirroredRepository_ReadRepair(t *testing.T) {
+	mirror, primary, local := newTestMirror(t)
+	ctx := context.Background()
+
+	card, err := mirror.GetCardByNumber(ctx, "hotel_a", "GUEST-1")
+	require.NoError(t, err)
+	assert.Equal(t, "card-1", card.ID)
+	copied, err := local.GetCardByNumber(ctx, "hotel_a", "GUEST-1")
+	require.NoError(t, err, "a card read from the primary should be copied locally")
+	assert.Equal(t, "card-1", copied.ID)
+
+	policy, err := mirror.GetPolicy(ctx, "hotel_a")
+	require.NoError(t, err)
+	assert.Nil(t, policy, "a namespace without overrides should report none")
+	stored, err := local.GetPolicy(ctx, "hotel_a")
+	require.NoError(t, err)
+	assert.NotNil(t, stored, "a namespace without overrides should be copied as an empty policy")
+
+	// A change made by another instance is served from the local copy until the next resync
+	card.DisplayName = "Renamed"
+	require.NoError(t, primary.SaveCard(ctx, "hotel_a", card))
+	card, err = mirror.GetCardByNumber(ctx, "hotel_a", "GUEST-1")
+	require.NoError(t, err)
+	assert.Empty(t, card.DisplayName)
+
+	require.NoError(t, mirror.Resync(ctx))
+	card, err = mirror.GetCardByNumber(ctx, "hotel_a", "GUEST-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", card.DisplayName)
+}
+
+func TestMirroredRepository_Writes(t *testing.T) {
+	mirror, primary, local := newTestMirror(t)
+	ctx := context.Background()
+
+	card := testCard("GUEST-2", "SN-001")
+	card.ID = "card-2"
+	require.NoError(t, mirror.SaveCard(ctx, "hotel_a", card))
+	for _, repo := range []Repository{primary, local} {
+		stored, err := repo.GetCard(ctx, "hotel_a", "card-2")
+		require.NoError(t, err)
+		assert.Equal(t, "GUEST-2", stored.Number)
+	}
+
+	revocation := &models.Revocation{ID: models.RevocationID(models.RevocationByCardID, "card-2"), CardID: "card-2", RevokedAt: time.Now().UTC()}
+	require.NoError(t, mirror.SaveRevocation(ctx, "hotel_a", revocation))
+	_, err := local.GetRevocation(ctx, "hotel_a", revocation.ID)
+	require.NoError(t, err)
+
+	// Deleting a record the local copy never had is not an error
+	require.NoError(t, mirror.DeleteDevice(ctx, "hotel_a", "device-1"))
+	_, err = primary.GetDevice(ctx, "hotel_a", "device-1")
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+
+	require.NoError(t, mirror.DeleteCard(ctx, "hotel_a", "card-2"))
+	_, err = local.GetCard(ctx, "hotel_a", "card-2")
+	assert.ErrorIs(t, err, ErrCardNotFound)
+}
+
+func TestMirroredRepository_ResyncRemovesDeleted(t *testing.T) {
+	mirror, primary, local := newTestMirror(t)
+	ctx := context.Background()
+
+	require.NoError(t, mirror.Resync(ctx))
+	_, err := local.GetDeviceBySN(ctx, "hotel_a", "SN-001")
+	require.NoError(t, err)
+
+	// Deleted on the primary by another instance
+	require.NoError(t, primary.DeleteCard(ctx, "hotel_a", "card-1"))
+	require.NoError(t, mirror.Resync(ctx))
+	_, err = mirror.GetCardByNumber(ctx, "hotel_a", "GUEST-1")
+	assert.ErrorIs(t, err, ErrCardNotFound)
+	_, err = local.GetCard(ctx, "hotel_a", "card-1")
+	assert.ErrorIs(t, err, ErrCardNotFound)
+}
+
+func TestMirroredRepository_RepairSkippedAfterWrite(t *testing.T) {
+	mirror, _, local := newTestMirror(t)
+	ctx := context.Background()
+
+	// A copy read before a write must not overwrite it
+	generation := mirror.currentGeneration()
+	stale, err := mirror.Repository.GetCard(ctx, "hotel_a", "card-1")
+	require.NoError(t, err)
+	updated := *stale
+	updated.DisplayName = "Renamed"
+	require.NoError(t, mirror.SaveCard(ctx, "hotel_a", &updated))
+
+	mirror.repair(ctx, "hotel_a", generation, func(ctx context.Context) error {
+		return replicateCard(ctx, mirror.local, "hotel_a", stale)
+	})
+	card, err := local.GetCard(ctx, "hotel_a", "card-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", card.DisplayName)
+}
@@ -0,0 +1,712 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"commander/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRepository reads devices and cards from MongoDB
+// Each namespace is a database with "devices" and "cards" collections
+type MongoRepository struct {
+	client *mongo.Client
+
+	// Namespaces whose access_logs TTL index has been created
+	accessLogIndexes sync.Map
+
+	// Namespace/collection pairs whose unique sn or number index has been created
+	uniqueIndexes sync.Map
+
+	// changeMu serializes AppendChange, so revisions of this process are stored in order
+	changeMu sync.Mutex
+}
+
+// NewMongoRepository creates a repository backed by a MongoDB client
+func NewMongoRepository(client *mongo.Client) *MongoRepository {
+	return &MongoRepository{
+		client: client,
+	}
+}
+
+// GetDeviceBySN retrieves a device by SN from the devices collection
+func (r *MongoRepository) GetDeviceBySN(ctx context.Context, namespace, sn string) (*models.Device, error) {
+	collection := r.client.Database(namespace).Collection(DevicesCollection)
+
+	var device models.Device
+	err := collection.FindOne(ctx, bson.M{"sn": sn}).Decode(&device)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, fmt.Errorf("failed to query device: %w", err)
+	}
+
+	return &device, nil
+}
+
+// GetDevice retrieves a device by _id from the devices collection
+func (r *MongoRepository) GetDevice(ctx context.Context, namespace, id string) (*models.Device, error) {
+	collection := r.client.Database(namespace).Collection(DevicesCollection)
+
+	var device models.Device
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&device)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, fmt.Errorf("failed to query device: %w", err)
+	}
+
+	return &device, nil
+}
+
+// ListDevices queries the devices collection ordered by _id
+func (r *MongoRepository) ListDevices(ctx context.Context, namespace string, query DeviceQuery) ([]*models.Device, string, error) {
+	collection := r.client.Database(namespace).Collection(DevicesCollection)
+	limit := query.limit()
+
+	filter := bson.M{}
+	if query.Cursor != "" {
+		filter["_id"] = bson.M{"$gt": query.Cursor}
+	}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+
+	// Fetch one extra device to know whether there is a next page
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit) + 1)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list devices: %w", err)
+	}
+	defer cursor.Close(ctx) //nolint:errcheck // Best effort cursor cleanup
+
+	devices := make([]*models.Device, 0)
+	if err := cursor.All(ctx, &devices); err != nil {
+		return nil, "", fmt.Errorf("failed to decode devices: %w", err)
+	}
+
+	if len(devices) > limit {
+		devices = devices[:limit]
+		return devices, devices[limit-1].ID, nil
+	}
+	return devices, "", nil
+}
+
+// SaveDevice replaces (or inserts) a device by _id after checking the SN is not used by another device
+// A unique index on sn, created on the first save to the namespace, rejects concurrent duplicates
+func (r *MongoRepository) SaveDevice(ctx context.Context, namespace string, device *models.Device) error {
+	collection := r.client.Database(namespace).Collection(DevicesCollection)
+	if err := r.ensureUniqueIndex(ctx, namespace, collection, "sn"); err != nil {
+		return err
+	}
+
+	err := collection.FindOne(ctx, bson.M{"sn": device.SN, "_id": bson.M{"$ne": device.ID}}).Err()
+	if err == nil {
+		return ErrDeviceSNExists
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("failed to query device: %w", err)
+	}
+
+	// The unique index catches a concurrent save of the same SN that passed the check above
+	_, err = collection.ReplaceOne(ctx, bson.M{"_id": device.ID}, device, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDeviceSNExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save device: %w", err)
+	}
+	return nil
+}
+
+// DeleteDevice removes a device by _id
+func (r *MongoRepository) DeleteDevice(ctx context.Context, namespace, id string) error {
+	collection := r.client.Database(namespace).Collection(DevicesCollection)
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+// GetCardByNumber retrieves a card by number from the cards collection
+func (r *MongoRepository) GetCardByNumber(ctx context.Context, namespace, number string) (*models.Card, error) {
+	collection := r.client.Database(namespace).Collection(CardsCollection)
+
+	var card models.Card
+	err := collection.FindOne(ctx, bson.M{"number": number}).Decode(&card)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrCardNotFound
+		}
+		return nil, fmt.Errorf("failed to query card: %w", err)
+	}
+
+	return &card, nil
+}
+
+// GetCard retrieves a card by _id from the cards collection
+func (r *MongoRepository) GetCard(ctx context.Context, namespace, id string) (*models.Card, error) {
+	collection := r.client.Database(namespace).Collection(CardsCollection)
+
+	var card models.Card
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&card)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrCardNotFound
+		}
+		return nil, fmt.Errorf("failed to query card: %w", err)
+	}
+
+	return &card, nil
+}
+
+// ListCards queries the cards collection with the filters translated by cardQueryFilter
+func (r *MongoRepository) ListCards(ctx context.Context, namespace string, query CardQuery) ([]*models.Card, string, error) {
+	collection := r.client.Database(namespace).Collection(CardsCollection)
+	limit := query.limit()
+
+	// Fetch one extra card to know whether there is a next page
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit) + 1)
+	cursor, err := collection.Find(ctx, cardQueryFilter(query), opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list cards: %w", err)
+	}
+	defer cursor.Close(ctx) //nolint:errcheck // Best effort cursor cleanup
+
+	cards := make([]*models.Card, 0)
+	if err := cursor.All(ctx, &cards); err != nil {
+		return nil, "", fmt.Errorf("failed to decode cards: %w", err)
+	}
+
+	if len(cards) > limit {
+		cards = cards[:limit]
+		return cards, cards[limit-1].ID, nil
+	}
+	return cards, "", nil
+}
+
+// SaveCard replaces (or inserts) a card by _id after checking the number is not used by another card
+// A unique index on number, created on the first save to the namespace, rejects concurrent duplicates
+func (r *MongoRepository) SaveCard(ctx context.Context, namespace string, card *models.Card) error {
+	collection := r.client.Database(namespace).Collection(CardsCollection)
+	if err := r.ensureUniqueIndex(ctx, namespace, collection, "number"); err != nil {
+		return err
+	}
+
+	err := collection.FindOne(ctx, bson.M{"number": card.Number, "_id": bson.M{"$ne": card.ID}}).Err()
+	if err == nil {
+		return ErrCardNumberExists
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("failed to query card: %w", err)
+	}
+
+	// The unique index catches a concurrent save of the same number that passed the check above
+	_, err = collection.ReplaceOne(ctx, bson.M{"_id": card.ID}, card, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCardNumberExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save card: %w", err)
+	}
+	return nil
+}
+
+// DeleteCard removes a card by _id
+func (r *MongoRepository) DeleteCard(ctx context.Context, namespace, id string) error {
+	collection := r.client.Database(namespace).Collection(CardsCollection)
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("failed to delete card: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrCardNotFound
+	}
+	return nil
+}
+
+// ConsumeCardUse increments use_count with a single conditional update, so concurrent calls never exceed max_uses
+func (r *MongoRepository) ConsumeCardUse(ctx context.Context, namespace, id string) (*models.Card, error) {
+	collection := r.client.Database(namespace).Collection(CardsCollection)
+
+	filter := bson.M{
+		"_id": id,
+		"$expr": bson.M{"$or": bson.A{
+			bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$max_uses", 0}}, 0}},
+			bson.M{"$lt": bson.A{bson.M{"$ifNull": bson.A{"$use_count", 0}}, "$max_uses"}},
+		}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var card models.Card
+	err := collection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"use_count": 1}}, opts).Decode(&card)
+	if err == nil {
+		return &card, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to update card: %w", err)
+	}
+
+	// Either the card is gone or it has no uses left
+	if _, err := r.GetCard(ctx, namespace, id); err != nil {
+		return nil, err
+	}
+	return nil, ErrCardUsageExhausted
+}
+
+// GetDeviceGroup retrieves a device group by _id from the device_groups collection
+func (r *MongoRepository) GetDeviceGroup(ctx context.Context, namespace, id string) (*models.DeviceGroup, error) {
+	collection := r.client.Database(namespace).Collection(DeviceGroupsCollection)
+
+	var group models.DeviceGroup
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&group)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrDeviceGroupNotFound
+		}
+		return nil, fmt.Errorf("failed to query device group: %w", err)
+	}
+
+	return &group, nil
+}
+
+// ListDeviceGroups queries the device_groups collection ordered by _id
+func (r *MongoRepository) ListDeviceGroups(ctx context.Context, namespace string, query DeviceGroupQuery) ([]*models.DeviceGroup, string, error) {
+	collection := r.client.Database(namespace).Collection(DeviceGroupsCollection)
+	limit := query.limit()
+
+	filter := bson.M{}
+	if query.Cursor != "" {
+		filter["_id"] = bson.M{"$gt": query.Cursor}
+	}
+	if query.Device != "" {
+		// Matches array elements
+		filter["devices"] = query.Device
+	}
+
+	// Fetch one extra group to know whether there is a next page
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit) + 1)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list device groups: %w", err)
+	}
+	defer cursor.Close(ctx) //nolint:errcheck // Best effort cursor cleanup
+
+	groups := make([]*models.DeviceGroup, 0)
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, "", fmt.Errorf("failed to decode device groups: %w", err)
+	}
+
+	if len(groups) > limit {
+		groups = groups[:limit]
+		return groups, groups[limit-1].ID, nil
+	}
+	return groups, "", nil
+}
+
+// SaveDeviceGroup replaces (or inserts) a device group by _id
+func (r *MongoRepository) SaveDeviceGroup(ctx context.Context, namespace string, group *models.DeviceGroup) error {
+	collection := r.client.Database(namespace).Collection(DeviceGroupsCollection)
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": group.ID}, group, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save device group: %w", err)
+	}
+	return nil
+}
+
+// DeleteDeviceGroup removes a device group by _id
+func (r *MongoRepository) DeleteDeviceGroup(ctx context.Context, namespace, id string) error {
+	collection := r.client.Database(namespace).Collection(DeviceGroupsCollection)
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("failed to delete device group: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrDeviceGroupNotFound
+	}
+	return nil
+}
+
+// GetRevocation retrieves a revocation by _id from the revocations collection
+func (r *MongoRepository) GetRevocation(ctx context.Context, namespace, id string) (*models.Revocation, error) {
+	collection := r.client.Database(namespace).Collection(RevocationsCollection)
+
+	var revocation models.Revocation
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&revocation)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrRevocationNotFound
+		}
+		return nil, fmt.Errorf("failed to query revocation: %w", err)
+	}
+
+	return &revocation, nil
+}
+
+// ListRevocations queries the revocations collection ordered by _id
+func (r *MongoRepository) ListRevocations(ctx context.Context, namespace string, query RevocationQuery) ([]*models.Revocation, string, error) {
+	collection := r.client.Database(namespace).Collection(RevocationsCollection)
+	limit := query.limit()
+
+	filter := bson.M{}
+	if query.Cursor != "" {
+		filter["_id"] = bson.M{"$gt": query.Cursor}
+	}
+
+	// Fetch one extra revocation to know whether there is a next page
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit) + 1)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list revocations: %w", err)
+	}
+	defer cursor.Close(ctx) //nolint:errcheck // Best effort cursor cleanup
+
+	revocations := make([]*models.Revocation, 0)
+	if err := cursor.All(ctx, &revocations); err != nil {
+		return nil, "", fmt.Errorf("failed to decode revocations: %w", err)
+	}
+
+	if len(revocations) > limit {
+		revocations = revocations[:limit]
+		return revocations, revocations[limit-1].ID, nil
+	}
+	return revocations, "", nil
+}
+
+// SaveRevocation replaces (or inserts) a revocation by _id
+func (r *MongoRepository) SaveRevocation(ctx context.Context, namespace string, revocation *models.Revocation) error {
+	collection := r.client.Database(namespace).Collection(RevocationsCollection)
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": revocation.ID}, revocation, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save revocation: %w", err)
+	}
+	return nil
+}
+
+// DeleteRevocation removes a revocation by _id
+func (r *MongoRepository) DeleteRevocation(ctx context.Context, namespace, id string) error {
+	collection := r.client.Database(namespace).Collection(RevocationsCollection)
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("failed to delete revocation: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrRevocationNotFound
+	}
+	return nil
+}
+
+// GetPresence retrieves the anti-passback state of a card in a zone from the presence collection
+func (r *MongoRepository) GetPresence(ctx context.Context, namespace, cardID, zone string) (*models.Presence, error) {
+	collection := r.client.Database(namespace).Collection(PresenceCollection)
+
+	var presence models.Presence
+	err := collection.FindOne(ctx, bson.M{"_id": models.PresenceID(cardID, zone)}).Decode(&presence)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query presence: %w", err)
+	}
+
+	return &presence, nil
+}
+
+// ListPresence queries the presence collection by card_id ordered by zone
+func (r *MongoRepository) ListPresence(ctx context.Context, namespace, cardID string) ([]*models.Presence, error) {
+	collection := r.client.Database(namespace).Collection(PresenceCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "zone", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"card_id": cardID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list presence: %w", err)
+	}
+	defer cursor.Close(ctx) //nolint:errcheck // Best effort cursor cleanup
+
+	presence := make([]*models.Presence, 0)
+	if err := cursor.All(ctx, &presence); err != nil {
+		return nil, fmt.Errorf("failed to decode presence: %w", err)
+	}
+	return presence, nil
+}
+
+// SavePresence replaces (or inserts) the anti-passback state of a card in a zone
+func (r *MongoRepository) SavePresence(ctx context.Context, namespace string, presence *models.Presence) error {
+	collection := r.client.Database(namespace).Collection(PresenceCollection)
+
+	presence.ID = models.PresenceID(presence.CardID, presence.Zone)
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": presence.ID}, presence, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save presence: %w", err)
+	}
+	return nil
+}
+
+// DeletePresence removes the anti-passback state of a card in a zone
+func (r *MongoRepository) DeletePresence(ctx context.Context, namespace, cardID, zone string) error {
+	collection := r.client.Database(namespace).Collection(PresenceCollection)
+
+	if _, err := collection.DeleteOne(ctx, bson.M{"_id": models.PresenceID(cardID, zone)}); err != nil {
+		return fmt.Errorf("failed to delete presence: %w", err)
+	}
+	return nil
+}
+
+// GetPolicy retrieves the policy overrides from the settings collection
+func (r *MongoRepository) GetPolicy(ctx context.Context, namespace string) (*models.NamespacePolicy, error) {
+	collection := r.client.Database(namespace).Collection(settingsCollection)
+
+	var policy models.NamespacePolicy
+	err := collection.FindOne(ctx, bson.M{"_id": policyKey}).Decode(&policy)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// SavePolicy stores the policy overrides in the settings collection
+func (r *MongoRepository) SavePolicy(ctx context.Context, namespace string, policy *models.NamespacePolicy) error {
+	collection := r.client.Database(namespace).Collection(settingsCollection)
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": policyKey}, policy, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save policy: %w", err)
+	}
+	return nil
+}
+
+// AppendAccessLog inserts an entry into the access_logs collection
+// Retention is enforced by a TTL index on expires_at, created on the first write to the namespace
+func (r *MongoRepository) AppendAccessLog(ctx context.Context, namespace string, entry *models.AccessLog, retention time.Duration) error {
+	collection := r.client.Database(namespace).Collection(AccessLogsCollection)
+
+	if _, done := r.accessLogIndexes.Load(namespace); !done {
+		index := mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}
+		if _, err := collection.Indexes().CreateOne(ctx, index); err != nil {
+			return fmt.Errorf("failed to create access log index: %w", err)
+		}
+		r.accessLogIndexes.Store(namespace, struct{}{})
+	}
+
+	if retention > 0 {
+		expiresAt := entry.Timestamp.Add(retention)
+		entry.ExpiresAt = &expiresAt
+	}
+	// An entry with the same ID is already stored (a retried push), which is not an error
+	if _, err := collection.InsertOne(ctx, entry); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("failed to save access log: %w", err)
+	}
+	return nil
+}
+
+// ListAccessLogs queries the access_logs collection ordered by _id (time order)
+func (r *MongoRepository) ListAccessLogs(ctx context.Context, namespace string, query AccessLogQuery) ([]*models.AccessLog, string, error) {
+	collection := r.client.Database(namespace).Collection(AccessLogsCollection)
+	limit := query.limit()
+
+	// Fetch one extra entry to know whether there is a next page
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit) + 1)
+	cursor, err := collection.Find(ctx, accessLogQueryFilter(query), opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list access logs: %w", err)
+	}
+	defer cursor.Close(ctx) //nolint:errcheck // Best effort cursor cleanup
+
+	entries := make([]*models.AccessLog, 0)
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, "", fmt.Errorf("failed to decode access logs: %w", err)
+	}
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+		return entries, entries[limit-1].ID, nil
+	}
+	return entries, "", nil
+}
+
+// AppendChange increments the change revision counter in the settings collection with a single
+// upsert and inserts change under the new revision into the changes collection
+func (r *MongoRepository) AppendChange(ctx context.Context, namespace string, change *models.Change) error {
+	r.changeMu.Lock()
+	defer r.changeMu.Unlock()
+
+	settings := r.client.Database(namespace).Collection(settingsCollection)
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter struct {
+		Value int64 `bson:"value"`
+	}
+	err := settings.FindOneAndUpdate(ctx, bson.M{"_id": changeRevisionKey}, bson.M{"$inc": bson.M{"value": int64(1)}}, opts).Decode(&counter)
+	if err != nil {
+		return fmt.Errorf("failed to allocate revision: %w", err)
+	}
+
+	change.Revision = counter.Value
+	if _, err := r.client.Database(namespace).Collection(ChangesCollection).InsertOne(ctx, change); err != nil {
+		return fmt.Errorf("failed to save change: %w", err)
+	}
+	return nil
+}
+
+// ListChanges queries the changes collection ordered by _id (revision)
+func (r *MongoRepository) ListChanges(ctx context.Context, namespace string, since int64, limit int) ([]*models.Change, error) {
+	collection := r.client.Database(namespace).Collection(ChangesCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$gt": since}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+	defer cursor.Close(ctx) //nolint:errcheck // Best effort cursor cleanup
+
+	changes := make([]*models.Change, 0)
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, fmt.Errorf("failed to decode changes: %w", err)
+	}
+	return changes, nil
+}
+
+// ChangeRevision reads the change revision counter from the settings collection
+func (r *MongoRepository) ChangeRevision(ctx context.Context, namespace string) (int64, error) {
+	collection := r.client.Database(namespace).Collection(settingsCollection)
+
+	var counter struct {
+		Value int64 `bson:"value"`
+	}
+	err := collection.FindOne(ctx, bson.M{"_id": changeRevisionKey}).Decode(&counter)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to query change revision: %w", err)
+	}
+	return counter.Value, nil
+}
+
+// GetSyncState retrieves the upstream sync progress from the settings collection
+func (r *MongoRepository) GetSyncState(ctx context.Context, namespace string) (*models.SyncState, error) {
+	collection := r.client.Database(namespace).Collection(settingsCollection)
+
+	var state models.SyncState
+	err := collection.FindOne(ctx, bson.M{"_id": syncStateKey}).Decode(&state)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query sync state: %w", err)
+	}
+	return &state, nil
+}
+
+// SaveSyncState stores the upstream sync progress in the settings collection
+func (r *MongoRepository) SaveSyncState(ctx context.Context, namespace string, state *models.SyncState) error {
+	collection := r.client.Database(namespace).Collection(settingsCollection)
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": syncStateKey}, state, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save sync state: %w", err)
+	}
+	return nil
+}
+
+// accessLogQueryFilter translates the AccessLogQuery filters and cursor to a MongoDB filter
+func accessLogQueryFilter(query AccessLogQuery) bson.M {
+	filter := bson.M{}
+	if query.Cursor != "" {
+		filter["_id"] = bson.M{"$gt": query.Cursor}
+	}
+	if query.DeviceSN != "" {
+		filter["device_sn"] = query.DeviceSN
+	}
+	if query.Card != "" {
+		filter["$or"] = bson.A{bson.M{"card_number": query.Card}, bson.M{"card_id": query.Card}}
+	}
+	if query.Outcome != "" {
+		filter["outcome"] = query.Outcome
+	}
+	if !query.From.IsZero() || !query.To.IsZero() {
+		timestamp := bson.M{}
+		if !query.From.IsZero() {
+			timestamp["$gte"] = query.From
+		}
+		if !query.To.IsZero() {
+			timestamp["$lt"] = query.To
+		}
+		filter["timestamp"] = timestamp
+	}
+	return filter
+}
+
+// cardQueryFilter translates the CardQuery filters and cursor to a MongoDB filter
+func cardQueryFilter(query CardQuery) bson.M {
+	filter := bson.M{}
+	if query.Cursor != "" {
+		filter["_id"] = bson.M{"$gt": query.Cursor}
+	}
+	if query.Device != "" {
+		// Matches array elements
+		filter["devices"] = query.Device
+	}
+	if query.Number != "" {
+		filter["number"] = bson.M{"$regex": "^" + regexp.QuoteMeta(query.Number)}
+	}
+	if !query.ValidAt.IsZero() {
+		filter["effective_at"] = bson.M{"$lte": query.ValidAt}
+		filter["invalid_at"] = bson.M{"$gt": query.ValidAt}
+	}
+	if query.Revoked != nil {
+		if *query.Revoked {
+			filter["revoked_at"] = bson.M{"$ne": nil}
+		} else {
+			// Matches missing and null fields
+			filter["revoked_at"] = nil
+		}
+	}
+	return filter
+}
+
+// ensureUniqueIndex creates a unique index on field once per namespace and collection
+// Creation fails while the collection still holds duplicates, which then have to be resolved by hand
+func (r *MongoRepository) ensureUniqueIndex(ctx context.Context, namespace string, collection *mongo.Collection, field string) error {
+	cacheKey := namespace + "/" + collection.Name()
+	if _, done := r.uniqueIndexes.Load(cacheKey); done {
+		return nil
+	}
+
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := collection.Indexes().CreateOne(ctx, index); err != nil {
+		return fmt.Errorf("failed to create unique %s index: %w", field, err)
+	}
+	r.uniqueIndexes.Store(cacheKey, struct{}{})
+	return nil
+}
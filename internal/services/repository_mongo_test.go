@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCardQueryFilter(t *testing.T) {
+	validAt := time.Date(2026, 8, 25, 15, 0, 0, 0, time.UTC)
+	revoked := true
+	notRevoked := false
+
+	tests := []struct {
+		name     string
+		query    CardQuery
+		expected bson.M
+	}{
+		{"empty", CardQuery{}, bson.M{}},
+		{"cursor", CardQuery{Cursor: "card-1"}, bson.M{"_id": bson.M{"$gt": "card-1"}}},
+		{"device", CardQuery{Device: "SN-001"}, bson.M{"devices": "SN-001"}},
+		{"number prefix is escaped", CardQuery{Number: "A.B"}, bson.M{"number": bson.M{"$regex": `^A\.B`}}},
+		{"valid at", CardQuery{ValidAt: validAt}, bson.M{
+			"effective_at": bson.M{"$lte": validAt},
+			"invalid_at":   bson.M{"$gt": validAt},
+		}},
+		{"revoked", CardQuery{Revoked: &revoked}, bson.M{"revoked_at": bson.M{"$ne": nil}}},
+		{"not revoked", CardQuery{Revoked: &notRevoked}, bson.M{"revoked_at": nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, cardQueryFilter(tt.query))
+		})
+	}
+}
+
+func TestAccessLogQueryFilter(t *testing.T) {
+	from := time.Date(2026, 8, 25, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		query    AccessLogQuery
+		expected bson.M
+	}{
+		{"empty", AccessLogQuery{}, bson.M{}},
+		{"device and outcome", AccessLogQuery{DeviceSN: "SN-001", Outcome: "denied"}, bson.M{"device_sn": "SN-001", "outcome": "denied"}},
+		{"card number or id", AccessLogQuery{Card: "GUEST-1"}, bson.M{
+			"$or": bson.A{bson.M{"card_number": "GUEST-1"}, bson.M{"card_id": "GUEST-1"}},
+		}},
+		{"time range", AccessLogQuery{From: from, To: to}, bson.M{"timestamp": bson.M{"$gte": from, "$lt": to}}},
+		{"open range", AccessLogQuery{From: from, Cursor: "x"}, bson.M{"timestamp": bson.M{"$gte": from}, "_id": bson.M{"$gt": "x"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, accessLogQueryFilter(tt.query))
+		})
+	}
+}
+
+func TestMongoRepository_InterfaceImplementation(t *testing.T) {
+	var _ Repository = (*MongoRepository)(nil)
+	var _ Repository = (*KVRepository)(nil)
+}
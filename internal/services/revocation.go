@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"commander/internal/models"
+)
+
+// Revocation errors.
+var (
+	ErrRevocationNotFound = errors.New("revocation not found")
+	ErrInvalidRevocation  = errors.New("invalid revocation")
+	ErrRevocationExists   = errors.New("card already revoked")
+)
+
+// MaxRevocationListLimit is the largest page size accepted by ListRevocations
+const MaxRevocationListLimit = 1000
+
+// ListRevocations returns a page of the revocation list of namespace
+func (s *CardService) ListRevocations(ctx context.Context, namespace string, query RevocationQuery) ([]*models.Revocation, string, error) {
+	if query.Limit > MaxRevocationListLimit {
+		query.Limit = MaxRevocationListLimit
+	}
+	return s.repo.ListRevocations(ctx, namespace, query)
+}
+
+// Revoke adds a card number or card ID to the revocation list of namespace
+// Exactly one of CardNumber and CardID must be set; the card does not need to exist, so numbers
+// can be blocked before they are synced. Returns ErrRevocationExists if the card is already revoked
+func (s *CardService) Revoke(ctx context.Context, namespace string, revocation *models.Revocation) (*models.Revocation, error) {
+	revocation.CardNumber = strings.TrimSpace(revocation.CardNumber)
+	revocation.CardID = strings.TrimSpace(revocation.CardID)
+	switch {
+	case revocation.CardNumber != "" && revocation.CardID != "":
+		return nil, fmt.Errorf("%w: card_number and card_id are mutually exclusive", ErrInvalidRevocation)
+	case revocation.CardNumber != "":
+		revocation.ID = models.RevocationID(models.RevocationByNumber, revocation.CardNumber)
+	case revocation.CardID != "":
+		revocation.ID = models.RevocationID(models.RevocationByCardID, revocation.CardID)
+	default:
+		return nil, fmt.Errorf("%w: card_number or card_id is required", ErrInvalidRevocation)
+	}
+
+	_, err := s.repo.GetRevocation(ctx, namespace, revocation.ID)
+	if err == nil {
+		return nil, ErrRevocationExists
+	}
+	if !errors.Is(err, ErrRevocationNotFound) {
+		return nil, err
+	}
+
+	revocation.RevokedAt = s.clock.Now().UTC()
+	if err := s.repo.SaveRevocation(ctx, namespace, revocation); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Revocation] Card revoked: namespace=%s, revocation_id=%s, reason=%q", namespace, revocation.ID, revocation.Reason)
+	return revocation, nil
+}
+
+// Unrevoke removes an entry from the revocation list; the card verifies again if it is otherwise valid
+func (s *CardService) Unrevoke(ctx context.Context, namespace, id string) error {
+	if err := s.repo.DeleteRevocation(ctx, namespace, id); err != nil {
+		return err
+	}
+
+	log.Printf("[Revocation] Card unrevoked: namespace=%s, revocation_id=%s", namespace, id)
+	return nil
+}
+
+// checkRevoked returns ErrCardRevoked if the card number or ID (kind) is on the revocation list
+// The list is read on every verification and never cached, so revocations apply at once on every instance
+// Lookup errors are returned as is, so verification fails closed
+func (s *CardService) checkRevoked(ctx context.Context, namespace, kind, value string) error {
+	revocation, err := s.repo.GetRevocation(ctx, namespace, models.RevocationID(kind, value))
+	if errors.Is(err, ErrRevocationNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("[CardVerification] Revocation lookup failed: namespace=%s, %s=%s, error=%v", namespace, kind, value, err)
+		return err
+	}
+
+	log.Printf("[CardVerification] Card revoked: namespace=%s, revocation_id=%s, revoked_at=%s, reason=%q",
+		namespace, revocation.ID, revocation.RevokedAt.Format(time.RFC3339), revocation.Reason)
+	return ErrCardRevoked
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCardService_Revoke(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	revocation, err := service.Revoke(ctx, "hotel_a", &models.Revocation{CardNumber: " GUEST-1 ", Reason: "lost"})
+	require.NoError(t, err)
+	assert.Equal(t, "number:GUEST-1", revocation.ID)
+	assert.False(t, revocation.RevokedAt.IsZero())
+
+	tests := []struct {
+		name       string
+		revocation *models.Revocation
+		expected   error
+	}{
+		{"already revoked", &models.Revocation{CardNumber: "GUEST-1"}, ErrRevocationExists},
+		{"missing card", &models.Revocation{Reason: "lost"}, ErrInvalidRevocation},
+		{"number and id", &models.Revocation{CardNumber: "GUEST-2", CardID: "card-2"}, ErrInvalidRevocation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Revoke(ctx, "hotel_a", tt.revocation)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+
+	_, err = service.Revoke(ctx, "hotel_a", &models.Revocation{CardID: "card-2"})
+	require.NoError(t, err)
+	revocations, _, err := service.ListRevocations(ctx, "hotel_a", RevocationQuery{})
+	require.NoError(t, err)
+	assert.Len(t, revocations, 2)
+
+	require.NoError(t, service.Unrevoke(ctx, "hotel_a", "number:GUEST-1"))
+	assert.ErrorIs(t, service.Unrevoke(ctx, "hotel_a", "number:GUEST-1"), ErrRevocationNotFound)
+}
+
+func TestCardServiceVerifyCard_Revoked(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+
+	card, err := service.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-001"))
+	require.NoError(t, err)
+	require.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard))
+
+	tests := []struct {
+		name       string
+		revocation *models.Revocation
+	}{
+		{"by number", &models.Revocation{CardNumber: "GUEST-1"}},
+		{"by card id", &models.Revocation{CardID: card.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revocation, err := service.Revoke(ctx, "hotel_a", tt.revocation)
+			require.NoError(t, err)
+			assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard), ErrCardRevoked)
+
+			// Unrevoking restores access since the validity window was not touched
+			require.NoError(t, service.Unrevoke(ctx, "hotel_a", revocation.ID))
+			assert.NoError(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard))
+		})
+	}
+
+	// Cards revoked through RevokeCard are reported as revoked rather than expired
+	_, err = service.RevokeCard(ctx, "hotel_a", card.ID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, service.VerifyCard(ctx, "hotel_a", "SN-001", "GUEST-1", models.ProtocolStandard), ErrCardRevoked)
+}
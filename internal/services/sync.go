@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"commander/internal/models"
+)
+
+// Sync errors.
+var (
+	ErrInvalidAccessLog = errors.New("invalid access log entry")
+	ErrInvalidChange    = errors.New("invalid change")
+)
+
+// Change feed paging
+const (
+	DefaultChangeListLimit = 100
+	MaxChangeListLimit     = 1000
+
+	// MaxAccessLogImport is the largest number of entries accepted by one ImportAccessLogs call
+	MaxAccessLogImport = 1000
+
+	// SyncGapGrace is how long ListChanges waits for a missing revision before skipping it
+	// Revisions are allocated before the change is stored, so a concurrent writer may store a later
+	// revision first; a revision still missing after the grace period belongs to a failed write
+	SyncGapGrace = 10 * time.Second
+)
+
+// ChangeBatch is a page of the change feed returned by ListChanges
+type ChangeBatch struct {
+	Changes []*models.Change
+	// Checkpoint is the revision to pass as since on the next call
+	Checkpoint int64
+	// More is true if further changes can be read right away
+	More bool
+	// Snapshot is true if Changes is the current state of every record rather than a delta;
+	// records missing from a snapshot have been deleted
+	Snapshot bool
+}
+
+// ListChanges returns the changes of namespace after revision since
+// since <= 0, or a revision the feed never reached (the feed was reset), returns a snapshot of every
+// record instead. The checkpoint stops before revisions that are still being written, so a consumer
+// resuming from it never skips a change
+func (s *CardService) ListChanges(ctx context.Context, namespace string, since int64, limit int) (*ChangeBatch, error) {
+	if limit <= 0 {
+		limit = DefaultChangeListLimit
+	}
+	if limit > MaxChangeListLimit {
+		limit = MaxChangeListLimit
+	}
+
+	latest, err := s.repo.ChangeRevision(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if since <= 0 || since > latest {
+		// Read the revision first: changes made while the snapshot is taken are pulled again afterwards
+		changes, err := s.snapshot(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &ChangeBatch{Changes: changes, Checkpoint: latest, Snapshot: true}, nil
+	}
+
+	changes, err := s.repo.ListChanges(ctx, namespace, since, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	batch := &ChangeBatch{Changes: make([]*models.Change, 0, len(changes)), Checkpoint: since}
+	for i, change := range changes {
+		if i == limit {
+			batch.More = true
+			break
+		}
+		if change.Revision != batch.Checkpoint+1 && now.Sub(change.Timestamp) < SyncGapGrace {
+			// An earlier revision is still being written; the next call resumes before it
+			break
+		}
+		batch.Changes = append(batch.Changes, change)
+		batch.Checkpoint = change.Revision
+	}
+	return batch, nil
+}
+
+// snapshot returns the current devices, cards, device groups, revocations and policy of namespace as changes
+func (s *CardService) snapshot(ctx context.Context, namespace string) ([]*models.Change, error) {
+	now := s.clock.Now().UTC()
+	changes := make([]*models.Change, 0)
+	add := func(kind, id string, record interface{}) error {
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, &models.Change{Kind: kind, ID: id, Record: value, Timestamp: now})
+		return nil
+	}
+
+	devices, err := listAll(func(cursor string) ([]*models.Device, string, error) {
+		return s.repo.ListDevices(ctx, namespace, DeviceQuery{Cursor: cursor, Limit: MaxChangeListLimit})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		if err := add(models.ChangeDevice, device.ID, device); err != nil {
+			return nil, err
+		}
+	}
+
+	cards, err := listAll(func(cursor string) ([]*models.Card, string, error) {
+		return s.repo.ListCards(ctx, namespace, CardQuery{Cursor: cursor, Limit: MaxChangeListLimit})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, card := range cards {
+		if err := add(models.ChangeCard, card.ID, card); err != nil {
+			return nil, err
+		}
+	}
+
+	groups, err := listAll(func(cursor string) ([]*models.DeviceGroup, string, error) {
+		return s.repo.ListDeviceGroups(ctx, namespace, DeviceGroupQuery{Cursor: cursor, Limit: MaxChangeListLimit})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		if err := add(models.ChangeDeviceGroup, group.ID, group); err != nil {
+			return nil, err
+		}
+	}
+
+	revocations, err := listAll(func(cursor string) ([]*models.Revocation, string, error) {
+		return s.repo.ListRevocations(ctx, namespace, RevocationQuery{Cursor: cursor, Limit: MaxChangeListLimit})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, revocation := range revocations {
+		if err := add(models.ChangeRevocation, revocation.ID, revocation); err != nil {
+			return nil, err
+		}
+	}
+
+	policy, err := s.repo.GetPolicy(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		if err := add(models.ChangePolicy, "", policy); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// ImportAccessLogs stores access log entries recorded by the edge instance edgeID
+// Entries keep their IDs, so importing the same entries again (a retried push) stores them once
+func (s *CardService) ImportAccessLogs(ctx context.Context, namespace, edgeID string, entries []*models.AccessLog) (int, error) {
+	if edgeID == "" {
+		return 0, fmt.Errorf("%w: edge_id is required", ErrInvalidAccessLog)
+	}
+	if len(entries) > MaxAccessLogImport {
+		return 0, fmt.Errorf("%w: at most %d entries per request", ErrInvalidAccessLog, MaxAccessLogImport)
+	}
+	for i, entry := range entries {
+		if entry == nil || entry.ID == "" || entry.Timestamp.IsZero() ||
+			(entry.Outcome != models.AccessGranted && entry.Outcome != models.AccessDenied) {
+			return 0, fmt.Errorf("%w: entry %d needs an id, a timestamp and a granted or denied outcome", ErrInvalidAccessLog, i)
+		}
+	}
+
+	for _, entry := range entries {
+		entry.Edge = edgeID
+		entry.ExpiresAt = nil
+		if err := s.repo.AppendAccessLog(ctx, namespace, entry, s.accessLogRetention); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// ApplyChanges applies upstream changes to the local records of namespace, in order
+// Upstream wins every conflict: a local record holding the SN or number of an incoming record is
+// removed. Use counts are the exception and keep the higher of both values, so uses counted locally
+// are not handed back by a stale upstream copy. Local uses are never sent upstream, so use limits
+// apply per edge
+func (s *CardService) ApplyChanges(ctx context.Context, namespace string, changes []*models.Change) error {
+	for _, change := range changes {
+		if err := s.applyChange(ctx, namespace, change); err != nil {
+			return fmt.Errorf("failed to apply %s %q (revision %d): %w", change.Kind, change.ID, change.Revision, err)
+		}
+	}
+	return nil
+}
+
+// ApplySnapshot replaces the local records of namespace by a snapshot returned by ListChanges
+// Local records missing from the snapshot are removed; the others are applied like ApplyChanges
+func (s *CardService) ApplySnapshot(ctx context.Context, namespace string, changes []*models.Change) error {
+	keep := make(map[string]map[string]bool)
+	for _, change := range changes {
+		if keep[change.Kind] == nil {
+			keep[change.Kind] = make(map[string]bool)
+		}
+		keep[change.Kind][change.ID] = true
+	}
+
+	// Revocations are applied before anything is removed or added, so revoked cards never pass in between
+	for _, change := range changes {
+		if change.Kind == models.ChangeRevocation {
+			if err := s.applyChange(ctx, namespace, change); err != nil {
+				return err
+			}
+		}
+	}
+
+	devices, err := listAll(func(cursor string) ([]*models.Device, string, error) {
+		return s.repo.ListDevices(ctx, namespace, DeviceQuery{Cursor: cursor, Limit: MaxChangeListLimit})
+	})
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		if !keep[models.ChangeDevice][device.ID] {
+			if err := s.repo.DeleteDevice(ctx, namespace, device.ID); err != nil && !errors.Is(err, ErrDeviceNotFound) {
+				return err
+			}
+		}
+	}
+
+	cards, err := listAll(func(cursor string) ([]*models.Card, string, error) {
+		return s.repo.ListCards(ctx, namespace, CardQuery{Cursor: cursor, Limit: MaxChangeListLimit})
+	})
+	if err != nil {
+		return err
+	}
+	for _, card := range cards {
+		if !keep[models.ChangeCard][card.ID] {
+			if err := s.repo.DeleteCard(ctx, namespace, card.ID); err != nil && !errors.Is(err, ErrCardNotFound) {
+				return err
+			}
+		}
+	}
+
+	groups, err := listAll(func(cursor string) ([]*models.DeviceGroup, string, error) {
+		return s.repo.ListDeviceGroups(ctx, namespace, DeviceGroupQuery{Cursor: cursor, Limit: MaxChangeListLimit})
+	})
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if !keep[models.ChangeDeviceGroup][group.ID] {
+			if err := s.repo.DeleteDeviceGroup(ctx, namespace, group.ID); err != nil && !errors.Is(err, ErrDeviceGroupNotFound) {
+				return err
+			}
+			s.groups.invalidate(namespace, group.ID)
+		}
+	}
+
+	revocations, err := listAll(func(cursor string) ([]*models.Revocation, string, error) {
+		return s.repo.ListRevocations(ctx, namespace, RevocationQuery{Cursor: cursor, Limit: MaxChangeListLimit})
+	})
+	if err != nil {
+		return err
+	}
+	for _, revocation := range revocations {
+		if !keep[models.ChangeRevocation][revocation.ID] {
+			if err := s.repo.DeleteRevocation(ctx, namespace, revocation.ID); err != nil && !errors.Is(err, ErrRevocationNotFound) {
+				return err
+			}
+		}
+	}
+
+	if !keep[models.ChangePolicy][""] {
+		if err := s.repo.SavePolicy(ctx, namespace, &models.NamespacePolicy{}); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range changes {
+		if change.Kind == models.ChangeRevocation {
+			continue
+		}
+		if err := s.applyChange(ctx, namespace, change); err != nil {
+			return fmt.Errorf("failed to apply %s %q: %w", change.Kind, change.ID, err)
+		}
+	}
+	return nil
+}
+
+// applyChange writes or deletes the record carried by change
+func (s *CardService) applyChange(ctx context.Context, namespace string, change *models.Change) error {
+	switch change.Kind {
+	case models.ChangeDevice:
+		if change.Deleted {
+			return ignoreNotFound(s.repo.DeleteDevice(ctx, namespace, change.ID), ErrDeviceNotFound)
+		}
+		var device models.Device
+		if err := decodeChange(change, &device); err != nil {
+			return err
+		}
+		return s.saveReplicatedDevice(ctx, namespace, &device)
+
+	case models.ChangeCard:
+		if change.Deleted {
+			return ignoreNotFound(s.repo.DeleteCard(ctx, namespace, change.ID), ErrCardNotFound)
+		}
+		var card models.Card
+		if err := decodeChange(change, &card); err != nil {
+			return err
+		}
+		return s.saveReplicatedCard(ctx, namespace, &card)
+
+	case models.ChangeDeviceGroup:
+		s.groups.invalidate(namespace, change.ID)
+		if change.Deleted {
+			return ignoreNotFound(s.repo.DeleteDeviceGroup(ctx, namespace, change.ID), ErrDeviceGroupNotFound)
+		}
+		var group models.DeviceGroup
+		if err := decodeChange(change, &group); err != nil {
+			return err
+		}
+		return s.repo.SaveDeviceGroup(ctx, namespace, &group)
+
+	case models.ChangeRevocation:
+		if change.Deleted {
+			return ignoreNotFound(s.repo.DeleteRevocation(ctx, namespace, change.ID), ErrRevocationNotFound)
+		}
+		var revocation models.Revocation
+		if err := decodeChange(change, &revocation); err != nil {
+			return err
+		}
+		return s.repo.SaveRevocation(ctx, namespace, &revocation)
+
+	case models.ChangePolicy:
+		policy := &models.NamespacePolicy{}
+		if !change.Deleted {
+			if err := decodeChange(change, policy); err != nil {
+				return err
+			}
+		}
+		return s.repo.SavePolicy(ctx, namespace, policy)
+
+	default:
+		// Written by a newer upstream; skipping keeps older edges syncing
+		log.Printf("[Sync] Skipping unknown change: namespace=%s, kind=%s, id=%s, revision=%d",
+			namespace, change.Kind, change.ID, change.Revision)
+		return nil
+	}
+}
+
+// saveReplicatedDevice stores an upstream device, removing the local device that still holds its SN
+func (s *CardService) saveReplicatedDevice(ctx context.Context, namespace string, device *models.Device) error {
+	err := s.repo.SaveDevice(ctx, namespace, device)
+	if !errors.Is(err, ErrDeviceSNExists) {
+		return err
+	}
+	owner, err := s.repo.GetDeviceBySN(ctx, namespace, device.SN)
+	if err != nil {
+		return err
+	}
+	log.Printf("[Sync] Replacing local device: namespace=%s, sn=%s, local_id=%s, upstream_id=%s",
+		namespace, device.SN, owner.ID, device.ID)
+	if err := s.repo.DeleteDevice(ctx, namespace, owner.ID); err != nil {
+		return err
+	}
+	return s.repo.SaveDevice(ctx, namespace, device)
+}
+
+// saveReplicatedCard stores an upstream card, keeping the higher use count and removing the local
+// card that still holds its number
+func (s *CardService) saveReplicatedCard(ctx context.Context, namespace string, card *models.Card) error {
+	local, err := s.repo.GetCard(ctx, namespace, card.ID)
+	if err != nil && !errors.Is(err, ErrCardNotFound) {
+		return err
+	}
+	if local != nil && local.UseCount > card.UseCount {
+		card.UseCount = local.UseCount
+	}
+
+	err = s.repo.SaveCard(ctx, namespace, card)
+	if !errors.Is(err, ErrCardNumberExists) {
+		return err
+	}
+	owner, err := s.repo.GetCardByNumber(ctx, namespace, card.Number)
+	if err != nil {
+		return err
+	}
+	log.Printf("[Sync] Replacing local card: namespace=%s, card_number=%s, local_id=%s, upstream_id=%s",
+		namespace, card.Number, owner.ID, card.ID)
+	if err := s.repo.DeleteCard(ctx, namespace, owner.ID); err != nil {
+		return err
+	}
+	return s.repo.SaveCard(ctx, namespace, card)
+}
+
+// SyncState returns the upstream sync progress of namespace (zero if it never synced)
+func (s *CardService) SyncState(ctx context.Context, namespace string) (*models.SyncState, error) {
+	state, err := s.repo.GetSyncState(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = &models.SyncState{}
+	}
+	return state, nil
+}
+
+// SaveSyncState stores the upstream sync progress of namespace
+func (s *CardService) SaveSyncState(ctx context.Context, namespace string, state *models.SyncState) error {
+	return s.repo.SaveSyncState(ctx, namespace, state)
+}
+
+// decodeChange decodes the record of change into v
+func decodeChange(change *models.Change, v interface{}) error {
+	if len(change.Record) == 0 {
+		return fmt.Errorf("%w: record is missing", ErrInvalidChange)
+	}
+	if err := json.Unmarshal(change.Record, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidChange, err)
+	}
+	return nil
+}
+
+// ignoreNotFound returns nil if err is notFound; deleting a record twice is not an error when replicating
+func ignoreNotFound(err, notFound error) error {
+	if errors.Is(err, notFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"commander/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeFeed_RecordsWrites(t *testing.T) {
+	repo, _ := newTestKVRepository(t, true)
+	service := NewCardService(NewChangeFeed(repo), Policy{})
+	ctx := context.Background()
+
+	_, err := service.RegisterDevice(ctx, "hotel_a", &models.Device{ID: "device-1", DeviceID: "lobby", SN: "SN-001"})
+	require.NoError(t, err)
+	card, err := service.CreateCard(ctx, "hotel_a", testCard("GUEST-1", "SN-001"))
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteCard(ctx, "hotel_a", card.ID))
+
+	changes, err := repo.ListChanges(ctx, "hotel_a", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	for i, change := range changes {
+		assert.Equal(t, int64(i+1), change.Revision)
+	}
+	assert.Equal(t, models.ChangeDevice, changes[0].Kind)
+	assert.Equal(t, card.ID, changes[1].ID)
+	assert.True(t, changes[2].Deleted)
+	assert.Empty(t, changes[2].Record)
+
+	// Reads after a revision only return later changes
+	changes, err = repo.ListChanges(ctx, "hotel_a", 2, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, int64(3), changes[0].Revision)
+}
+
+func TestCardService_ListChanges(t *testing.T) {
+	repo, store := newTestKVRepository(t, false)
+	service := NewCardService(repo, Policy{})
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	service.SetClock(fixedClock{now})
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, repo.AppendChange(ctx, "hotel_a", &models.Change{Kind: models.ChangeCard, ID: "card-1", Record: []byte(`{}`), Timestamp: now}))
+	}
+	// Revision 3 has been allocated but not stored yet
+	require.NoError(t, store.Delete(ctx, "hotel_a", ChangesCollection, changeKey(3)))
+
+	batch, err := service.ListChanges(ctx, "hotel_a", 1, 0)
+	require.NoError(t, err)
+	assert.False(t, batch.Snapshot)
+	assert.Len(t, batch.Changes, 1)
+	assert.Equal(t, int64(2), batch.Checkpoint)
+	assert.False(t, batch.More)
+
+	// Past the grace period the missing revision counts as a failed write
+	service.SetClock(fixedClock{now.Add(SyncGapGrace)})
+	batch, err = service.ListChanges(ctx, "hotel_a", 2, 0)
+	require.NoError(t, err)
+	assert.Len(t, batch.Changes, 1)
+	assert.Equal(t, int64(4), batch.Checkpoint)
+
+	batch, err = service.ListChanges(ctx, "hotel_a", 1, 1)
+	require.NoError(t, err)
+	assert.True(t, batch.More)
+
+	// Starting over, or from a revision the feed never reached, returns a snapshot
+	for _, since := range []int64{0, 99} {
+		batch, err = service.ListChanges(ctx, "hotel_a", since, 0)
+		require.NoError(t, err)
+		assert.True(t, batch.Snapshot)
+		assert.Equal(t, int64(4), batch.Checkpoint)
+	}
+}
+
+func TestCardService_ImportAccessLogs(t *testing.T) {
+	service := newTestCardService(t)
+	ctx := context.Background()
+	entry := func(id string) *models.AccessLog {
+		return &models.AccessLog{ID: id, DeviceSN: "SN-001", CardNumber: "GUEST-1", Outcome: models.AccessGranted, Timestamp: time.Now().UTC()}
+	}
+
+	accepted, err := service.ImportAccessLogs(ctx, "hotel_a", "edge-1", []*models.AccessLog{entry("a"), entry("b")})
+	require.NoError(t, err)
+	assert.Equal(t, 2, accepted)
+
+	// Retried pushes are stored once
+	_, err = service.ImportAccessLogs(ctx, "hotel_a", "edge-1", []*models.AccessLog{entry("b")})
+	require.NoError(t, err)
+	logs, _, err := service.ListAccessLogs(ctx, "hotel_a", AccessLogQuery{})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "edge-1", logs[0].Edge)
+
+	_, err = service.ImportAccessLogs(ctx, "hotel_a", "", []*models.AccessLog{entry("c")})
+	assert.ErrorIs(t, err, ErrInvalidAccessLog)
+	_, err = service.ImportAccessLogs(ctx, "hotel_a", "edge-1", []*models.AccessLog{{ID: "d"}})
+	assert.ErrorIs(t, err, ErrInvalidAccessLog)
+}